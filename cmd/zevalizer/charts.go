@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/report"
+)
+
+// whToKW converts the energy of one interval into its average power
+func whToKW(wh float64, interval *analyzer.IntervalData) float64 {
+	hours := interval.End.Sub(interval.Start).Hours()
+	if hours <= 0 {
+		return 0
+	}
+	return wh / hours / 1000
+}
+
+// writeCharts renders per-interval production, consumption and grid exchange
+// charts as SVG files into dir. The consumption adds up the metered
+// consumers that keep accepts.
+func writeCharts(dir string, ea *analyzer.EnergyAnalyzer, keep func(id string) bool) error {
+	intervals := ea.Intervals()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating chart directory: %v", err)
+	}
+
+	times := make([]time.Time, len(intervals))
+	production := make([]float64, len(intervals))
+	battery := make([]float64, len(intervals))
+	consumption := make([]float64, len(intervals))
+	gridImport := make([]float64, len(intervals))
+	gridExport := make([]float64, len(intervals))
+	for i, interval := range intervals {
+		times[i] = interval.Start
+		production[i] = whToKW(interval.InverterGeneratedPower, interval)
+		battery[i] = whToKW(interval.BatteryDischarge-interval.BatteryCharge, interval)
+		var usage float64
+		// the metered consumers only, the shared usage is a residual
+		for id, wh := range interval.ConsumerUsage {
+			if id != analyzer.SharedConsumerID && keep(id) {
+				usage += wh
+			}
+		}
+		consumption[i] = whToKW(usage, interval)
+		gridImport[i] = whToKW(interval.GridImport, interval)
+		gridExport[i] = whToKW(interval.GridExport, interval)
+	}
+
+	charts := map[string]*report.LineChart{
+		"production.svg": {
+			Title:  "Production",
+			YLabel: "kW",
+			Times:  times,
+			Series: []report.Series{
+				{Name: "Production (NET)", Color: "#f2b134", Values: production},
+				{Name: "Battery (+discharge/-charge)", Color: "#4caf50", Values: battery},
+			},
+		},
+		"consumption.svg": {
+			Title:  "Consumption",
+			YLabel: "kW",
+			Times:  times,
+			Series: []report.Series{
+				{Name: "Consumption", Color: "#5b7fa6", Values: consumption},
+			},
+		},
+		"grid.svg": {
+			Title:  "Grid Exchange",
+			YLabel: "kW",
+			Times:  times,
+			Series: []report.Series{
+				{Name: "Import", Color: "#c0392b", Values: gridImport},
+				{Name: "Export", Color: "#27ae60", Values: gridExport},
+			},
+		},
+	}
+
+	for name, chart := range charts {
+		path := filepath.Join(dir, name)
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := chart.WriteSVG(file); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/config"
+	"zevalizer/internal/i18n"
+)
+
+// analyzeTotal runs an analysis and returns the statistics across both tariffs
+func analyzeTotal(ctx context.Context, client analyzer.DataFetcher, cfg *config.Config, smId string, from, to time.Time) (*analyzer.EnergyStats, error) {
+	statsLT, statsHT, err := analyzer.NewEnergyAnalyzer(client, cfg).Analyze(ctx, smId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return analyzer.MergeStats(statsLT, statsHT), nil
+}
+
+// compareEnergy analyzes the period and the baseline period and prints the
+// differences between them
+func compareEnergy(ctx context.Context, client analyzer.DataFetcher, cfg *config.Config, smId string, from, to, baseFrom, baseTo time.Time, opts reportOptions) error {
+	current, err := analyzeTotal(ctx, client, cfg, smId, from, to)
+	if err != nil {
+		return fmt.Errorf("analyzing energy data: %w", err)
+	}
+	baseline, err := analyzeTotal(ctx, client, cfg, smId, baseFrom, baseTo)
+	if err != nil {
+		return fmt.Errorf("analyzing baseline energy data: %w", err)
+	}
+	if opts.anonymize {
+		anonymizeStats(current)
+		anonymizeStats(baseline)
+	}
+
+	comparison := analyzer.Compare(current, baseline)
+	if opts.format == formatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(comparison); err != nil {
+			return fmt.Errorf("encoding json: %v", err)
+		}
+		return nil
+	}
+	printComparison(comparison)
+	return nil
+}
+
+// printComparison prints the system totals and consumer usage of both
+// periods with their absolute and relative change
+func printComparison(c *analyzer.Comparison) {
+	fmt.Printf("\n"+i18n.T("Comparison of %s to %s with %s to %s")+"\n\n",
+		c.Current.Start.Format("2006-01-02"), c.Current.End.Format("2006-01-02"),
+		c.Baseline.Start.Format("2006-01-02"), c.Baseline.End.Format("2006-01-02"))
+
+	header := func() {
+		fmt.Printf("%-20s %13s %13s %13s %8s\n",
+			i18n.T("Name"), i18n.T("Current"), i18n.T("Baseline"), i18n.T("Change"), "%")
+		fmt.Printf("%s\n", strings.Repeat("-", 71))
+	}
+
+	printHeading("System Overview")
+	header()
+	printDeltaRow(i18n.T("Production"), c.Production)
+	printDeltaRow(i18n.T("Grid Import"), c.GridImport)
+	printDeltaRow(i18n.T("Grid Export"), c.GridExport)
+	printDeltaRow(i18n.T("Battery Charge"), c.BatteryCharge)
+	printDeltaRow(i18n.T("Battery Discharge"), c.BatteryDischarge)
+	fmt.Printf("\n")
+
+	printHeading("Consumer Details")
+	header()
+	for _, consumer := range c.Consumers {
+		printDeltaRow(displayName(&analyzer.ConsumerStats{Sensor: consumer.Sensor}), consumer.Delta)
+	}
+	fmt.Printf("%s\n", strings.Repeat("-", 71))
+	printDeltaRow(i18n.T("Total"), c.Consumption)
+	fmt.Printf("\n")
+}
+
+// printDeltaRow prints one comparison row in kWh
+func printDeltaRow(name string, d analyzer.Delta) {
+	percent := "-"
+	if p, ok := d.Percent(); ok {
+		percent = fmt.Sprintf("%+.1f%%", p)
+	}
+	fmt.Printf("%-20s %9.1f kWh %9.1f kWh %+9.1f kWh %8s\n",
+		name, d.Current/1000, d.Baseline/1000, d.Change()/1000, percent)
+}
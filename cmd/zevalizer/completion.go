@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/i18n"
+)
+
+// subcommands are the non-flag commands understood by zevalizer
+var subcommands = []string{"version", "completion", "sensors", "live", "report"}
+
+// sensorCommands are the commands of the sensors subcommand
+var sensorCommands = []string{"health"}
+
+// reportCommands are the commands of the report subcommand
+var reportCommands = []string{"annual"}
+
+// completionShells are the shells a completion script can be generated for
+var completionShells = []string{"bash", "zsh"}
+
+// flagChoices returns the fixed set of values accepted by a flag, if any
+func flagChoices(name string) []string {
+	switch name {
+	case "format":
+		return []string{formatText, formatJSON}
+	case "sort":
+		return []string{analyzer.SortConfig, analyzer.SortName, analyzer.SortTotal, analyzer.SortGrid}
+	case "lang":
+		return i18n.Languages()
+	case "aggregate":
+		return []string{analyzer.AggregateDay, analyzer.AggregateMonth}
+	}
+	return nil
+}
+
+// fileFlags take a file path, dirFlags a directory
+var (
+	fileFlags = map[string]bool{"csv": true, "audit-csv": true, "xlsx": true, "template": true, "sankey": true, "heatmap": true, "verify-audit": true, "audit-pubkey": true, "bill-csv": true}
+	dirFlags  = map[string]bool{"audit": true, "charts": true, "sdat": true}
+)
+
+// isBoolFlag reports whether a flag does not take a value
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// sortedFlags returns all registered flags ordered by name
+func sortedFlags() []*flag.Flag {
+	var flags []*flag.Flag
+	flag.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// writeCompletion writes a completion script for the given shell, generated
+// from the registered flags so it never falls behind the CLI.
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		writeZshCompletion(w)
+	default:
+		return fmt.Errorf("unsupported shell %q, use one of: %s", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer) {
+	var names []string
+	fmt.Fprintln(w, "# bash completion for zevalizer")
+	fmt.Fprintln(w, "# source <(zevalizer completion bash)")
+	fmt.Fprintln(w, "_zevalizer() {")
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `    case "$prev" in`)
+	for _, f := range sortedFlags() {
+		names = append(names, "-"+f.Name)
+		switch {
+		case flagChoices(f.Name) != nil:
+			fmt.Fprintf(w, "        -%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n",
+				f.Name, strings.Join(flagChoices(f.Name), " "))
+		case fileFlags[f.Name]:
+			fmt.Fprintf(w, "        -%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", f.Name)
+		case dirFlags[f.Name]:
+			fmt.Fprintf(w, "        -%s) COMPREPLY=($(compgen -d -- \"$cur\")); return ;;\n", f.Name)
+		case !isBoolFlag(f):
+			fmt.Fprintf(w, "        -%s) return ;;\n", f.Name)
+		}
+	}
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, `    if [[ $COMP_CWORD -eq 2 && ${COMP_WORDS[1]} == completion ]]; then`)
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %q -- \"$cur\")); return\n", strings.Join(completionShells, " "))
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, `    if [[ $COMP_CWORD -eq 2 && ${COMP_WORDS[1]} == sensors ]]; then`)
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %q -- \"$cur\")); return\n", strings.Join(sensorCommands, " "))
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, `    if [[ $COMP_CWORD -eq 2 && ${COMP_WORDS[1]} == report ]]; then`)
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %q -- \"$cur\")); return\n", strings.Join(reportCommands, " "))
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, `    if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then`)
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %q -- \"$cur\")); return\n", strings.Join(subcommands, " "))
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintf(w, "    COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _zevalizer zevalizer")
+}
+
+// zshEscaper protects the characters _arguments treats specially in descriptions
+var zshEscaper = strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`)
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintln(w, "#compdef zevalizer")
+	fmt.Fprintln(w, "# zsh completion for zevalizer, save as _zevalizer in your $fpath")
+	fmt.Fprintln(w, "_zevalizer() {")
+	fmt.Fprintln(w, "    _arguments \\")
+	for _, f := range sortedFlags() {
+		spec := "-" + f.Name + "[" + zshEscaper.Replace(f.Usage) + "]"
+		switch {
+		case isBoolFlag(f):
+		case flagChoices(f.Name) != nil:
+			spec += ":" + f.Name + ":(" + strings.Join(flagChoices(f.Name), " ") + ")"
+		case fileFlags[f.Name]:
+			spec += ":file:_files"
+		case dirFlags[f.Name]:
+			spec += ":directory:_files -/"
+		default:
+			spec += ":" + f.Name + ":"
+		}
+		fmt.Fprintf(w, "        '%s' \\\n", spec)
+	}
+	fmt.Fprintf(w, "        '1:command:(%s)' \\\n", strings.Join(subcommands, " "))
+	fmt.Fprintf(w, "        '2:argument:(%s %s %s)'\n", strings.Join(completionShells, " "), strings.Join(sensorCommands, " "), strings.Join(reportCommands, " "))
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, `_zevalizer "$@"`)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/anonymize"
+	"zevalizer/internal/billing"
+	"zevalizer/internal/config"
+)
+
+// writeIntervalCSV writes one row per analysis interval with all system
+// values and the usage of every consumer that keep accepts (in Wh) to the
+// given file
+func writeIntervalCSV(path string, cfg *config.Config, ea *analyzer.EnergyAnalyzer, keep func(id string) bool, anonymized bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating csv file: %v", err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+
+	consumerIDs := slices.DeleteFunc(append(ea.ConsumerIDs(), analyzer.SharedConsumerID), func(id string) bool { return !keep(id) })
+
+	header := []string{"start", "end", "tariff", "grid_import_wh", "grid_export_wh",
+		"production_wh", "battery_charge_wh", "battery_discharge_wh"}
+	for _, id := range consumerIDs {
+		header = append(header, consumerColumnName(ea, id, anonymized))
+	}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("writing csv: %v", err)
+	}
+
+	for _, interval := range ea.Intervals() {
+		tariff := "high"
+		if ea.IsLowTariff(interval.Start) {
+			tariff = "low"
+		}
+		row := []string{
+			interval.Start.Format(time.RFC3339),
+			interval.End.Format(time.RFC3339),
+			tariff,
+			formatWh(interval.GridImport),
+			formatWh(interval.GridExport),
+			formatWh(interval.InverterGeneratedPower),
+			formatWh(interval.BatteryCharge),
+			formatWh(interval.BatteryDischarge),
+		}
+		for _, id := range consumerIDs {
+			row = append(row, formatWh(interval.ConsumerUsage[id]))
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("writing csv: %v", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("writing csv: %v", err)
+	}
+	return file.Close()
+}
+
+// writeAttributionCSV writes the attribution of the usage of every consumer
+// that keep accepts to the sources, one row per interval and consumer, so
+// each bill can be reconstructed after the fact. Energy is in Wh, shares in
+// percent.
+func writeAttributionCSV(path string, ea *analyzer.EnergyAnalyzer, lowTariff, highTariff *analyzer.EnergyStats, keep func(id string) bool, anonymized bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating csv file: %v", err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	header := []string{"start", "end", "tariff", "consumer_id", "consumer",
+		"grid_import_wh", "grid_export_wh", "production_wh", "battery_discharge_wh", "battery_grid_share_pct",
+		"metered_wh", "shared_added_wh", "usage_wh", "solar_wh", "battery_wh", "grid_wh",
+		"solar_pct", "battery_pct", "grid_pct", "shared_allocation_pct"}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("writing csv: %v", err)
+	}
+
+	for _, row := range ea.Attribution(lowTariff, highTariff) {
+		if !keep(row.ConsumerID) {
+			continue
+		}
+		tariff := "high"
+		if row.LowTariff {
+			tariff = "low"
+		}
+		id := row.ConsumerID
+		if anonymized && id != analyzer.SharedConsumerID {
+			id = anonymize.ID(id)
+		}
+		usage := row.Sources.Total()
+		share := func(part float64) string {
+			if usage <= 0 {
+				return ""
+			}
+			return formatPercent(part / usage * 100)
+		}
+		record := []string{
+			row.Start.Format(time.RFC3339),
+			row.End.Format(time.RFC3339),
+			tariff,
+			id,
+			consumerColumnName(ea, row.ConsumerID, anonymized),
+			formatWh(row.GridImport),
+			formatWh(row.GridExport),
+			formatWh(row.Production),
+			formatWh(row.BatteryDischarge),
+			formatPercent(row.BatteryGridShare * 100),
+			formatWh(row.Metered),
+			formatWh(row.SharedAdded),
+			formatWh(usage),
+			formatWh(row.Sources.Solar),
+			formatWh(row.Sources.Battery),
+			formatWh(row.Sources.Grid),
+			share(row.Sources.Solar),
+			share(row.Sources.Battery),
+			share(row.Sources.Grid),
+			formatPercent(row.SharedPart * 100),
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("writing csv: %v", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("writing csv: %v", err)
+	}
+	return file.Close()
+}
+
+// billEnergyItems are the items of the bill export with a kWh field
+var billEnergyItems = []string{billing.ItemSolar, billing.ItemBattery, billing.ItemGridHigh, billing.ItemGridLow}
+
+// billExportFields returns the fields of the bill export in their default
+// order: the invoice, the period, the consumer, the energy and amount of every item
+// and the sums
+func billExportFields() []string {
+	fields := []string{"invoice", "corrects", "period_from", "period_to", "consumer_id", "consumer", "tenant", "from", "to", "currency", "kwh"}
+	for _, item := range billEnergyItems {
+		fields = append(fields, billItemField(item, "kwh"))
+	}
+	for _, item := range billing.Items {
+		fields = append(fields, billItemField(item, "amount"))
+	}
+	return append(fields, "net", "vat_percent", "vat", "rounding", "total")
+}
+
+// billItemField returns the field name of an item, e.g. grid_high_kwh
+func billItemField(item, suffix string) string {
+	return strings.ReplaceAll(item, "-", "_") + "_" + suffix
+}
+
+// validateBillColumns checks that the columns of the bill export refer to
+// known fields
+func validateBillColumns(export config.BillExportConfig) error {
+	known := make(map[string]bool)
+	for _, field := range billExportFields() {
+		known[field] = true
+	}
+	for _, column := range export.Columns {
+		if column.Value == "" && !known[column.Field] {
+			return fmt.Errorf("%w: billExport: column %q has unknown field %q, known fields: %s",
+				config.ErrInvalid, column.Header, column.Field, strings.Join(billExportFields(), ", "))
+		}
+	}
+	return nil
+}
+
+// writeBillCSV writes one row per consumer bill in the layout of the
+// billExport config, for the import into property management or
+// accounting software. Without configured columns every field gets a
+// column under its own name.
+func writeBillCSV(path string, bill *billing.Bill, export config.BillExportConfig) error {
+	columns := export.Columns
+	if len(columns) == 0 {
+		for _, field := range billExportFields() {
+			columns = append(columns, config.BillColumnConfig{Header: field, Field: field})
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating csv file: %v", err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	if export.Delimiter != "" {
+		w.Comma = []rune(export.Delimiter)[0]
+	}
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Header
+	}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("writing csv: %v", err)
+	}
+
+	number := func(v float64, decimals int) string {
+		text := strconv.FormatFloat(v, 'f', decimals, 64)
+		if export.Decimal != "" {
+			text = strings.Replace(text, ".", export.Decimal, 1)
+		}
+		return text
+	}
+	// the periods end on the first moment after them, the export shows
+	// the last day
+	date := func(t time.Time) string { return t.Format(export.DateLayout()) }
+	lastDay := func(t time.Time) string { return date(t.Add(-time.Millisecond)) }
+
+	for _, consumer := range bill.Consumers {
+		values := map[string]string{
+			"invoice":     consumer.Invoice,
+			"corrects":    consumer.Corrects,
+			"period_from": date(bill.From),
+			"period_to":   lastDay(bill.To),
+			"consumer_id": consumer.ID,
+			"consumer":    consumer.Name,
+			"tenant":      consumer.Tenant,
+			"from":        date(consumer.From),
+			"to":          lastDay(consumer.To),
+			"currency":    bill.Currency,
+			"kwh":         number(consumer.Energy, 3),
+			"net":         number(consumer.Net, 2),
+			"vat_percent": number(bill.VATPercent, 2),
+			"vat":         number(consumer.VAT, 2),
+			"rounding":    number(consumer.Rounding, 2),
+			"total":       number(consumer.Total, 2),
+		}
+		energy := make(map[string]float64)
+		amounts := make(map[string]float64)
+		for _, line := range consumer.Lines {
+			energy[line.Item] += line.Energy
+			amounts[line.Item] += line.Amount
+		}
+		for _, item := range billEnergyItems {
+			values[billItemField(item, "kwh")] = number(energy[item], 3)
+		}
+		for _, item := range billing.Items {
+			// formatting to cents drops the residue of adding up the amounts
+			values[billItemField(item, "amount")] = number(amounts[item], 2)
+		}
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = column.Value
+			if column.Value == "" {
+				record[i] = values[column.Field]
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return fmt.Errorf("writing csv: %v", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("writing csv: %v", err)
+	}
+	return file.Close()
+}
+
+// consumerColumnName returns the CSV column header for a consumer
+func consumerColumnName(ea *analyzer.EnergyAnalyzer, id string, anonymized bool) string {
+	if id == analyzer.SharedConsumerID {
+		return "Shared Usage"
+	}
+	if anonymized {
+		return anonymize.Name(id)
+	}
+	if sensor := ea.Sensor(id); sensor != nil && sensor.Tag.Name != "" {
+		return sensor.Tag.Name
+	}
+	return id
+}
+
+func formatWh(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+func formatPercent(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
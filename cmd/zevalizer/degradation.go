@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/anonymize"
+	"zevalizer/internal/config"
+	"zevalizer/internal/i18n"
+)
+
+// pvDegradation analyzes [from, to] once and reports the seasonally
+// normalized yield of every inverter per year with its trend
+func pvDegradation(ctx context.Context, client analyzer.DataFetcher, cfg *config.Config, smId string, from, to time.Time, opts reportOptions) error {
+	energyAnalyzer := analyzer.NewEnergyAnalyzer(client, cfg)
+	if _, _, err := energyAnalyzer.Analyze(ctx, smId, from, to); err != nil {
+		return fmt.Errorf("analyzing energy data: %w", err)
+	}
+	inverters := energyAnalyzer.Degradation()
+	if opts.anonymize {
+		for i, inverter := range inverters {
+			inverters[i].Name = anonymize.Name(inverter.SensorID)
+			inverters[i].SensorID = anonymize.ID(inverter.SensorID)
+		}
+	}
+
+	if opts.format == formatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(inverters); err != nil {
+			return fmt.Errorf("encoding json: %v", err)
+		}
+		return nil
+	}
+	printDegradation(inverters)
+	return nil
+}
+
+// printDegradation prints one table per inverter with a row per year
+func printDegradation(inverters []analyzer.InverterDegradation) {
+	fmt.Printf("\n%s\n\n", i18n.T("PV Degradation"))
+	for _, inverter := range inverters {
+		printHeading(inverter.Name)
+		fmt.Printf("%-6s %14s %14s %7s %8s\n", i18n.T("Year"), i18n.T("Production"), i18n.T("Specific Yield"), i18n.T("Months"), i18n.T("Index"))
+		for _, year := range inverter.Years {
+			specific := "-"
+			if year.SpecificYield > 0 {
+				specific = fmt.Sprintf("%.0f kWh/kWp", year.SpecificYield)
+			}
+			index := "-"
+			if year.Months > 0 {
+				index = fmt.Sprintf("%.1f%%", year.Index)
+			}
+			fmt.Printf("%-6d %10.1f kWh %14s %7d %8s\n", year.Year, year.Production, specific, year.Months, index)
+		}
+		fmt.Printf("%s: %+.2f%% %s\n\n", i18n.T("Trend"), inverter.Trend, i18n.T("per year"))
+	}
+}
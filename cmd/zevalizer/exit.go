@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/api"
+	"zevalizer/internal/config"
+)
+
+// Exit codes, so cron jobs and scripts can react to the kind of failure
+const (
+	exitOK          = 0
+	exitFailure     = 1   // any other error
+	exitUsage       = 2   // invalid command line (same as the flag package)
+	exitConfig      = 3   // config file missing, unreadable or invalid
+	exitAPI         = 4   // Solar Manager API unreachable or returned an error
+	exitDataQuality = 5   // data missing or failing validation
+	exitInterrupted = 130 // interrupted by Ctrl-C, as shells report SIGINT
+)
+
+// exitCode classifies an error into one of the exit codes
+func exitCode(err error) int {
+	var apiErr *api.Error
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.As(err, &apiErr):
+		return exitAPI
+	case errors.Is(err, config.ErrInvalid):
+		return exitConfig
+	case errors.Is(err, analyzer.ErrNoData), errors.Is(err, analyzer.ErrImbalance),
+		errors.Is(err, analyzer.ErrUnhealthySensors), errors.Is(err, analyzer.ErrNoPowerData):
+		return exitDataQuality
+	}
+	return exitFailure
+}
+
+// fatalf logs the message and exits with the given code
+func fatalf(code int, format string, args ...interface{}) {
+	log.Printf(format, args...)
+	os.Exit(code)
+}
+
+// fatalErr logs the message and exits with the code derived from err
+func fatalErr(err error, format string, args ...interface{}) {
+	fatalf(exitCode(err), "%s: %v", fmt.Sprintf(format, args...), err)
+}
+
+// infof prints an informational message unless quiet mode is active
+func infof(cfg *config.Config, format string, args ...interface{}) {
+	if !cfg.Quiet {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
+
+// warnf prints a warning that needs attention, also in quiet mode
+func warnf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/billing"
+)
+
+// listFlag collects the values of a flag that may be given several times
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// consumerFilter selects the consumers shown in the report by ID or name
+// (case-insensitive). With include patterns only matching consumers are
+// shown, exclude patterns hide consumers. "shared" matches the shared usage.
+type consumerFilter struct {
+	include []string
+	exclude []string
+}
+
+func (f consumerFilter) active() bool {
+	return len(f.include) > 0 || len(f.exclude) > 0
+}
+
+// keep reports whether the consumer with the given ID and name is shown
+func (f consumerFilter) keep(id, name string) bool {
+	if len(f.include) > 0 && !matchesAny(f.include, id, name) {
+		return false
+	}
+	return !matchesAny(f.exclude, id, name)
+}
+
+// keepStats is keep for consumer statistics
+func (f consumerFilter) keepStats(consumer *analyzer.ConsumerStats) bool {
+	return f.keep(statsID(consumer), consumer.Name())
+}
+
+// keepBill is keep for consumer bills
+func (f consumerFilter) keepBill(consumer *billing.Consumer) bool {
+	id := consumer.ID
+	if id == "" {
+		id = analyzer.SharedConsumerID
+	}
+	return f.keep(id, consumer.Name)
+}
+
+// keepIDs returns keep for the consumer IDs of the interval data of ea,
+// SharedConsumerID for the shared usage
+func (f consumerFilter) keepIDs(ea *analyzer.EnergyAnalyzer) func(id string) bool {
+	return func(id string) bool {
+		name := id
+		if sensor := ea.Sensor(id); sensor != nil && sensor.Tag.Name != "" {
+			name = sensor.Tag.Name
+		}
+		return f.keep(id, name)
+	}
+}
+
+// validate fails for patterns that match none of the consumers, which
+// usually is a typo
+func (f consumerFilter) validate(stats *analyzer.EnergyStats) error {
+	for _, pattern := range append(append([]string(nil), f.include...), f.exclude...) {
+		found := false
+		for i := range stats.Consumers {
+			if matchesAny([]string{pattern}, statsID(&stats.Consumers[i]), stats.Consumers[i].Name()) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("no consumer matches %q", pattern)
+		}
+	}
+	return nil
+}
+
+// single returns the ID of the one consumer selected by the include
+// pattern, failing if a name is ambiguous
+func (f consumerFilter) single(stats *analyzer.EnergyStats) (string, error) {
+	var ids []string
+	for i := range stats.Consumers {
+		if f.keepStats(&stats.Consumers[i]) {
+			ids = append(ids, statsID(&stats.Consumers[i]))
+		}
+	}
+	if len(ids) != 1 {
+		return "", fmt.Errorf("%q matches %d consumers, use the sensor ID", strings.Join(f.include, ","), len(ids))
+	}
+	return ids[0], nil
+}
+
+// statsID returns the sensor ID of a consumer, SharedConsumerID for the
+// shared usage
+func statsID(consumer *analyzer.ConsumerStats) string {
+	if consumer.Sensor != nil && consumer.Sensor.ID != "" {
+		return consumer.Sensor.ID
+	}
+	return analyzer.SharedConsumerID
+}
+
+func matchesAny(patterns []string, id, name string) bool {
+	for _, pattern := range patterns {
+		if strings.EqualFold(pattern, id) || strings.EqualFold(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
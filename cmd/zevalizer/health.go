@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/anonymize"
+	"zevalizer/internal/config"
+	"zevalizer/internal/i18n"
+)
+
+// sensorHealth analyzes [from, to] and reports the health of every
+// configured sensor. It returns analyzer.ErrUnhealthySensors after the
+// report when a sensor is stale or dead, so monitoring can alert on the
+// exit code.
+func sensorHealth(ctx context.Context, client analyzer.DataFetcher, cfg *config.Config, smId string, from, to time.Time, opts reportOptions) error {
+	energyAnalyzer := analyzer.NewEnergyAnalyzer(client, cfg)
+	// Without any readings the completeness is still measured, which is
+	// exactly what the report is about
+	if _, _, err := energyAnalyzer.Analyze(ctx, smId, from, to); err != nil && !errors.Is(err, analyzer.ErrNoData) {
+		return fmt.Errorf("analyzing energy data: %w", err)
+	}
+	health := energyAnalyzer.SensorHealth(time.Now())
+	if opts.anonymize {
+		for i, sensor := range health {
+			health[i].Name = anonymize.Name(sensor.SensorID)
+			health[i].SensorID = anonymize.ID(sensor.SensorID)
+		}
+	}
+
+	if opts.format == formatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(health); err != nil {
+			return fmt.Errorf("encoding json: %v", err)
+		}
+	} else {
+		printSensorHealth(health)
+	}
+
+	for _, sensor := range health {
+		if sensor.Status == analyzer.HealthStale || sensor.Status == analyzer.HealthDead {
+			return analyzer.ErrUnhealthySensors
+		}
+	}
+	return nil
+}
+
+// printSensorHealth prints one row per sensor
+func printSensorHealth(health []analyzer.SensorHealth) {
+	printHeading("Sensor Health")
+	fmt.Printf("%-22s %-11s %8s %-16s %8s %5s %9s  %s\n", i18n.T("Sensor"), i18n.T("Role"), i18n.T("Activity"),
+		i18n.T("Last Data"), i18n.T("Complete"), i18n.T("Gaps"), i18n.T("Longest"), i18n.T("Status"))
+	for _, sensor := range health {
+		lastData := "-"
+		if sensor.LastData != nil {
+			lastData = sensor.LastData.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-22s %-11s %8d %-16s %7.1f%% %5d %7d m  %s\n", sensor.Name, i18n.T(sensor.Role), sensor.DeviceActivity,
+			lastData, sensor.Completeness, sensor.Gaps, sensor.LongestGap, i18n.T(sensor.Status))
+	}
+	fmt.Printf("\n")
+}
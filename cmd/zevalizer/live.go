@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"zevalizer/internal/anonymize"
+	"zevalizer/internal/api"
+	"zevalizer/internal/config"
+	"zevalizer/internal/i18n"
+)
+
+// livePower is the current power of the gateway and the configured
+// consumers in W
+type livePower struct {
+	Time        time.Time      `json:"time"`
+	Production  float64        `json:"productionW"`
+	Consumption float64        `json:"consumptionW"`
+	Battery     float64        `json:"batteryW"` // positive while charging
+	BatterySOC  float64        `json:"batterySoc,omitempty"`
+	Consumers   []liveConsumer `json:"consumers"`
+}
+
+type liveConsumer struct {
+	SensorID string  `json:"sensorId"`
+	Name     string  `json:"name"`
+	Power    float64 `json:"powerW"`
+}
+
+// liveData prints the current power of the gateway and of every configured
+// consumer the gateway reports
+func liveData(ctx context.Context, client *api.Client, cfg *config.Config, smId string, opts reportOptions) error {
+	stream, err := client.GetLiveData(ctx, smId)
+	if err != nil {
+		return err
+	}
+	sensors, err := client.GetSensors(ctx, smId)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string, len(sensors))
+	for _, sensor := range sensors {
+		names[sensor.ID] = sensor.Tag.Name
+	}
+	devices := make(map[string]float64, len(stream.Devices))
+	for _, device := range stream.Devices {
+		devices[device.ID] = device.Power
+	}
+
+	live := livePower{Time: stream.TimeStamp, Production: stream.PvGeneration, Consumption: stream.PowerConsumption,
+		Battery: stream.BatteryPower, BatterySOC: stream.BatterySOC, Consumers: []liveConsumer{}}
+	if live.Time.IsZero() {
+		live.Time = time.Now()
+	}
+	for _, id := range cfg.ZEV.ConsumerIDs {
+		power, ok := devices[id]
+		if !ok {
+			continue
+		}
+		consumer := liveConsumer{SensorID: id, Name: names[id], Power: power}
+		if consumer.Name == "" {
+			consumer.Name = id
+		}
+		if opts.anonymize {
+			consumer.Name = anonymize.Name(id)
+			consumer.SensorID = anonymize.ID(id)
+		}
+		live.Consumers = append(live.Consumers, consumer)
+	}
+
+	if opts.format == formatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(live); err != nil {
+			return fmt.Errorf("encoding json: %v", err)
+		}
+		return nil
+	}
+	printHeading("Live Power")
+	fmt.Printf("%s\n", live.Time.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("%-22s %8.0f W\n", i18n.T("Production")+":", live.Production)
+	fmt.Printf("%-22s %8.0f W\n", i18n.T("Consumption")+":", live.Consumption)
+	if len(cfg.ZEV.BatterySystemIDs) > 0 {
+		fmt.Printf("%-22s %8.0f W  (%.0f%%)\n", i18n.T("Battery")+":", live.Battery, live.BatterySOC)
+	}
+	for _, consumer := range live.Consumers {
+		fmt.Printf("%-22s %8.0f W\n", consumer.Name+":", consumer.Power)
+	}
+	fmt.Printf("\n")
+	return nil
+}
@@ -1,23 +1,141 @@
 package main
 
 import (
+	"context"
+	"crypto/ed25519"
 	"flag"
 	"fmt"
-	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"zevalizer/internal/analyzer"
+	"zevalizer/internal/anonymize"
 	"zevalizer/internal/api"
+	"zevalizer/internal/audit"
+	"zevalizer/internal/billing"
 	"zevalizer/internal/cache"
 	"zevalizer/internal/config"
+	"zevalizer/internal/i18n"
+	"zevalizer/internal/plugin"
+	"zevalizer/internal/report"
 	"zevalizer/internal/setup"
+	"zevalizer/internal/version"
+	"zevalizer/internal/weather"
 )
 
+// reportOptions controls how analysis results are presented
+type reportOptions struct {
+	anonymize bool
+	auditDir  string             // write an audit bundle of the run into this directory
+	auditKey  ed25519.PrivateKey // signs the audit bundle
+	plugin    string             // name of a configured report plugin to render the result
+	format    string             // output format, formatText or formatJSON
+	csvPath   string             // write per-interval data to this CSV file
+	auditCSV  string             // write the per-interval attribution to this CSV file
+	billCSV   string             // write the consumer bills to this CSV file
+	book      bool               // number the consumer bills with invoices from the ledger
+	xlsxPath  string             // write an Excel workbook to this file
+	template  string             // render the result with this text/template file
+	sankey    string             // write an SVG Sankey diagram of the energy flows to this file
+	heatmap   string             // write an hour-by-weekday heatmap to this .csv or .html file
+	chartDir  string             // write SVG line charts of the interval data into this directory
+	sdatDir   string             // write SDAT load profiles of the metering points into this directory
+	sortKey   string             // consumer order, see analyzer.SortConsumers
+	minKWh    float64            // collapse consumers below this total into "Other"
+	aggregate string             // add a per-day or per-month series, see analyzer.Series
+	stream    int                // analyze in pieces of this many days to bound memory use
+	validate  bool               // check the per interval energy balance and fail on violations
+	peaks     int                // report this many highest grid import intervals and the monthly maxima
+	peakRes   int                // take the peaks from power readings every this many seconds
+	diagnose  int                // list this many intervals with unaccounted energy and their meter readings
+	profile   bool               // add the typical daily load profile
+	standby   bool               // add the standby load of every consumer
+	soc       bool               // add the battery state of charge summary and timeline
+	ev        bool               // add the charging sessions of every EV charger
+
+	batteries []analyzer.BatteryScenario // simulate these batteries in place of the installed ones
+	shaving   []float64                  // recommend peak shaving to these levels in kW
+	forecast  bool                       // compare the daily production with the PV forecast
+	anomalies bool                       // report suspicious patterns in the meter data
+
+	consumers consumerFilter // consumers shown in the report
+	detail    bool           // add the daily breakdown of the single -consumer
+}
+
+// anonymizeSetupHint replaces all suggested IDs and names with pseudonyms
+func anonymizeSetupHint(zevConfig *config.ZEVConfig) {
+	zevConfig.GridMeterID = anonymize.ConfigEntry(zevConfig.GridMeterID)
+	for _, ids := range [][]string{zevConfig.GridMeterIDs, zevConfig.ProductionIDs, zevConfig.BatterySystemIDs, zevConfig.ConsumerIDs} {
+		for i := range ids {
+			ids[i] = anonymize.ConfigEntry(ids[i])
+		}
+	}
+}
+
+// anonymizeStats replaces the consumer sensors with anonymized copies
+func anonymizeStats(stats *analyzer.EnergyStats) {
+	for i := range stats.Consumers {
+		stats.Consumers[i].Sensor = anonymize.Sensor(stats.Consumers[i].Sensor)
+	}
+}
+
+// anonymizeGroups replaces the names of the configured groups, the final
+// ungrouped subtotal keeps its name
+func anonymizeGroups(groups []analyzer.ConsumerStats) {
+	for i := range groups {
+		if name := groups[i].Sensor.Tag.Name; name != analyzer.UngroupedName || i < len(groups)-1 {
+			groups[i].Sensor.Tag.Name = anonymize.Group(name)
+		}
+	}
+}
+
+// bookBill numbers the bills with the invoices of the ledger, issuing new
+// ones as needed, and saves the ledger
+func bookBill(cfg *config.Config, bill *billing.Bill) error {
+	ledger, err := billing.LoadLedger(cfg.Ledger.File)
+	if err != nil {
+		return err
+	}
+	issued := ledger.Book(bill, cfg.Ledger.Prefix, time.Now())
+	if issued == 0 {
+		infof(cfg, "All bills are booked in %s already.", cfg.Ledger.File)
+		return nil
+	}
+	if err := ledger.Save(cfg.Ledger.File); err != nil {
+		return err
+	}
+	infof(cfg, "Booked %d invoices in %s.", issued, cfg.Ledger.File)
+	return nil
+}
+
+func anonymizeBill(bill *billing.Bill) {
+	for i, consumer := range bill.Consumers {
+		if consumer.ID != "" {
+			bill.Consumers[i].Name = anonymize.Name(consumer.ID)
+			bill.Consumers[i].ID = anonymize.ID(consumer.ID)
+		}
+		if consumer.Tenant != "" {
+			bill.Consumers[i].Tenant = anonymize.Tenant(consumer.Tenant)
+		}
+		for j, line := range consumer.Lines {
+			if line.ID != "" {
+				consumer.Lines[j].Name = anonymize.Name(line.ID)
+				consumer.Lines[j].ID = anonymize.ID(line.ID)
+			}
+		}
+	}
+}
+
 func printSetupHint(zevConfig *config.ZEVConfig) {
 	fmt.Printf("\nZEV Setup Hint:\n")
 	fmt.Printf("Grid Meter: %s\n", zevConfig.GridMeterID)
+	if len(zevConfig.GridMeterIDs) > 0 {
+		fmt.Printf("Further Grid Meters: %v\n", zevConfig.GridMeterIDs)
+	}
 	fmt.Printf("Production Meters: %v\n", zevConfig.ProductionIDs)
 	fmt.Printf("Battery System: %v\n", zevConfig.BatterySystemIDs)
 	fmt.Printf("Consumer Meters: %v\n", zevConfig.ConsumerIDs)
@@ -40,6 +158,12 @@ func printSetupHint(zevConfig *config.ZEVConfig) {
 	fmt.Printf("\nSuggested config.yaml ZEV section:\n")
 	fmt.Printf("zev:\n")
 	fmt.Printf("  gridMeterId: %q\n", zevConfig.GridMeterID)
+	if len(zevConfig.GridMeterIDs) > 0 {
+		fmt.Printf("  gridMeterIds:\n")
+		for _, id := range zevConfig.GridMeterIDs {
+			fmt.Printf("    - %q\n", id)
+		}
+	}
 	fmt.Printf("  productionIds:\n")
 	for _, id := range zevConfig.ProductionIDs {
 		fmt.Printf("    - %q\n", id)
@@ -54,120 +178,804 @@ func printSetupHint(zevConfig *config.ZEVConfig) {
 	}
 }
 
-// Update the analyzeEnergy function in main.go
-
-func analyzeEnergy(client analyzer.DataFetcher, cfg *config.Config, smId string, from, to time.Time) error {
+func analyzeEnergy(ctx context.Context, client analyzer.DataFetcher, cfg *config.Config, smId string, from, to time.Time, opts reportOptions) error {
+	// the audit bundle keeps a copy of every reading the analysis fetches
+	var recorder *audit.Recorder
+	if opts.auditDir != "" {
+		recorder = audit.NewRecorder(client)
+		client = recorder
+	}
 	energyAnalyzer := analyzer.NewEnergyAnalyzer(client, cfg)
-	statsLT, statsHT, err := energyAnalyzer.Analyze(smId, from, to)
+	analyzedAt := time.Now()
+	energyAnalyzer.SetNow(analyzedAt)
+	var statsLT, statsHT *analyzer.EnergyStats
+	var err error
+	if opts.stream > 0 {
+		statsLT, statsHT, err = energyAnalyzer.AnalyzeStream(ctx, smId, from, to, opts.stream)
+	} else {
+		statsLT, statsHT, err = energyAnalyzer.Analyze(ctx, smId, from, to)
+	}
 	if err != nil {
-		return fmt.Errorf("analyzing energy data: %v", err)
-	}
-	// Print summary
-	fmt.Printf("\nEnergy Analysis for period: %s to %s\n\n",
-		from.Format("2006-01-02 15:04"),
-		to.Format("2006-01-02 15:04"))
-
-	fmt.Printf("High Tariff Energy %d:00 - %d:00\n", cfg.LowTariff.EndHour, cfg.LowTariff.StartHour)
-	fmt.Printf("------------------------------------------------\n")
-	printEnergyStats(statsHT)
-	fmt.Printf("Low Tariff Energy %d:00 - %d:00\n", cfg.LowTariff.StartHour, cfg.LowTariff.EndHour)
-	fmt.Printf("------------------------------------------------\n")
-	printEnergyStats(statsLT)
-	return nil
+		return fmt.Errorf("analyzing energy data: %w", err)
+	}
+	// the bundle records the results as analyzed, before any filtering
+	var bundle *audit.Bundle
+	if recorder != nil {
+		if bundle, err = newAuditBundle(cfg, smId, from, to, analyzedAt, opts.stream, recorder, statsLT, statsHT); err != nil {
+			return fmt.Errorf("writing audit bundle: %v", err)
+		}
+	}
+	// the exports below are filtered as they are written, check the
+	// patterns first
+	if err := opts.consumers.validate(analyzer.MergeStats(statsLT, statsHT)); err != nil {
+		return err
+	}
+	var series []*analyzer.EnergyStats
+	var detail *analyzer.ConsumerDetail
+	if opts.aggregate != "" {
+		if series, err = energyAnalyzer.Series(opts.aggregate, from, to); err != nil {
+			return fmt.Errorf("aggregating energy data: %w", err)
+		}
+		if cfg.Weather.Enabled() {
+			irradiation, err := weather.Fetch(cfg.Weather, from, to)
+			if err != nil {
+				infof(cfg, "Trend without weather normalization: %v", err)
+			} else {
+				analyzer.NormalizeByWeather(series, irradiation)
+			}
+		}
+	}
+	// the savings value every part of the period at its own prices, the
+	// parts go through the same filters as the stats
+	var priceParts []analyzer.PricePart
+	var partStats []*analyzer.EnergyStats
+	if cfg.Prices.Enabled() {
+		if priceParts, err = energyAnalyzer.PriceParts(from, to); err != nil {
+			return fmt.Errorf("splitting at price changes: %w", err)
+		}
+		for _, part := range priceParts {
+			partStats = append(partStats, part.LowTariff, part.HighTariff)
+		}
+	}
+	if opts.csvPath != "" {
+		if err := writeIntervalCSV(opts.csvPath, cfg, energyAnalyzer, opts.consumers.keepIDs(energyAnalyzer), opts.anonymize); err != nil {
+			return fmt.Errorf("writing interval csv: %v", err)
+		}
+	}
+	if opts.auditCSV != "" {
+		if err := writeAttributionCSV(opts.auditCSV, energyAnalyzer, statsLT, statsHT, opts.consumers.keepIDs(energyAnalyzer), opts.anonymize); err != nil {
+			return fmt.Errorf("writing attribution csv: %v", err)
+		}
+	}
+	// Bill all consumers before filtering them, the shared costs and common
+	// areas are split among all of them
+	var bill *billing.Bill
+	if cfg.Prices.Billing() {
+		if bill, err = energyAnalyzer.ConsumerBills(statsLT, statsHT); err != nil {
+			return fmt.Errorf("billing consumers: %w", err)
+		}
+		for _, violation := range billing.Violations(cfg.Prices) {
+			warnf("VSE guideline: %s, the bills are capped", violation)
+		}
+	}
+	if opts.consumers.active() {
+		merged := analyzer.MergeStats(statsLT, statsHT)
+		if opts.detail {
+			detailID, err := opts.consumers.single(merged)
+			if err != nil {
+				return err
+			}
+			detail = energyAnalyzer.ConsumerDetail(detailID)
+		}
+		for _, stats := range append(append([]*analyzer.EnergyStats{statsLT, statsHT}, series...), partStats...) {
+			stats.FilterConsumers(opts.consumers.keepStats)
+		}
+		if bill != nil {
+			bill.Keep(opts.consumers.keepBill)
+		}
+	}
+	// Group and book before anonymizing and collapsing, both change the
+	// consumer IDs; only the bills shown are booked
+	groups := analyzer.MergeStats(statsLT, statsHT).Groups(cfg.ZEV.Groups)
+	if bill != nil && opts.book {
+		if err := bookBill(cfg, bill); err != nil {
+			return fmt.Errorf("booking bills: %w", err)
+		}
+	}
+	// the bundle records the bills with their invoice numbers, so it is
+	// written after booking
+	if bundle != nil {
+		if err := writeAuditBundle(cfg, bundle, opts, bill); err != nil {
+			return fmt.Errorf("writing audit bundle: %v", err)
+		}
+	}
+	all := append(append([]*analyzer.EnergyStats{statsLT, statsHT}, series...), partStats...)
+	if opts.anonymize {
+		for _, stats := range all {
+			anonymizeStats(stats)
+		}
+		if bill != nil {
+			anonymizeBill(bill)
+		}
+		anonymizeGroups(groups)
+	}
+	if opts.billCSV != "" {
+		if err := writeBillCSV(opts.billCSV, bill, cfg.BillExport); err != nil {
+			return fmt.Errorf("writing bill csv: %v", err)
+		}
+	}
+	if opts.minKWh > 0 {
+		small := analyzer.SmallConsumers(opts.minKWh*1000, statsLT, statsHT)
+		for _, stats := range all {
+			stats.CollapseConsumers(small)
+		}
+	}
+	for _, stats := range all {
+		if err := stats.SortConsumers(opts.sortKey); err != nil {
+			return err
+		}
+	}
+	var savings *analyzer.SavingsReport
+	if cfg.Prices.Enabled() {
+		savings = analyzer.PricedSavings(statsLT, statsHT, priceParts, cfg.Prices)
+	}
+	var emissions *analyzer.EmissionsReport
+	if cfg.Emissions.Enabled() {
+		emissions = analyzer.Emissions(analyzer.MergeStats(statsLT, statsHT), cfg.Emissions)
+	}
+
+	completeness := energyAnalyzer.Completeness()
+	if opts.anonymize {
+		for i := range completeness {
+			completeness[i].Name = anonymize.Name(completeness[i].SensorID)
+			completeness[i].SensorID = anonymize.ID(completeness[i].SensorID)
+		}
+	}
+
+	resets := energyAnalyzer.CounterResets()
+	for i, reset := range resets {
+		if opts.anonymize {
+			resets[i].SensorID = anonymize.ID(reset.SensorID)
+		}
+		infof(cfg, "Counter reset on %s %s at %s: %.1f -> %.1f Wh, continuing from the new value",
+			resets[i].SensorID, reset.Counter, reset.Time.Format("2006-01-02 15:04"), reset.Previous, reset.Current)
+	}
+
+	inverted := energyAnalyzer.InvertedMeters()
+	for i, meter := range inverted {
+		if opts.anonymize {
+			inverted[i].Name = anonymize.Name(meter.SensorID)
+			inverted[i].SensorID = anonymize.ID(meter.SensorID)
+		}
+		infof(cfg, "Consumer %s (%s) used no energy, but its other counter advanced by %.1f kWh: set invertMeasurement to %v for this sensor",
+			inverted[i].Name, inverted[i].SensorID, meter.Advance/1000, !meter.InvertMeasurement)
+	}
+
+	idle := energyAnalyzer.IdleBatteries()
+	for i, run := range idle {
+		if opts.anonymize {
+			idle[i].Name = anonymize.Name(run.SensorID)
+			idle[i].SensorID = anonymize.ID(run.SensorID)
+		}
+		if run.Ongoing {
+			warnf("Battery %s (%s) has neither charged nor discharged since %s (%d days), check its breaker and inverter",
+				idle[i].Name, idle[i].SensorID, run.Start.Format("2006-01-02"), run.Days)
+		} else {
+			warnf("Battery %s (%s) was idle for %d days from %s to %s",
+				idle[i].Name, idle[i].SensorID, run.Days, run.Start.Format("2006-01-02"), run.End.AddDate(0, 0, -1).Format("2006-01-02"))
+		}
+	}
+
+	outliers := energyAnalyzer.Outliers()
+	if opts.anonymize {
+		for i := range outliers {
+			outliers[i].SensorID = anonymize.ID(outliers[i].SensorID)
+		}
+	}
+
+	result := &analyzer.Result{From: from, To: to, LowTariff: statsLT, HighTariff: statsHT,
+		Aggregation: opts.aggregate, Series: series, Completeness: completeness, CounterResets: resets, InvertedMeters: inverted, IdleBatteries: idle,
+		Outliers: outliers, Groups: groups, Detail: detail, Emissions: emissions, Savings: savings, Bill: bill}
+	if cfg.Prices.Enabled() || cfg.Prices.FeedIn > 0 {
+		result.Currency = cfg.Prices.CurrencyLabel()
+	}
+	result.MeterTariff = energyAnalyzer.MeterTariffUsed()
+	if detail != nil && opts.anonymize {
+		detail.Name = anonymize.Name(detail.SensorID)
+		detail.SensorID = anonymize.ID(detail.SensorID)
+	}
+	if opts.validate {
+		result.Balance = energyAnalyzer.Balance()
+	}
+	if opts.peaks > 0 && opts.peakRes > 0 {
+		if result.Peaks, err = energyAnalyzer.PowerPeaks(ctx, smId, from, to, opts.peakRes, opts.peaks); err != nil {
+			return fmt.Errorf("analyzing power peaks: %w", err)
+		}
+	} else if opts.peaks > 0 {
+		result.Peaks = energyAnalyzer.Peaks(opts.peaks)
+	}
+	if len(opts.shaving) > 0 {
+		result.PeakShaving = energyAnalyzer.PeakShaving(opts.shaving)
+	}
+	if opts.diagnose > 0 {
+		result.Diagnostics = energyAnalyzer.Diagnose(opts.diagnose)
+		if opts.anonymize {
+			for _, diagnosis := range result.Diagnostics {
+				for i, c := range diagnosis.Contributions {
+					diagnosis.Contributions[i].Name = anonymize.Name(c.SensorID)
+					diagnosis.Contributions[i].SensorID = anonymize.ID(c.SensorID)
+				}
+			}
+		}
+	}
+	result.GridMeters = energyAnalyzer.GridMeters()
+	result.Phases = energyAnalyzer.Phases()
+	result.GridBill = energyAnalyzer.GridBill()
+	if opts.anonymize {
+		for i, meter := range result.GridMeters {
+			result.GridMeters[i].Name = anonymize.Name(meter.SensorID)
+			result.GridMeters[i].SensorID = anonymize.ID(meter.SensorID)
+		}
+		for i, meter := range result.Phases {
+			result.Phases[i].Name = anonymize.Name(meter.SensorID)
+			result.Phases[i].SensorID = anonymize.ID(meter.SensorID)
+		}
+	}
+	result.Producers = energyAnalyzer.Producers()
+	if opts.anonymize {
+		for i, producer := range result.Producers {
+			result.Producers[i].Name = anonymize.Name(producer.SensorID)
+			result.Producers[i].SensorID = anonymize.ID(producer.SensorID)
+		}
+	}
+	result.HeatPumps = slices.DeleteFunc(energyAnalyzer.HeatPumps(), func(heatPump analyzer.HeatPumpStats) bool {
+		return !opts.consumers.keep(heatPump.SensorID, heatPump.Name)
+	})
+	if opts.anonymize {
+		for i, heatPump := range result.HeatPumps {
+			result.HeatPumps[i].Name = anonymize.Name(heatPump.SensorID)
+			result.HeatPumps[i].SensorID = anonymize.ID(heatPump.SensorID)
+		}
+	}
+	if opts.anomalies {
+		result.Anomalies = energyAnalyzer.Anomalies()
+		if opts.anonymize {
+			for i, anomaly := range result.Anomalies {
+				if anomaly.SensorID != "" {
+					result.Anomalies[i].Name = anonymize.Name(anomaly.SensorID)
+					result.Anomalies[i].SensorID = anonymize.ID(anomaly.SensorID)
+				}
+			}
+		}
+	}
+	if opts.forecast {
+		forecast, err := weather.NewForecast(cfg, from, to)
+		if err != nil {
+			return fmt.Errorf("loading forecast: %w", err)
+		}
+		if result.Forecast, err = energyAnalyzer.CompareForecast(forecast, from, to); err != nil {
+			return err
+		}
+	}
+	if len(opts.batteries) > 0 {
+		result.BatterySimulation = energyAnalyzer.SimulateBatteries(opts.batteries)
+	}
+	result.Batteries = energyAnalyzer.Batteries()
+	if opts.anonymize {
+		for i, battery := range result.Batteries {
+			result.Batteries[i].Name = anonymize.Name(battery.SensorID)
+			result.Batteries[i].SensorID = anonymize.ID(battery.SensorID)
+		}
+	}
+	if opts.soc {
+		result.StateOfCharge = energyAnalyzer.StateOfCharge()
+		if opts.anonymize {
+			for i, summary := range result.StateOfCharge {
+				result.StateOfCharge[i].Name = anonymize.Name(summary.SensorID)
+				result.StateOfCharge[i].SensorID = anonymize.ID(summary.SensorID)
+			}
+		}
+	}
+	if opts.ev {
+		result.EVChargers = slices.DeleteFunc(energyAnalyzer.EVChargers(), func(charger analyzer.EVCharger) bool {
+			return !opts.consumers.keep(charger.SensorID, charger.Name)
+		})
+		if opts.anonymize {
+			for i, charger := range result.EVChargers {
+				result.EVChargers[i].Name = anonymize.Name(charger.SensorID)
+				result.EVChargers[i].SensorID = anonymize.ID(charger.SensorID)
+			}
+		}
+	}
+	if opts.standby {
+		result.Standby = slices.DeleteFunc(energyAnalyzer.StandbyLoads(), func(load analyzer.StandbyLoad) bool {
+			return !opts.consumers.keep(load.ID, load.Name)
+		})
+		if opts.anonymize {
+			for i, load := range result.Standby {
+				result.Standby[i].Name = anonymize.Name(load.ID)
+				result.Standby[i].ID = anonymize.ID(load.ID)
+			}
+		}
+	}
+	if opts.profile {
+		result.Profile = energyAnalyzer.LoadProfile()
+		result.Profile.Consumers = slices.DeleteFunc(result.Profile.Consumers, func(consumer analyzer.ConsumerProfile) bool {
+			id := consumer.ID
+			if id == "" {
+				id = analyzer.SharedConsumerID
+			}
+			return !opts.consumers.keep(id, consumer.Name)
+		})
+		if opts.anonymize {
+			for i, consumer := range result.Profile.Consumers {
+				if consumer.ID != "" {
+					result.Profile.Consumers[i].Name = anonymize.Name(consumer.ID)
+					result.Profile.Consumers[i].ID = anonymize.ID(consumer.ID)
+				}
+			}
+		}
+	}
+
+	if opts.xlsxPath != "" {
+		if err := writeExcelReport(opts.xlsxPath, energyAnalyzer, result, opts.consumers, opts.anonymize); err != nil {
+			return fmt.Errorf("writing excel report: %v", err)
+		}
+	}
+
+	if opts.chartDir != "" {
+		if err := writeCharts(opts.chartDir, energyAnalyzer, opts.consumers.keepIDs(energyAnalyzer)); err != nil {
+			return fmt.Errorf("writing charts: %v", err)
+		}
+	}
+	if opts.sdatDir != "" {
+		if err := writeSDAT(opts.sdatDir, cfg, energyAnalyzer, opts.consumers.keepIDs(energyAnalyzer), opts.anonymize); err != nil {
+			return fmt.Errorf("writing sdat files: %w", err)
+		}
+	}
+	if opts.sankey != "" {
+		if err := writeSankey(opts.sankey, result); err != nil {
+			return fmt.Errorf("writing sankey diagram: %v", err)
+		}
+	}
+	if opts.heatmap != "" {
+		if err := writeHeatmap(opts.heatmap, energyAnalyzer.Heatmap(opts.consumers.keepIDs(energyAnalyzer)), from, to); err != nil {
+			return fmt.Errorf("writing heatmap: %v", err)
+		}
+	}
+
+	if opts.plugin != "" {
+		pluginCfg, ok := cfg.Plugins[opts.plugin]
+		if !ok {
+			return fmt.Errorf("unknown report plugin %q", opts.plugin)
+		}
+		if err := plugin.Run(opts.plugin, pluginCfg, "energy", result, os.Stdout, os.Stderr); err != nil {
+			return err
+		}
+		return balanceError(result.Balance)
+	}
+
+	if opts.template != "" {
+		if err := printTemplate(os.Stdout, opts.template, result); err != nil {
+			return err
+		}
+		return balanceError(result.Balance)
+	}
+
+	switch opts.format {
+	case formatJSON:
+		if err := printJSON(os.Stdout, result); err != nil {
+			return err
+		}
+	default:
+		var currency string
+		if cfg.Spot.File != "" {
+			currency = cfg.Spot.CurrencyLabel()
+		}
+		printTextReport(energyAnalyzer.Tariff(), result, currency)
+	}
+	return balanceError(result.Balance)
+}
+
+// balanceError returns an error wrapping analyzer.ErrImbalance when the
+// balance check found violations, nil otherwise or when it did not run
+func balanceError(balance *analyzer.BalanceReport) error {
+	if balance == nil || len(balance.Violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d intervals", analyzer.ErrImbalance, len(balance.Violations), balance.Checked)
 }
 
-func printEnergyStats(stats *analyzer.EnergyStats) {
-
-	fmt.Printf("System Overview:\n")
-	fmt.Printf("---------------\n")
-	fmt.Printf("Grid Import:       %8.1f kWh\n", stats.GridImport/1000)
-	fmt.Printf("Grid Export:       %8.1f kWh\n", stats.GridExport/1000)
-	fmt.Printf("Production:        %8.1f kWh\n", stats.Production/1000)
-	fmt.Printf("Consumption:       %8.1f kWh\n", stats.Consumption/1000)
-	fmt.Printf("Battery Charge:    %8.1f kWh\n", stats.BatteryCharge/1000)
-	fmt.Printf("Battery Discharge: %8.1f kWh\n", stats.BatteryDischarge/1000)
-	fmt.Printf("Self Consumption:  %8.1f %%\n", stats.SelfConsumptionRate())
-	fmt.Printf("Autarchy:          %8.1f %%\n", stats.AutarchyRate())
-
-	fmt.Printf("\nEnergy Balance:\n")
-	fmt.Printf("--------------\n")
-	totalInput := stats.GridImport + stats.Production
-	totalOutput := stats.GridExport + stats.Consumption
-	for _, consumer := range stats.Consumers {
-		if consumer.Sensor.Tag.Name != "Unaccounted Energy" {
-			totalOutput += consumer.Total
-		}
-	}
-	fmt.Printf("Total Input:       %.1f kWh\n", totalInput/1000)
-	fmt.Printf("Total Output:      %.1f kWh\n", totalOutput/1000)
-	fmt.Printf("Difference:        %.1f kWh\n", (totalInput-totalOutput)/1000)
-
-	fmt.Printf("\nConsumer Details:\n")
-	fmt.Printf("----------------\n")
-	fmt.Printf("%-15s %13s %13s %13s %13s\n",
-		"Name", "Total", "Inverter", "Battery", "Grid")
-	fmt.Printf("%s\n", strings.Repeat("-", 71))
-
-	// First print regular consumers
-	for _, consumer := range stats.Consumers {
-
-		fmt.Printf("%-15s %9.1f kWh %9.1f kWh %9.1f kWh %9.1f kWh\n",
-			consumer.Sensor.Tag.Name,
-			consumer.Total/1000,
-			consumer.Sources.FromInverter/1000,
-			consumer.Sources.FromBattery/1000,
-			consumer.Sources.FromGrid/1000)
-
-	}
-	fmt.Printf("\n")
+// writeExcelReport writes the xlsx workbook with daily values of the
+// consumers that the filter keeps
+func writeExcelReport(path string, ea *analyzer.EnergyAnalyzer, result *analyzer.Result, consumers consumerFilter, anonymized bool) error {
+	daily, err := ea.DailyStats(result.From, result.To)
+	if err != nil {
+		return err
+	}
+	for _, day := range daily {
+		day.FilterConsumers(consumers.keepStats)
+	}
+	if anonymized {
+		for _, day := range daily {
+			anonymizeStats(day)
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteExcel(file, result, daily); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeSankey writes the energy flows of the whole period as SVG
+func writeSankey(path string, result *analyzer.Result) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	title := fmt.Sprintf("Energy Flows %s - %s",
+		result.From.Format("2006-01-02"), result.To.Format("2006-01-02"))
+	if err := report.WriteSankey(file, analyzer.MergeStats(result.HighTariff, result.LowTariff), title); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeHeatmap writes the heatmap as HTML page or, for any other extension,
+// as CSV
+func writeHeatmap(path string, heatmap *analyzer.Heatmap, from, to time.Time) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		title := fmt.Sprintf("Average Power by Hour and Weekday %s - %s",
+			from.Format("2006-01-02"), to.Format("2006-01-02"))
+		err = report.WriteHeatmapHTML(file, heatmap, title)
+	default:
+		err = report.WriteHeatmapCSV(file, heatmap)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
 
-func parseDate(dateStr string) (time.Time, error) {
-	// Try different date formats
-	formats := []string{
-		"2006-01-02",
-		"02.01.2006",
-		"02.01.06",
+// newAuditBundle records the readings and the results of an analysis run
+func newAuditBundle(cfg *config.Config, smId string, from, to, analyzedAt time.Time, streamDays int, recorder *audit.Recorder, statsLT, statsHT *analyzer.EnergyStats) (*audit.Bundle, error) {
+	bundle, err := audit.NewBundle(cfg, smId, from, to, recorder.Readings())
+	if err != nil {
+		return nil, err
 	}
+	bundle.AnalyzedAt = analyzedAt
+	bundle.StreamDays = streamDays
+	if err := bundle.AddResult("lowTariff", statsLT); err != nil {
+		return nil, err
+	}
+	if err := bundle.AddResult("highTariff", statsHT); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
 
-	var parseErr error
-	for _, format := range formats {
-		t, err := time.ParseInLocation(format, dateStr, time.Local)
-		if err == nil {
-			return t, nil
+// writeAuditBundle adds the bills shown to the bundle, signs and writes it
+func writeAuditBundle(cfg *config.Config, bundle *audit.Bundle, opts reportOptions, bill *billing.Bill) error {
+	if bill != nil {
+		if err := bundle.AddResult("bill", bill); err != nil {
+			return err
 		}
-		parseErr = err
 	}
-	return time.Time{}, fmt.Errorf("invalid date format, please use YYYY-MM-DD or DD.MM.YYYY: %v", parseErr)
+	path, err := bundle.Write(opts.auditDir, opts.auditKey)
+	if err != nil {
+		return err
+	}
+	infof(cfg, "Audit bundle written to %s", path)
+	return nil
 }
 
 func main() {
 	var (
-		startDate  string
-		endDate    string
-		days       int
+		period     periodFlags
 		noCache    bool
 		clearCache bool
 		dumpCache  bool
 	)
 
-	flag.StringVar(&startDate, "from", "", "Start date (format: YYYY-MM-DD or DD.MM.YYYY)")
-	flag.StringVar(&endDate, "to", "", "End date (format: YYYY-MM-DD or DD.MM.YYYY)")
-	flag.IntVar(&days, "days", 0, "Number of days to analyze (ignored if from/to are specified)")
+	flag.StringVar(&period.from, "from", "", "Start date (format: YYYY-MM-DD or DD.MM.YYYY)")
+	flag.StringVar(&period.to, "to", "", "End date (format: YYYY-MM-DD or DD.MM.YYYY)")
+	flag.IntVar(&period.days, "days", 0, "Number of days to analyze (ignored if another period is specified)")
+	flag.StringVar(&period.month, "month", "", "Analyze a calendar month (format: YYYY-MM)")
+	flag.StringVar(&period.year, "year", "", "Analyze a calendar year (format: YYYY)")
+	flag.StringVar(&period.week, "week", "", "Analyze an ISO week, Monday to Sunday (format: YYYY-Www)")
+	yoyRange := flag.String("yoy", "", "Year-over-year report of the calendar months of these years (format: YYYY-YYYY)")
+	degradationRange := flag.String("degradation", "", "Report the seasonally normalized yield of every inverter over these years (format: YYYY-YYYY)")
+	flag.StringVar(&period.period, "period", "", "Analyze the billing period starting in the given month (format: YYYY-MM)")
+	baselineFrom := flag.String("baseline-from", "", "Compare with a baseline period starting on this date (format: YYYY-MM-DD or DD.MM.YYYY)")
+	baselineTo := flag.String("baseline-to", "", "End date of the baseline period (format: YYYY-MM-DD or DD.MM.YYYY)")
 	analyzeFlag := flag.Bool("analyze", false, "Analyze setup and suggest configuration")
 	energy := flag.Bool("energy", false, "Show energy analysis")
 	debug := flag.Bool("debug", false, "Enable debug output")
+	quiet := flag.Bool("quiet", false, "Suppress informational messages (progress, notices)")
 	flag.BoolVar(&noCache, "no-cache", false, "Disable caching, fetch all data fresh")
 	flag.BoolVar(&clearCache, "clear-cache", false, "Clear the cache before running")
 	flag.BoolVar(&dumpCache, "dump-cache", false, "Dump cache contents and exit")
+	anonymizeFlag := flag.Bool("anonymize", false, "Replace consumer names, sensor IDs and group names with stable pseudonyms in all output")
+	auditDir := flag.String("audit", "", "Write an audit bundle (readings, config, results) into this directory")
+	format := flag.String("format", formatText, "Output format of the energy analysis: text or json")
+	csvPath := flag.String("csv", "", "Write per-interval data (900s) to this CSV file")
+	auditCSV := flag.String("audit-csv", "", "Write the attribution of every consumer's usage per interval to this CSV file")
+	billCSV := flag.String("bill-csv", "", "Write the consumer bills to this CSV file, laid out by billExport in the config")
+	book := flag.Bool("book", false, "Number the consumer bills and record the invoices in the ledger file of the config")
+	xlsxPath := flag.String("xlsx", "", "Write an Excel workbook (overview and daily values per consumer) to this file")
+	templatePath := flag.String("template", "", "Render the energy analysis with this Go text/template file")
+	sankeyPath := flag.String("sankey", "", "Write an SVG Sankey diagram of the energy flows to this file")
+	heatmapPath := flag.String("heatmap", "", "Write an hour-by-weekday heatmap of consumption and production to this .csv or .html file")
+	chartDir := flag.String("charts", "", "Write SVG charts of production, consumption and grid exchange into this directory")
+	sdatDir := flag.String("sdat", "", "Write SDAT load profiles (15-minute kWh) of the metering points in the config into this directory")
+	sortKey := flag.String("sort", analyzer.SortConfig, "Consumer order: config, name, total or grid (grid share)")
+	stream := flag.Int("stream", 0, "Analyze in pieces of this many days to keep memory bounded on long periods")
+	validate := flag.Bool("validate", false, "Check the energy balance of every interval and exit with code 5 on violations (implies -energy)")
+	detail := flag.Bool("detail", false, "Add a daily breakdown of the consumer selected with -consumer (implies -energy)")
+	var include, exclude listFlag
+	flag.Var(&include, "consumer", "Only report this consumer (ID or name, repeatable)")
+	flag.Var(&exclude, "exclude-consumer", "Leave this consumer out of the report (ID or name, repeatable)")
+	ev := flag.Bool("ev", false, "Add the charging sessions of every EV charger with their solar share")
+	soc := flag.Bool("soc", false, "Add the battery state of charge (min, max, average, full cycles; timeline in JSON)")
+	standby := flag.Bool("standby", false, "Add the standby (always-on) load of every consumer, measured at night")
+	profile := flag.Bool("profile", false, "Add the average daily load profile of the ZEV and every consumer")
+	peaks := flag.Int("peaks", 0, "Report the N highest 15-minute grid import peaks and the maximum of every month")
+	peakRes := flag.Int("peak-resolution", 0, "Take the -peaks from the grid meter power every N seconds (below 900) instead of the 15-minute intervals")
+	anomalies := flag.Bool("anomalies", false, "Report suspicious patterns in the meter data with their severity")
+	forecast := flag.Bool("forecast", false, "Compare the daily production with the configured PV forecast and flag shortfalls")
+	peakShaving := flag.String("peak-shaving", "", "Report the battery or load shifting needed to cap every monthly peak at these levels in kW, e.g. 15,10")
+	diagnose := flag.Int("diagnose", 0, "List the N intervals with the most unaccounted energy and the reading of every meter")
+	simulateBattery := flag.String("simulate-battery", "", "Simulate batteries in place of the installed ones: capacity in kWh with optional power in kW, e.g. 10,20:5")
+	aggregate := flag.String("aggregate", "", "Add a per-period series to the energy analysis: day or month")
+	minKWh := flag.Float64("min-kwh", 0, "Collapse consumers with less than this total (kWh) into an \"Other\" row")
+	lang := flag.String("lang", i18n.English, "Report language: "+strings.Join(i18n.Languages(), ", "))
+	pluginName := flag.String("plugin", "", "Render the energy analysis with the named report plugin from the config")
+	verifyAudit := flag.String("verify-audit", "", "Verify the digest and signature of an audit bundle and exit")
+	auditPubKey := flag.String("audit-pubkey", "", "PEM file with the ed25519 public key that -verify-audit checks the signature against")
 	flag.Parse()
 
+	var healthCmd, liveCmd, annualCmd bool
+	switch flag.Arg(0) {
+	case "":
+	case "version":
+		fmt.Printf("zevalizer %s\n", version.Get())
+		return
+	case "completion":
+		if err := writeCompletion(os.Stdout, flag.Arg(1)); err != nil {
+			fatalf(exitUsage, "Completion: %v", err)
+		}
+		return
+	case "sensors":
+		if flag.Arg(1) != "health" {
+			fatalf(exitUsage, "Unknown sensors command %q, available commands: %s", flag.Arg(1), strings.Join(sensorCommands, ", "))
+		}
+		healthCmd = true
+	case "live":
+		liveCmd = true
+	case "report":
+		if flag.Arg(1) != "annual" {
+			fatalf(exitUsage, "Unknown report command %q, available commands: %s", flag.Arg(1), strings.Join(reportCommands, ", "))
+		}
+		// the options may also follow the command
+		flag.CommandLine.Parse(flag.Args()[2:])
+		if flag.NArg() > 0 {
+			fatalf(exitUsage, "Unexpected argument %q after report annual", flag.Arg(0))
+		}
+		if period.year == "" || period != (periodFlags{year: period.year}) || *yoyRange != "" || *degradationRange != "" || *baselineFrom != "" || *baselineTo != "" {
+			fatalf(exitUsage, "report annual needs -year and no other period selectors")
+		}
+		annualCmd = true
+		*energy = true
+	default:
+		fatalf(exitUsage, "Unknown command %q, available commands: %s", flag.Arg(0), strings.Join(subcommands, ", "))
+	}
+
+	if *verifyAudit != "" {
+		if *auditPubKey == "" {
+			fatalf(exitUsage, "-verify-audit needs the public key of the signer in -audit-pubkey")
+		}
+		pub, err := audit.LoadPublicKey(*auditPubKey)
+		if err != nil {
+			fatalf(exitUsage, "Failed to load the public key: %v", err)
+		}
+		bundle, err := audit.Verify(*verifyAudit, pub)
+		if err != nil {
+			fatalf(exitDataQuality, "Audit verification failed: %v", err)
+		}
+		if err := bundle.Reproduce(context.Background()); err != nil {
+			fatalf(exitDataQuality, "Audit verification failed: %v", err)
+		}
+		fmt.Printf("Audit bundle OK: %s to %s, version %s, results reproduced from %d sensors\n",
+			bundle.From.Format("2006-01-02 15:04"), bundle.To.Format("2006-01-02 15:04"), bundle.Version, len(bundle.ReadingsSHA256))
+		return
+	}
+
+	opts := reportOptions{
+		anonymize: *anonymizeFlag,
+		auditDir:  *auditDir,
+		plugin:    *pluginName,
+		format:    *format,
+		csvPath:   *csvPath,
+		auditCSV:  *auditCSV,
+		billCSV:   *billCSV,
+		book:      *book,
+		xlsxPath:  *xlsxPath,
+		template:  *templatePath,
+		sankey:    *sankeyPath,
+		heatmap:   *heatmapPath,
+		chartDir:  *chartDir,
+		sdatDir:   *sdatDir,
+		sortKey:   *sortKey,
+		minKWh:    *minKWh,
+		aggregate: *aggregate,
+		peaks:     *peaks,
+		peakRes:   *peakRes,
+		diagnose:  *diagnose,
+		profile:   *profile,
+		standby:   *standby,
+		soc:       *soc,
+		ev:        *ev,
+		forecast:  *forecast,
+		anomalies: *anomalies,
+		consumers: consumerFilter{include: include, exclude: exclude},
+		detail:    *detail,
+		stream:    *stream,
+		validate:  *validate,
+	}
+	if opts.validate {
+		*energy = true
+	}
+	if annualCmd {
+		if opts.stream > 0 {
+			fatalf(exitUsage, "report annual cannot be combined with -stream, its monthly series and diagnostics need all intervals")
+		}
+		// the year-end package: monthly series, diagnostics and the
+		// monthly peaks on top of the tariff split, the consumer totals
+		// and the bills of the energy analysis
+		if opts.aggregate == "" {
+			opts.aggregate = analyzer.AggregateMonth
+		}
+		opts.anomalies = true
+		if opts.peaks == 0 {
+			opts.peaks = 10
+		}
+		if opts.diagnose == 0 {
+			opts.diagnose = 10
+		}
+	}
+	if opts.detail {
+		if len(include) != 1 || len(exclude) > 0 {
+			fatalf(exitUsage, "-detail needs exactly one -consumer and no -exclude-consumer")
+		}
+		*energy = true
+	}
+	if opts.format != formatText && opts.format != formatJSON {
+		fatalf(exitUsage, "Invalid format %q: must be %s or %s", opts.format, formatText, formatJSON)
+	}
+	if err := analyzer.ValidateSortKey(opts.sortKey); err != nil {
+		fatalf(exitUsage, "Invalid sort: %v", err)
+	}
+	if err := analyzer.ValidateAggregation(opts.aggregate); err != nil {
+		fatalf(exitUsage, "Invalid aggregate: %v", err)
+	}
+	if opts.stream < 0 {
+		fatalf(exitUsage, "Invalid stream: %d must not be negative", opts.stream)
+	}
+	if opts.peaks < 0 {
+		fatalf(exitUsage, "Invalid peaks: %d must not be negative", opts.peaks)
+	}
+	if opts.peakRes < 0 || opts.peakRes >= analyzer.IntervalSeconds {
+		fatalf(exitUsage, "Invalid peak-resolution: %d must be between 1 and %d seconds", opts.peakRes, analyzer.IntervalSeconds-1)
+	}
+	if opts.peakRes > 0 && opts.peaks == 0 {
+		fatalf(exitUsage, "-peak-resolution needs -peaks")
+	}
+	if opts.diagnose < 0 {
+		fatalf(exitUsage, "Invalid diagnose: %d must not be negative", opts.diagnose)
+	}
+	batteries, err := analyzer.ParseBatteryScenarios(*simulateBattery)
+	if err != nil {
+		fatalf(exitUsage, "Invalid simulate-battery: %v", err)
+	}
+	opts.batteries = batteries
+	if opts.shaving, err = analyzer.ParsePeakLevels(*peakShaving); err != nil {
+		fatalf(exitUsage, "Invalid peak-shaving: %v", err)
+	}
+	if opts.stream > 0 && (opts.csvPath != "" || opts.auditCSV != "" || opts.xlsxPath != "" || opts.chartDir != "" || opts.sdatDir != "" || opts.aggregate != "" || opts.peaks > 0 || opts.heatmap != "" || opts.profile || opts.standby || opts.ev || opts.detail || opts.diagnose > 0 || len(opts.batteries) > 0 || len(opts.shaving) > 0 || opts.forecast) {
+		fatalf(exitUsage, "-stream cannot be combined with -csv, -audit-csv, -xlsx, -charts, -sdat, -aggregate, -peaks, -peak-shaving, -heatmap, -profile, -standby, -ev, -detail, -diagnose, -forecast or -simulate-battery, they need all intervals")
+	}
+	if err := i18n.SetLanguage(*lang); err != nil {
+		fatalf(exitUsage, "Invalid language: %v", err)
+	}
+
 	configPath := "config.yaml"
 	cfg, err := config.Load(configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatalf(exitConfig, "Failed to load config: %v", err)
 	}
 	cfg.Debug = *debug
+	if opts.forecast && cfg.Forecast.Provider == "" {
+		fatalf(exitConfig, "-forecast needs a forecast provider in the config")
+	}
+	cfg.Quiet = *quiet
+	if opts.sdatDir != "" && (len(cfg.SDAT.MeteringPoints) == 0 || cfg.SDAT.Sender == "" || cfg.SDAT.Receiver == "") {
+		fatalf(exitConfig, "-sdat needs a sender, a receiver and metering points in the sdat section of the config")
+	}
+	if opts.anonymize {
+		if err := anonymize.LoadKey(anonymize.KeyFilePath(configPath)); err != nil {
+			fatalf(exitConfig, "Failed to load the pseudonym key: %v", err)
+		}
+	}
+	if opts.auditDir != "" {
+		if cfg.Audit.SigningKey == "" {
+			fatalf(exitConfig, "-audit needs a signingKey in the audit section of the config")
+		}
+		if opts.auditKey, err = audit.LoadSigningKey(cfg.Audit.SigningKey); err != nil {
+			fatalf(exitConfig, "Failed to load the audit signing key: %v", err)
+		}
+	}
+	if opts.book && (!cfg.Prices.Billing() || cfg.Ledger.File == "") {
+		fatalf(exitConfig, "-book needs a solarTariff in the prices and a ledger file in the config")
+	}
+	if opts.billCSV != "" {
+		if !cfg.Prices.Billing() {
+			fatalf(exitConfig, "-bill-csv needs a solarTariff in the prices of the config")
+		}
+		if err := validateBillColumns(cfg.BillExport); err != nil {
+			fatalf(exitConfig, "Failed to load config: %v", err)
+		}
+	}
+	if opts.stream > 0 && len(cfg.Prices.Changes) > 0 {
+		fatalf(exitUsage, "-stream cannot apply price changes, splitting the analysis at them needs all intervals")
+	}
+	if opts.stream > 0 && cfg.Prices.Billing() && len(cfg.ZEV.Tenants) > 0 {
+		fatalf(exitUsage, "-stream cannot bill tenants, splitting the bills at move-in and move-out needs all intervals")
+	}
+
+	period.billingStartDay = cfg.Billing.PeriodStartDay()
+	from, to, err := resolvePeriod(period, time.Now())
+	if err != nil {
+		fatalf(exitUsage, "Invalid period: %v", err)
+	}
+	if *yoyRange != "" && *degradationRange != "" {
+		fatalf(exitUsage, "-yoy cannot be combined with -degradation")
+	}
+	yearFlag, yearRange := "yoy", *yoyRange
+	if *degradationRange != "" {
+		yearFlag, yearRange = "degradation", *degradationRange
+	}
+	if yearRange != "" {
+		if period != (periodFlags{billingStartDay: period.billingStartDay}) {
+			fatalf(exitUsage, "Invalid period: -%s cannot be combined with other period selectors", yearFlag)
+		}
+		if from, to, err = resolveYearRange(yearRange, time.Now()); err != nil {
+			fatalf(exitUsage, "Invalid period: %v", err)
+		}
+	}
+	compare := *baselineFrom != "" || *baselineTo != ""
+	if opts.validate && (compare || *yoyRange != "" || *degradationRange != "") {
+		fatalf(exitUsage, "-validate cannot be combined with -yoy, -degradation or -baseline-from/-baseline-to")
+	}
+	var baseFrom, baseTo time.Time
+	if compare {
+		baseFrom, baseTo, err = resolvePeriod(periodFlags{from: *baselineFrom, to: *baselineTo}, time.Now())
+		if err != nil {
+			fatalf(exitUsage, "Invalid baseline period: %v", err)
+		}
+	}
 
 	cachePath := cache.CacheFilePath(configPath)
 
@@ -175,89 +983,107 @@ func main() {
 	if dumpCache {
 		c, err := cache.Load(cachePath, "")
 		if err != nil {
-			log.Fatalf("Failed to load cache: %v", err)
+			fatalf(exitFailure, "Failed to load cache: %v", err)
 		}
-		c.Dump(os.Stdout)
+		c.Dump(os.Stdout, opts.anonymize)
 		return
 	}
 
 	// Handle clear-cache command
 	if clearCache {
 		if err := cache.Delete(cachePath); err != nil {
-			log.Fatalf("Failed to clear cache: %v", err)
+			fatalf(exitFailure, "Failed to clear cache: %v", err)
 		}
-		fmt.Println("Cache cleared.")
-		if !*analyzeFlag && !*energy {
+		infof(cfg, "Cache cleared.")
+		if !*analyzeFlag && !*energy && !healthCmd {
 			return
 		}
 	}
 
-	client := api.NewClient(cfg)
+	// Ctrl-C cancels the requests in flight, so long fetches stop at once
+	// and the cache keeps the chunks completed so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		fatalErr(err, "Failed to create API client")
+	}
 
-	users, err := client.GetUsers()
+	users, err := client.GetUsers(ctx)
 	if err != nil {
-		log.Fatalf("Failed to get users: %v", err)
+		fatalErr(err, "Failed to get users")
 	}
 
 	if len(users) == 0 {
-		log.Fatal("No users found")
+		fatalf(exitAPI, "No users found")
 	}
 
 	smId := users[0].SmID
 
+	if liveCmd {
+		if err := liveData(ctx, client, cfg, smId, opts); err != nil {
+			fatalErr(err, "Live data failed")
+		}
+		return
+	}
+
 	if *analyzeFlag {
 		setupAnalyzer := setup.NewAnalyzer(client)
-		zevConfig, err := setupAnalyzer.AnalyzeSetup(smId)
+		zevConfig, err := setupAnalyzer.AnalyzeSetup(ctx, smId)
 		if err != nil {
-			log.Fatalf("Setup analysis failed: %v", err)
+			fatalErr(err, "Setup analysis failed")
+		}
+		if opts.anonymize {
+			anonymizeSetupHint(zevConfig)
 		}
 		printSetupHint(zevConfig)
 		return
 	}
 
-	if *energy {
+	if *energy || healthCmd {
 		// Create cached client wrapper
-		cachedClient, err := cache.NewCachedClient(client, cachePath, smId, !noCache, cfg.Debug)
+		cachedClient, err := cache.NewCachedClient(client, cachePath, smId, !noCache, cfg.Debug, cfg.Quiet)
 		if err != nil {
-			log.Fatalf("Failed to initialize cache: %v", err)
+			fatalf(exitFailure, "Failed to initialize cache: %v", err)
 		}
 
-		// Handle time range
-		var from, to time.Time
-		now := time.Now()
+		if cfg.Debug {
+			fmt.Fprintf(os.Stderr, "Analyzing period from %s to %s\n",
+				from.Format("2006-01-02 15:04:05 MST"),
+				to.Format("2006-01-02 15:04:05 MST"))
+		}
 
-		if startDate != "" && endDate != "" {
-			// Parse dates
-			from, err = parseDate(startDate)
-			if err != nil {
-				log.Fatalf("Invalid start date: %v", err)
+		if healthCmd {
+			if err := sensorHealth(ctx, cachedClient, cfg, smId, from, to, opts); err != nil {
+				fatalErr(err, "Sensor health report failed")
 			}
-			to, err = parseDate(endDate)
-			if err != nil {
-				log.Fatalf("Invalid end date: %v", err)
+			return
+		}
+
+		if *degradationRange != "" {
+			if err := pvDegradation(ctx, cachedClient, cfg, smId, from, to, opts); err != nil {
+				fatalErr(err, "Degradation report failed")
 			}
-			// Set to start and end of days
-			from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
-			to = time.Date(to.Year(), to.Month(), to.Day(), 23, 59, 59, 999999999, time.Local)
-		} else if days > 0 {
-			// Use days parameter
-			to = time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 999999999, time.Local)
-			from = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.Local).
-				AddDate(0, 0, -days+1)
-		} else {
-			// Default to current day
-			to = time.Date(now.Year(), now.Month(), now.Day(), 23, 59, 59, 999999999, time.Local)
-			from = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+			return
 		}
 
-		if cfg.Debug {
-			fmt.Printf("Analyzing period from %s to %s\n",
-				from.Format("2006-01-02 15:04:05 MST"),
-				to.Format("2006-01-02 15:04:05 MST"))
+		if *yoyRange != "" {
+			if err := yearOverYear(ctx, cachedClient, cfg, smId, from, to, opts); err != nil {
+				fatalErr(err, "Year-over-year report failed")
+			}
+			return
+		}
+
+		if compare {
+			if err := compareEnergy(ctx, cachedClient, cfg, smId, from, to, baseFrom, baseTo, opts); err != nil {
+				fatalErr(err, "Energy comparison failed")
+			}
+			return
 		}
 
-		if err := analyzeEnergy(cachedClient, cfg, smId, from, to); err != nil {
-			log.Fatalf("Energy analysis failed: %v", err)
+		if err := analyzeEnergy(ctx, cachedClient, cfg, smId, from, to, opts); err != nil {
+			fatalErr(err, "Energy analysis failed")
 		}
 		return
 	}
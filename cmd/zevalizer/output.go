@@ -0,0 +1,932 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/billing"
+	"zevalizer/internal/i18n"
+	"zevalizer/internal/tariff"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// printJSON writes the analysis result as indented JSON
+func printJSON(w io.Writer, result *analyzer.Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		return fmt.Errorf("encoding json: %v", err)
+	}
+	return nil
+}
+
+// templateFuncs are available to user supplied report templates
+var templateFuncs = template.FuncMap{
+	"kwh": func(wh float64) float64 { return wh / 1000 },
+	"name": func(consumer analyzer.ConsumerStats) string {
+		return consumer.Name()
+	},
+	"id": func(consumer analyzer.ConsumerStats) string {
+		if consumer.Sensor == nil {
+			return ""
+		}
+		return consumer.Sensor.ID
+	},
+	"merge": func(stats ...*analyzer.EnergyStats) *analyzer.EnergyStats {
+		return analyzer.MergeStats(stats...)
+	},
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+}
+
+// printTemplate executes a user supplied text/template against the analysis result
+func printTemplate(w io.Writer, path string, result *analyzer.Result) error {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading template: %v", err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Parse(string(buf))
+	if err != nil {
+		return fmt.Errorf("parsing template: %v", err)
+	}
+	if err := tmpl.Execute(w, result); err != nil {
+		return fmt.Errorf("executing template: %v", err)
+	}
+	return nil
+}
+
+// printTextReport prints the human-readable report for both tariff periods.
+// currency is set when the grid exchange was valued at spot prices.
+func printTextReport(schedule *tariff.Schedule, result *analyzer.Result, currency string) {
+	fmt.Printf("\n"+i18n.T("Energy Analysis for period: %s to %s")+"\n\n",
+		result.From.Format("2006-01-02 15:04"),
+		result.To.Format("2006-01-02 15:04"))
+
+	highHours, lowHours := schedule.HighTariffString(), schedule.String()
+	if result.MeterTariff {
+		// the schedule only fills in where the grid meter tells no tariff
+		highHours = "(" + i18n.T("grid meter tariff counters") + ")"
+		lowHours = highHours
+	}
+	fmt.Printf("%s %s\n", i18n.T("High Tariff Energy"), highHours)
+	fmt.Printf("------------------------------------------------\n")
+	printEnergyStats(result.HighTariff)
+	fmt.Printf("%s %s\n", i18n.T("Low Tariff Energy"), lowHours)
+	fmt.Printf("------------------------------------------------\n")
+	printEnergyStats(result.LowTariff)
+	fmt.Printf("%s\n", i18n.T("Total Energy"))
+	fmt.Printf("------------------------------------------------\n")
+	total := analyzer.MergeStats(result.HighTariff, result.LowTariff)
+	printEnergyStats(total)
+	if len(result.Groups) > 0 {
+		printGroups(result.Groups)
+	}
+	if currency != "" {
+		printSpotValuation(total, currency)
+	}
+	if len(result.GridMeters) > 0 {
+		printGridMeters(result.GridMeters)
+	}
+	if len(result.Phases) > 0 {
+		printPhases(result.Phases)
+	}
+	if total.FeedInRevenue > 0 {
+		printFeedIn(result, total)
+	}
+	if result.GridBill != nil {
+		printGridBill(result.GridBill)
+	}
+	if result.Savings != nil {
+		printSavings(result.Savings)
+	}
+	if result.Bill != nil {
+		printBill(result.Bill)
+	}
+	if result.Emissions != nil {
+		printEmissions(result.Emissions)
+	}
+	if len(result.Producers) > 0 {
+		printProducers(result.Producers, currency, total.FeedInRevenue > 0)
+	}
+	if len(result.HeatPumps) > 0 {
+		printHeatPumps(result.HeatPumps)
+	}
+	if len(result.Batteries) > 0 {
+		printBatteries(result.Batteries)
+	}
+	if result.BatterySimulation != nil {
+		printBatterySimulation(result.BatterySimulation)
+	}
+	if result.Forecast != nil {
+		printForecast(result.Forecast)
+	}
+	if len(result.Series) > 0 {
+		printSeries(result.Aggregation, result.Series)
+	}
+	if result.Peaks != nil {
+		printPeaks(result.Peaks)
+	}
+	if result.PeakShaving != nil {
+		printPeakShaving(result.PeakShaving)
+	}
+	if result.Profile != nil {
+		printProfile(result.Profile)
+	}
+	if result.Standby != nil {
+		printStandby(result.Standby)
+	}
+	if result.StateOfCharge != nil {
+		printStateOfCharge(result.StateOfCharge)
+	}
+	if result.EVChargers != nil {
+		printEVChargers(result.EVChargers)
+	}
+	if result.Detail != nil {
+		printConsumerDetail(result.Detail)
+	}
+	printCompleteness(result.Completeness)
+	printOutliers(result.Outliers)
+	if result.Anomalies != nil {
+		printAnomalies(result.Anomalies)
+	}
+	printBalance(result.Balance)
+	if result.Diagnostics != nil {
+		printDiagnostics(result.Diagnostics)
+	}
+}
+
+// maxBalanceViolations limits the violating intervals listed in the report
+const maxBalanceViolations = 20
+
+// printBalance prints the result of the balance check (-validate) with the
+// largest violations first
+func printBalance(balance *analyzer.BalanceReport) {
+	if balance == nil {
+		return
+	}
+	printHeading("Energy Balance Validation")
+	fmt.Printf(i18n.T("%d of %d intervals outside tolerance")+"\n", len(balance.Violations), balance.Checked)
+	violations := append([]analyzer.BalanceViolation(nil), balance.Violations...)
+	sort.Slice(violations, func(i, j int) bool {
+		return math.Abs(violations[i].Difference) > math.Abs(violations[j].Difference)
+	})
+	for i, v := range violations {
+		if i == maxBalanceViolations {
+			fmt.Printf("    "+i18n.T("... %d more intervals")+"\n", len(violations)-maxBalanceViolations)
+			break
+		}
+		fmt.Printf("    %s  %s %8.1f Wh  %s %8.1f Wh  %s %8.1f Wh\n", v.Start.Format("2006-01-02 15:04"),
+			i18n.T("Input"), v.Input, i18n.T("Output"), v.Output, i18n.T("Difference"), v.Difference)
+	}
+	fmt.Printf("\n")
+}
+
+// printDiagnostics lists the intervals with unaccounted energy with the
+// reading of every meter, so the meter causing the difference stands out
+func printDiagnostics(diagnoses []analyzer.IntervalDiagnosis) {
+	printHeading("Unaccounted Energy")
+	if len(diagnoses) == 0 {
+		fmt.Printf("%s\n\n", i18n.T("All intervals within tolerance"))
+		return
+	}
+	for _, d := range diagnoses {
+		fmt.Printf("%s  %s %8.1f Wh  %s %8.1f Wh  %s %8.1f Wh\n", d.Start.Format("2006-01-02 15:04"),
+			i18n.T("Input"), d.Input, i18n.T("Output"), d.Output, i18n.T("Difference"), d.Difference)
+		for _, c := range d.Contributions {
+			fmt.Printf("    %-24s %-10s %-9s %8.1f Wh\n", c.Name, i18n.T(c.Role), i18n.T(c.Flow), c.Energy)
+		}
+	}
+	fmt.Printf("\n")
+}
+
+// printOutliers summarizes the readings rejected by the outlier detection
+// per sensor counter
+func printOutliers(outliers []analyzer.Outlier) {
+	if len(outliers) == 0 {
+		return
+	}
+	type summary struct {
+		count  int
+		energy float64
+	}
+	var keys []string
+	sums := make(map[string]*summary)
+	for _, o := range outliers {
+		key := o.SensorID + " " + i18n.T(o.Counter)
+		if sums[key] == nil {
+			sums[key] = &summary{}
+			keys = append(keys, key)
+		}
+		sums[key].count++
+		sums[key].energy += o.Energy
+	}
+	printHeading("Rejected Outliers")
+	for _, key := range keys {
+		fmt.Printf("%-34s "+i18n.T("%d readings, %.1f kWh excluded")+"\n", key, sums[key].count, sums[key].energy/1000)
+	}
+	fmt.Printf("\n")
+}
+
+// maxGapDays limits the days with gaps listed per sensor
+const maxGapDays = 10
+
+// printCompleteness prints the share of intervals with readings per sensor
+// and the days on which readings were missing
+func printCompleteness(completeness []analyzer.SensorCompleteness) {
+	if len(completeness) == 0 {
+		return
+	}
+	printHeading("Data Completeness")
+	for _, sc := range completeness {
+		name := sc.Name
+		if name == "" {
+			name = sc.SensorID
+		}
+		fmt.Printf("%-22s %-11s %6.1f%% (%d/%d)\n", name, i18n.T(sc.Role), sc.Percent(), sc.Covered, sc.Expected)
+		for i, gap := range sc.Gaps {
+			if i == maxGapDays {
+				fmt.Printf("    "+i18n.T("... %d more days with gaps")+"\n", len(sc.Gaps)-maxGapDays)
+				break
+			}
+			fmt.Printf("    %s: "+i18n.T("%d intervals missing")+"\n", gap.Day.Format("2006-01-02"), gap.Missing)
+		}
+	}
+	fmt.Printf("\n")
+}
+
+// printSeries prints one row of system totals per day or month
+func printSeries(aggregation string, series []*analyzer.EnergyStats) {
+	layout := "2006-01-02"
+	if aggregation == analyzer.AggregateMonth {
+		layout = "2006-01"
+	}
+	weather := slices.ContainsFunc(series, func(stats *analyzer.EnergyStats) bool { return stats.Irradiation > 0 })
+	printHeading("Trend")
+	fmt.Printf("%-10s %13s %13s %13s %13s %7s %7s",
+		i18n.T("Period"), i18n.T("Production"), i18n.T("Consumers"), i18n.T("Grid Import"), i18n.T("Grid Export"),
+		i18n.T("Self"), i18n.T("Autarchy"))
+	width := 83
+	if weather {
+		fmt.Printf(" %15s %8s", i18n.T("Irradiation"), i18n.T("Weather"))
+		width += 25
+	}
+	fmt.Printf("\n%s\n", strings.Repeat("-", width))
+	for _, stats := range series {
+		var consumption float64
+		for _, consumer := range stats.Consumers {
+			consumption += consumer.Total
+		}
+		fmt.Printf("%-10s %9.1f kWh %9.1f kWh %9.1f kWh %9.1f kWh %6.1f%% %6.1f%%",
+			stats.Period.Start.Format(layout),
+			stats.Production/1000, consumption/1000, stats.GridImport/1000, stats.GridExport/1000,
+			stats.SelfConsumptionRate(), stats.AutarchyRate())
+		if weather {
+			if stats.Irradiation > 0 {
+				fmt.Printf(" %8.1f kWh/m² %7.1f%%", stats.Irradiation, stats.WeatherIndex)
+			} else {
+				fmt.Printf(" %15s %8s", "-", "-")
+			}
+		}
+		fmt.Printf("\n")
+	}
+	fmt.Printf("\n")
+}
+
+// printGroups prints the subtotals of the consumer groups
+func printGroups(groups []analyzer.ConsumerStats) {
+	printHeading("Consumer Groups")
+	fmt.Printf("%-15s %13s %13s %13s %13s %7s %7s %7s %7s\n",
+		i18n.T("Name"), i18n.T("Total"), i18n.T("Inverter"), i18n.T("Battery"), i18n.T("Grid"),
+		i18n.T("Share"), i18n.T("Solar"), i18n.T("Batt."), i18n.T("Grid"))
+	fmt.Printf("%s\n", strings.Repeat("-", 103))
+	var total float64
+	for _, group := range groups {
+		total += group.Total
+	}
+	for _, group := range groups {
+		printConsumerRow(displayName(&group), &group, total)
+	}
+	fmt.Printf("\n")
+}
+
+// printFeedIn prints the export revenue at the feed-in tariff per tariff
+// period
+func printFeedIn(result *analyzer.Result, total *analyzer.EnergyStats) {
+	printHeading("Feed-in Revenue")
+	for _, period := range []struct {
+		label string
+		stats *analyzer.EnergyStats
+	}{{"High Tariff", result.HighTariff}, {"Low Tariff", result.LowTariff}, {"Total", total}} {
+		fmt.Printf("%-22s %10.2f %s\n", i18n.T(period.label)+":", period.stats.FeedInRevenue, result.Currency)
+	}
+	fmt.Printf("\n")
+}
+
+// printSavings prints what PV and battery saved every consumer and the ZEV
+// compared with buying all energy from the grid
+func printSavings(report *analyzer.SavingsReport) {
+	printHeading("Savings vs. Grid Only")
+	fmt.Printf("%-22s %12s %12s %12s\n", i18n.T("Name"), i18n.T("Grid only"), i18n.T("Grid"), i18n.T("Savings"))
+	row := func(name string, gridOnly, grid, savings float64) {
+		fmt.Printf("%-22s %8.2f %s %8.2f %s %8.2f %s\n", name,
+			gridOnly, report.Currency, grid, report.Currency, savings, report.Currency)
+	}
+	for _, consumer := range report.Consumers {
+		name := consumer.Name
+		if consumer.ID == "" {
+			name = i18n.T(name)
+		}
+		row(name, consumer.GridOnlyCost, consumer.GridCost, consumer.Savings)
+	}
+	row(i18n.T("Total"), report.GridOnlyCost, report.GridCost, report.Savings)
+	fmt.Printf("\n")
+}
+
+// billItems labels the items of a consumer bill
+var billItems = map[string]string{
+	billing.ItemSolar:        "Solar energy",
+	billing.ItemBattery:      "Battery energy",
+	billing.ItemGridHigh:     "Grid energy, high tariff",
+	billing.ItemGridLow:      "Grid energy, low tariff",
+	billing.ItemPassedOn:     "Passed on",
+	billing.ItemGuidelineCap: "VSE guideline cap",
+}
+
+// printBill prints the items of every consumer bill and the sum of all bills
+func printBill(bill *billing.Bill) {
+	printHeading("Consumer Bills")
+	sum := func(indent, label string, energy, amount float64) {
+		fmt.Printf("%s%-*s %9.1f kWh %*s %9.2f %s\n", indent, 28-len(indent), label, energy,
+			len(bill.Currency)+13, "", amount, bill.Currency)
+	}
+	vat := fmt.Sprintf("%s %g%%", i18n.T("VAT"), bill.VATPercent)
+	for _, consumer := range bill.Consumers {
+		name := consumer.Name
+		if consumer.ID == "" {
+			name = i18n.T(name)
+		}
+		if consumer.Tenant != "" {
+			name += ", " + consumer.Tenant
+		}
+		if !consumer.From.Equal(bill.From) || !consumer.To.Equal(bill.To) {
+			// the end is exclusive, show the last day
+			name += fmt.Sprintf(" (%s - %s)", consumer.From.Format("2006-01-02"), consumer.To.Add(-time.Millisecond).Format("2006-01-02"))
+		}
+		switch {
+		case consumer.Corrects != "":
+			name += "  " + fmt.Sprintf(i18n.T("Invoice %s, corrects %s"), consumer.Invoice, consumer.Corrects)
+		case consumer.Invoice != "":
+			name += "  " + fmt.Sprintf(i18n.T("Invoice %s"), consumer.Invoice)
+		}
+		fmt.Printf("%s\n", name)
+		var prices *time.Time
+		for _, line := range consumer.Lines {
+			if line.From != nil && (prices == nil || !prices.Equal(*line.From)) {
+				prices = line.From
+				fmt.Printf("  %s\n", fmt.Sprintf(i18n.T("Prices from %s"), prices.Format("2006-01-02")))
+			}
+			label := line.Name
+			if line.Name == "" {
+				label = i18n.T(billItems[line.Item])
+			} else if line.Item == billing.ItemCommonArea && line.ID == "" {
+				label = i18n.T(line.Name)
+			}
+			quantity, unit := fmt.Sprintf("%.1f", line.Energy), "kWh"
+			price := fmt.Sprintf("%8.4f %s/%-3s", line.Price, bill.Currency, unit)
+			switch line.Item {
+			case billing.ItemBaseFee:
+				quantity, unit = fmt.Sprintf("%.2f", line.Months), i18n.T("mo.")
+				price = fmt.Sprintf("%8.4f %s/%-3s", line.Price, bill.Currency, unit)
+			case billing.ItemAmortization, billing.ItemCommonCost, billing.ItemCommonArea:
+				// the share is of the amount of the whole period
+				quantity, unit = fmt.Sprintf("%.2f", line.Share), "%"
+				price = fmt.Sprintf("%8.2f %-*s", line.Price, len(bill.Currency)+4, bill.Currency)
+			case billing.ItemPassedOn:
+				quantity, unit, price = "", "", fmt.Sprintf("%*s", len(bill.Currency)+13, "")
+			}
+			// the items of a price period go below its heading
+			indent := "  "
+			if line.From != nil {
+				indent = "    "
+			}
+			fmt.Printf("%s%-*s %9s %-3s %s %9.2f %s\n", indent, 28-len(indent), label,
+				quantity, unit, price, line.Amount, bill.Currency)
+		}
+		if bill.VATPercent > 0 {
+			sum("  ", i18n.T("Net"), consumer.Energy, consumer.Net)
+			fmt.Printf("  %-26s %*s %9.2f %s\n", vat, len(bill.Currency)+27, "", consumer.VAT, bill.Currency)
+		}
+		if consumer.Rounding != 0 {
+			fmt.Printf("  %-26s %*s %9.2f %s\n", i18n.T("Rounding"), len(bill.Currency)+27, "", consumer.Rounding, bill.Currency)
+		}
+		sum("  ", i18n.T("Total"), consumer.Energy, consumer.Total)
+	}
+	if bill.VATPercent > 0 {
+		sum("", i18n.T("Net"), bill.Energy, bill.Net)
+		fmt.Printf("%-28s %*s %9.2f %s\n", vat, len(bill.Currency)+27, "", bill.VAT, bill.Currency)
+	}
+	if bill.Rounding != 0 {
+		fmt.Printf("%-28s %*s %9.2f %s\n", i18n.T("Rounding"), len(bill.Currency)+27, "", bill.Rounding, bill.Currency)
+	}
+	sum("", i18n.T("Total"), bill.Energy, bill.Total)
+	fmt.Printf("\n")
+}
+
+// printEmissions prints the CO₂ footprint of every consumer and of the ZEV,
+// compared with covering the same consumption from the grid only
+func printEmissions(report *analyzer.EmissionsReport) {
+	printHeading("CO₂ Emissions")
+	fmt.Printf("%-22s %12s %12s\n", i18n.T("Name"), "kg CO₂", "g/kWh")
+	for _, consumer := range report.Consumers {
+		name := consumer.Name
+		if consumer.ID == "" {
+			name = i18n.T(name)
+		}
+		fmt.Printf("%-22s %12.1f %12.0f\n", name, consumer.CO2, consumer.Intensity)
+	}
+	fmt.Printf("%-22s %12.1f %12.0f\n", i18n.T("Total"), report.CO2, report.Intensity)
+	fmt.Printf("%-22s %12.1f\n", i18n.T("Grid only"), report.GridOnlyCO2)
+	fmt.Printf("\n")
+}
+
+// printProducers prints the production of every PV plant with its part of
+// the grid export, valued at spot prices if they are configured
+func printProducers(producers []analyzer.ProducerStats, currency string, feedIn bool) {
+	printHeading("Producers")
+	fmt.Printf("%-22s %13s %13s %13s", i18n.T("Name"), i18n.T("Production"), i18n.T("Export"), i18n.T("Self-used"))
+	if currency != "" {
+		fmt.Printf(" %12s", i18n.T("Revenue"))
+	}
+	if feedIn {
+		fmt.Printf(" %12s", i18n.T("Feed-in"))
+	}
+	fmt.Printf("\n")
+	for _, producer := range producers {
+		fmt.Printf("%-22s %9.1f kWh %9.1f kWh %9.1f kWh", producer.Name,
+			producer.Production/1000, producer.Export/1000, producer.SelfConsumed()/1000)
+		if currency != "" {
+			fmt.Printf(" %8.2f %s", producer.ExportRevenue, currency)
+		}
+		if feedIn {
+			fmt.Printf(" %12.2f", producer.FeedInRevenue)
+		}
+		fmt.Printf("\n")
+	}
+	fmt.Printf("\n")
+}
+
+// printGridMeters prints the exchange of every grid connection point
+func printGridMeters(meters []analyzer.GridMeterStats) {
+	printHeading("Grid Meters")
+	fmt.Printf("%-22s %13s %13s\n", i18n.T("Name"), i18n.T("Grid Import"), i18n.T("Grid Export"))
+	for _, meter := range meters {
+		fmt.Printf("%-22s %9.1f kWh %9.1f kWh\n", meter.Name, meter.Import/1000, meter.Export/1000)
+	}
+	fmt.Printf("\n")
+}
+
+// printGridBill prints the energy and capacity cost of every month
+func printGridBill(bill *analyzer.GridBill) {
+	printHeading("Grid Bill")
+	fmt.Printf("%-7s %13s %11s %12s %12s %12s %12s\n", i18n.T("Month"), i18n.T("Grid Import"), i18n.T("Peak"),
+		i18n.T("Energy"), i18n.T("Capacity"), i18n.T("Feed-in"), i18n.T("Total"))
+	for _, month := range bill.Months {
+		fmt.Printf("%-7s %9.1f kWh %8.2f kW %8.2f %s %8.2f %s %8.2f %s %8.2f %s\n", month.Start.Format("2006-01"),
+			month.Import, month.Peak, month.Energy, bill.Currency, month.Capacity, bill.Currency,
+			month.FeedIn, bill.Currency, month.Total, bill.Currency)
+	}
+	fmt.Printf("%-7s %13s %11s %8.2f %s %8.2f %s %8.2f %s %8.2f %s\n\n", i18n.T("Total"), "", "",
+		bill.Energy, bill.Currency, bill.Capacity, bill.Currency, bill.FeedIn, bill.Currency, bill.Total, bill.Currency)
+}
+
+// printPhases prints the load per phase of every grid meter and how often
+// the phases were out of balance
+func printPhases(phases []analyzer.PhaseStats) {
+	printHeading("Phase Imbalance")
+	for _, meter := range phases {
+		fmt.Printf("%s (%d %s)\n", meter.Name, meter.Readings, i18n.T("readings"))
+		fmt.Printf("%-22s %10s %10s %10s\n", "", "L1", "L2", "L3")
+		fmt.Printf("%-22s %8.0f W %8.0f W %8.0f W\n", i18n.T("Average")+":", meter.Average[0], meter.Average[1], meter.Average[2])
+		fmt.Printf("%-22s %8.0f W %8.0f W %8.0f W\n", i18n.T("Maximum")+":", meter.Max[0], meter.Max[1], meter.Max[2])
+		fmt.Printf("%-22s %8.0f W\n", i18n.T("Average Spread")+":", meter.AverageSpread)
+		fmt.Printf("%-22s %8.0f W  %s\n", i18n.T("Maximum Spread")+":", meter.MaxSpread, meter.MaxSpreadAt.Format("2006-01-02 15:04"))
+		fmt.Printf("%-22s %8.1f%%  (> %.0f W)\n", i18n.T("Unbalanced")+":", meter.UnbalancedPercent(), meter.Limit)
+		if meter.FromCurrent {
+			fmt.Printf("%s\n", i18n.T("Power estimated from the phase currents at 230 V"))
+		}
+		fmt.Printf("\n")
+	}
+}
+
+// printBatteries prints the throughput of every battery system
+func printBatteries(batteries []analyzer.BatteryStats) {
+	printHeading("Battery Systems")
+	fmt.Printf("%-22s %13s %13s %10s %7s\n", i18n.T("Name"), i18n.T("Charge"), i18n.T("Discharge"), i18n.T("Efficiency"), i18n.T("Cycles"))
+	for _, battery := range batteries {
+		fmt.Printf("%-22s %9.1f kWh %9.1f kWh %9.1f%% %7.1f\n", battery.Name,
+			battery.Charge/1000, battery.Discharge/1000, battery.Efficiency(), battery.Cycles)
+	}
+	fmt.Printf("\n")
+}
+
+// anomalyDescriptions explains every kind of anomaly in the report
+var anomalyDescriptions = map[string]string{
+	analyzer.AnomalyNegativeBalance:   "Consumers used more than came in",
+	analyzer.AnomalyStuckAtZero:       "Meter stuck at zero",
+	analyzer.AnomalyDuplicateReadings: "Several readings for the same time",
+	analyzer.AnomalyChargeWhileExport: "Battery charged from the grid while exporting",
+}
+
+// printAnomalies prints the anomalies found in the meter data, the most
+// severe first
+func printAnomalies(anomalies []analyzer.Anomaly) {
+	printHeading("Anomalies")
+	if len(anomalies) == 0 {
+		fmt.Printf("%s\n\n", i18n.T("No anomalies found"))
+		return
+	}
+	for _, anomaly := range anomalies {
+		fmt.Printf("%-8s %s - %s  %s", i18n.T(anomaly.Severity),
+			anomaly.Start.Format("2006-01-02 15:04"), anomaly.End.Format("2006-01-02 15:04"),
+			i18n.T(anomalyDescriptions[anomaly.Kind]))
+		if anomaly.Name != "" {
+			fmt.Printf(": %s", anomaly.Name)
+		}
+		unit := i18n.T("intervals")
+		if anomaly.Kind == analyzer.AnomalyDuplicateReadings {
+			unit = i18n.T("readings")
+		}
+		fmt.Printf(" (%d %s)\n", anomaly.Count, unit)
+	}
+	fmt.Printf("\n")
+}
+
+// printForecast prints the production against the PV forecast and lists
+// the days with a shortfall
+func printForecast(report *analyzer.ForecastReport) {
+	printHeading("Forecast Comparison")
+	fmt.Printf("%-22s %10.1f kWh\n", i18n.T("Forecast")+":", report.Forecast)
+	fmt.Printf("%-22s %10.1f kWh\n", i18n.T("Actual")+":", report.Actual)
+	if report.Forecast > 0 {
+		fmt.Printf("%-22s %10.1f%%\n", i18n.T("Deviation")+":", (report.Actual-report.Forecast)/report.Forecast*100)
+	}
+	fmt.Printf(i18n.T("%d of %d days more than %.0f%% below forecast")+"\n", report.Shortfalls, len(report.Days), report.ShortfallPercent)
+	if report.Shortfalls > 0 {
+		fmt.Printf("\n%-10s %13s %13s %10s\n", i18n.T("Date"), i18n.T("Forecast"), i18n.T("Actual"), i18n.T("Deviation"))
+		for _, day := range report.Days {
+			if day.Shortfall {
+				fmt.Printf("%-10s %9.1f kWh %9.1f kWh %9.1f%%\n", day.Date.Format("2006-01-02"), day.Forecast, day.Actual, day.Deviation)
+			}
+		}
+	}
+	fmt.Printf("\n")
+}
+
+// printBatterySimulation prints the grid exchange without a battery, with
+// the installed batteries and with every simulated battery
+func printBatterySimulation(simulation *analyzer.BatterySimulation) {
+	printHeading("Battery Simulation")
+	fmt.Printf("%-22s %13s %13s %10s %10s %7s", i18n.T("Scenario"), i18n.T("Grid Import"), i18n.T("Grid Export"),
+		i18n.T("Self Consumption"), i18n.T("Autarchy"), i18n.T("Cycles"))
+	if simulation.Currency != "" {
+		fmt.Printf(" %12s", i18n.T("Avoided"))
+	}
+	fmt.Printf("\n")
+	for _, scenario := range simulation.Scenarios {
+		label := scenario.Label
+		switch label {
+		case analyzer.ScenarioNone:
+			label = i18n.T("No battery")
+		case analyzer.ScenarioInstalled:
+			label = i18n.T("Installed")
+		}
+		fmt.Printf("%-22s %9.1f kWh %9.1f kWh %9.1f%% %9.1f%% %7.1f", label,
+			scenario.GridImport/1000, scenario.GridExport/1000, scenario.SelfConsumption, scenario.Autarchy, scenario.FullCycles)
+		if simulation.Currency != "" {
+			fmt.Printf(" %8.2f %s", scenario.AvoidedCost, simulation.Currency)
+		}
+		fmt.Printf("\n")
+	}
+	fmt.Printf("\n")
+}
+
+// printHeatPumps prints the consumption of every heat pump by tariff and
+// season, and its heat output if a COP is configured
+func printHeatPumps(heatPumps []analyzer.HeatPumpStats) {
+	printHeading("Heat Pumps")
+	for _, heatPump := range heatPumps {
+		fmt.Printf("%s\n", heatPump.Name)
+		printValue("Total", heatPump.Total/1000, "kWh")
+		printValue("Low Tariff", heatPump.LowTariff/1000, "kWh")
+		printValue("High Tariff", heatPump.HighTariff/1000, "kWh")
+		printValue("Winter", heatPump.Seasons.Winter/1000, "kWh")
+		printValue("Spring", heatPump.Seasons.Spring/1000, "kWh")
+		printValue("Summer", heatPump.Seasons.Summer/1000, "kWh")
+		printValue("Autumn", heatPump.Seasons.Autumn/1000, "kWh")
+		if heatPump.COP > 0 {
+			printValue("Heat Output", heatPump.HeatOutput/1000, fmt.Sprintf("kWh (COP %.1f)", heatPump.COP))
+		}
+	}
+	fmt.Printf("\n")
+}
+
+// printStateOfCharge prints the state of charge summary of every battery
+func printStateOfCharge(summaries []analyzer.SocSummary) {
+	printHeading("Battery State of Charge")
+	for _, summary := range summaries {
+		fmt.Printf("%s\n", summary.Name)
+		printValue("Minimum", summary.Min, "%")
+		printValue("Maximum", summary.Max, "%")
+		printValue("Average", summary.Average, "%")
+		printValue("Full Cycles", summary.FullCycles, "")
+	}
+	fmt.Printf("\n")
+}
+
+// printConsumerDetail prints the daily breakdown of a single consumer with
+// the interval of its highest usage on each day
+func printConsumerDetail(detail *analyzer.ConsumerDetail) {
+	printHeading("Daily Detail")
+	fmt.Printf("%s\n", detail.Name)
+	fmt.Printf("%-10s %9s %9s %9s %9s %9s %9s %15s\n", i18n.T("Date"), i18n.T("Total"), i18n.T("Solar"),
+		i18n.T("Battery"), i18n.T("Grid"), i18n.T("LT"), i18n.T("HT"), i18n.T("Peak"))
+	for _, day := range detail.Days {
+		peak := ""
+		if day.Peak.Power > 0 {
+			peak = fmt.Sprintf("%5.2f kW %s", day.Peak.Power, day.Peak.Start.Format("15:04"))
+		}
+		fmt.Printf("%-10s %9.2f %9.2f %9.2f %9.2f %9.2f %9.2f %15s\n", day.Date.Format("2006-01-02"),
+			day.Total/1000, day.Solar/1000, day.Battery/1000, day.Grid/1000,
+			day.LowTariff/1000, day.HighTariff/1000, peak)
+	}
+	fmt.Printf("(kWh)\n\n")
+}
+
+// printEVChargers prints every charging session with its solar share
+func printEVChargers(chargers []analyzer.EVCharger) {
+	printHeading("EV Charging Sessions")
+	for _, charger := range chargers {
+		fmt.Printf("%s: %d %s, %.1f kWh, %.1f%% %s\n", charger.Name, len(charger.Sessions),
+			i18n.T("sessions"), charger.Energy/1000, charger.SolarShare, i18n.T("solar"))
+		for _, session := range charger.Sessions {
+			fmt.Printf("  %s - %s %9.1f kWh %6.1f%%\n", session.Start.Format("2006-01-02 15:04"),
+				session.End.Format("15:04"), session.Energy/1000, session.SolarShare)
+		}
+	}
+	fmt.Printf("\n")
+}
+
+// printStandby prints the always-on baseline of every consumer
+func printStandby(loads []analyzer.StandbyLoad) {
+	printHeading("Standby Load")
+	for _, load := range loads {
+		fmt.Printf("%-22s %8.3f kW  %8.1f %s\n", load.Name+":", load.Power, load.AnnualKWh, i18n.T("kWh per year"))
+	}
+	fmt.Printf("\n")
+}
+
+// printProfile prints the typical day as hourly averages; the JSON output
+// has the full quarter-hour resolution
+func printProfile(profile *analyzer.LoadProfile) {
+	printHeading("Daily Load Profile (kW)")
+	fmt.Printf("%-5s %10s %10s", i18n.T("Hour"), truncate(i18n.T("Production"), 10), truncate(i18n.T("ZEV"), 10))
+	for _, consumer := range profile.Consumers {
+		name := consumer.Name
+		if consumer.ID == "" {
+			name = i18n.T(name)
+		}
+		fmt.Printf(" %10s", truncate(name, 10))
+	}
+	fmt.Printf("\n")
+	const perHour = analyzer.ProfileSlots / 24
+	hourly := func(values *[analyzer.ProfileSlots]float64, hour int) float64 {
+		var sum float64
+		for _, v := range values[hour*perHour : (hour+1)*perHour] {
+			sum += v
+		}
+		return sum / perHour
+	}
+	for hour := 0; hour < 24; hour++ {
+		fmt.Printf("%02d:00 %10.2f %10.2f", hour, hourly(&profile.Production, hour), hourly(&profile.Consumption, hour))
+		for i := range profile.Consumers {
+			fmt.Printf(" %10.2f", hourly(&profile.Consumers[i].Power, hour))
+		}
+		fmt.Printf("\n")
+	}
+	fmt.Printf("\n")
+}
+
+// truncate shortens s to at most n runes
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// printPeaks prints the highest grid import intervals and the monthly maxima
+func printPeaks(peaks *analyzer.PeakReport) {
+	printHeading("Grid Import Peaks")
+	if peaks.Resolution > 0 {
+		fmt.Printf(i18n.T("Resolution: %d seconds")+"\n", peaks.Resolution)
+	}
+	for _, peak := range peaks.Top {
+		fmt.Printf("%-16s %8.2f kW\n", peak.Start.Format(peakLayout(peaks)), peak.Power)
+	}
+	fmt.Printf("\n")
+	printHeading("Monthly Maximum")
+	for _, peak := range peaks.Monthly {
+		fmt.Printf("%-7s %8.2f kW  %s\n", peak.Start.Format("2006-01"), peak.Power, peak.Start.Format(peakLayout(peaks)))
+	}
+	fmt.Printf("\n")
+}
+
+// peakLayout shows the seconds of peaks finer than a minute
+func peakLayout(peaks *analyzer.PeakReport) string {
+	if peaks.Resolution > 0 && peaks.Resolution%60 != 0 {
+		return "2006-01-02 15:04:05"
+	}
+	return "2006-01-02 15:04"
+}
+
+// printPeakShaving prints per peak level the battery and load shifting
+// needed to stay below it in every month
+func printPeakShaving(report *analyzer.PeakShavingReport) {
+	for _, level := range report.Levels {
+		printHeading(fmt.Sprintf("%s %.1f kW", i18n.T("Peak Shaving to"), level.Level))
+		fmt.Printf("%-7s %11s %11s %13s %13s", i18n.T("Month"), i18n.T("Peak"), i18n.T("Power"), i18n.T("Capacity"), i18n.T("Shift"))
+		if report.Currency != "" {
+			fmt.Printf(" %12s", i18n.T("Savings"))
+		}
+		fmt.Printf("\n")
+		for _, month := range level.Months {
+			fmt.Printf("%-7s %8.2f kW %8.2f kW %9.1f kWh %9.1f kWh", month.Start.Format("2006-01"),
+				month.Peak, month.Power, month.Capacity, month.Shifted)
+			if report.Currency != "" {
+				fmt.Printf(" %8.2f %s", month.Savings, report.Currency)
+			}
+			fmt.Printf("\n")
+		}
+		fmt.Printf("%-7s %11s %8.2f kW %9.1f kWh %9.1f kWh", i18n.T("Total"), "", level.Power, level.Capacity, level.Shifted)
+		if report.Currency != "" {
+			fmt.Printf(" %8.2f %s", level.Savings, report.Currency)
+		}
+		fmt.Printf("\n\n")
+	}
+}
+
+// printSpotValuation prints the grid exchange and each consumer's grid
+// energy valued at the spot price of its interval
+func printSpotValuation(stats *analyzer.EnergyStats, currency string) {
+	printHeading("Spot Price Valuation")
+	fmt.Printf("%-22s %10.2f %s\n", i18n.T("Grid Import Cost")+":", stats.GridImportCost, currency)
+	fmt.Printf("%-22s %10.2f %s\n", i18n.T("Grid Export Revenue")+":", stats.GridExportRevenue, currency)
+	if stats.GridImport > 0 {
+		fmt.Printf("%-22s %10.4f %s/kWh\n", i18n.T("Average Import Price")+":", stats.GridImportCost/(stats.GridImport/1000), currency)
+	}
+	fmt.Printf("\n")
+	for _, consumer := range stats.Consumers {
+		fmt.Printf("%-22s %10.2f %s\n", displayName(&consumer)+":", consumer.GridCost, currency)
+	}
+	if stats.UnpricedIntervals > 0 {
+		fmt.Printf("\n"+i18n.T("Warning: %d intervals without spot price were not valued")+"\n", stats.UnpricedIntervals)
+	}
+	fmt.Printf("\n")
+}
+
+// printHeading prints a translated section heading with an underline
+func printHeading(heading string) {
+	heading = i18n.T(heading) + ":"
+	fmt.Printf("%s\n%s\n", heading, strings.Repeat("-", utf8.RuneCountInString(heading)-1))
+}
+
+// printValue prints a translated label followed by a value and its unit
+func printValue(label string, value float64, unit string) {
+	fmt.Printf("%-22s %8.1f %s\n", i18n.T(label)+":", value, unit)
+}
+
+// displayName returns the consumer name, translating synthetic consumers
+// such as "Shared Usage" whose names are not user data
+func displayName(consumer *analyzer.ConsumerStats) string {
+	if consumer.Sensor == nil || consumer.Sensor.ID == "" {
+		return i18n.T(consumer.Name())
+	}
+	return consumer.Name()
+}
+
+func printEnergyStats(stats *analyzer.EnergyStats) {
+
+	printHeading("System Overview")
+	printValue("Grid Import", stats.GridImport/1000, "kWh")
+	printValue("Grid Export", stats.GridExport/1000, "kWh")
+	if stats.NetMetered {
+		printValue("Net Import", stats.NetImport/1000, "kWh")
+		printValue("Net Export", stats.NetExport/1000, "kWh")
+	}
+	printValue("Production", stats.Production/1000, "kWh")
+	printValue("Consumption", stats.Consumption/1000, "kWh")
+	printValue("Battery Charge", stats.BatteryCharge/1000, "kWh")
+	printValue("  from Grid", stats.BatteryChargeFromGrid/1000, "kWh")
+	printValue("Battery Discharge", stats.BatteryDischarge/1000, "kWh")
+	printValue("Self Consumption", stats.SelfConsumptionRate(), "%")
+	printValue("Autarchy", stats.AutarchyRate(), "%")
+
+	fmt.Printf("\n")
+	printHeading("Energy Balance")
+	totalInput := stats.GridImport + stats.Production
+	totalOutput := stats.GridExport + stats.Consumption
+	for _, consumer := range stats.Consumers {
+		if consumer.Sensor.Tag.Name != "Unaccounted Energy" {
+			totalOutput += consumer.Total
+		}
+	}
+	printValue("Total Input", totalInput/1000, "kWh")
+	printValue("Total Output", totalOutput/1000, "kWh")
+	printValue("Difference", (totalInput-totalOutput)/1000, "kWh")
+
+	fmt.Printf("\n")
+	printHeading("Consumer Details")
+	fmt.Printf("%-15s %13s %13s %13s %13s %7s %7s %7s %7s\n",
+		i18n.T("Name"), i18n.T("Total"), i18n.T("Inverter"), i18n.T("Battery"), i18n.T("Grid"),
+		i18n.T("Share"), i18n.T("Solar"), i18n.T("Batt."), i18n.T("Grid"))
+	fmt.Printf("%s\n", strings.Repeat("-", 103))
+
+	var total analyzer.ConsumerStats
+	for _, consumer := range stats.Consumers {
+		total.Total += consumer.Total
+		total.Sources.FromInverter += consumer.Sources.FromInverter
+		total.Sources.FromBattery += consumer.Sources.FromBattery
+		total.Sources.FromGrid += consumer.Sources.FromGrid
+	}
+
+	for _, consumer := range stats.Consumers {
+		printConsumerRow(displayName(&consumer), &consumer, total.Total)
+	}
+	fmt.Printf("%s\n", strings.Repeat("-", 103))
+	printConsumerRow(i18n.T("Total"), &total, total.Total)
+	if stats.SharedStrategy != "" {
+		fmt.Printf("%s\n", i18n.T("Shared usage attributed per interval in proportion to consumption"))
+	}
+	if stats.SharedAllocation != "" {
+		fmt.Printf("%s: %s\n", i18n.T("Shared usage allocated by"), i18n.T(stats.SharedAllocation))
+	}
+	fmt.Printf("\n")
+
+	if total.Sources.FromBattery > 0 {
+		printHeading("Battery Energy by Origin")
+		fmt.Printf("%-15s %13s %13s\n", i18n.T("Name"), i18n.T("Solar"), i18n.T("Grid"))
+		for _, consumer := range stats.Consumers {
+			fmt.Printf("%-15s %9.1f kWh %9.1f kWh\n", displayName(&consumer),
+				consumer.Sources.FromBatterySolar/1000, consumer.Sources.FromBatteryGrid/1000)
+		}
+		fmt.Printf("\n")
+	}
+
+	printHeading("Solar Coverage per Consumer")
+	fmt.Printf("%-15s %9s %18s\n", i18n.T("Name"), i18n.T("Autarchy"), i18n.T("Production Share"))
+	for _, consumer := range stats.Consumers {
+		fmt.Printf("%-15s %8.1f%% %17.1f%%\n", displayName(&consumer),
+			consumer.AutarchyRate(), consumer.SelfConsumptionRate(stats.Production))
+	}
+	fmt.Printf("\n")
+}
+
+// printConsumerRow prints the kWh values of a consumer followed by its share of
+// the total consumption and the percentage of each source in its own usage
+func printConsumerRow(name string, consumer *analyzer.ConsumerStats, total float64) {
+	fmt.Printf("%-15s %9.1f kWh %9.1f kWh %9.1f kWh %9.1f kWh %6.1f%% %6.1f%% %6.1f%% %6.1f%%\n",
+		name,
+		consumer.Total/1000,
+		consumer.Sources.FromInverter/1000,
+		consumer.Sources.FromBattery/1000,
+		consumer.Sources.FromGrid/1000,
+		percent(consumer.Total, total),
+		percent(consumer.Sources.FromInverter, consumer.Total),
+		percent(consumer.Sources.FromBattery, consumer.Total),
+		percent(consumer.Sources.FromGrid, consumer.Total))
+}
+
+// percent returns part as percentage of whole, or 0 if whole is not positive
+func percent(part, whole float64) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return part / whole * 100
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// periodFlags holds the command line selectors for the analysis period
+type periodFlags struct {
+	from  string
+	to    string
+	days  int
+	month string // YYYY-MM
+	year  string // YYYY
+	week  string // ISO week, YYYY-Www
+	// billing period starting in YYYY-MM on billingStartDay
+	period          string
+	billingStartDay int
+}
+
+// resolvePeriod turns the period selectors into local time boundaries.
+// The end is the last instant of the last day of the period.
+func resolvePeriod(p periodFlags, now time.Time) (time.Time, time.Time, error) {
+	selectors := 0
+	for _, set := range []bool{p.from != "" || p.to != "", p.month != "", p.year != "", p.week != "", p.period != ""} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors > 1 {
+		return time.Time{}, time.Time{}, fmt.Errorf("only one of -from/-to, -month, -year, -week and -period may be used")
+	}
+
+	switch {
+	case p.from != "" && p.to != "":
+		from, err := parseDate(p.from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start date: %v", err)
+		}
+		to, err := parseDate(p.to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end date: %v", err)
+		}
+		return startOfDay(from), endOfDay(to), nil
+
+	case p.from != "" || p.to != "":
+		return time.Time{}, time.Time{}, fmt.Errorf("-from and -to must be used together")
+
+	case p.month != "":
+		month, err := time.ParseInLocation("2006-01", p.month, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid month %q, please use YYYY-MM", p.month)
+		}
+		// Day 0 of the following month is the last day of this month
+		last := time.Date(month.Year(), month.Month()+1, 0, 0, 0, 0, 0, time.Local)
+		return month, endOfDay(last), nil
+
+	case p.year != "":
+		year, err := strconv.Atoi(p.year)
+		if err != nil || year < 1000 || year > 9999 {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid year %q, please use YYYY", p.year)
+		}
+		return time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local),
+			endOfDay(time.Date(year, time.December, 31, 0, 0, 0, 0, time.Local)), nil
+
+	case p.week != "":
+		monday, err := parseISOWeek(p.week)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		return monday, endOfDay(monday.AddDate(0, 0, 6)), nil
+
+	case p.period != "":
+		month, err := time.ParseInLocation("2006-01", p.period, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid billing period %q, please use YYYY-MM", p.period)
+		}
+		startDay := max(p.billingStartDay, 1)
+		start := time.Date(month.Year(), month.Month(), startDay, 0, 0, 0, 0, time.Local)
+		// The period ends the day before the start day of the following month
+		return start, endOfDay(start.AddDate(0, 1, -1)), nil
+
+	case p.days > 0:
+		to := endOfDay(now)
+		return startOfDay(now).AddDate(0, 0, -p.days+1), to, nil
+	}
+
+	// Default to current day
+	return startOfDay(now), endOfDay(now), nil
+}
+
+// resolveYearRange turns a year range like "2022-2024" (or a single year)
+// into the period from January 1st of the first year to the end of the last
+// year, cut off at the end of today
+func resolveYearRange(s string, now time.Time) (time.Time, time.Time, error) {
+	firstStr, lastStr, found := strings.Cut(s, "-")
+	if !found {
+		lastStr = firstStr
+	}
+	first, err1 := strconv.Atoi(firstStr)
+	last, err2 := strconv.Atoi(lastStr)
+	if err1 != nil || err2 != nil || first < 1000 || last > 9999 || first > last {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid year range %q, please use YYYY-YYYY", s)
+	}
+	from := time.Date(first, time.January, 1, 0, 0, 0, 0, time.Local)
+	to := endOfDay(time.Date(last, time.December, 31, 0, 0, 0, 0, time.Local))
+	if to.After(endOfDay(now)) {
+		to = endOfDay(now)
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("year range %q lies in the future", s)
+	}
+	return from, to, nil
+}
+
+// parseISOWeek returns the Monday of an ISO 8601 week given as YYYY-Www
+func parseISOWeek(s string) (time.Time, error) {
+	var year, week int
+	if _, err := fmt.Sscanf(s, "%4d-W%2d", &year, &week); err != nil || len(s) != 8 {
+		return time.Time{}, fmt.Errorf("invalid week %q, please use YYYY-Www (e.g. 2024-W32)", s)
+	}
+
+	// January 4th is always in week 1; go back to the Monday of that week
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.Local)
+	offset := (int(jan4.Weekday()) + 6) % 7
+	monday := jan4.AddDate(0, 0, -offset+(week-1)*7)
+
+	// Reject week 53 in years with only 52 weeks (and week 0)
+	if y, w := monday.ISOWeek(); y != year || w != week {
+		return time.Time{}, fmt.Errorf("invalid week %q: %d has no week %d", s, year, week)
+	}
+	return monday, nil
+}
+
+// startOfDay returns midnight of the given day in local time
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// endOfDay returns the last instant of the given day in local time
+func endOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 999999999, time.Local)
+}
+
+func parseDate(dateStr string) (time.Time, error) {
+	// Try different date formats
+	formats := []string{
+		"2006-01-02",
+		"02.01.2006",
+		"02.01.06",
+	}
+
+	var parseErr error
+	for _, format := range formats {
+		t, err := time.ParseInLocation(format, dateStr, time.Local)
+		if err == nil {
+			return t, nil
+		}
+		parseErr = err
+	}
+	return time.Time{}, fmt.Errorf("invalid date format, please use YYYY-MM-DD or DD.MM.YYYY: %v", parseErr)
+}
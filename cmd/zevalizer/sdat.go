@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/anonymize"
+	"zevalizer/internal/config"
+	"zevalizer/internal/report"
+)
+
+// Metering points of the whole ZEV in the sdat config, besides the consumers
+const (
+	sdatGridImport = "grid-import"
+	sdatGridExport = "grid-export"
+	sdatProduction = "production"
+)
+
+// writeSDAT writes the 15-minute load profile of every configured metering
+// point as an SDAT file into dir, named after the metering point and the
+// period. Consumers that keep rejects are left out, the metering points of
+// the whole ZEV are always written.
+func writeSDAT(dir string, cfg *config.Config, ea *analyzer.EnergyAnalyzer, keep func(id string) bool, anonymized bool) error {
+	known := map[string]bool{sdatGridImport: true, sdatGridExport: true, sdatProduction: true}
+	for _, id := range ea.ConsumerIDs() {
+		known[id] = true
+	}
+	var sources []string
+	for source := range cfg.SDAT.MeteringPoints {
+		if !known[source] {
+			return fmt.Errorf("%w: sdat: %s is neither a reported consumer nor %s, %s or %s",
+				config.ErrInvalid, source, sdatGridImport, sdatGridExport, sdatProduction)
+		}
+		if source == sdatGridImport || source == sdatGridExport || source == sdatProduction || keep(source) {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+
+	intervals := ea.Intervals()
+	if len(intervals) == 0 {
+		return fmt.Errorf("no intervals to export")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating sdat directory: %v", err)
+	}
+	start, end := intervals[0].Start, intervals[len(intervals)-1].End
+	created := time.Now()
+	for _, source := range sources {
+		profile := report.SDATProfile{MeteringPoint: cfg.SDAT.MeteringPoints[source], Start: start}
+		if anonymized {
+			profile.MeteringPoint = anonymize.ID(profile.MeteringPoint)
+		}
+		for _, interval := range intervals {
+			var wh float64
+			switch source {
+			case sdatGridImport:
+				wh = interval.GridImport
+			case sdatGridExport:
+				wh = interval.GridExport
+			case sdatProduction:
+				wh = interval.InverterGeneratedPower
+			default:
+				wh = interval.ConsumerUsage[source]
+			}
+			profile.Values = append(profile.Values, wh/1000)
+		}
+		documentID := fmt.Sprintf("%s_%s_%s", profile.MeteringPoint,
+			start.UTC().Format("200601021504"), end.UTC().Format("200601021504"))
+		header := report.SDATHeader{Sender: cfg.SDAT.Sender, Receiver: cfg.SDAT.Receiver, DocumentID: documentID, Created: created}
+
+		file, err := os.Create(filepath.Join(dir, documentID+".xml"))
+		if err != nil {
+			return err
+		}
+		if err := report.WriteSDAT(file, header, profile); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
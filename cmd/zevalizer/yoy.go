@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/config"
+	"zevalizer/internal/i18n"
+)
+
+// yoyMetric is one table of the year-over-year report
+type yoyMetric struct {
+	label string
+	unit  string
+	value func(*analyzer.EnergyStats) float64
+}
+
+var yoyMetrics = []yoyMetric{
+	{"Production", "kWh", func(s *analyzer.EnergyStats) float64 { return s.Production / 1000 }},
+	{"Consumers", "kWh", func(s *analyzer.EnergyStats) float64 { return consumerTotal(s) / 1000 }},
+	{"Grid Import", "kWh", func(s *analyzer.EnergyStats) float64 { return s.GridImport / 1000 }},
+	{"Grid Export", "kWh", func(s *analyzer.EnergyStats) float64 { return s.GridExport / 1000 }},
+	{"Autarchy", "%", (*analyzer.EnergyStats).AutarchyRate},
+}
+
+// consumerTotal sums the usage of all consumers including shared usage
+func consumerTotal(stats *analyzer.EnergyStats) float64 {
+	var total float64
+	for _, consumer := range stats.Consumers {
+		total += consumer.Total
+	}
+	return total
+}
+
+// yearOverYear analyzes [from, to] once and reports each calendar month
+// side by side for all years of the period
+func yearOverYear(ctx context.Context, client analyzer.DataFetcher, cfg *config.Config, smId string, from, to time.Time, opts reportOptions) error {
+	energyAnalyzer := analyzer.NewEnergyAnalyzer(client, cfg)
+	if _, _, err := energyAnalyzer.Analyze(ctx, smId, from, to); err != nil {
+		return fmt.Errorf("analyzing energy data: %w", err)
+	}
+	monthly, err := energyAnalyzer.MonthlyStats(from, to)
+	if err != nil {
+		return fmt.Errorf("aggregating energy data: %w", err)
+	}
+	if opts.anonymize {
+		for _, stats := range monthly {
+			anonymizeStats(stats)
+		}
+	}
+	yoy := analyzer.GroupByMonth(monthly)
+
+	if opts.format == formatJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(yoy); err != nil {
+			return fmt.Errorf("encoding json: %v", err)
+		}
+		return nil
+	}
+	printYearOverYear(yoy)
+	return nil
+}
+
+// printYearOverYear prints one table per metric with a row per calendar
+// month and a column per year
+func printYearOverYear(yoy *analyzer.YearOverYear) {
+	fmt.Printf("\n%s\n\n", i18n.T("Year over Year"))
+	width := 8 + 12*len(yoy.Years)
+	for _, metric := range yoyMetrics {
+		printHeading(metric.label)
+		fmt.Printf("%-8s", i18n.T("Month"))
+		for _, year := range yoy.Years {
+			fmt.Printf(" %11d", year)
+		}
+		fmt.Printf("\n%s\n", strings.Repeat("-", width))
+		for m, years := range yoy.Months {
+			fmt.Printf("%-8s", fmt.Sprintf("%02d", m+1))
+			for _, stats := range years {
+				if stats == nil {
+					fmt.Printf(" %11s", "-")
+					continue
+				}
+				fmt.Printf(" %7.1f %-3s", metric.value(stats), metric.unit)
+			}
+			fmt.Printf("\n")
+		}
+		fmt.Printf("%s\n%-8s", strings.Repeat("-", width), i18n.T("Total"))
+		for i := range yoy.Years {
+			fmt.Printf(" %7.1f %-3s", metric.value(yoy.Year(i)), metric.unit)
+		}
+		fmt.Printf("\n\n")
+	}
+}
@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"zevalizer/internal/config"
+	"zevalizer/internal/models"
+)
+
+// validateAliases rejects aliases pointing to themselves or to another alias
+func (ea *EnergyAnalyzer) validateAliases() error {
+	aliases := ea.config.ZEV.Aliases
+	for old, current := range aliases {
+		if old == current || current == "" {
+			return fmt.Errorf("%w: aliases: sensor %s must map to a different sensor", config.ErrInvalid, old)
+		}
+		if _, chained := aliases[current]; chained {
+			return fmt.Errorf("%w: aliases: %s maps to %s which is an alias itself", config.ErrInvalid, old, current)
+		}
+	}
+	return nil
+}
+
+// aliasesOf returns the former IDs of a sensor, sorted
+func (ea *EnergyAnalyzer) aliasesOf(id string) []string {
+	var old []string
+	for alias, current := range ea.config.ZEV.Aliases {
+		if current == id {
+			old = append(old, alias)
+		}
+	}
+	sort.Strings(old)
+	return old
+}
+
+// resolveAliases joins the data of former sensor IDs into that of the
+// current ID, so a replaced meter is one logical meter over time
+func (ea *EnergyAnalyzer) resolveAliases(zevData []models.ZevData, sensorData map[string][]models.SensorData) ([]models.ZevData, map[string][]models.SensorData) {
+	aliases := ea.config.ZEV.Aliases
+	if len(aliases) == 0 {
+		return zevData, sensorData
+	}
+
+	segments := make(map[string][][]models.ZevSensorData)
+	var order []string
+	for _, sd := range zevData {
+		id := sd.SensorID
+		if current, ok := aliases[id]; ok {
+			id = current
+		}
+		if _, ok := segments[id]; !ok {
+			order = append(order, id)
+		}
+		segments[id] = append(segments[id], sd.Data)
+	}
+	joined := make([]models.ZevData, 0, len(order))
+	for _, id := range order {
+		joined = append(joined, models.ZevData{
+			SensorID: id,
+			Data: joinSegments(segments[id], func(d models.ZevSensorData) time.Time {
+				return d.CreatedAt
+			}),
+		})
+	}
+
+	for old, current := range aliases {
+		data, ok := sensorData[old]
+		if !ok {
+			continue
+		}
+		sensorData[current] = joinSegments([][]models.SensorData{sensorData[current], data},
+			func(d models.SensorData) time.Time { return d.Date })
+		delete(sensorData, old)
+	}
+	return joined, sensorData
+}
+
+// joinSegments concatenates the readings of several meters that measured
+// the same thing one after the other. Segments are ordered by their first
+// reading; readings of an earlier meter from the time its successor started
+// reporting on are dropped. The jump between the last counter value of the
+// old meter and the first of the new one is left to the counter reset and
+// abnormal reading checks, so at most one reading is lost at the swap.
+func joinSegments[T any](segments [][]T, at func(T) time.Time) []T {
+	var nonEmpty [][]T
+	for _, segment := range segments {
+		if len(segment) > 0 {
+			nonEmpty = append(nonEmpty, segment)
+		}
+	}
+	if len(nonEmpty) == 1 {
+		return nonEmpty[0]
+	}
+	sort.SliceStable(nonEmpty, func(i, j int) bool {
+		return at(nonEmpty[i][0]).Before(at(nonEmpty[j][0]))
+	})
+	var joined []T
+	for i, segment := range nonEmpty {
+		for _, reading := range segment {
+			if i+1 < len(nonEmpty) && !at(reading).Before(at(nonEmpty[i+1][0])) {
+				break
+			}
+			joined = append(joined, reading)
+		}
+	}
+	return joined
+}
@@ -0,0 +1,117 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"zevalizer/internal/config"
+)
+
+// validateAllocation checks the strategy and allocation key of the shared usage
+func (ea *EnergyAnalyzer) validateAllocation() error {
+	switch strategy := ea.config.ZEV.SharedStrategy; strategy {
+	case "", config.SharedStrategyResidual, config.SharedStrategyProportional:
+	default:
+		return fmt.Errorf("%w: sharedStrategy %q must be %s or %s", config.ErrInvalid, strategy,
+			config.SharedStrategyResidual, config.SharedStrategyProportional)
+	}
+
+	alloc := ea.config.ZEV.SharedAllocation
+	return ea.validateKey("sharedAllocation", alloc.Key, alloc.Weights)
+}
+
+// validateKey checks an allocation key and the weights it needs, named
+// setting in the errors. An empty key passes.
+func (ea *EnergyAnalyzer) validateKey(setting, key string, weights map[string]float64) error {
+	switch key {
+	case "", config.AllocationEqual, config.AllocationConsumption:
+		return nil
+	case config.AllocationQuota, config.AllocationArea:
+	default:
+		return fmt.Errorf("%w: %s key %q must be %s, %s, %s or %s", config.ErrInvalid, setting, key,
+			config.AllocationEqual, config.AllocationQuota, config.AllocationConsumption, config.AllocationArea)
+	}
+
+	known := make(map[string]bool)
+	for _, id := range ea.ConsumerIDs() {
+		known[id] = true
+	}
+	var sum float64
+	for id, weight := range weights {
+		if !known[id] {
+			return fmt.Errorf("%w: %s weight for %s, which is not a reported consumer", config.ErrInvalid, setting, id)
+		}
+		if weight < 0 {
+			return fmt.Errorf("%w: %s weight for %s must not be negative", config.ErrInvalid, setting, id)
+		}
+		sum += weight
+	}
+	if sum <= 0 {
+		return fmt.Errorf("%w: %s key %s needs weights per consumer", config.ErrInvalid, setting, key)
+	}
+	return nil
+}
+
+// sharedScale returns the factor by which the consumers' usage of an
+// interval is raised to include the shared usage with the proportional
+// strategy, or 1 if the shared usage stays a consumer of its own. Intervals
+// without metered consumption keep their shared usage.
+func (ea *EnergyAnalyzer) sharedScale(shared, consumption float64) float64 {
+	if ea.config.ZEV.SharedStrategy != config.SharedStrategyProportional || shared <= 0 || consumption <= 0 {
+		return 1
+	}
+	return (consumption + shared) / consumption
+}
+
+// allocateShared distributes the shared usage of the stats onto the real
+// consumers with the configured allocation key. Each consumer receives its
+// part of the shared total and of every source, and the shared consumer is
+// removed. Without a key, or when no consumer has a weight (e.g. no
+// consumption), the shared usage stays a consumer of its own.
+func (ea *EnergyAnalyzer) allocateShared(stats *EnergyStats) {
+	alloc := ea.config.ZEV.SharedAllocation
+	if alloc.Key == "" {
+		return
+	}
+	var shared *ConsumerStats
+	weights := make([]float64, len(stats.Consumers))
+	var sum float64
+	for i := range stats.Consumers {
+		consumer := &stats.Consumers[i]
+		if synthetic(consumer) {
+			shared = consumer
+			continue
+		}
+		switch alloc.Key {
+		case config.AllocationEqual:
+			weights[i] = 1
+		case config.AllocationConsumption:
+			weights[i] = consumer.Total
+		default:
+			weights[i] = alloc.Weights[consumer.Sensor.ID]
+		}
+		sum += weights[i]
+	}
+	if shared == nil || sum <= 0 {
+		return
+	}
+
+	kept := stats.Consumers[:0]
+	for i, consumer := range stats.Consumers {
+		if synthetic(&consumer) {
+			continue
+		}
+		part := weights[i] / sum
+		consumer.sharedPart = part
+		consumer.SharedAllocated += shared.Total * part
+		consumer.Total += shared.Total * part
+		consumer.Sources.FromInverter += shared.Sources.FromInverter * part
+		consumer.Sources.FromBattery += shared.Sources.FromBattery * part
+		consumer.Sources.FromBatterySolar += shared.Sources.FromBatterySolar * part
+		consumer.Sources.FromBatteryGrid += shared.Sources.FromBatteryGrid * part
+		consumer.Sources.FromGrid += shared.Sources.FromGrid * part
+		consumer.GridCost += shared.GridCost * part
+		kept = append(kept, consumer)
+	}
+	stats.Consumers = kept
+	stats.SharedAllocation = alloc.Key
+}
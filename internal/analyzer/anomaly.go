@@ -0,0 +1,186 @@
+package analyzer
+
+import (
+	"sort"
+	"time"
+
+	"zevalizer/internal/models"
+)
+
+// Kinds of anomalies
+const (
+	AnomalyNegativeBalance   = "negative-balance"    // consumers use more than came in, for a while
+	AnomalyStuckAtZero       = "stuck-at-zero"       // a consumer meter reads zero for a day or longer
+	AnomalyDuplicateReadings = "duplicate-readings"  // a sensor delivered several readings for the same time
+	AnomalyChargeWhileExport = "charge-while-export" // the battery charges from the grid while the ZEV exports
+)
+
+// Severities of anomalies, in increasing order
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// severityRank orders severities for sorting
+var severityRank = map[string]int{SeverityInfo: 0, SeverityWarning: 1, SeverityCritical: 2}
+
+// Minimum number of consecutive intervals before a pattern is reported
+const (
+	minNegativeBalanceIntervals = 4  // one hour
+	minStuckIntervals           = 96 // one day
+	minChargeExportIntervals    = 4  // one hour
+	criticalIntervals           = 96 // runs of a day and longer are critical
+)
+
+// Anomaly is a suspicious pattern in the meter data, covering Count
+// intervals (or readings, for duplicates) between Start and End
+type Anomaly struct {
+	Kind     string    `json:"kind"`
+	Severity string    `json:"severity"`
+	SensorID string    `json:"sensorId,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Count    int       `json:"count"`
+}
+
+// Anomalies returns the anomalies found in the last analysis, the most
+// severe first and in time order within a severity
+func (ea *EnergyAnalyzer) Anomalies() []Anomaly {
+	anomalies := append([]Anomaly{}, ea.anomalies...)
+	for i, anomaly := range anomalies {
+		if anomaly.SensorID == "" {
+			continue
+		}
+		anomalies[i].Name = anomaly.SensorID
+		if sensor := ea.sensorMap[anomaly.SensorID]; sensor != nil && sensor.Tag.Name != "" {
+			anomalies[i].Name = sensor.Tag.Name
+		}
+	}
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		if severityRank[anomalies[i].Severity] != severityRank[anomalies[j].Severity] {
+			return severityRank[anomalies[i].Severity] > severityRank[anomalies[j].Severity]
+		}
+		if !anomalies[i].Start.Equal(anomalies[j].Start) {
+			return anomalies[i].Start.Before(anomalies[j].Start)
+		}
+		return anomalies[i].SensorID < anomalies[j].SensorID
+	})
+	return anomalies
+}
+
+// scanDuplicates reports sensors that delivered more than one reading for
+// the same time, a warning when the readings differ and for information
+// when they are identical
+func (ea *EnergyAnalyzer) scanDuplicates(data []models.ZevData, sensorData map[string][]models.SensorData) {
+	for _, sensor := range data {
+		seen := make(map[time.Time]models.ZevSensorData, len(sensor.Data))
+		var duplicate Anomaly
+		for _, reading := range sensor.Data {
+			previous, ok := seen[reading.CreatedAt]
+			if ok {
+				duplicate.add(reading.CreatedAt, previous != reading)
+			}
+			seen[reading.CreatedAt] = reading
+		}
+		ea.addDuplicates(sensor.SensorID, duplicate)
+	}
+	for id, readings := range sensorData {
+		seen := make(map[time.Time]models.SensorData, len(readings))
+		var duplicate Anomaly
+		for _, reading := range readings {
+			previous, ok := seen[reading.Date]
+			if ok {
+				duplicate.add(reading.Date, !sameSensorReading(previous, reading))
+			}
+			seen[reading.Date] = reading
+		}
+		ea.addDuplicates(id, duplicate)
+	}
+}
+
+// add counts one duplicate reading at t, raising the severity when it
+// conflicts with the earlier one
+func (a *Anomaly) add(t time.Time, conflicting bool) {
+	if a.Count == 0 || t.Before(a.Start) {
+		a.Start = t
+	}
+	if t.After(a.End) {
+		a.End = t
+	}
+	a.Count++
+	if conflicting {
+		a.Severity = SeverityWarning
+	} else if a.Severity == "" {
+		a.Severity = SeverityInfo
+	}
+}
+
+// addDuplicates records the duplicates found for a sensor, if any
+func (ea *EnergyAnalyzer) addDuplicates(sensorID string, duplicate Anomaly) {
+	if duplicate.Count == 0 {
+		return
+	}
+	duplicate.Kind = AnomalyDuplicateReadings
+	duplicate.SensorID = sensorID
+	ea.anomalies = append(ea.anomalies, duplicate)
+}
+
+// sameSensorReading compares two readings including the state of charge
+func sameSensorReading(a, b models.SensorData) bool {
+	socA, socB := a.SOC, b.SOC
+	a.SOC, b.SOC = nil, nil
+	return a == b && (socA == nil) == (socB == nil) && (socA == nil || *socA == *socB)
+}
+
+// scanAnomalies looks for suspicious patterns in the intervals of the
+// current range. Like checkBalance it must run before the stats add the
+// shared residual.
+func (ea *EnergyAnalyzer) scanAnomalies() {
+	validation := ea.config.Validation
+	ea.scanRuns(AnomalyNegativeBalance, "", minNegativeBalanceIntervals, criticalIntervals, func(index int, interval *IntervalData) bool {
+		input, output := intervalBalance(interval)
+		return output-input > validation.Tolerance(max(input, output))
+	})
+	ea.scanRuns(AnomalyChargeWhileExport, "", minChargeExportIntervals, criticalIntervals, func(index int, interval *IntervalData) bool {
+		return interval.BatteryChargeFromGrid > 0 && interval.GridExport > 0
+	})
+	for _, id := range ea.config.ZEV.ConsumerIDs {
+		covered := ea.coverage[id]
+		usage := ea.raw[rawKey{id, RoleConsumer, FlowUsage}]
+		// a meter reading zero over the whole range is critical
+		ea.scanRuns(AnomalyStuckAtZero, id, minStuckIntervals, len(ea.intervals), func(index int, interval *IntervalData) bool {
+			return covered != nil && covered[index] && (usage == nil || usage[index] == 0)
+		})
+	}
+}
+
+// scanRuns records an anomaly for every run of at least minLength
+// consecutive intervals matching, a critical one from criticalLength on
+func (ea *EnergyAnalyzer) scanRuns(kind, sensorID string, minLength, criticalLength int, matching func(int, *IntervalData) bool) {
+	start := -1
+	for index := 0; index <= len(ea.intervals); index++ {
+		if index < len(ea.intervals) && matching(index, ea.intervals[index]) {
+			if start < 0 {
+				start = index
+			}
+			continue
+		}
+		if start >= 0 && index-start >= minLength {
+			severity := SeverityWarning
+			if index-start >= criticalLength {
+				severity = SeverityCritical
+			}
+			ea.anomalies = append(ea.anomalies, Anomaly{
+				Kind:     kind,
+				Severity: severity,
+				SensorID: sensorID,
+				Start:    ea.intervals[start].Start,
+				End:      ea.intervals[index-1].End,
+				Count:    index - start,
+			})
+		}
+		start = -1
+	}
+}
@@ -0,0 +1,85 @@
+package analyzer
+
+import "time"
+
+// AttributionRow records how the usage of one consumer in one interval was
+// attributed to the sources, with the inputs of the interval the split was
+// based on. The rows of an analysis reconstruct every consumer's totals.
+type AttributionRow struct {
+	Start      time.Time
+	End        time.Time
+	LowTariff  bool
+	ConsumerID string // SharedConsumerID for the unmetered shared usage
+
+	// Inputs of the interval
+	GridImport       float64
+	GridExport       float64
+	Production       float64 // inverter output, AC
+	BatteryDischarge float64 // DC, before the inverter efficiency
+	BatteryGridShare float64 // share of the discharge charged from the grid
+
+	// Usage read from the consumer's meter (the residual for the shared
+	// usage) and the shared usage added to it by the proportional strategy
+	Metered     float64
+	SharedAdded float64
+	// Usage split by source; the parts add up to Metered + SharedAdded
+	Sources Supply
+	// Share of the tariff's shared usage this consumer receives by the
+	// allocation key, 0 without a key
+	SharedPart float64
+}
+
+// Attribution returns a row per interval and consumer with any usage or
+// attributed energy in the last analysis. lowTariff and highTariff are the
+// stats that analysis returned, they carry the allocation of the shared
+// usage.
+func (ea *EnergyAnalyzer) Attribution(lowTariff, highTariff *EnergyStats) []AttributionRow {
+	parts := map[bool]map[string]float64{true: sharedParts(lowTariff), false: sharedParts(highTariff)}
+	ids := append(ea.ConsumerIDs(), SharedConsumerID)
+
+	var rows []AttributionRow
+	for _, interval := range ea.intervals {
+		low := ea.IsLowTariff(interval.Start)
+		for _, id := range ids {
+			metered := interval.ConsumerUsage[id]
+			sources, attributed := interval.ConsumerSources[id]
+			if metered == 0 && !attributed {
+				continue
+			}
+			row := AttributionRow{
+				Start:            interval.Start,
+				End:              interval.End,
+				LowTariff:        low,
+				ConsumerID:       id,
+				GridImport:       interval.GridImport,
+				GridExport:       interval.GridExport,
+				Production:       interval.InverterGeneratedPower,
+				BatteryDischarge: interval.BatteryDischarge,
+				BatteryGridShare: interval.BatteryGridShare,
+				Metered:          metered,
+				Sources:          sources,
+				SharedPart:       parts[low][id],
+			}
+			if attributed {
+				row.SharedAdded = sources.Total() - metered
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// sharedParts maps the consumer IDs of stats to their share of the shared
+// usage by the allocation key
+func sharedParts(stats *EnergyStats) map[string]float64 {
+	parts := make(map[string]float64)
+	if stats == nil {
+		return parts
+	}
+	for _, consumer := range stats.Consumers {
+		if !synthetic(&consumer) {
+			parts[consumer.Sensor.ID] = consumer.sharedPart
+		}
+	}
+	return parts
+}
@@ -0,0 +1,67 @@
+package analyzer
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrImbalance is returned by -validate when intervals violate the energy
+// balance tolerance
+var ErrImbalance = errors.New("energy balance outside tolerance")
+
+// BalanceViolation is an interval whose inputs and outputs do not match
+type BalanceViolation struct {
+	Start      time.Time `json:"start"`
+	Input      float64   `json:"inputWh"`      // grid import + inverter production
+	Output     float64   `json:"outputWh"`     // consumers + grid export + inverter consumption
+	Difference float64   `json:"differenceWh"` // input - output
+	Tolerance  float64   `json:"toleranceWh"`
+}
+
+// BalanceReport summarizes the per interval energy balance check
+type BalanceReport struct {
+	Checked    int                `json:"checked"`
+	Violations []BalanceViolation `json:"violations"`
+}
+
+// Balance returns the balance check of the last analysis
+func (ea *EnergyAnalyzer) Balance() *BalanceReport {
+	return &ea.balance
+}
+
+// checkBalance compares inputs and outputs of every interval of the current
+// range and adds violations to those of earlier pieces (see AnalyzeStream).
+// It must run before the stats add the shared residual.
+func (ea *EnergyAnalyzer) checkBalance() {
+	validation := ea.config.Validation
+	for _, interval := range ea.intervals {
+		input, output := intervalBalance(interval)
+		ea.balance.Checked++
+
+		difference := input - output
+		tolerance := validation.Tolerance(max(input, output))
+		if -difference > tolerance || (validation.CheckSurplus && difference > tolerance) {
+			ea.balance.Violations = append(ea.balance.Violations, BalanceViolation{
+				Start:      interval.Start,
+				Input:      input,
+				Output:     output,
+				Difference: difference,
+				Tolerance:  tolerance,
+			})
+		}
+	}
+}
+
+// intervalBalance returns the energy entering an interval (grid import and
+// inverter production) and leaving it (consumers without the shared usage,
+// grid export and inverter consumption)
+func intervalBalance(interval *IntervalData) (input, output float64) {
+	input = interval.GridImport + interval.InverterGeneratedPower
+	output = interval.GridExport + interval.InverterPowerConsumption
+	for consumerId, usage := range interval.ConsumerUsage {
+		if consumerId != SharedConsumerID {
+			output += usage
+		}
+	}
+	return input, output
+}
@@ -0,0 +1,46 @@
+package analyzer
+
+// BatteryStats holds the throughput of a single battery system
+type BatteryStats struct {
+	SensorID  string  `json:"sensorId"`
+	Name      string  `json:"name"`
+	Charge    float64 `json:"chargeWh"`
+	Discharge float64 `json:"dischargeWh"`
+	// Equivalent full cycles, from the state of charge if reported, else
+	// from the discharge and the configured capacity (0 if neither is known)
+	Cycles float64 `json:"cycles,omitempty"`
+}
+
+// Efficiency returns the discharged energy as percentage of the charged
+// energy. Over short periods a changed state of charge distorts it.
+func (bs *BatteryStats) Efficiency() float64 {
+	if bs.Charge <= 0 {
+		return 0
+	}
+	return bs.Discharge / bs.Charge * 100
+}
+
+// Batteries returns the statistics of every configured battery system in
+// the last analysis
+func (ea *EnergyAnalyzer) Batteries() []BatteryStats {
+	cycles := make(map[string]float64)
+	for _, summary := range ea.StateOfCharge() {
+		cycles[summary.SensorID] = summary.FullCycles
+	}
+
+	batteries := make([]BatteryStats, 0, len(ea.config.ZEV.BatterySystemIDs))
+	for _, id := range ea.config.ZEV.BatterySystemIDs {
+		throughput := ea.batteryThroughput[id]
+		stats := BatteryStats{SensorID: id, Name: id, Charge: throughput[0], Discharge: throughput[1]}
+		if sensor := ea.sensorMap[id]; sensor != nil && sensor.Tag.Name != "" {
+			stats.Name = sensor.Tag.Name
+		}
+		if c, ok := cycles[id]; ok {
+			stats.Cycles = c
+		} else if capacity := ea.config.ZEV.BatteryCapacityWh[id]; capacity > 0 {
+			stats.Cycles = stats.Discharge / capacity
+		}
+		batteries = append(batteries, stats)
+	}
+	return batteries
+}
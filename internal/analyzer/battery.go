@@ -0,0 +1,55 @@
+package analyzer
+
+// batteryPool tracks the origin of the energy stored in the batteries as a
+// mixing tank: every discharge takes solar and grid energy in proportion to
+// what the tank holds
+type batteryPool struct {
+	solar, grid float64
+	dayCharge   float64 // energy charged on the current day
+	capacity    float64 // most energy charged on a single day so far
+	day         int     // year*1000 + day of year of dayCharge
+}
+
+// trackBatteryOrigin splits the battery charge of every interval of the
+// current range into energy from PV and from the grid and records which
+// share of its discharge was charged from the grid. The pool carries over
+// to the next piece of a streamed analysis.
+//
+// A hybrid inverter charges from the grid only while it draws energy from
+// the AC side, i.e. while its NET production is negative. Everything else
+// is charged from PV. The tank never holds more than the most energy
+// charged on a single day, so old charges do not dilute recent ones forever
+// (charge and discharge losses are not measured separately).
+func (ea *EnergyAnalyzer) trackBatteryOrigin() {
+	pool := &ea.batteryPool
+	for _, interval := range ea.intervals {
+		if stored := pool.solar + pool.grid; stored > 0 {
+			share := pool.grid / stored
+			interval.BatteryGridShare = share
+			taken := min(interval.BatteryDischarge, stored)
+			pool.grid -= taken * share
+			pool.solar -= taken * (1 - share)
+		}
+
+		charge := interval.BatteryCharge
+		if charge <= 0 {
+			continue
+		}
+		fromGrid := min(charge, max(0, -interval.InverterGeneratedPower))
+		interval.BatteryChargeFromGrid = fromGrid
+		pool.grid += fromGrid
+		pool.solar += charge - fromGrid
+
+		day := interval.Start.Year()*1000 + interval.Start.YearDay()
+		if day != pool.day {
+			pool.day, pool.dayCharge = day, 0
+		}
+		pool.dayCharge += charge
+		pool.capacity = max(pool.capacity, pool.dayCharge)
+		if stored := pool.solar + pool.grid; stored > pool.capacity {
+			scale := pool.capacity / stored
+			pool.solar *= scale
+			pool.grid *= scale
+		}
+	}
+}
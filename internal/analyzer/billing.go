@@ -0,0 +1,343 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"zevalizer/internal/billing"
+	"zevalizer/internal/config"
+)
+
+// tenancy is the part [start, end) of a bill for a unit that a tenant
+// occupied, or that stood empty when tenant is empty
+type tenancy struct {
+	tenant     string
+	start, end time.Time
+}
+
+// validateTenants checks that tenants have names, live in the unit of a
+// reported consumer and do not overlap in the same unit
+func (ea *EnergyAnalyzer) validateTenants() error {
+	known := make(map[string]bool)
+	for _, id := range ea.ConsumerIDs() {
+		known[id] = true
+	}
+	units := make(map[string][]tenancy)
+	for _, tenant := range ea.config.ZEV.Tenants {
+		if tenant.Name == "" {
+			return fmt.Errorf("%w: tenants: every tenant needs a name", config.ErrInvalid)
+		}
+		if !known[tenant.Consumer] {
+			return fmt.Errorf("%w: tenants: consumer %s of %q is not a reported consumer", config.ErrInvalid, tenant.Consumer, tenant.Name)
+		}
+		start, end, err := parseTenancy(tenant, time.Local)
+		if err != nil {
+			return err
+		}
+		if !end.IsZero() && !end.After(start) {
+			return fmt.Errorf("%w: tenants: %q moves out before moving in", config.ErrInvalid, tenant.Name)
+		}
+		for _, other := range units[tenant.Consumer] {
+			if (end.IsZero() || end.After(other.start)) && (other.end.IsZero() || other.end.After(start)) {
+				return fmt.Errorf("%w: tenants: %q and %q occupy %s at the same time", config.ErrInvalid, other.tenant, tenant.Name, tenant.Consumer)
+			}
+		}
+		units[tenant.Consumer] = append(units[tenant.Consumer], tenancy{tenant.Name, start, end})
+	}
+	return nil
+}
+
+// validateBillingKeys checks the named allocation keys of the consumer
+// bills, the keys of the shared costs and the common areas
+func (ea *EnergyAnalyzer) validateBillingKeys() error {
+	prices := ea.config.Prices
+	named := make(map[string]bool)
+	for _, key := range prices.AllocationKeys {
+		setting := fmt.Sprintf("allocationKeys %q", key.Name)
+		if key.Key == "" {
+			return fmt.Errorf("%w: %s needs a key", config.ErrInvalid, setting)
+		}
+		if err := ea.validateKey(setting, key.Key, key.Weights); err != nil {
+			return err
+		}
+		named[key.Name] = true
+	}
+	for _, cost := range append(append([]config.SharedCostConfig{}, prices.Amortization...), prices.CommonCosts...) {
+		if named[cost.Key] {
+			continue
+		}
+		if err := ea.validateKey(fmt.Sprintf("cost %q", cost.Name), cost.Key, cost.Weights); err != nil {
+			return err
+		}
+	}
+	known := map[string]bool{SharedConsumerID: true}
+	for _, id := range ea.ConsumerIDs() {
+		known[id] = true
+	}
+	common := make(map[string]bool)
+	for _, area := range prices.CommonAreas {
+		if !known[area.Consumer] {
+			return fmt.Errorf("%w: commonAreas: %s is not a reported consumer", config.ErrInvalid, area.Consumer)
+		}
+		if common[area.Consumer] {
+			return fmt.Errorf("%w: commonAreas: %s is listed twice", config.ErrInvalid, area.Consumer)
+		}
+		common[area.Consumer] = true
+		if named[area.Key] {
+			continue
+		}
+		if err := ea.validateKey(fmt.Sprintf("commonAreas %s", area.Consumer), area.Key, area.Weights); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseTenancy returns the first moment of the move-in day and the first
+// moment after the move-out day, each zero when open
+func parseTenancy(tenant config.TenantConfig, location *time.Location) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if tenant.MoveIn != "" {
+		if start, err = time.ParseInLocation("2006-01-02", tenant.MoveIn, location); err != nil {
+			return start, end, fmt.Errorf("%w: tenants: moveIn %q of %q must be YYYY-MM-DD", config.ErrInvalid, tenant.MoveIn, tenant.Name)
+		}
+	}
+	if tenant.MoveOut != "" {
+		if end, err = time.ParseInLocation("2006-01-02", tenant.MoveOut, location); err != nil {
+			return start, end, fmt.Errorf("%w: tenants: moveOut %q of %q must be YYYY-MM-DD", config.ErrInvalid, tenant.MoveOut, tenant.Name)
+		}
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end, nil
+}
+
+// ConsumerBills prices the usage of the consumers in the low and high
+// tariff with the internal ZEV prices (see billing.Calculate). A consumer
+// with tenants gets a bill per tenancy within the period and one for every
+// time in between, which the unit's owner pays. When the prices change
+// within the period, the usage is priced in parts with the prices of each.
+// The stats must not be filtered, the shared costs and common areas are
+// split among all consumers, and the consumer IDs must not be anonymized
+// yet, base fees and tenants go by ID. Tenants and price changes need the
+// intervals of the whole period, so they do not work with AnalyzeStream.
+func (ea *EnergyAnalyzer) ConsumerBills(lowTariff, highTariff *EnergyStats) (*billing.Bill, error) {
+	groups := make(map[string]string)
+	for _, group := range ea.config.ZEV.Groups {
+		for _, id := range group.Consumers {
+			groups[id] = group.Name
+		}
+	}
+	var usage []billing.Usage
+	index := make(map[string]int)
+	for _, tariff := range []struct {
+		stats *EnergyStats
+		low   bool
+	}{{lowTariff, true}, {highTariff, false}} {
+		if tariff.stats == nil {
+			continue
+		}
+		for i := range tariff.stats.Consumers {
+			consumer := &tariff.stats.Consumers[i]
+			key := consumerKey(consumer)
+			pos, ok := index[key]
+			if !ok {
+				pos = len(usage)
+				index[key] = pos
+				u := billing.Usage{Name: consumer.Name(), AreaID: SharedConsumerID}
+				if !synthetic(consumer) {
+					u.ID = consumer.Sensor.ID
+					u.Group = groups[u.ID]
+				}
+				usage = append(usage, u)
+			}
+			sources := &usage[pos].High
+			if tariff.low {
+				sources = &usage[pos].Low
+			}
+			addSources(sources, consumer)
+		}
+	}
+	period := MergeStats(lowTariff, highTariff).Period
+	cache := make(partCache)
+	if len(ea.config.ZEV.Tenants) > 0 {
+		var err error
+		if usage, err = ea.splitTenancies(cache, usage, period.Start, period.End); err != nil {
+			return nil, err
+		}
+	}
+	if err := ea.splitPrices(cache, usage, period.Start, period.End); err != nil {
+		return nil, err
+	}
+	return billing.Calculate(period.Start, period.End, usage, ea.config.Prices), nil
+}
+
+// partCache holds the stats of parts [start, end) of the period in the
+// low and the high tariff, shared by all consumers
+type partCache map[[2]time.Time][2]*EnergyStats
+
+// partStats returns the stats of the part [start, end) in the low and the
+// high tariff
+func (ea *EnergyAnalyzer) partStats(cache partCache, start, end time.Time) ([2]*EnergyStats, error) {
+	if stats, ok := cache[[2]time.Time{start, end}]; ok {
+		return stats, nil
+	}
+	var stats [2]*EnergyStats
+	for i, low := range []bool{true, false} {
+		var err error
+		stats[i], err = ea.calculateStatsFor("Part", func(interval *IntervalData) bool {
+			return !interval.Start.Before(start) && interval.Start.Before(end) && ea.IsLowTariff(interval.Start) == low
+		})
+		if err != nil {
+			return stats, err
+		}
+	}
+	cache[[2]time.Time{start, end}] = stats
+	return stats, nil
+}
+
+// addUsage adds the usage of the billed consumer u in stats to sources.
+// Consumers without an ID, like the shared usage, go by name.
+func addUsage(sources *billing.Sources, stats *EnergyStats, u billing.Usage) {
+	for i := range stats.Consumers {
+		consumer := &stats.Consumers[i]
+		if u.ID != "" && !synthetic(consumer) && consumer.Sensor.ID == u.ID ||
+			u.ID == "" && synthetic(consumer) && consumer.Name() == u.Name {
+			addSources(sources, consumer)
+		}
+	}
+}
+
+// splitPrices divides the usage of every consumer into parts at the price
+// changes within its time, see billing.Usage
+func (ea *EnergyAnalyzer) splitPrices(cache partCache, usage []billing.Usage, from, to time.Time) error {
+	for i := range usage {
+		u := &usage[i]
+		start, end := from, to
+		if !u.From.IsZero() {
+			start, end = u.From, u.To
+		}
+		changes := ea.config.Prices.ChangesWithin(start, end)
+		if changes == nil {
+			continue
+		}
+		for j, partStart := range append([]time.Time{start}, changes...) {
+			partEnd := end
+			if j < len(changes) {
+				partEnd = changes[j]
+			}
+			stats, err := ea.partStats(cache, partStart, partEnd)
+			if err != nil {
+				return err
+			}
+			part := billing.Part{From: partStart}
+			addUsage(&part.Low, stats[0], *u)
+			addUsage(&part.High, stats[1], *u)
+			u.Parts = append(u.Parts, part)
+		}
+	}
+	return nil
+}
+
+// addSources adds the usage of a consumer to sources, in kWh
+func addSources(sources *billing.Sources, consumer *ConsumerStats) {
+	sources.Solar += consumer.Sources.FromInverter / 1000
+	sources.BatterySolar += consumer.Sources.FromBatterySolar / 1000
+	sources.BatteryGrid += consumer.Sources.FromBatteryGrid / 1000
+	sources.Grid += consumer.Sources.FromGrid / 1000
+}
+
+// splitTenancies replaces the usage of every unit with tenants by the
+// usage of each tenancy within [from, to)
+func (ea *EnergyAnalyzer) splitTenancies(cache partCache, usage []billing.Usage, from, to time.Time) ([]billing.Usage, error) {
+	var split []billing.Usage
+	for _, u := range usage {
+		tenancies := ea.tenancies(u.ID, from, to)
+		if tenancies == nil {
+			split = append(split, u)
+			continue
+		}
+		for _, t := range tenancies {
+			stats, err := ea.partStats(cache, t.start, t.end)
+			if err != nil {
+				return nil, err
+			}
+			tenant := billing.Usage{ID: u.ID, Name: u.Name, Group: u.Group, Tenant: t.tenant, From: t.start, To: t.end}
+			addUsage(&tenant.Low, stats[0], tenant)
+			addUsage(&tenant.High, stats[1], tenant)
+			split = append(split, tenant)
+		}
+	}
+	return split, nil
+}
+
+// tenancies divides [from, to) into the tenancies of the consumer's unit
+// and the times it stood empty, in time order. Returns nil for a consumer
+// without tenants.
+func (ea *EnergyAnalyzer) tenancies(id string, from, to time.Time) []tenancy {
+	var occupied []tenancy
+	for _, tenant := range ea.config.ZEV.Tenants {
+		if tenant.Consumer != id {
+			continue
+		}
+		start, end, _ := parseTenancy(tenant, from.Location()) // checked by validateTenants
+		if start.IsZero() || start.Before(from) {
+			start = from
+		}
+		if end.IsZero() || end.After(to) {
+			end = to
+		}
+		if start.Before(end) {
+			occupied = append(occupied, tenancy{tenant.Name, start, end})
+		}
+	}
+	if occupied == nil {
+		return nil
+	}
+	sort.Slice(occupied, func(i, j int) bool { return occupied[i].start.Before(occupied[j].start) })
+	var tenancies []tenancy
+	cursor := from
+	for _, t := range occupied {
+		if cursor.Before(t.start) {
+			tenancies = append(tenancies, tenancy{start: cursor, end: t.start})
+		}
+		tenancies = append(tenancies, t)
+		cursor = t.end
+	}
+	if cursor.Before(to) {
+		tenancies = append(tenancies, tenancy{start: cursor, end: to})
+	}
+	return tenancies
+}
+
+// PricePart is the usage of a part of the period with the same prices,
+// starting at Start
+type PricePart struct {
+	Start      time.Time
+	LowTariff  *EnergyStats
+	HighTariff *EnergyStats
+}
+
+// PriceParts divides [from, to) at the price changes within it and
+// returns the stats of every part, nil when the prices do not change
+func (ea *EnergyAnalyzer) PriceParts(from, to time.Time) ([]PricePart, error) {
+	changes := ea.config.Prices.ChangesWithin(from, to)
+	if changes == nil {
+		return nil, nil
+	}
+	cache := make(partCache)
+	var parts []PricePart
+	for i, start := range append([]time.Time{from}, changes...) {
+		end := to
+		if i < len(changes) {
+			end = changes[i]
+		}
+		stats, err := ea.partStats(cache, start, end)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, PricePart{Start: start, LowTariff: stats[0], HighTariff: stats[1]})
+	}
+	return parts, nil
+}
@@ -0,0 +1,78 @@
+package analyzer
+
+import "time"
+
+// GridBillMonth is the grid bill of one calendar month under a tariff with
+// an energy and a capacity component
+type GridBillMonth struct {
+	Start    time.Time `json:"start"`
+	Import   float64   `json:"importKwh"`
+	Peak     float64   `json:"peakKw"` // highest 15-minute average import
+	PeakAt   time.Time `json:"peakAt"`
+	Energy   float64   `json:"energyCost"`   // import at the tariff prices
+	Capacity float64   `json:"capacityCost"` // peak at the capacity tariff
+	FeedIn   float64   `json:"feedInRevenue,omitempty"`
+	Total    float64   `json:"total"` // energy and capacity cost less feed-in
+}
+
+// GridBill reproduces the utility bill of the grid connection month by
+// month. Months only partly in the analysis are charged the full capacity
+// cost of their peak so far.
+type GridBill struct {
+	Currency string          `json:"currency"`
+	Months   []GridBillMonth `json:"months"`
+	Energy   float64         `json:"energyCost"`
+	Capacity float64         `json:"capacityCost"`
+	FeedIn   float64         `json:"feedInRevenue,omitempty"`
+	Total    float64         `json:"total"`
+}
+
+// trackGridBill adds the grid exchange of the current intervals to the
+// monthly bill, continuing the last month of an earlier piece (see
+// AnalyzeStream)
+func (ea *EnergyAnalyzer) trackGridBill() {
+	hours := float64(IntervalSeconds) / 3600
+	for _, interval := range ea.intervals {
+		last := len(ea.billMonths) - 1
+		if last < 0 || !sameMonth(ea.billMonths[last].Start, interval.Start) {
+			start := time.Date(interval.Start.Year(), interval.Start.Month(), 1, 0, 0, 0, 0, interval.Start.Location())
+			ea.billMonths = append(ea.billMonths, GridBillMonth{Start: start})
+			last++
+		}
+		month := &ea.billMonths[last]
+		prices := ea.config.Prices.At(interval.Start)
+		lowTariff := ea.IsLowTariff(interval.Start)
+		price := prices.GridHigh
+		if lowTariff {
+			price = prices.GridLowPrice()
+		}
+		month.Import += interval.GridImport / 1000
+		month.Energy += interval.GridImport / 1000 * price
+		month.FeedIn += interval.GridExport / 1000 * prices.FeedInPrice(lowTariff)
+		if power := interval.GridImport / 1000 / hours; power > month.Peak {
+			month.Peak = power
+			month.PeakAt = interval.Start
+		}
+	}
+}
+
+// GridBill returns the monthly grid bill of the last analysis, or nil
+// without a capacity tariff
+func (ea *EnergyAnalyzer) GridBill() *GridBill {
+	prices := ea.config.Prices
+	if prices.Capacity <= 0 {
+		return nil
+	}
+	bill := &GridBill{Currency: prices.CurrencyLabel(), Months: []GridBillMonth{}}
+	for _, month := range ea.billMonths {
+		// the capacity tariff in force at the start of the month
+		month.Capacity = month.Peak * prices.At(month.Start).Capacity
+		month.Total = month.Energy + month.Capacity - month.FeedIn
+		bill.Energy += month.Energy
+		bill.Capacity += month.Capacity
+		bill.FeedIn += month.FeedIn
+		bill.Total += month.Total
+		bill.Months = append(bill.Months, month)
+	}
+	return bill
+}
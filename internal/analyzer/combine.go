@@ -0,0 +1,78 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"zevalizer/internal/config"
+)
+
+// validateCombine checks that combined meters are configured consumers and
+// belong to exactly one logical consumer
+func (ea *EnergyAnalyzer) validateCombine() error {
+	consumers := make(map[string]bool)
+	for _, id := range ea.config.ZEV.ConsumerIDs {
+		consumers[id] = true
+	}
+	owner := make(map[string]string)
+	for primary, members := range ea.config.ZEV.Combine {
+		if !consumers[primary] {
+			return fmt.Errorf("%w: combine: %s is not a consumer", config.ErrInvalid, primary)
+		}
+		for _, member := range members {
+			if !consumers[member] || member == primary {
+				return fmt.Errorf("%w: combine: member %s of %s must be another consumer", config.ErrInvalid, member, primary)
+			}
+			if other, taken := owner[member]; taken {
+				return fmt.Errorf("%w: combine: %s is a member of both %s and %s", config.ErrInvalid, member, other, primary)
+			}
+			owner[member] = primary
+		}
+	}
+	for primary := range ea.config.ZEV.Combine {
+		if other, ok := owner[primary]; ok {
+			return fmt.Errorf("%w: combine: %s is a member of %s and cannot combine meters itself", config.ErrInvalid, primary, other)
+		}
+	}
+	return nil
+}
+
+// ConsumerIDs returns the consumers that are reported: the configured
+// consumers without the meters combined into another consumer, with split
+// meters replaced by their virtual consumers
+func (ea *EnergyAnalyzer) ConsumerIDs() []string {
+	members := make(map[string]bool)
+	for _, ids := range ea.config.ZEV.Combine {
+		for _, id := range ids {
+			members[id] = true
+		}
+	}
+	var ids []string
+	for _, id := range ea.config.ZEV.ConsumerIDs {
+		if members[id] {
+			continue
+		}
+		if shares, ok := ea.config.ZEV.Splits[id]; ok {
+			for _, share := range shares {
+				ids = append(ids, splitID(id, share.Name))
+			}
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// combineConsumers adds the usage of combined meters to their logical
+// consumer in every interval
+func (ea *EnergyAnalyzer) combineConsumers() {
+	for primary, members := range ea.config.ZEV.Combine {
+		for _, interval := range ea.intervals {
+			for _, member := range members {
+				if usage, ok := interval.ConsumerUsage[member]; ok {
+					interval.ConsumerUsage[primary] += usage
+					delete(interval.ConsumerUsage, member)
+				}
+			}
+		}
+	}
+}
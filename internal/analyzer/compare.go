@@ -0,0 +1,119 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"time"
+
+	"zevalizer/internal/models"
+)
+
+// Delta compares one energy value of two periods
+type Delta struct {
+	Current  float64
+	Baseline float64
+}
+
+// Change returns the absolute difference to the baseline in Wh
+func (d Delta) Change() float64 {
+	return d.Current - d.Baseline
+}
+
+// Percent returns the change relative to the baseline. ok is false when
+// the baseline is zero and no relative change can be given.
+func (d Delta) Percent() (percent float64, ok bool) {
+	if d.Baseline == 0 {
+		return 0, false
+	}
+	return d.Change() / d.Baseline * 100, true
+}
+
+// MarshalJSON adds the absolute and relative change
+func (d Delta) MarshalJSON() ([]byte, error) {
+	out := struct {
+		Current  float64  `json:"currentWh"`
+		Baseline float64  `json:"baselineWh"`
+		Change   float64  `json:"changeWh"`
+		Percent  *float64 `json:"changePercent,omitempty"`
+	}{Current: d.Current, Baseline: d.Baseline, Change: d.Change()}
+	if percent, ok := d.Percent(); ok {
+		out.Percent = &percent
+	}
+	return json.Marshal(out)
+}
+
+// ConsumerDelta compares the total usage of one consumer
+type ConsumerDelta struct {
+	Sensor *models.Sensor
+	Delta
+}
+
+// MarshalJSON identifies the consumer by sensor ID and name
+func (cd ConsumerDelta) MarshalJSON() ([]byte, error) {
+	var id, name string
+	if cd.Sensor != nil {
+		id = cd.Sensor.ID
+		name = cd.Sensor.Tag.Name
+	}
+	delta, err := json.Marshal(cd.Delta)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		ID    string          `json:"id,omitempty"`
+		Name  string          `json:"name"`
+		Delta json.RawMessage `json:"delta"`
+	}{id, name, delta})
+}
+
+// Comparison holds the differences between an analysis and a baseline period
+type Comparison struct {
+	Current struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	} `json:"current"`
+	Baseline struct {
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	} `json:"baseline"`
+	Production       Delta           `json:"production"`
+	GridImport       Delta           `json:"gridImport"`
+	GridExport       Delta           `json:"gridExport"`
+	BatteryCharge    Delta           `json:"batteryCharge"`
+	BatteryDischarge Delta           `json:"batteryDischarge"`
+	Consumption      Delta           `json:"consumption"` // sum of all consumers
+	Consumers        []ConsumerDelta `json:"consumers"`
+}
+
+// Compare calculates the differences between two statistics. Consumers are
+// matched by sensor ID and listed in the order of current, followed by
+// consumers that only appear in the baseline.
+func Compare(current, baseline *EnergyStats) *Comparison {
+	c := &Comparison{
+		Production:       Delta{current.Production, baseline.Production},
+		GridImport:       Delta{current.GridImport, baseline.GridImport},
+		GridExport:       Delta{current.GridExport, baseline.GridExport},
+		BatteryCharge:    Delta{current.BatteryCharge, baseline.BatteryCharge},
+		BatteryDischarge: Delta{current.BatteryDischarge, baseline.BatteryDischarge},
+	}
+	c.Current.Start, c.Current.End = current.Period.Start, current.Period.End
+	c.Baseline.Start, c.Baseline.End = baseline.Period.Start, baseline.Period.End
+
+	index := make(map[string]int)
+	for i := range current.Consumers {
+		consumer := &current.Consumers[i]
+		index[consumerKey(consumer)] = len(c.Consumers)
+		c.Consumers = append(c.Consumers, ConsumerDelta{Sensor: consumer.Sensor, Delta: Delta{Current: consumer.Total}})
+		c.Consumption.Current += consumer.Total
+	}
+	for i := range baseline.Consumers {
+		consumer := &baseline.Consumers[i]
+		pos, ok := index[consumerKey(consumer)]
+		if !ok {
+			pos = len(c.Consumers)
+			c.Consumers = append(c.Consumers, ConsumerDelta{Sensor: consumer.Sensor})
+		}
+		c.Consumers[pos].Baseline = consumer.Total
+		c.Consumption.Baseline += consumer.Total
+	}
+	return c
+}
@@ -0,0 +1,126 @@
+package analyzer
+
+import "time"
+
+// Sensor roles in the completeness report
+const (
+	RoleGrid       = "grid"
+	RoleProduction = "production"
+	RoleBattery    = "battery"
+	RoleConsumer   = "consumer"
+)
+
+// DayGap is a day on which a sensor missed readings for some intervals
+type DayGap struct {
+	Day     time.Time `json:"day"`
+	Missing int       `json:"missingIntervals"`
+}
+
+// SensorCompleteness tells how many of the expected 15-minute intervals of
+// a sensor received a reading. Intervals that lie in the future are not
+// expected.
+type SensorCompleteness struct {
+	SensorID string   `json:"sensorId"`
+	Name     string   `json:"name"`
+	Role     string   `json:"role"`
+	Expected int      `json:"expectedIntervals"`
+	Covered  int      `json:"coveredIntervals"`
+	Gaps     []DayGap `json:"gaps,omitempty"`
+
+	// Gap statistics across days for the health report
+	lastData   time.Time // end of the last interval with a reading
+	gapRuns    int       // runs of consecutive missing intervals
+	longestRun int       // longest run of missing intervals
+	openRun    int       // missing intervals at the end so far
+}
+
+// Percent returns the share of expected intervals with a reading
+func (sc *SensorCompleteness) Percent() float64 {
+	if sc.Expected == 0 {
+		return 100
+	}
+	return float64(sc.Covered) / float64(sc.Expected) * 100
+}
+
+// Completeness returns the data completeness of every configured sensor for
+// the last analysis, in the order grid, production, battery, consumers
+func (ea *EnergyAnalyzer) Completeness() []SensorCompleteness {
+	return ea.completeness
+}
+
+// readingInterval returns the interval containing t and records that the
+// sensor delivered a reading for it
+func (ea *EnergyAnalyzer) readingInterval(sensorID string, t time.Time) *IntervalData {
+	index := ea.intervalIndex(t)
+	if index < 0 {
+		return nil
+	}
+	covered, ok := ea.coverage[sensorID]
+	if !ok {
+		covered = make([]bool, len(ea.intervals))
+		ea.coverage[sensorID] = covered
+	}
+	covered[index] = true
+	return ea.intervals[index]
+}
+
+// configuredSensors lists the IDs of all configured sensors with their role
+func (ea *EnergyAnalyzer) configuredSensors() (ids []string, roles []string) {
+	add := func(role string, list ...string) {
+		for _, id := range list {
+			if id != "" {
+				ids = append(ids, id)
+				roles = append(roles, role)
+			}
+		}
+	}
+	add(RoleGrid, ea.config.ZEV.GridMeters()...)
+	add(RoleProduction, ea.config.ZEV.ProductionIDs...)
+	add(RoleBattery, ea.config.ZEV.BatterySystemIDs...)
+	add(RoleConsumer, ea.config.ZEV.ConsumerIDs...)
+	return ids, roles
+}
+
+// measureCompleteness evaluates the coverage of the current intervals and
+// adds it to the completeness of earlier pieces (see AnalyzeStream)
+func (ea *EnergyAnalyzer) measureCompleteness(now time.Time) {
+	ids, roles := ea.configuredSensors()
+	if ea.completeness == nil {
+		ea.completeness = make([]SensorCompleteness, len(ids))
+		for i, id := range ids {
+			ea.completeness[i] = SensorCompleteness{SensorID: id, Role: roles[i]}
+			if sensor := ea.sensorMap[id]; sensor != nil {
+				ea.completeness[i].Name = sensor.Tag.Name
+			}
+		}
+	}
+
+	for i, id := range ids {
+		sc := &ea.completeness[i]
+		covered := ea.coverage[id]
+		var gap *DayGap
+		for index, interval := range ea.intervals {
+			if interval.End.After(now) {
+				break
+			}
+			sc.Expected++
+			if covered != nil && covered[index] {
+				sc.Covered++
+				sc.lastData = interval.End
+				sc.openRun = 0
+				continue
+			}
+			if sc.openRun == 0 {
+				sc.gapRuns++
+			}
+			sc.openRun++
+			sc.longestRun = max(sc.longestRun, sc.openRun)
+			day := time.Date(interval.Start.Year(), interval.Start.Month(), interval.Start.Day(), 0, 0, 0, 0, interval.Start.Location())
+			if gap == nil || !gap.Day.Equal(day) {
+				sc.Gaps = append(sc.Gaps, DayGap{Day: day})
+				gap = &sc.Gaps[len(sc.Gaps)-1]
+			}
+			gap.Missing++
+		}
+	}
+}
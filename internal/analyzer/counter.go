@@ -0,0 +1,55 @@
+package analyzer
+
+import "time"
+
+// counterJitterWh is how far a counter may go backwards (rounding of the
+// readings) before it is considered reset
+const counterJitterWh = 1
+
+// CounterReset records a counter that went backwards between two readings,
+// e.g. because the meter was replaced or its counter rolled over
+type CounterReset struct {
+	SensorID string    `json:"sensorId"`
+	Counter  string    `json:"counter"` // "purchase" or "delivery"
+	Time     time.Time `json:"time"`
+	Previous float64   `json:"previousWh"`
+	Current  float64   `json:"currentWh"`
+	Assumed  float64   `json:"assumedWh"` // energy booked for the reading
+}
+
+// CounterResets returns the counter resets detected in the last analysis
+func (ea *EnergyAnalyzer) CounterResets() []CounterReset {
+	return ea.resets
+}
+
+// counterDiff returns the energy between two readings of a counter. When the
+// counter went backwards it was reset: if the new value is plausible for a
+// single reading (at most limit), the counter restarted at zero and the new
+// value is the energy; otherwise the new baseline is unknown and the reading
+// contributes nothing. Either way the next reading continues from the new
+// baseline, and the reset is recorded.
+func (ea *EnergyAnalyzer) counterDiff(sensorID, counter string, at time.Time, previous, current, limit float64) float64 {
+	diff := current - previous
+	if diff >= 0 {
+		return diff
+	}
+	if diff > -counterJitterWh {
+		return 0
+	}
+
+	assumed := 0.0
+	if current >= 0 && current <= limit {
+		assumed = current
+	}
+	ea.resets = append(ea.resets, CounterReset{
+		SensorID: sensorID,
+		Counter:  counter,
+		Time:     at,
+		Previous: previous,
+		Current:  current,
+		Assumed:  assumed,
+	})
+	ea.debugf("Counter reset on %s %s at %s: %.1f -> %.1f Wh, assuming %.1f Wh",
+		sensorID, counter, at.Format("2006-01-02 15:04"), previous, current, assumed)
+	return assumed
+}
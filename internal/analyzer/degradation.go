@@ -0,0 +1,166 @@
+package analyzer
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+// YieldYear is the production of one inverter in one calendar year
+type YieldYear struct {
+	Year       int     `json:"year"`
+	Production float64 `json:"productionKwh"`
+	// Production per kW peak power, when the peak power is configured
+	SpecificYield float64 `json:"specificYieldKwhPerKwp,omitempty"`
+	// Complete months of the year that entered the index
+	Months int `json:"months"`
+	// Production of the complete months relative to the average of the same
+	// calendar months over all years, in percent; 0 without complete months
+	Index float64 `json:"indexPercent"`
+}
+
+// InverterDegradation holds the yearly yield of one production meter and
+// its trend
+type InverterDegradation struct {
+	SensorID  string      `json:"sensorId"`
+	Name      string      `json:"name"`
+	PeakPower float64     `json:"peakPowerKw,omitempty"`
+	Years     []YieldYear `json:"years"`
+	// Change of the seasonally normalized yield per year in percent,
+	// negative for degradation; 0 with fewer than two indexed years
+	Trend float64 `json:"trendPercentPerYear"`
+}
+
+// validatePeakPower checks that peak powers are positive and belong to
+// production meters
+func (ea *EnergyAnalyzer) validatePeakPower() error {
+	for id, power := range ea.config.ZEV.PeakPowerKW {
+		if !slices.Contains(ea.config.ZEV.ProductionIDs, id) {
+			return fmt.Errorf("%w: peak power of %s, which is not a production meter", config.ErrInvalid, id)
+		}
+		if power <= 0 {
+			return fmt.Errorf("%w: peak power %.2f kW of %s must be positive", config.ErrInvalid, power, id)
+		}
+	}
+	return nil
+}
+
+// Degradation compares the production of every inverter across the years
+// of the last analysis. Seasons are normalized by comparing each calendar
+// month only with the same month of the other years: the index of a year is
+// the production of its complete months relative to the average production
+// of these months over all years. The trend is the slope of a linear fit
+// through the yearly indexes. Partial months at the edges of the analysis
+// are left out of the index, so a year that is not yet over still counts.
+func (ea *EnergyAnalyzer) Degradation() []InverterDegradation {
+	if len(ea.intervals) == 0 {
+		return nil
+	}
+	years, complete := ea.completeMonths()
+
+	var inverters []InverterDegradation
+	for _, id := range ea.config.ZEV.ProductionIDs {
+		// production per year index and calendar month
+		production := make([][12]float64, len(years))
+		series := ea.raw[rawKey{id, RoleProduction, FlowNet}]
+		for index, interval := range ea.intervals {
+			if series == nil || series[index] <= 0 {
+				continue
+			}
+			year := interval.Start.Year() - years[0]
+			production[year][interval.Start.Month()-1] += series[index] / 1000
+		}
+
+		// average of every calendar month over the years it is complete in
+		var reference [12]float64
+		for m := range reference {
+			var sum float64
+			var count int
+			for y := range years {
+				if complete[y][m] {
+					sum += production[y][m]
+					count++
+				}
+			}
+			if count > 0 {
+				reference[m] = sum / float64(count)
+			}
+		}
+
+		inverter := InverterDegradation{SensorID: id, Name: id, PeakPower: ea.config.ZEV.PeakPowerKW[id]}
+		if sensor := ea.sensorMap[id]; sensor != nil && sensor.Tag.Name != "" {
+			inverter.Name = sensor.Tag.Name
+		}
+		for y, year := range years {
+			yield := YieldYear{Year: year}
+			var actual, expected float64
+			for m := range production[y] {
+				yield.Production += production[y][m]
+				if complete[y][m] && reference[m] > 0 {
+					actual += production[y][m]
+					expected += reference[m]
+					yield.Months++
+				}
+			}
+			if inverter.PeakPower > 0 {
+				yield.SpecificYield = yield.Production / inverter.PeakPower
+			}
+			if expected > 0 {
+				yield.Index = actual / expected * 100
+			}
+			inverter.Years = append(inverter.Years, yield)
+		}
+		inverter.Trend = yieldTrend(inverter.Years)
+		inverters = append(inverters, inverter)
+	}
+	return inverters
+}
+
+// completeMonths returns the calendar years of the last analysis and for
+// each of them which months have all their intervals in the analysis
+func (ea *EnergyAnalyzer) completeMonths() ([]int, [][12]bool) {
+	first := ea.intervals[0].Start
+	last := ea.intervals[len(ea.intervals)-1].Start
+	var years []int
+	for year := first.Year(); year <= last.Year(); year++ {
+		years = append(years, year)
+	}
+	counts := make([][12]int, len(years))
+	for _, interval := range ea.intervals {
+		counts[interval.Start.Year()-years[0]][interval.Start.Month()-1]++
+	}
+	complete := make([][12]bool, len(years))
+	for y, year := range years {
+		for m := range complete[y] {
+			start := time.Date(year, time.Month(m+1), 1, 0, 0, 0, 0, first.Location())
+			end := start.AddDate(0, 1, 0)
+			complete[y][m] = counts[y][m] == int(end.Sub(start)/(IntervalSeconds*time.Second))
+		}
+	}
+	return years, complete
+}
+
+// yieldTrend fits a line through the indexes of the years with complete
+// months and returns its slope relative to the mean index, in percent per
+// year
+func yieldTrend(years []YieldYear) float64 {
+	var n, sumX, sumY, sumXY, sumXX float64
+	for _, year := range years {
+		if year.Months == 0 {
+			continue
+		}
+		x := float64(year.Year)
+		n++
+		sumX += x
+		sumY += year.Index
+		sumXY += x * year.Index
+		sumXX += x * x
+	}
+	if n < 2 {
+		return 0
+	}
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	return slope / (sumY / n) * 100
+}
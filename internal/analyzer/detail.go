@@ -0,0 +1,61 @@
+package analyzer
+
+import "time"
+
+// ConsumerDay is the usage of one consumer on one calendar day
+type ConsumerDay struct {
+	Date       time.Time `json:"date"`
+	Total      float64   `json:"totalWh"`
+	Solar      float64   `json:"solarWh"`
+	Battery    float64   `json:"batteryWh"`
+	Grid       float64   `json:"gridWh"`
+	LowTariff  float64   `json:"lowTariffWh"`
+	HighTariff float64   `json:"highTariffWh"`
+	Peak       Peak      `json:"peak"` // interval with the highest usage
+}
+
+// ConsumerDetail is the daily breakdown of a single consumer
+type ConsumerDetail struct {
+	SensorID string        `json:"sensorId"`
+	Name     string        `json:"name"`
+	Days     []ConsumerDay `json:"days"`
+}
+
+// ConsumerDetail returns the daily usage of a consumer in the last analysis,
+// split by source and tariff as in the energy statistics. id is a reported
+// consumer or SharedConsumerID.
+func (ea *EnergyAnalyzer) ConsumerDetail(id string) *ConsumerDetail {
+	detail := &ConsumerDetail{SensorID: id, Name: id, Days: []ConsumerDay{}}
+	if id == SharedConsumerID {
+		detail.Name = "Shared Usage"
+	} else if sensor := ea.sensorMap[id]; sensor != nil && sensor.Tag.Name != "" {
+		detail.Name = sensor.Tag.Name
+	}
+
+	var day *ConsumerDay
+	for _, interval := range ea.intervals {
+		date := time.Date(interval.Start.Year(), interval.Start.Month(), interval.Start.Day(), 0, 0, 0, 0, interval.Start.Location())
+		if day == nil || !day.Date.Equal(date) {
+			detail.Days = append(detail.Days, ConsumerDay{Date: date})
+			day = &detail.Days[len(detail.Days)-1]
+		}
+		part, ok := interval.ConsumerSources[id]
+		if !ok {
+			continue
+		}
+		usage := part.Total()
+		day.Total += usage
+		day.Solar += part.Solar
+		day.Battery += part.Battery
+		day.Grid += part.Grid
+		if ea.IsLowTariff(interval.Start) {
+			day.LowTariff += usage
+		} else {
+			day.HighTariff += usage
+		}
+		if power := usage / 1000 * 3600 / IntervalSeconds; power > day.Peak.Power {
+			day.Peak = Peak{Start: interval.Start, Power: power}
+		}
+	}
+	return detail
+}
@@ -0,0 +1,121 @@
+package analyzer
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Directions of a meter's contribution to an interval
+const (
+	FlowImport    = "import"    // grid import, input of the ZEV
+	FlowExport    = "export"    // grid export, output of the ZEV
+	FlowNet       = "net"       // inverter delivery - purchase
+	FlowCharge    = "charge"    // battery charge, part of the inverter's net
+	FlowDischarge = "discharge" // battery discharge, part of the inverter's net
+	FlowUsage     = "usage"     // consumer meter
+)
+
+// Contribution is the energy a single meter measured in an interval, as
+// read before sub-meters, combined and split consumers are applied
+type Contribution struct {
+	SensorID string  `json:"sensorId"`
+	Name     string  `json:"name"`
+	Role     string  `json:"role"`
+	Flow     string  `json:"flow"`
+	Energy   float64 `json:"energyWh"`
+}
+
+// IntervalDiagnosis is an interval with unaccounted energy and every
+// meter's contribution to it
+type IntervalDiagnosis struct {
+	Start         time.Time      `json:"start"`
+	Input         float64        `json:"inputWh"`      // grid import + inverter production
+	Output        float64        `json:"outputWh"`     // consumers + grid export + inverter consumption
+	Difference    float64        `json:"differenceWh"` // input - output, the shared usage if positive
+	Tolerance     float64        `json:"toleranceWh"`
+	Contributions []Contribution `json:"contributions"`
+}
+
+// rawKey identifies the raw energy series of one meter and flow
+type rawKey struct {
+	sensorID string
+	role     string
+	flow     string
+}
+
+// recordRaw adds energy a meter measured to the raw series of the interval
+// for the diagnostics
+func (ea *EnergyAnalyzer) recordRaw(sensorID, role, flow string, interval *IntervalData, energy float64) {
+	index := ea.intervalIndex(interval.Start)
+	if index < 0 || energy == 0 {
+		return
+	}
+	key := rawKey{sensorID, role, flow}
+	series, ok := ea.raw[key]
+	if !ok {
+		series = make([]float64, len(ea.intervals))
+		ea.raw[key] = series
+		ea.rawKeys = append(ea.rawKeys, key)
+	}
+	series[index] += energy
+}
+
+// Diagnose returns up to n intervals of the last analysis in which the
+// energy balance is off by more than the validation tolerance, in either
+// direction: outputs exceeding inputs point to a meter reading too much,
+// a large surplus (shared usage) to a meter reading too little or a missing
+// meter. The intervals with the largest difference come first.
+func (ea *EnergyAnalyzer) Diagnose(n int) []IntervalDiagnosis {
+	validation := ea.config.Validation
+	var diagnoses []IntervalDiagnosis
+	var indexes []int
+	for index, interval := range ea.intervals {
+		input, output := intervalBalance(interval)
+		difference := input - output
+		tolerance := validation.Tolerance(max(input, output))
+		if difference > -tolerance && difference < tolerance {
+			continue
+		}
+		diagnoses = append(diagnoses, IntervalDiagnosis{
+			Start:      interval.Start,
+			Input:      input,
+			Output:     output,
+			Difference: difference,
+			Tolerance:  tolerance,
+		})
+		indexes = append(indexes, index)
+	}
+
+	order := make([]int, len(diagnoses))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return math.Abs(diagnoses[order[a]].Difference) > math.Abs(diagnoses[order[b]].Difference)
+	})
+	if len(order) > n {
+		order = order[:n]
+	}
+
+	worst := make([]IntervalDiagnosis, 0, len(order))
+	for _, i := range order {
+		diagnosis := diagnoses[i]
+		diagnosis.Contributions = []Contribution{}
+		for _, key := range ea.rawKeys {
+			energy := ea.raw[key][indexes[i]]
+			if energy == 0 {
+				continue
+			}
+			name := key.sensorID
+			if sensor := ea.sensorMap[key.sensorID]; sensor != nil && sensor.Tag.Name != "" {
+				name = sensor.Tag.Name
+			}
+			diagnosis.Contributions = append(diagnosis.Contributions, Contribution{
+				SensorID: key.sensorID, Name: name, Role: key.role, Flow: key.flow, Energy: energy,
+			})
+		}
+		worst = append(worst, diagnosis)
+	}
+	return worst
+}
@@ -0,0 +1,364 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+// Supply is energy by source in Wh. Solar is the PV output on the AC side
+// and Battery the AC contribution of the battery discharge; Solar is
+// negative while the inverter draws power to charge the battery.
+type Supply struct {
+	Solar   float64
+	Battery float64
+	Grid    float64
+}
+
+// Total returns the energy of all sources
+func (s Supply) Total() float64 {
+	return s.Solar + s.Battery + s.Grid
+}
+
+// Demand is the usage of one consumer in an interval
+type Demand struct {
+	ConsumerID string
+	Usage      float64
+	Shared     bool      // the unmetered Shared Usage
+	Since      time.Time // start of the running EV charging session, zero if none
+}
+
+// Distributor splits the supply of an interval onto the consumers. input
+// is the interval's total input, of which supply is the split by source.
+// The result holds the energy each demand draws from every source, in the
+// order of demands; the parts of a demand add up to its usage.
+type Distributor interface {
+	Distribute(supply Supply, input float64, demands []Demand) []Supply
+}
+
+// NewDistributor returns the distributor of the configured strategy,
+// serving the battery priority consumers first if there are any. Consumer
+// IDs in the configuration are not checked here.
+func NewDistributor(cfg config.DistributionConfig) (Distributor, error) {
+	distributor, err := newStrategy(cfg)
+	if err != nil || len(cfg.BatteryPriority) == 0 {
+		return distributor, err
+	}
+	rank, err := rankOf(cfg.BatteryPriority, "battery priority")
+	if err != nil {
+		return nil, err
+	}
+	return batteryFirst{inner: distributor, rank: rank}, nil
+}
+
+// rankOf maps the listed consumer IDs to their position
+func rankOf(ids []string, list string) (map[string]int, error) {
+	rank := make(map[string]int, len(ids))
+	for i, id := range ids {
+		if _, dup := rank[id]; dup {
+			return nil, fmt.Errorf("%w: consumer %s is listed twice in the %s", config.ErrInvalid, id, list)
+		}
+		rank[id] = i
+	}
+	return rank, nil
+}
+
+// newStrategy returns the distributor of the configured strategy
+func newStrategy(cfg config.DistributionConfig) (Distributor, error) {
+	switch cfg.Strategy {
+	case "", config.DistributionProportional:
+		return proportional{}, nil
+	case config.DistributionPriority:
+		if len(cfg.Priority) == 0 {
+			return nil, fmt.Errorf("%w: distribution strategy %s needs a priority list", config.ErrInvalid, cfg.Strategy)
+		}
+		rank, err := rankOf(cfg.Priority, "distribution priority")
+		if err != nil {
+			return nil, err
+		}
+		return priority{rank: rank}, nil
+	case config.DistributionContracted:
+		var sum float64
+		for id, share := range cfg.SolarShares {
+			if share < 0 || share > 1 {
+				return nil, fmt.Errorf("%w: solar share %.2f of %s must be between 0 and 1", config.ErrInvalid, share, id)
+			}
+			sum += share
+		}
+		if sum <= 0 || sum > 1+1e-9 {
+			return nil, fmt.Errorf("%w: contracted solar shares must add up to more than 0 and at most 1, got %.2f", config.ErrInvalid, sum)
+		}
+		return contracted{shares: cfg.SolarShares}, nil
+	case config.DistributionFirstCome:
+		return firstCome{}, nil
+	}
+	return nil, fmt.Errorf("%w: distribution strategy %q must be %s, %s, %s or %s", config.ErrInvalid, cfg.Strategy,
+		config.DistributionProportional, config.DistributionPriority, config.DistributionContracted, config.DistributionFirstCome)
+}
+
+// validateDistribution builds the distributor and checks that it only
+// references reported consumers
+func (ea *EnergyAnalyzer) validateDistribution() error {
+	dist := ea.config.ZEV.Distribution
+	distributor, err := NewDistributor(dist)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, id := range ea.ConsumerIDs() {
+		known[id] = true
+	}
+	for _, id := range dist.Priority {
+		if !known[id] {
+			return fmt.Errorf("%w: distribution priority lists %s, which is not a reported consumer", config.ErrInvalid, id)
+		}
+	}
+	for id := range dist.SolarShares {
+		if !known[id] {
+			return fmt.Errorf("%w: solar share for %s, which is not a reported consumer", config.ErrInvalid, id)
+		}
+	}
+	for _, id := range dist.BatteryPriority {
+		if !known[id] && id != SharedConsumerID {
+			return fmt.Errorf("%w: battery priority lists %s, which is not a reported consumer", config.ErrInvalid, id)
+		}
+	}
+	ea.distributor = distributor
+	return nil
+}
+
+// proportional gives every consumer the same source mix: the share of each
+// source in the interval's input
+type proportional struct{}
+
+func (proportional) Distribute(supply Supply, input float64, demands []Demand) []Supply {
+	solarShare := supply.Solar / input
+	batteryShare := supply.Battery / input
+	gridShare := supply.Grid / input
+
+	parts := make([]Supply, len(demands))
+	for i, d := range demands {
+		parts[i].Battery = d.Usage * batteryShare
+		if supply.Solar < 0 && !d.Shared {
+			// Regular consumers don't show negative inverter energy, the
+			// inverter consumption is common power attributed to Shared
+			// Usage and the grid covers it
+			parts[i].Grid = d.Usage * (gridShare + solarShare)
+		} else {
+			parts[i].Solar = d.Usage * solarShare
+			parts[i].Grid = d.Usage * gridShare
+		}
+	}
+	return parts
+}
+
+// priority serves the listed consumers in order, each first from solar,
+// then from the battery and then from the grid. The others share what is
+// left in proportion to their usage.
+type priority struct {
+	rank map[string]int
+}
+
+func (p priority) Distribute(supply Supply, input float64, demands []Demand) []Supply {
+	parts := proportional{}.Distribute(supply, input, demands)
+	if supply.Solar < 0 {
+		return parts
+	}
+	pool := sumSupply(parts)
+
+	var first, rest []int
+	for i, d := range demands {
+		if _, ok := p.rank[d.ConsumerID]; ok {
+			first = append(first, i)
+		} else {
+			rest = append(rest, i)
+		}
+	}
+	sort.Slice(first, func(a, b int) bool {
+		return p.rank[demands[first[a]].ConsumerID] < p.rank[demands[first[b]].ConsumerID]
+	})
+	for _, i := range first {
+		parts[i] = takeSupply(&pool, demands[i].Usage)
+	}
+	spreadSupply(pool, demands, rest, parts)
+	return parts
+}
+
+// contracted guarantees each consumer with a contract its share of the
+// solar energy used by the consumers, up to its usage. Unused contracted
+// solar, the battery and the grid are shared in proportion to the usage
+// that is still uncovered.
+type contracted struct {
+	shares map[string]float64
+}
+
+func (c contracted) Distribute(supply Supply, input float64, demands []Demand) []Supply {
+	parts := proportional{}.Distribute(supply, input, demands)
+	if supply.Solar < 0 {
+		return parts
+	}
+	pool := sumSupply(parts)
+
+	solar := pool.Solar
+	all := make([]int, len(demands))
+	covered := make([]float64, len(demands))
+	for i, d := range demands {
+		all[i] = i
+		covered[i] = min(d.Usage, c.shares[d.ConsumerID]*solar)
+		pool.Solar -= covered[i]
+	}
+
+	var need float64
+	for i, d := range demands {
+		need += d.Usage - covered[i]
+	}
+	for i, d := range demands {
+		parts[i] = Supply{Solar: covered[i]}
+		if need > 0 {
+			part := (d.Usage - covered[i]) / need
+			parts[i].Solar += pool.Solar * part
+			parts[i].Battery = pool.Battery * part
+			parts[i].Grid = pool.Grid * part
+		}
+	}
+	return parts
+}
+
+// firstCome serves the EV chargers that are charging in the order their
+// sessions started, each first from solar, then from the battery and then
+// from the grid. Chargers that started in the same interval go by ID. The
+// other consumers share what is left in proportion to their usage.
+type firstCome struct{}
+
+func (firstCome) Distribute(supply Supply, input float64, demands []Demand) []Supply {
+	parts := proportional{}.Distribute(supply, input, demands)
+	if supply.Solar < 0 {
+		return parts
+	}
+	pool := sumSupply(parts)
+
+	var first, rest []int
+	for i, d := range demands {
+		if !d.Since.IsZero() {
+			first = append(first, i)
+		} else {
+			rest = append(rest, i)
+		}
+	}
+	// the demands come sorted by ID
+	sort.SliceStable(first, func(a, b int) bool {
+		return demands[first[a]].Since.Before(demands[first[b]].Since)
+	})
+	for _, i := range first {
+		parts[i] = takeSupply(&pool, demands[i].Usage)
+	}
+	spreadSupply(pool, demands, rest, parts)
+	return parts
+}
+
+// batteryFirst gives the battery discharge of the consumers to the listed
+// consumers in order, up to their usage. The remaining battery energy goes
+// to the other consumers in proportion to their usage. Every consumer keeps
+// the solar energy of the inner strategy as far as its usage allows, the
+// rest of the solar and the grid energy covers what is still uncovered.
+type batteryFirst struct {
+	inner Distributor
+	rank  map[string]int
+}
+
+func (b batteryFirst) Distribute(supply Supply, input float64, demands []Demand) []Supply {
+	parts := b.inner.Distribute(supply, input, demands)
+	if supply.Solar < 0 || supply.Battery <= 0 {
+		return parts
+	}
+	pool := sumSupply(parts)
+
+	var first, rest []int
+	for i, d := range demands {
+		if _, ok := b.rank[d.ConsumerID]; ok {
+			first = append(first, i)
+		} else {
+			rest = append(rest, i)
+		}
+	}
+	if len(first) == 0 {
+		return parts
+	}
+	sort.Slice(first, func(x, y int) bool {
+		return b.rank[demands[first[x]].ConsumerID] < b.rank[demands[first[y]].ConsumerID]
+	})
+
+	battery := make([]float64, len(demands))
+	for _, i := range first {
+		battery[i] = min(demands[i].Usage, pool.Battery)
+		pool.Battery -= battery[i]
+	}
+	var restUsage float64
+	for _, i := range rest {
+		restUsage += demands[i].Usage
+	}
+	for _, i := range rest {
+		if restUsage > 0 {
+			battery[i] = pool.Battery * demands[i].Usage / restUsage
+		}
+	}
+
+	var need float64
+	for i, d := range demands {
+		solar := min(parts[i].Solar, d.Usage-battery[i])
+		pool.Solar -= solar
+		parts[i] = Supply{Solar: solar, Battery: battery[i]}
+		need += d.Usage - solar - battery[i]
+	}
+	if need <= 0 {
+		return parts
+	}
+	for i, d := range demands {
+		part := (d.Usage - parts[i].Solar - battery[i]) / need
+		parts[i].Solar += pool.Solar * part
+		parts[i].Grid = pool.Grid * part
+	}
+	return parts
+}
+
+// sumSupply returns the total of all parts
+func sumSupply(parts []Supply) Supply {
+	var sum Supply
+	for _, part := range parts {
+		sum.Solar += part.Solar
+		sum.Battery += part.Battery
+		sum.Grid += part.Grid
+	}
+	return sum
+}
+
+// takeSupply covers usage from the pool, solar first, then battery, then
+// grid, and removes it from the pool
+func takeSupply(pool *Supply, usage float64) Supply {
+	var part Supply
+	part.Solar = min(max(pool.Solar, 0), usage)
+	part.Battery = min(max(pool.Battery, 0), usage-part.Solar)
+	part.Grid = usage - part.Solar - part.Battery
+	pool.Solar -= part.Solar
+	pool.Battery -= part.Battery
+	pool.Grid -= part.Grid
+	return part
+}
+
+// spreadSupply shares the pool among the demands at the given indexes in
+// proportion to their usage
+func spreadSupply(pool Supply, demands []Demand, indexes []int, parts []Supply) {
+	var usage float64
+	for _, i := range indexes {
+		usage += demands[i].Usage
+	}
+	if usage <= 0 {
+		return
+	}
+	for _, i := range indexes {
+		part := demands[i].Usage / usage
+		parts[i] = Supply{Solar: pool.Solar * part, Battery: pool.Battery * part, Grid: pool.Grid * part}
+	}
+}
@@ -0,0 +1,66 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+func TestFirstCome(t *testing.T) {
+	distributor, err := NewDistributor(config.DistributionConfig{Strategy: config.DistributionFirstCome})
+	if err != nil {
+		t.Fatal(err)
+	}
+	early := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+
+	// 3 kWh of solar, 1 kWh from the battery, 2 kWh from the grid; the
+	// charger that started first takes solar and battery before the later
+	// one, the flat shares the grid with the later charger
+	supply := Supply{Solar: 3000, Battery: 1000, Grid: 2000}
+	demands := []Demand{
+		{ConsumerID: "ev-a", Usage: 2500, Since: late},
+		{ConsumerID: "ev-b", Usage: 2500, Since: early},
+		{ConsumerID: "flat", Usage: 1000},
+	}
+	want := []Supply{
+		{Solar: 500, Battery: 1000, Grid: 1000},
+		{Solar: 2500},
+		{Grid: 1000},
+	}
+	parts := distributor.Distribute(supply, supply.Total(), demands)
+	for i, part := range parts {
+		if !sameSupply(part, want[i]) {
+			t.Errorf("%s gets %+v, want %+v", demands[i].ConsumerID, part, want[i])
+		}
+	}
+	if sum := sumSupply(parts); !sameSupply(sum, supply) {
+		t.Errorf("distributed %+v, want %+v", sum, supply)
+	}
+}
+
+// sameSupply reports whether a and b match up to rounding errors
+func sameSupply(a, b Supply) bool {
+	return math.Abs(a.Solar-b.Solar) < 1e-6 && math.Abs(a.Battery-b.Battery) < 1e-6 && math.Abs(a.Grid-b.Grid) < 1e-6
+}
+
+func TestChargingSince(t *testing.T) {
+	start := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	// the charger draws 2 kW, pauses and draws again; 1 kW is not charging
+	usage := []float64{0, 500, 500, 250, 500, 0}
+	ea := &EnergyAnalyzer{config: &config.Config{ZEV: config.ZEVConfig{EVChargerIDs: []string{"ev"}}}}
+	for i, wh := range usage {
+		at := start.Add(time.Duration(i) * IntervalSeconds * time.Second)
+		ea.intervals = append(ea.intervals, &IntervalData{Start: at, ConsumerUsage: map[string]float64{"ev": wh}})
+	}
+	since := ea.chargingSince()["ev"]
+	session := func(i int) time.Time { return start.Add(time.Duration(i) * IntervalSeconds * time.Second) }
+	want := []time.Time{{}, session(1), session(1), {}, session(4), {}}
+	for i := range want {
+		if !since[i].Equal(want[i]) {
+			t.Errorf("interval %d: charging since %s, want %s", i, since[i], want[i])
+		}
+	}
+}
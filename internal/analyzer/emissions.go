@@ -0,0 +1,51 @@
+package analyzer
+
+import "zevalizer/internal/config"
+
+// ConsumerEmissions is the CO₂ footprint of one consumer
+type ConsumerEmissions struct {
+	ID        string  `json:"id,omitempty"`
+	Name      string  `json:"name"`
+	Energy    float64 `json:"energyWh"`
+	CO2       float64 `json:"co2Kg"`
+	Intensity float64 `json:"intensityGPerKwh"`
+}
+
+// EmissionsReport holds the CO₂ footprint per consumer and of the ZEV
+type EmissionsReport struct {
+	Consumers []ConsumerEmissions `json:"consumers"`
+	CO2       float64             `json:"co2Kg"`
+	Intensity float64             `json:"intensityGPerKwh"`
+	// Emissions of the same consumption covered entirely by the grid
+	GridOnlyCO2 float64 `json:"gridOnlyCo2Kg"`
+}
+
+// Emissions returns the CO₂ footprint of the consumers in stats. Grid
+// energy, including battery energy charged from the grid, is weighted with
+// the grid mix, solar energy with the PV factor, and all battery energy
+// additionally with the battery factor for storage losses.
+func Emissions(stats *EnergyStats, factors config.EmissionFactors) *EmissionsReport {
+	report := &EmissionsReport{Consumers: []ConsumerEmissions{}}
+	var energy float64
+	for i := range stats.Consumers {
+		consumer := &stats.Consumers[i]
+		grams := (consumer.Sources.FromGrid+consumer.Sources.FromBatteryGrid)/1000*factors.Grid +
+			(consumer.Sources.FromInverter+consumer.Sources.FromBatterySolar)/1000*factors.PV +
+			consumer.Sources.FromBattery/1000*factors.Battery
+		emissions := ConsumerEmissions{Name: consumer.Name(), Energy: consumer.Total, CO2: grams / 1000}
+		if !synthetic(consumer) {
+			emissions.ID = consumer.Sensor.ID
+		}
+		if consumer.Total > 0 {
+			emissions.Intensity = grams / (consumer.Total / 1000)
+		}
+		report.Consumers = append(report.Consumers, emissions)
+		report.CO2 += emissions.CO2
+		energy += consumer.Total
+	}
+	if energy > 0 {
+		report.Intensity = report.CO2 * 1000 / (energy / 1000)
+	}
+	report.GridOnlyCO2 = energy / 1000 * factors.Grid / 1000
+	return report
+}
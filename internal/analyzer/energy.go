@@ -3,54 +3,210 @@
 package analyzer
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"slices"
+	"sort"
 	"time"
 
+	"zevalizer/internal/billing"
 	"zevalizer/internal/config"
 	"zevalizer/internal/models"
+	"zevalizer/internal/tariff"
 )
 
 const (
 	// IntervalSeconds is the duration of each analysis interval (15 minutes)
 	IntervalSeconds = 900
 
-	// MaxGridReadingDiffWh is the maximum reasonable grid import/export per interval (30 kWh).
+	// MaxGridReadingDiffWh is the default maximum reasonable grid import/export per interval (30 kWh).
 	// Readings above this are considered anomalies and skipped.
 	MaxGridReadingDiffWh = 30000
 
-	// MaxProductionReadingWh is the maximum reasonable inverter production per interval (10 kWh).
+	// MaxProductionReadingWh is the default maximum reasonable inverter production per interval (10 kWh).
 	// Readings above this are considered anomalies and skipped.
 	MaxProductionReadingWh = 10000
 
-	// MaxConsumerReadingWh is the maximum reasonable consumer usage per interval (10 kWh).
+	// MaxConsumerReadingWh is the default maximum reasonable consumer usage per interval (10 kWh).
 	// Readings above this are considered anomalies and skipped.
 	MaxConsumerReadingWh = 10000
 )
 
+// Result bundles the statistics of one analysis run, split by tariff
+type Result struct {
+	From       time.Time    `json:"from"`
+	To         time.Time    `json:"to"`
+	LowTariff  *EnergyStats `json:"lowTariff"`
+	HighTariff *EnergyStats `json:"highTariff"`
+
+	// Per-day or per-month statistics across both tariffs, see Series
+	Aggregation string         `json:"aggregation,omitempty"`
+	Series      []*EnergyStats `json:"series,omitempty"`
+
+	// Share of intervals with readings per configured sensor
+	Completeness []SensorCompleteness `json:"completeness,omitempty"`
+	// The tariffs were split by the counters of dual-tariff grid meters
+	// where they advanced, see IsLowTariff
+	MeterTariff bool `json:"meterTariff,omitempty"`
+	// Meter counters that went backwards during the period
+	CounterResets []CounterReset `json:"counterResets,omitempty"`
+	// Consumer meters that look inverted, see InvertedMeter
+	InvertedMeters []InvertedMeter `json:"invertedMeters,omitempty"`
+	// Batteries without any activity for days, see IdleBattery
+	IdleBatteries []IdleBattery `json:"idleBatteries,omitempty"`
+	// Counter jumps rejected by the statistical outlier detection
+	Outliers []Outlier `json:"outliers,omitempty"`
+	// Per interval energy balance check, set by -validate
+	Balance *BalanceReport `json:"balance,omitempty"`
+	// Highest grid import intervals and monthly maxima, set by -peaks
+	Peaks *PeakReport `json:"peaks,omitempty"`
+	// Typical day of the period, set by -profile
+	Profile *LoadProfile `json:"profile,omitempty"`
+	// Always-on baseline per consumer, set by -standby
+	Standby []StandbyLoad `json:"standby,omitempty"`
+	// Subtotals per configured consumer group over both tariffs
+	Groups []ConsumerStats `json:"groups,omitempty"`
+	// Currency of the configured prices (savings, feed-in revenue)
+	Currency string `json:"currency,omitempty"`
+	// Savings by PV and battery against grid-only supply, with prices
+	Savings *SavingsReport `json:"savings,omitempty"`
+	// Bill of every consumer at the internal ZEV prices, with a solar tariff
+	Bill *billing.Bill `json:"bill,omitempty"`
+	// CO₂ footprint per consumer over both tariffs, with emission factors
+	Emissions *EmissionsReport `json:"emissions,omitempty"`
+	// Production and attributed export per PV plant, when there are several
+	Producers []ProducerStats `json:"producers,omitempty"`
+	// Import and export per grid meter, when there are several
+	GridMeters []GridMeterStats `json:"gridMeters,omitempty"`
+	// Monthly grid bill with energy and capacity cost, with a capacity tariff
+	GridBill *GridBill `json:"gridBill,omitempty"`
+	// Load per phase of the grid meters, with zev.phases
+	Phases []PhaseStats `json:"phases,omitempty"`
+	// Consumption by tariff and season per configured heat pump
+	HeatPumps []HeatPumpStats `json:"heatPumps,omitempty"`
+	// Throughput per battery system
+	Batteries []BatteryStats `json:"batteries,omitempty"`
+	// Battery state of charge, set by -soc
+	StateOfCharge []SocSummary `json:"stateOfCharge,omitempty"`
+	// Intervals with unaccounted energy and their meter readings, set by -diagnose
+	Diagnostics []IntervalDiagnosis `json:"diagnostics,omitempty"`
+	// Daily breakdown of a single consumer, set by -detail
+	Detail *ConsumerDetail `json:"detail,omitempty"`
+	// Charging sessions per EV charger, set by -ev
+	EVChargers []EVCharger `json:"evChargers,omitempty"`
+	// Battery and load shifting needed to cap the monthly peaks, set by -peak-shaving
+	PeakShaving *PeakShavingReport `json:"peakShaving,omitempty"`
+	// Suspicious patterns in the meter data, set by -anomalies
+	Anomalies []Anomaly `json:"anomalies,omitempty"`
+	// Daily production against the PV forecast, set by -forecast
+	Forecast *ForecastReport `json:"forecast,omitempty"`
+	// Grid exchange with alternative batteries, set by -simulate-battery
+	BatterySimulation *BatterySimulation `json:"batterySimulation,omitempty"`
+}
+
+// ErrNoData is returned when none of the configured meters delivered any
+// readings for the analysis period
+var ErrNoData = errors.New("no meter readings for the analysis period")
+
 // EnergyStats represents energy data for a time period
 type EnergyStats struct {
 	Period struct {
-		Start time.Time
-		End   time.Time
-	}
-	GridImport       float64
-	GridExport       float64
-	Production       float64
-	Consumption      float64
-	BatteryCharge    float64
-	BatteryDischarge float64
-	Consumers        []ConsumerStats
+		Start time.Time `json:"start"`
+		End   time.Time `json:"end"`
+	} `json:"period"`
+	GridImport       float64         `json:"gridImportWh"`
+	GridExport       float64         `json:"gridExportWh"`
+	Production       float64         `json:"productionWh"`
+	Consumption      float64         `json:"consumptionWh"`
+	BatteryCharge    float64         `json:"batteryChargeWh"`
+	BatteryDischarge float64         `json:"batteryDischargeWh"`
+	Consumers        []ConsumerStats `json:"consumers"`
+
+	// Part of BatteryCharge drawn from the grid, the rest came from PV
+	BatteryChargeFromGrid float64 `json:"batteryChargeFromGridWh"`
+
+	// Strategy attributing the shared usage per interval, if not residual
+	SharedStrategy string `json:"sharedStrategy,omitempty"`
+
+	// Allocation key used to distribute the shared usage onto the consumers
+	SharedAllocation string `json:"sharedAllocation,omitempty"`
+
+	// Valuation at spot prices, only set when spot prices are configured
+	GridImportCost    float64 `json:"gridImportCost,omitempty"`
+	GridExportRevenue float64 `json:"gridExportRevenue,omitempty"`
+	UnpricedIntervals int     `json:"unpricedIntervals,omitempty"` // intervals without a spot price
+
+	// Export valued at the configured feed-in tariff
+	FeedInRevenue float64 `json:"feedInRevenue,omitempty"`
+
+	// Import and export after offsetting them per tariff, with net metering
+	NetMetered bool    `json:"netMetered,omitempty"`
+	NetImport  float64 `json:"netImportWh,omitempty"`
+	NetExport  float64 `json:"netExportWh,omitempty"`
+
+	// Solar irradiation and the production per irradiation relative to the
+	// whole series, set on series entries when a weather location is configured
+	Irradiation  float64 `json:"irradiationKwhPerM2,omitempty"`
+	WeatherIndex float64 `json:"weatherIndexPercent,omitempty"`
 }
 
 // ConsumerStats represents energy usage for a single consumer
 type ConsumerStats struct {
-	Sensor  *models.Sensor
+	Sensor  *models.Sensor `json:"-"`
 	Sources struct {
-		FromInverter float64
-		FromBattery  float64
-		FromGrid     float64
+		FromInverter float64 `json:"fromInverterWh"`
+		FromBattery  float64 `json:"fromBatteryWh"`
+		// FromBattery split by the origin of the stored energy
+		FromBatterySolar float64 `json:"fromBatterySolarWh"`
+		FromBatteryGrid  float64 `json:"fromBatteryGridWh"`
+		FromGrid         float64 `json:"fromGridWh"`
+	} `json:"sources"`
+	Total    float64 `json:"totalWh"`
+	GridCost float64 `json:"gridCost,omitempty"` // grid energy valued at spot prices
+
+	// Part of Total allocated from the shared usage (see allocateShared)
+	SharedAllocated float64 `json:"sharedAllocatedWh,omitempty"`
+
+	sharedPart float64 // share of the shared usage received by the allocation key
+}
+
+// MarshalJSON identifies the consumer by sensor ID and name instead of
+// serializing the complete sensor record
+func (cs ConsumerStats) MarshalJSON() ([]byte, error) {
+	type plain ConsumerStats
+	var id, name string
+	if cs.Sensor != nil {
+		id = cs.Sensor.ID
+		name = cs.Sensor.Tag.Name
+	}
+	return json.Marshal(struct {
+		ID           string  `json:"id,omitempty"`
+		Name         string  `json:"name"`
+		AutarchyRate float64 `json:"autarchyRate"`
+		plain
+	}{id, name, cs.AutarchyRate(), plain(cs)})
+}
+
+// AutarchyRate calculates the percentage of the consumer's usage covered by
+// local production, directly or through the battery (grid charged battery
+// energy does not count)
+func (cs *ConsumerStats) AutarchyRate() float64 {
+	if cs.Total <= 0 {
+		return 0
+	}
+	return (cs.Sources.FromInverter + cs.Sources.FromBatterySolar) / cs.Total * 100
+}
+
+// SelfConsumptionRate calculates the percentage of the system's production
+// that was consumed by this consumer, directly or through the battery
+func (cs *ConsumerStats) SelfConsumptionRate(production float64) float64 {
+	if production <= 0 {
+		return 0
 	}
-	Total float64
+	return (cs.Sources.FromInverter + cs.Sources.FromBatterySolar) / production * 100
 }
 
 // SelfConsumptionRate calculates the percentage of produced energy that was consumed locally
@@ -82,14 +238,25 @@ type IntervalData struct {
 	BatteryCharge            float64
 	BatteryDischarge         float64
 	ConsumerUsage            map[string]float64 // key: consumer ID
+
+	BatteryChargeFromGrid float64 // part of BatteryCharge drawn from the grid
+	BatteryGridShare      float64 // share of BatteryDischarge originally charged from the grid
+
+	// Tariff counter (1 or 2) a dual-tariff grid meter counted the interval
+	// in, 0 if unknown
+	MeterTariff int
+
+	// Usage per consumer split by source, set by the stats calculation
+	ConsumerSources map[string]Supply
 }
 
 // DataFetcher is an interface for fetching data from the API
-// Both api.Client and cache.CachedClient implement this interface
+// Both api.Client and cache.CachedClient implement this interface.
+// Implementations must be safe for concurrent use.
 type DataFetcher interface {
-	GetZevData(smId string, from, to time.Time) ([]models.ZevData, error)
-	GetSensorData(smId string, sensorID string, from, to time.Time) ([]models.SensorData, error)
-	GetSensors(smID string) ([]models.Sensor, error)
+	GetZevData(ctx context.Context, smId string, from, to time.Time) ([]models.ZevData, error)
+	GetSensorData(ctx context.Context, smId string, sensorID string, from, to time.Time) ([]models.SensorData, error)
+	GetSensors(ctx context.Context, smID string) ([]models.Sensor, error)
 }
 
 type EnergyAnalyzer struct {
@@ -97,11 +264,46 @@ type EnergyAnalyzer struct {
 	config    *config.Config
 	sensorMap map[string]*models.Sensor
 	intervals []*IntervalData
+	tariff    *tariff.Schedule
+	// Tariff counter of dual-tariff grid meters that is the low tariff, 0
+	// to ignore the counters; meterTariffUsed is set once an interval was
+	// assigned by them
+	lowMeterTariff  int
+	meterTariffUsed bool
+	prices          *tariff.SpotPrices // nil without spot prices
+
+	coverage     map[string][]bool // sensor ID -> interval index -> reading received
+	completeness []SensorCompleteness
+	resets       []CounterReset
+
+	outlierWindows map[string][]float64 // sensor/counter -> recent accepted Wh per interval
+	outliers       []Outlier
+	balance        BalanceReport
+	anomalies      []Anomaly
+	counterAdvance map[string][2]float64 // consumer ID -> advance of the read and the other counter
+	batteryPool    batteryPool
+	soc            map[string][]SocPoint  // battery ID -> state of charge readings
+	idleRuns       map[string]IdleBattery // battery ID -> idle days so far
+	idleBatteries  []IdleBattery          // finished idle runs
+	phases         map[string]*phaseSums  // grid meter ID -> phase readings so far
+	billMonths     []GridBillMonth        // grid bill per month so far
+
+	batteryThroughput map[string][2]float64 // battery ID -> charge, discharge Wh
+	gridExchange      map[string][2]float64 // grid meter ID -> import, export Wh
+	splitMeter        map[string]string     // virtual consumer ID -> split meter ID
+	distributor       Distributor
+	heatPumps         map[string]*HeatPumpStats // heat pump ID -> usage so far
+	producers         map[string]*ProducerStats // production meter ID -> production and export so far
+
+	raw     map[rawKey][]float64 // meter energy per interval, for Diagnose
+	rawKeys []rawKey             // keys of raw in the order they were read
+
+	now time.Time // time the analysis runs at, zero for the current time
 }
 
 func (ea *EnergyAnalyzer) debugf(format string, args ...interface{}) {
 	if ea.config.Debug {
-		fmt.Printf("DEBUG: "+format+"\n", args...)
+		fmt.Fprintf(os.Stderr, "DEBUG: "+format+"\n", args...)
 	}
 }
 
@@ -113,23 +315,55 @@ func NewEnergyAnalyzer(client DataFetcher, config *config.Config) *EnergyAnalyze
 	}
 }
 
-// isLowTariffHour checks if a given hour falls within the low tariff period.
-// Handles both overnight periods (e.g., 22:00-06:00) and daytime periods (e.g., 06:00-22:00).
-func (ea *EnergyAnalyzer) isLowTariffHour(hour int) bool {
-	start := ea.config.LowTariff.StartHour
-	end := ea.config.LowTariff.EndHour
+// SetNow fixes the time the analysis treats as the current time, which
+// decides the intervals and days that are over. Repeating an analysis from
+// recorded readings sets the time of the original run.
+func (ea *EnergyAnalyzer) SetNow(now time.Time) {
+	ea.now = now
+}
+
+// currentTime returns the time set with SetNow, or else the current time
+func (ea *EnergyAnalyzer) currentTime() time.Time {
+	if ea.now.IsZero() {
+		return time.Now()
+	}
+	return ea.now
+}
+
+// Intervals returns the per-interval data of the last analysis
+func (ea *EnergyAnalyzer) Intervals() []*IntervalData {
+	return ea.intervals
+}
+
+// Sensor returns the sensor with the given ID, or nil if it is unknown
+func (ea *EnergyAnalyzer) Sensor(id string) *models.Sensor {
+	return ea.sensorMap[id]
+}
 
-	if start > end {
-		// Overnight period (e.g., 22:00 - 06:00)
-		return hour >= start || hour < end
+// Tariff returns the low tariff schedule of the last analysis
+func (ea *EnergyAnalyzer) Tariff() *tariff.Schedule {
+	return ea.tariff
+}
+
+// IsLowTariff reports whether the interval starting at t belongs to the low
+// tariff period: by the tariff counter a dual-tariff grid meter advanced in
+// that interval, else by the schedule
+func (ea *EnergyAnalyzer) IsLowTariff(t time.Time) bool {
+	if index := ea.intervalIndex(t); index >= 0 && ea.intervals[index].MeterTariff != 0 {
+		return ea.intervals[index].MeterTariff == ea.lowMeterTariff
 	}
-	// Daytime period (e.g., 06:00 - 22:00)
-	return hour >= start && hour < end
+	return ea.tariff.IsLow(t)
+}
+
+// MeterTariffUsed reports whether dual-tariff grid meters decided the tariff
+// of any interval in the last analysis
+func (ea *EnergyAnalyzer) MeterTariffUsed() bool {
+	return ea.meterTariffUsed
 }
 
 // loadSensors initializes the sensor map
-func (ea *EnergyAnalyzer) loadSensors(smId string) error {
-	sensors, err := ea.client.GetSensors(smId)
+func (ea *EnergyAnalyzer) loadSensors(ctx context.Context, smId string) error {
+	sensors, err := ea.client.GetSensors(ctx, smId)
 	if err != nil {
 		return fmt.Errorf("getting sensors: %w", err)
 	}
@@ -155,33 +389,164 @@ func (ea *EnergyAnalyzer) loadSensors(smId string) error {
 	return nil
 }
 
-func (ea *EnergyAnalyzer) Analyze(smId string, from, to time.Time) (*EnergyStats, *EnergyStats, error) {
+func (ea *EnergyAnalyzer) Analyze(ctx context.Context, smId string, from, to time.Time) (*EnergyStats, *EnergyStats, error) {
+	if err := ea.prepare(ctx, smId); err != nil {
+		return nil, nil, err
+	}
+	// Start fetching one interval early so the first interval gets its
+	// counter difference and is not reported as a gap
+	return ea.analyzeRange(ctx, smId, from, to, from.Add(-IntervalSeconds*time.Second))
+}
+
+// prepare validates the configuration and loads everything that does not
+// depend on the analysis period
+func (ea *EnergyAnalyzer) prepare(ctx context.Context, smId string) error {
 	// Validate inverter efficiency config
 	eff := ea.config.ZEV.InverterEfficiency
 	if eff != 0 && (eff < 0 || eff > 1) {
-		return nil, nil, fmt.Errorf("invalid inverterEfficiency %.2f: must be between 0 and 1", eff)
+		return fmt.Errorf("%w: inverterEfficiency %.2f must be between 0 and 1", config.ErrInvalid, eff)
+	}
+
+	if ea.config.ZEV.MaxInterpolationMinutes < 0 {
+		return fmt.Errorf("%w: maxInterpolationMinutes %d must not be negative", config.ErrInvalid, ea.config.ZEV.MaxInterpolationMinutes)
+	}
+
+	// Build the tariff schedule; equal hours mean there is no low tariff window
+	schedule, err := tariff.New(ea.config.LowTariff)
+	if err != nil {
+		return err
+	}
+	ea.tariff = schedule
+	switch counters := ea.config.LowTariff.MeterCounters; counters {
+	case "", config.MeterCountersTariff2:
+		ea.lowMeterTariff = 2
+	case config.MeterCountersTariff1:
+		ea.lowMeterTariff = 1
+	case config.MeterCountersOff:
+		ea.lowMeterTariff = 0
+	default:
+		return fmt.Errorf("%w: lowTariff meterCounters %q must be %s, %s or %s", config.ErrInvalid, counters,
+			config.MeterCountersTariff2, config.MeterCountersTariff1, config.MeterCountersOff)
+	}
+
+	if ea.config.Spot.File != "" {
+		prices, err := tariff.LoadSpotPrices(ea.config.Spot)
+		if err != nil {
+			return fmt.Errorf("loading spot prices: %w", err)
+		}
+		ea.prices = prices
+	}
+
+	if err := ea.validateReadingLimits(); err != nil {
+		return err
+	}
+	if err := ea.validateOutliers(); err != nil {
+		return err
+	}
+	if err := ea.validateTopology(); err != nil {
+		return err
+	}
+	if err := ea.validateAliases(); err != nil {
+		return err
+	}
+	if err := ea.validateCombine(); err != nil {
+		return err
+	}
+	if err := ea.validateSplits(); err != nil {
+		return err
+	}
+	if err := ea.validateGroups(); err != nil {
+		return err
+	}
+	if err := ea.validateBaseFees(); err != nil {
+		return err
+	}
+	if err := ea.validateTenants(); err != nil {
+		return err
+	}
+	if err := ea.validateBillingKeys(); err != nil {
+		return err
+	}
+	if err := ea.validateAllocation(); err != nil {
+		return err
+	}
+	if err := ea.validateDistribution(); err != nil {
+		return err
+	}
+	if err := ea.validateEVChargers(); err != nil {
+		return err
+	}
+	if err := ea.validateHeatPumps(); err != nil {
+		return err
+	}
+	if err := ea.validatePeakPower(); err != nil {
+		return err
+	}
+
+	// Validate sensor data modes
+	for id, mode := range ea.config.ZEV.SensorModes {
+		if mode != config.SensorModeCounter && mode != config.SensorModePower {
+			return fmt.Errorf("%w: sensor mode %q for sensor %s must be %q or %q",
+				config.ErrInvalid, mode, id, config.SensorModeCounter, config.SensorModePower)
+		}
+		// only the inverters are read from power samples
+		if mode == config.SensorModePower && !slices.Contains(ea.config.ZEV.ProductionIDs, id) {
+			return fmt.Errorf("%w: sensor mode %q for sensor %s is only supported for productionIds",
+				config.ErrInvalid, mode, id)
+		}
 	}
 
 	// Initialize data structures
-	if err := ea.loadSensors(smId); err != nil {
-		return nil, nil, fmt.Errorf("loading sensors: %w", err)
+	ea.completeness = nil
+	ea.resets = nil
+	ea.outliers = nil
+	ea.balance = BalanceReport{}
+	ea.anomalies = nil
+	ea.counterAdvance = make(map[string][2]float64)
+	ea.batteryPool = batteryPool{}
+	ea.soc = make(map[string][]SocPoint)
+	ea.idleRuns = make(map[string]IdleBattery)
+	ea.idleBatteries = nil
+	ea.meterTariffUsed = false
+	ea.phases = make(map[string]*phaseSums)
+	ea.billMonths = nil
+	ea.batteryThroughput = make(map[string][2]float64)
+	ea.gridExchange = make(map[string][2]float64)
+	ea.heatPumps = make(map[string]*HeatPumpStats)
+	ea.producers = make(map[string]*ProducerStats)
+	if err := ea.loadSensors(ctx, smId); err != nil {
+		return fmt.Errorf("loading sensors: %w", err)
 	}
+	ea.registerSplits()
+	return nil
+}
 
+// analyzeRange collects the data of [from, to] and calculates the statistics
+// per tariff. Data is fetched from fetchFrom on, so counter readings just
+// before the period can provide the first difference.
+func (ea *EnergyAnalyzer) analyzeRange(ctx context.Context, smId string, from, to, fetchFrom time.Time) (*EnergyStats, *EnergyStats, error) {
 	// Create intervals array covering the entire period
+	ea.intervals = nil
+	ea.coverage = make(map[string][]bool)
+	ea.raw = make(map[rawKey][]float64)
+	ea.rawKeys = nil
+	ea.outlierWindows = make(map[string][]float64)
 	ea.createIntervals(from, to)
 	ea.debugf("Created %d intervals for analysis", len(ea.intervals))
 
-	// Collect data for each source
-	data, err := ea.client.GetZevData(smId, from, to)
+	// Fetch the data of all sources concurrently, then collect it
+	data, sensorData, err := ea.fetchAll(ctx, smId, ea.sensorDataIDs(), fetchFrom, to)
 	if err != nil {
 		return nil, nil, err
 	}
+	data, sensorData = ea.resolveAliases(data, sensorData)
+	ea.scanDuplicates(data, sensorData)
 
 	if err := ea.collectGridData(data); err != nil {
 		return nil, nil, fmt.Errorf("collecting grid data: %w", err)
 	}
 
-	if err := ea.collectInverterData(data); err != nil {
+	if err := ea.collectInverterData(data, sensorData); err != nil {
 		return nil, nil, fmt.Errorf("collecting inverter data: %w", err)
 	}
 
@@ -189,9 +554,25 @@ func (ea *EnergyAnalyzer) Analyze(smId string, from, to time.Time) (*EnergyStats
 		return nil, nil, fmt.Errorf("collecting consumer data: %w", err)
 	}
 
-	if err := ea.collectBatteryData(smId, from, to); err != nil {
+	if err := ea.collectBatteryData(sensorData); err != nil {
 		return nil, nil, fmt.Errorf("collecting battery data: %w", err)
 	}
+	ea.collectPhases(sensorData)
+	ea.subtractSubMeters()
+	ea.combineConsumers()
+	ea.splitConsumers()
+
+	now := ea.currentTime()
+	ea.measureCompleteness(now)
+	ea.trackBatteryActivity(now)
+	if !ea.hasReadings() {
+		return nil, nil, ErrNoData
+	}
+	ea.checkBalance()
+	ea.trackBatteryOrigin()
+	ea.scanAnomalies()
+	ea.trackGridBill()
+	ea.attributeExport()
 
 	// Process intervals and create final statistics
 	statLowTariff, err := ea.calculateStats(true)
@@ -202,9 +583,31 @@ func (ea *EnergyAnalyzer) Analyze(smId string, from, to time.Time) (*EnergyStats
 	if err != nil {
 		return nil, nil, fmt.Errorf("calculating high tariff stats: %w", err)
 	}
+	ea.collectHeatPumps()
 	return statLowTariff, statHighTariff, nil
 }
 
+// hasReadings reports whether any interval received a non-zero reading
+func (ea *EnergyAnalyzer) hasReadings() bool {
+	for _, interval := range ea.intervals {
+		if interval.GridImport != 0 || interval.GridExport != 0 ||
+			interval.InverterGeneratedPower != 0 ||
+			interval.BatteryCharge != 0 || interval.BatteryDischarge != 0 {
+			return true
+		}
+		for _, usage := range interval.ConsumerUsage {
+			if usage != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// createIntervals splits [from, to) into 900 second intervals. The intervals
+// advance in absolute time, so a day with a daylight saving time switch gets
+// 92 or 100 intervals instead of 96, and the repeated hour in autumn shows up
+// as two sets of intervals with the same wall clock hour.
 func (ea *EnergyAnalyzer) createIntervals(from, to time.Time) {
 	interval := time.Duration(IntervalSeconds) * time.Second
 	current := from
@@ -225,58 +628,122 @@ func (ea *EnergyAnalyzer) createIntervals(from, to time.Time) {
 	}
 }
 
-// findInterval returns the interval containing the given time
-func (ea *EnergyAnalyzer) findInterval(t time.Time) *IntervalData {
-	for _, interval := range ea.intervals {
-		if (t.Equal(interval.Start) || t.After(interval.Start)) && t.Before(interval.End) {
-			return interval
-		}
+// intervalIndex returns the index of the interval containing t, or -1. All
+// intervals but the last are exactly IntervalSeconds long, so the index
+// follows from the distance to the first interval.
+func (ea *EnergyAnalyzer) intervalIndex(t time.Time) int {
+	if len(ea.intervals) == 0 || t.Before(ea.intervals[0].Start) {
+		return -1
 	}
-	return nil
+	index := int(t.Sub(ea.intervals[0].Start) / (IntervalSeconds * time.Second))
+	if index >= len(ea.intervals) || !t.Before(ea.intervals[index].End) {
+		return -1
+	}
+	return index
 }
 
 func (ea *EnergyAnalyzer) collectGridData(data []models.ZevData) error {
-	if ea.config.ZEV.GridMeterID == "" {
-		return nil
+	for _, gridId := range ea.config.ZEV.GridMeters() {
+		for _, sensorData := range data {
+			if sensorData.SensorID != gridId {
+				continue
+			}
+			ea.collectGridMeter(gridId, sensorData.Data)
+		}
 	}
+	return nil
+}
+
+// collectGridMeter adds the import and export of one grid meter to the
+// intervals; with several grid connection points they are summed
+func (ea *EnergyAnalyzer) collectGridMeter(gridId string, data []models.ZevSensorData) {
+	for i := 1; i < len(data); i++ {
+		current := data[i]
+		previous := data[i-1]
 
-	for _, sensorData := range data {
-		if sensorData.SensorID != ea.config.ZEV.GridMeterID {
+		interval := ea.readingInterval(gridId, current.CreatedAt)
+		if interval == nil {
 			continue
 		}
 
-		// Process each data point
-		for i := 1; i < len(sensorData.Data); i++ {
-			current := sensorData.Data[i]
-			previous := sensorData.Data[i-1]
+		if previous.Delivery() == 0 && current.Delivery() != 0 {
+			continue
+		}
 
-			interval := ea.findInterval(current.CreatedAt)
-			if interval == nil {
-				continue
-			}
+		span, interpolate := ea.readingSpan(previous.CreatedAt, current.CreatedAt)
+		limit := ea.readingLimit(RoleGrid, gridId) * span
+		purchaseDiff := ea.counterDiff(gridId, "purchase", current.CreatedAt,
+			previous.Purchase(), current.Purchase(), limit)
+		deliveryDiff := ea.counterDiff(gridId, "delivery", current.CreatedAt,
+			previous.Delivery(), current.Delivery(), limit)
 
-			if previous.CurrentEnergyDeliveryTariff1 == 0 && current.CurrentEnergyDeliveryTariff1 != 0 {
-				continue
-			}
+		if purchaseDiff > limit || deliveryDiff > limit {
+			ea.debugf("Skipping abnormal grid reading: purchase=%.1f delivery=%.1f",
+				purchaseDiff, deliveryDiff)
+			continue
+		}
+		if ea.rejectOutlier(gridId, "purchase", current.CreatedAt, purchaseDiff, span) ||
+			ea.rejectOutlier(gridId, "delivery", current.CreatedAt, deliveryDiff, span) {
+			continue
+		}
 
-			purchaseDiff := current.CurrentEnergyPurchaseTariff1 - previous.CurrentEnergyPurchaseTariff1
-			deliveryDiff := current.CurrentEnergyDeliveryTariff1 - previous.CurrentEnergyDeliveryTariff1
+		exchange := ea.gridExchange[gridId]
+		ea.gridExchange[gridId] = [2]float64{exchange[0] + purchaseDiff, exchange[1] + deliveryDiff}
+		counter := ea.meterTariff(previous, current)
+
+		if interpolate {
+			ea.distribute(previous.CreatedAt, current.CreatedAt, func(interval *IntervalData, fraction float64) {
+				ea.setMeterTariff(interval, counter)
+				interval.GridImport += purchaseDiff * fraction
+				interval.GridExport += deliveryDiff * fraction
+				ea.recordRaw(gridId, RoleGrid, FlowImport, interval, purchaseDiff*fraction)
+				ea.recordRaw(gridId, RoleGrid, FlowExport, interval, deliveryDiff*fraction)
+			})
+			continue
+		}
+		ea.setMeterTariff(interval, counter)
+		interval.GridImport += purchaseDiff
+		interval.GridExport += deliveryDiff
+		ea.recordRaw(gridId, RoleGrid, FlowImport, interval, purchaseDiff)
+		ea.recordRaw(gridId, RoleGrid, FlowExport, interval, deliveryDiff)
+	}
+}
 
-			if purchaseDiff > MaxGridReadingDiffWh || deliveryDiff > MaxGridReadingDiffWh {
-				ea.debugf("Skipping abnormal grid reading: purchase=%.1f delivery=%.1f",
-					purchaseDiff, deliveryDiff)
-				continue
-			}
+// meterTariff returns the tariff counter (1 or 2) of a dual-tariff grid
+// meter that advanced between two readings. It returns 0 for single-tariff
+// meters, when the counters are ignored and when both or neither advanced.
+func (ea *EnergyAnalyzer) meterTariff(previous, current models.ZevSensorData) int {
+	if ea.lowMeterTariff == 0 || !previous.DualTariff() || !current.DualTariff() {
+		return 0
+	}
+	tariff1 := current.CurrentEnergyPurchaseTariff1 - previous.CurrentEnergyPurchaseTariff1 +
+		current.CurrentEnergyDeliveryTariff1 - previous.CurrentEnergyDeliveryTariff1
+	tariff2 := current.CurrentEnergyPurchaseTariff2 - previous.CurrentEnergyPurchaseTariff2 +
+		current.CurrentEnergyDeliveryTariff2 - previous.CurrentEnergyDeliveryTariff2
+	switch {
+	case tariff1 > 0 && tariff2 <= 0:
+		return 1
+	case tariff2 > 0 && tariff1 <= 0:
+		return 2
+	}
+	return 0
+}
 
-			interval.GridImport += purchaseDiff
-			interval.GridExport += deliveryDiff
-		}
+// setMeterTariff assigns an interval to a meter tariff, the first grid
+// meter deciding
+func (ea *EnergyAnalyzer) setMeterTariff(interval *IntervalData, counter int) {
+	if counter != 0 && interval.MeterTariff == 0 {
+		interval.MeterTariff = counter
+		ea.meterTariffUsed = true
 	}
-	return nil
 }
 
-func (ea *EnergyAnalyzer) collectInverterData(data []models.ZevData) error {
+func (ea *EnergyAnalyzer) collectInverterData(data []models.ZevData, sensorData map[string][]models.SensorData) error {
 	for _, prodId := range ea.config.ZEV.ProductionIDs {
+		if ea.config.ZEV.SensorMode(prodId) == config.SensorModePower {
+			ea.collectInverterPowerData(prodId, sensorData[prodId])
+			continue
+		}
 
 		for _, sensorData := range data {
 			if sensorData.SensorID != prodId {
@@ -287,27 +754,44 @@ func (ea *EnergyAnalyzer) collectInverterData(data []models.ZevData) error {
 				current := sensorData.Data[i]
 				previous := sensorData.Data[i-1]
 
-				interval := ea.findInterval(current.CreatedAt)
+				interval := ea.readingInterval(prodId, current.CreatedAt)
 				if interval == nil {
 					continue
 				}
 
-				delivery := current.CurrentEnergyDeliveryTariff1 - previous.CurrentEnergyDeliveryTariff1
-				if delivery > MaxProductionReadingWh || delivery < 0 {
+				span, interpolate := ea.readingSpan(previous.CreatedAt, current.CreatedAt)
+				limit := ea.readingLimit(RoleProduction, prodId) * span
+				delivery := ea.counterDiff(prodId, "delivery", current.CreatedAt,
+					previous.Delivery(), current.Delivery(), limit)
+				if delivery > limit || delivery < 0 {
 					ea.debugf("Skipping abnormal delivery reading: %.1f", delivery)
 					continue
 				}
-				purchase := current.CurrentEnergyPurchaseTariff1 - previous.CurrentEnergyPurchaseTariff1
-				if purchase > MaxProductionReadingWh || purchase < 0 {
+				purchase := ea.counterDiff(prodId, "purchase", current.CreatedAt,
+					previous.Purchase(), current.Purchase(), limit)
+				if purchase > limit || purchase < 0 {
 					ea.debugf("Skipping abnormal purchase reading: %.1f", purchase)
 					continue
 				}
+				if ea.rejectOutlier(prodId, "delivery", current.CreatedAt, delivery, span) ||
+					ea.rejectOutlier(prodId, "purchase", current.CreatedAt, purchase, span) {
+					continue
+				}
+
+				if interpolate {
+					ea.distribute(previous.CreatedAt, current.CreatedAt, func(interval *IntervalData, fraction float64) {
+						interval.InverterGeneratedPower += (delivery - purchase) * fraction
+						ea.recordRaw(prodId, RoleProduction, FlowNet, interval, (delivery-purchase)*fraction)
+					})
+					continue
+				}
 
 				// Use NET formula: production = delivery - purchase
 				// This removes phantom power circulation from hybrid inverters
 				// Positive = inverter contributing energy (solar/battery)
 				// Negative = inverter consuming energy (standby, losses)
 				interval.InverterGeneratedPower += delivery - purchase
+				ea.recordRaw(prodId, RoleProduction, FlowNet, interval, delivery-purchase)
 				// Don't add purchase to InverterPowerConsumption separately -
 				// it's already accounted for in the NET calculation
 			}
@@ -316,18 +800,48 @@ func (ea *EnergyAnalyzer) collectInverterData(data []models.ZevData) error {
 	return nil
 }
 
-func (ea *EnergyAnalyzer) collectBatteryData(smId string, from, to time.Time) error {
-	for _, batteryId := range ea.config.ZEV.BatterySystemIDs {
-		data, err := ea.client.GetSensorData(smId, batteryId, from, to)
-		if err != nil {
-			return err
+// collectInverterPowerData integrates the instantaneous power samples of an
+// inverter that does not expose energy counters. Each pair of consecutive
+// samples contributes the trapezoidal area (W * s -> Wh), split at the
+// interval boundaries between them. Pairs further apart than two intervals
+// are treated as a gap and skipped rather than bridged.
+func (ea *EnergyAnalyzer) collectInverterPowerData(prodId string, data []models.SensorData) {
+	for i := 1; i < len(data); i++ {
+		current := data[i]
+		previous := data[i-1]
+
+		if ea.readingInterval(prodId, current.Date) == nil {
+			continue
+		}
+
+		seconds := current.Date.Sub(previous.Date).Seconds()
+		if seconds <= 0 || seconds > 2*IntervalSeconds {
+			ea.debugf("Skipping power sample gap of %.0fs for %s", seconds, prodId)
+			continue
 		}
 
+		// the limit is per interval, scale it to the time between the samples
+		energy := (previous.PowerW + current.PowerW) / 2 * seconds / 3600
+		if energy > ea.readingLimit(RoleProduction, prodId)*seconds/IntervalSeconds || energy < 0 {
+			ea.debugf("Skipping abnormal integrated production: %.1f", energy)
+			continue
+		}
+
+		ea.integrate(previous.Date, current.Date, previous.PowerW, current.PowerW, func(interval *IntervalData, energy float64) {
+			interval.InverterGeneratedPower += energy
+			ea.recordRaw(prodId, RoleProduction, FlowNet, interval, energy)
+		})
+	}
+}
+
+func (ea *EnergyAnalyzer) collectBatteryData(sensorData map[string][]models.SensorData) error {
+	for _, batteryId := range ea.config.ZEV.BatterySystemIDs {
+		data := sensorData[batteryId]
 		sensor := ea.sensorMap[batteryId]
 		for i := 1; i < len(data); i++ {
 			current := data[i]
 
-			interval := ea.findInterval(current.Date)
+			interval := ea.readingInterval(batteryId, current.Date)
 			if interval == nil {
 				continue
 			}
@@ -341,6 +855,13 @@ func (ea *EnergyAnalyzer) collectBatteryData(smId string, from, to time.Time) er
 			ea.debugf("%s, Battery: %s, Charge: %.1f kWh, Discharge: %.1f kWh", current.Date.Format("2006-01-02 15:04:05 MST"), batteryId, charge/1000, discharge/1000)
 			interval.BatteryCharge += charge
 			interval.BatteryDischarge += discharge
+			ea.recordRaw(batteryId, RoleBattery, FlowCharge, interval, charge)
+			ea.recordRaw(batteryId, RoleBattery, FlowDischarge, interval, discharge)
+			throughput := ea.batteryThroughput[batteryId]
+			ea.batteryThroughput[batteryId] = [2]float64{throughput[0] + charge, throughput[1] + discharge}
+			if current.SOC != nil {
+				ea.soc[batteryId] = append(ea.soc[batteryId], SocPoint{Time: current.Date, Percent: *current.SOC})
+			}
 		}
 	}
 	return nil
@@ -359,46 +880,109 @@ func (ea *EnergyAnalyzer) collectConsumerData(data []models.ZevData) error {
 				current := sensorData.Data[i]
 				previous := sensorData.Data[i-1]
 
-				interval := ea.findInterval(current.CreatedAt)
+				interval := ea.readingInterval(consumerId, current.CreatedAt)
 				if interval == nil {
 					continue
 				}
 
-				usage := current.CurrentEnergyPurchaseTariff1 - previous.CurrentEnergyPurchaseTariff1
+				span, interpolate := ea.readingSpan(previous.CreatedAt, current.CreatedAt)
+				counter, before, after := "purchase", previous.Purchase(), current.Purchase()
+				otherBefore, otherAfter := previous.Delivery(), current.Delivery()
 				if sensor.Data.InvertMeasurement {
-					usage = current.CurrentEnergyDeliveryTariff1 - previous.CurrentEnergyDeliveryTariff1
+					counter, before, after = "delivery", previous.Delivery(), current.Delivery()
+					otherBefore, otherAfter = previous.Purchase(), current.Purchase()
 				}
+				ea.trackCounterAdvance(consumerId, after-before, otherAfter-otherBefore)
+				limit := ea.readingLimit(RoleConsumer, consumerId) * span
+				usage := ea.counterDiff(consumerId, counter, current.CreatedAt, before, after, limit)
 
-				if usage > MaxConsumerReadingWh {
+				if usage > limit {
 					ea.debugf("Skipping abnormal consumer usage: %.1f", usage)
 					continue
 				}
+				if ea.rejectOutlier(consumerId, counter, current.CreatedAt, usage, span) {
+					continue
+				}
 
+				if interpolate {
+					ea.distribute(previous.CreatedAt, current.CreatedAt, func(interval *IntervalData, fraction float64) {
+						interval.ConsumerUsage[consumerId] += usage * fraction
+						ea.recordRaw(consumerId, RoleConsumer, FlowUsage, interval, usage*fraction)
+					})
+					continue
+				}
 				interval.ConsumerUsage[consumerId] += usage
+				ea.recordRaw(consumerId, RoleConsumer, FlowUsage, interval, usage)
 			}
 		}
 	}
 	return nil
 }
 
-func (ea *EnergyAnalyzer) calculateStats(lowTariff bool) (*EnergyStats, error) {
-	stats := &EnergyStats{}
+// StatsFor calculates the statistics of all intervals starting within
+// [from, to), across both tariffs. Analyze must have been called before.
+func (ea *EnergyAnalyzer) StatsFor(from, to time.Time) (*EnergyStats, error) {
+	stats, err := ea.calculateStatsFor("Range", func(interval *IntervalData) bool {
+		return !interval.Start.Before(from) && interval.Start.Before(to)
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats.Period.Start = from
+	stats.Period.End = to
+	return stats, nil
+}
+
+// DailyStats calculates one EnergyStats per local calendar day of [from, to]
+func (ea *EnergyAnalyzer) DailyStats(from, to time.Time) ([]*EnergyStats, error) {
+	var days []*EnergyStats
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for day.Before(to) {
+		next := day.AddDate(0, 0, 1)
+		stats, err := ea.StatsFor(day, next)
+		if err != nil {
+			return nil, fmt.Errorf("calculating stats for %s: %w", day.Format("2006-01-02"), err)
+		}
+		days = append(days, stats)
+		day = next
+	}
+	return days, nil
+}
 
+func (ea *EnergyAnalyzer) calculateStats(lowTariff bool) (*EnergyStats, error) {
+	label := "High-Tariff"
+	if lowTariff {
+		label = "Low-Tariff"
+	}
+	stats, err := ea.calculateStatsFor(label, func(interval *IntervalData) bool {
+		return ea.IsLowTariff(interval.Start) == lowTariff
+	})
+	if err != nil {
+		return nil, err
+	}
+	ea.applyNetMetering(stats)
 	if len(ea.intervals) > 0 {
 		stats.Period.Start = ea.intervals[0].Start
 		stats.Period.End = ea.intervals[len(ea.intervals)-1].End
 	}
+	return stats, nil
+}
+
+// calculateStatsFor distributes the energy of all intervals accepted by
+// include onto the consumers and accumulates the totals
+func (ea *EnergyAnalyzer) calculateStatsFor(label string, include func(*IntervalData) bool) (*EnergyStats, error) {
+	stats := &EnergyStats{}
 
 	// Initialize consumer stats
 	consumerStats := make(map[string]*ConsumerStats)
-	for _, consumerId := range ea.config.ZEV.ConsumerIDs {
+	for _, consumerId := range ea.ConsumerIDs() {
 		consumerStats[consumerId] = &ConsumerStats{
 			Sensor: ea.sensorMap[consumerId],
 		}
 	}
 
-	// Add special "shared" consumer
-	consumerStats["shared"] = &ConsumerStats{
+	// Add the shared consumer
+	consumerStats[SharedConsumerID] = &ConsumerStats{
 		Sensor: &models.Sensor{
 			Tag: models.SensorTag{
 				Name: "Shared Usage",
@@ -406,21 +990,21 @@ func (ea *EnergyAnalyzer) calculateStats(lowTariff bool) (*EnergyStats, error) {
 		},
 	}
 
+	// the first-come strategy serves the EV chargers in the order their
+	// charging sessions started
+	var charging map[string][]time.Time
+	if ea.config.ZEV.Distribution.Strategy == config.DistributionFirstCome {
+		charging = ea.chargingSince()
+	}
+
 	// Process each interval
-	for _, interval := range ea.intervals {
-		// Filter intervals based on tariff period
-		intervalIsLowTariff := ea.isLowTariffHour(interval.Start.Hour())
-		if intervalIsLowTariff != lowTariff {
+	for index, interval := range ea.intervals {
+		if !include(interval) {
 			continue
 		}
 
 		ea.debugf("\nProcessing %s interval: %s to %s",
-			func() string {
-				if lowTariff {
-					return "Low-Tariff"
-				}
-				return "High-Tariff"
-			}(),
+			label,
 			interval.Start.Format("15:04:05"),
 			interval.End.Format("15:04:05"))
 
@@ -439,13 +1023,32 @@ func (ea *EnergyAnalyzer) calculateStats(lowTariff bool) (*EnergyStats, error) {
 		stats.Consumption += interval.InverterPowerConsumption
 		stats.BatteryCharge += interval.BatteryCharge
 		stats.BatteryDischarge += interval.BatteryDischarge
+		stats.BatteryChargeFromGrid += interval.BatteryChargeFromGrid
+		prices := ea.config.Prices.At(interval.Start)
+		stats.FeedInRevenue += interval.GridExport / 1000 * prices.FeedInPrice(ea.IsLowTariff(interval.Start))
+
+		// Value grid exchange at the spot price of this interval
+		var price float64
+		if ea.prices != nil {
+			var ok bool
+			if price, ok = ea.prices.Price(interval.Start); ok {
+				stats.GridImportCost += interval.GridImport / 1000 * price
+				stats.GridExportRevenue += interval.GridExport / 1000 * price
+			} else {
+				stats.UnpricedIntervals++
+			}
+		}
 
 		// Calculate total energy input and consumption for this interval
 		totalInput := interval.GridImport + interval.InverterGeneratedPower
 
 		// Sum up all consumer usage for this interval
+		// (excluding a shared residual left over from an earlier calculation)
 		var totalEnergyConsumption float64
-		for _, usage := range interval.ConsumerUsage {
+		for consumerId, usage := range interval.ConsumerUsage {
+			if consumerId == SharedConsumerID {
+				continue
+			}
 			totalEnergyConsumption += usage
 		}
 
@@ -454,17 +1057,23 @@ func (ea *EnergyAnalyzer) calculateStats(lowTariff bool) (*EnergyStats, error) {
 
 		// Calculate sharedUseEnergy (shared) energy
 		sharedUseEnergy := totalInput - totalOutput
-		if sharedUseEnergy > 0 {
+		delete(interval.ConsumerUsage, SharedConsumerID)
+		sharedScale := ea.sharedScale(sharedUseEnergy, totalEnergyConsumption)
+		if sharedScale != 1 {
+			ea.debugf("Shared energy in interval: %.1f Wh, attributed to consumers with factor %.3f",
+				sharedUseEnergy, sharedScale)
+		} else if sharedUseEnergy > 0 {
 			ea.debugf("Shared energy in interval: %.1f Wh (Input: %.1f, Output: %.1f)",
 				sharedUseEnergy, totalInput, totalOutput)
 			// Add shared usage as a special consumer
-			interval.ConsumerUsage["shared"] = sharedUseEnergy
+			interval.ConsumerUsage[SharedConsumerID] = sharedUseEnergy
 		} else if sharedUseEnergy < -1 { // use -1 to account for small floating point differences
 			ea.debugf("Warning: Negative energy balance in interval: %.1f Wh (Input: %.1f, Output: %.1f)",
 				sharedUseEnergy, totalInput, totalOutput)
 		}
 
 		// Use totalInput as available energy for distribution
+		interval.ConsumerSources = nil
 		if totalInput <= 0 {
 			continue
 		}
@@ -491,47 +1100,65 @@ func (ea *EnergyAnalyzer) calculateStats(lowTariff bool) (*EnergyStats, error) {
 			solarContribution = 0
 		}
 
-		inverterShare := solarContribution / totalInput
-		batteryShare := batteryACContribution / totalInput
-		gridShare := interval.GridImport / totalInput
-
+		supply := Supply{Solar: solarContribution, Battery: batteryACContribution, Grid: interval.GridImport}
 		ea.debugf("Interval energy shares: Inverter=%.1f%% Battery=%.1f%% Grid=%.1f%% (consuming=%v)",
-			inverterShare*100, batteryShare*100, gridShare*100, inverterConsuming)
+			supply.Solar/totalInput*100, supply.Battery/totalInput*100, supply.Grid/totalInput*100, inverterConsuming)
 
-		// Distribute each consumer's usage according to source percentages
+		// Collect the usage of the interval, including the shared usage
+		// attributed to the consumers
+		var demands []Demand
 		for consumerId, usage := range interval.ConsumerUsage {
 			if usage <= 0 {
 				continue
 			}
-
-			consumer := consumerStats[consumerId]
-			consumer.Total += usage
-
-			if inverterConsuming && consumerId != "shared" {
-				// Regular consumers: don't show negative inverter, adjust grid share
-				// The inverter consumption is common power, attributed to Shared Usage
-				consumer.Sources.FromInverter += 0
-				consumer.Sources.FromBattery += usage * batteryShare
-				consumer.Sources.FromGrid += usage * (gridShare + inverterShare) // grid covers inverter consumption
-			} else {
-				// Normal case OR Shared Usage (which gets the inverter consumption)
-				consumer.Sources.FromInverter += usage * inverterShare
-				consumer.Sources.FromBattery += usage * batteryShare
-				consumer.Sources.FromGrid += usage * gridShare
+			shared := consumerId == SharedConsumerID
+			if !shared {
+				extra := usage * (sharedScale - 1)
+				consumerStats[consumerId].SharedAllocated += extra
+				usage += extra
+			}
+			demand := Demand{ConsumerID: consumerId, Usage: usage, Shared: shared}
+			if since := charging[consumerId]; since != nil {
+				demand.Since = since[index]
 			}
+			demands = append(demands, demand)
+		}
+		sort.Slice(demands, func(i, j int) bool { return demands[i].ConsumerID < demands[j].ConsumerID })
+
+		// Split the sources onto the consumers with the configured strategy
+		distributor := ea.distributor
+		if distributor == nil {
+			distributor = proportional{}
+		}
+		interval.ConsumerSources = make(map[string]Supply, len(demands))
+		for i, part := range distributor.Distribute(supply, totalInput, demands) {
+			interval.ConsumerSources[demands[i].ConsumerID] = part
+			consumer := consumerStats[demands[i].ConsumerID]
+			consumer.Total += demands[i].Usage
+			consumer.Sources.FromInverter += part.Solar
+			consumer.Sources.FromBattery += part.Battery
+			consumer.Sources.FromBatteryGrid += part.Battery * interval.BatteryGridShare
+			consumer.Sources.FromBatterySolar += part.Battery * (1 - interval.BatteryGridShare)
+			consumer.Sources.FromGrid += part.Grid
+			consumer.GridCost += part.Grid / 1000 * price
 
 			ea.debugf("Consumer %s interval usage: %.1f (Inverter: %.1f, Battery: %.1f, Grid: %.1f)",
-				consumer.Sensor.Tag.Name, usage,
-				usage*inverterShare,
-				usage*batteryShare,
-				usage*gridShare)
+				consumer.Sensor.Tag.Name, demands[i].Usage, part.Solar, part.Battery, part.Grid)
 		}
 	}
 
-	// Convert consumer stats map to slice
-	for _, consumerStat := range consumerStats {
-		stats.Consumers = append(stats.Consumers, *consumerStat)
+	// Convert consumer stats map to slice in config order, shared usage last
+	for _, consumerId := range ea.ConsumerIDs() {
+		if consumerStat, ok := consumerStats[consumerId]; ok {
+			stats.Consumers = append(stats.Consumers, *consumerStat)
+			delete(consumerStats, consumerId)
+		}
+	}
+	stats.Consumers = append(stats.Consumers, *consumerStats[SharedConsumerID])
+	if ea.config.ZEV.SharedStrategy == config.SharedStrategyProportional {
+		stats.SharedStrategy = config.SharedStrategyProportional
 	}
+	ea.allocateShared(stats)
 
 	return stats, nil
 }
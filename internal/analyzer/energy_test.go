@@ -0,0 +1,81 @@
+package analyzer
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // the switch days must not depend on the zoneinfo of the host
+
+	"zevalizer/internal/config"
+	"zevalizer/internal/tariff"
+)
+
+func TestCreateIntervalsDST(t *testing.T) {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := tariff.New(config.LowTariffConfig{StartHour: 22, EndHour: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		day       time.Time
+		intervals int
+		// intervals per wall clock hour, 4 unless listed
+		hours map[int]int
+		low   int
+	}{
+		// 02:00 to 03:00 is skipped, 00:00 to 06:00 and 22:00 to 24:00
+		// leave 7 low tariff hours
+		{"spring forward", time.Date(2025, 3, 30, 0, 0, 0, 0, zurich), 92, map[int]int{2: 0}, 28},
+		// 02:00 to 03:00 runs twice, 9 low tariff hours
+		{"fall back", time.Date(2025, 10, 26, 0, 0, 0, 0, zurich), 100, map[int]int{2: 8}, 36},
+		{"ordinary day", time.Date(2025, 10, 27, 0, 0, 0, 0, zurich), 96, nil, 32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ea := &EnergyAnalyzer{tariff: schedule}
+			ea.createIntervals(tt.day, tt.day.AddDate(0, 0, 1))
+
+			if len(ea.intervals) != tt.intervals {
+				t.Fatalf("got %d intervals, want %d", len(ea.intervals), tt.intervals)
+			}
+			hours := make(map[int]int)
+			var low int
+			for i, interval := range ea.intervals {
+				if d := interval.End.Sub(interval.Start); d != IntervalSeconds*time.Second {
+					t.Errorf("interval %d starting %s lasts %s", i, interval.Start, d)
+				}
+				if index := ea.intervalIndex(interval.Start); index != i {
+					t.Errorf("interval %d starting %s found at index %d", i, interval.Start, index)
+				}
+				hour := interval.Start.In(zurich).Hour()
+				hours[hour]++
+				isLow := ea.IsLowTariff(interval.Start)
+				if wantLow := hour < 6 || hour >= 22; isLow != wantLow {
+					t.Errorf("interval at %s: low tariff %v, want %v", interval.Start.In(zurich).Format("15:04 MST"), isLow, wantLow)
+				}
+				if isLow {
+					low++
+				}
+			}
+			for hour := 0; hour < 24; hour++ {
+				want, ok := tt.hours[hour]
+				if !ok {
+					want = 4
+				}
+				if hours[hour] != want {
+					t.Errorf("hour %02d has %d intervals, want %d", hour, hours[hour], want)
+				}
+			}
+			if low != tt.low || len(ea.intervals)-low != 64 {
+				t.Errorf("low/high tariff split %d/%d, want %d/64", low, len(ea.intervals)-low, tt.low)
+			}
+			if last := ea.intervals[len(ea.intervals)-1].End; !last.Equal(tt.day.AddDate(0, 0, 1)) {
+				t.Errorf("last interval ends %s, want the next midnight", last)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+// DefaultEVMinPowerW is the power above which an EV charger counts as
+// charging, below the lowest charging current of 6 A on one phase
+const DefaultEVMinPowerW = 1000
+
+// evKeywords identify EV chargers by the name of their consumer when no
+// chargers are configured
+var evKeywords = []string{"wallbox", "ev charger", "ev-charger", "charging station", "ladestation", "e-auto", "borne de recharge"}
+
+// EVSession is a run of contiguous intervals in which a charger charged
+type EVSession struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Energy     float64   `json:"energyWh"`
+	Solar      float64   `json:"solarWh"`   // directly from the inverter
+	Battery    float64   `json:"batteryWh"` // from the battery, of either origin
+	Grid       float64   `json:"gridWh"`
+	SolarShare float64   `json:"solarSharePercent"` // PV energy including the battery's solar part
+}
+
+// EVCharger holds the charging sessions of one charger
+type EVCharger struct {
+	SensorID   string      `json:"sensorId"`
+	Name       string      `json:"name"`
+	Energy     float64     `json:"energyWh"` // all sessions
+	SolarShare float64     `json:"solarSharePercent"`
+	Sessions   []EVSession `json:"sessions"`
+}
+
+// validateEVChargers checks that the configured chargers are reported
+// consumers
+func (ea *EnergyAnalyzer) validateEVChargers() error {
+	if ea.config.ZEV.EVMinPowerW < 0 {
+		return fmt.Errorf("%w: evMinPowerW must not be negative", config.ErrInvalid)
+	}
+	known := make(map[string]bool)
+	for _, id := range ea.ConsumerIDs() {
+		known[id] = true
+	}
+	for _, id := range ea.config.ZEV.EVChargerIDs {
+		if !known[id] {
+			return fmt.Errorf("%w: EV charger %s is not a reported consumer", config.ErrInvalid, id)
+		}
+	}
+	return nil
+}
+
+// evChargerIDs returns the configured EV chargers or, without any, the
+// consumers whose name contains one of the evKeywords
+func (ea *EnergyAnalyzer) evChargerIDs() []string {
+	if len(ea.config.ZEV.EVChargerIDs) > 0 {
+		return ea.config.ZEV.EVChargerIDs
+	}
+	var ids []string
+	for _, id := range ea.ConsumerIDs() {
+		sensor := ea.sensorMap[id]
+		if sensor == nil {
+			continue
+		}
+		name := strings.ToLower(sensor.Tag.Name)
+		for _, keyword := range evKeywords {
+			if strings.Contains(name, keyword) {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// EVChargers returns the charging sessions of every EV charger in the last
+// analysis. A session is a run of intervals in which the charger drew more
+// than the minimum power; its sources are those attributed to the charger.
+func (ea *EnergyAnalyzer) EVChargers() []EVCharger {
+	minWh := ea.evMinWh()
+
+	var chargers []EVCharger
+	for _, id := range ea.evChargerIDs() {
+		charger := EVCharger{SensorID: id, Name: id, Sessions: []EVSession{}}
+		if sensor := ea.sensorMap[id]; sensor != nil && sensor.Tag.Name != "" {
+			charger.Name = sensor.Tag.Name
+		}
+
+		var session *EVSession
+		var solar float64 // session PV energy including the battery's solar part
+		for _, interval := range ea.intervals {
+			usage := interval.ConsumerUsage[id]
+			if usage <= minWh {
+				if session != nil {
+					session.SolarShare = sharePercent(solar, session.Energy)
+					charger.Sessions = append(charger.Sessions, *session)
+					session = nil
+				}
+				continue
+			}
+			if session == nil {
+				session = &EVSession{Start: interval.Start}
+				solar = 0
+			}
+			session.End = interval.End
+			part, ok := interval.ConsumerSources[id]
+			if !ok {
+				part = Supply{Grid: usage}
+			}
+			session.Energy += part.Total()
+			session.Solar += part.Solar
+			session.Battery += part.Battery
+			session.Grid += part.Grid
+			solar += part.Solar + part.Battery*(1-interval.BatteryGridShare)
+		}
+		if session != nil {
+			session.SolarShare = sharePercent(solar, session.Energy)
+			charger.Sessions = append(charger.Sessions, *session)
+		}
+
+		var chargerSolar float64
+		for _, s := range charger.Sessions {
+			charger.Energy += s.Energy
+			chargerSolar += s.Energy * s.SolarShare / 100
+		}
+		charger.SolarShare = sharePercent(chargerSolar, charger.Energy)
+		chargers = append(chargers, charger)
+	}
+	return chargers
+}
+
+// evMinWh returns the energy per interval above which a charger charges
+func (ea *EnergyAnalyzer) evMinWh() float64 {
+	minPower := ea.config.ZEV.EVMinPowerW
+	if minPower == 0 {
+		minPower = DefaultEVMinPowerW
+	}
+	return minPower * IntervalSeconds / 3600
+}
+
+// chargingSince returns for every EV charger the start of the charging
+// session running in each interval of the analysis, zero while it does not
+// charge. The sessions are those of EVChargers.
+func (ea *EnergyAnalyzer) chargingSince() map[string][]time.Time {
+	minWh := ea.evMinWh()
+	since := make(map[string][]time.Time)
+	for _, id := range ea.evChargerIDs() {
+		starts := make([]time.Time, len(ea.intervals))
+		var start time.Time
+		for index, interval := range ea.intervals {
+			if interval.ConsumerUsage[id] <= minWh {
+				start = time.Time{}
+				continue
+			}
+			if start.IsZero() {
+				start = interval.Start
+			}
+			starts[index] = start
+		}
+		since[id] = starts
+	}
+	return since
+}
+
+// sharePercent returns part as a percentage of whole, 0 for an empty whole
+func sharePercent(part, whole float64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return part / whole * 100
+}
@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"zevalizer/internal/config"
+	"zevalizer/internal/models"
+)
+
+// MaxParallelFetches limits the number of concurrent sensor data requests
+const MaxParallelFetches = 4
+
+// sensorDataIDs returns the sensors whose data comes from the sensor
+// endpoint rather than the ZEV data: batteries and power-only inverters,
+// each followed by its former IDs (see resolveAliases)
+func (ea *EnergyAnalyzer) sensorDataIDs() []string {
+	var ids []string
+	for _, prodId := range ea.config.ZEV.ProductionIDs {
+		if ea.config.ZEV.SensorMode(prodId) == config.SensorModePower {
+			ids = append(ids, prodId)
+			ids = append(ids, ea.aliasesOf(prodId)...)
+		}
+	}
+	for _, batteryId := range ea.config.ZEV.BatterySystemIDs {
+		ids = append(ids, batteryId)
+		ids = append(ids, ea.aliasesOf(batteryId)...)
+	}
+	if ea.config.ZEV.Phases {
+		for _, gridId := range ea.config.ZEV.GridMeters() {
+			ids = append(ids, gridId)
+			ids = append(ids, ea.aliasesOf(gridId)...)
+		}
+	}
+	return ids
+}
+
+// fetchAll fetches the ZEV data and the data of all sensors in ids
+// concurrently, with at most MaxParallelFetches sensor requests in flight.
+// The first error cancels the requests still in flight and no further ones
+// are started; that error is returned.
+func (ea *EnergyAnalyzer) fetchAll(ctx context.Context, smId string, ids []string, from, to time.Time) ([]models.ZevData, map[string][]models.SensorData, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		zevData  []models.ZevData
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var err error
+		if zevData, err = ea.client.GetZevData(ctx, smId, from, to); err != nil {
+			fail(err)
+		}
+	}()
+
+	data := make([][]models.SensorData, len(ids))
+	jobs := make(chan int)
+	for w := 0; w < min(MaxParallelFetches, len(ids)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				var err error
+				if data[i], err = ea.client.GetSensorData(ctx, smId, ids[i], from, to); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+dispatch:
+	for i := range ids {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr == nil {
+		// canceled by the caller before all requests were started
+		firstErr = ctx.Err()
+	}
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+	sensorData := make(map[string][]models.SensorData, len(ids))
+	for i, id := range ids {
+		sensorData[id] = data[i]
+	}
+	return zevData, sensorData, nil
+}
@@ -0,0 +1,62 @@
+package analyzer
+
+import (
+	"fmt"
+	"time"
+
+	"zevalizer/internal/weather"
+)
+
+// ForecastDay compares the measured production of one day with its
+// forecast
+type ForecastDay struct {
+	Date      time.Time `json:"date"`
+	Forecast  float64   `json:"forecastKwh"`
+	Actual    float64   `json:"actualKwh"`
+	Deviation float64   `json:"deviationPercent"` // actual relative to forecast
+	Shortfall bool      `json:"shortfall,omitempty"`
+}
+
+// ForecastReport holds the daily comparison with the PV forecast. Days
+// producing more than ShortfallPercent below their forecast are flagged,
+// an early warning for failed strings or inverters.
+type ForecastReport struct {
+	Provider         string        `json:"provider"`
+	ShortfallPercent float64       `json:"shortfallPercent"`
+	Forecast         float64       `json:"forecastKwh"`
+	Actual           float64       `json:"actualKwh"`
+	Shortfalls       int           `json:"shortfalls"`
+	Days             []ForecastDay `json:"days"`
+}
+
+// CompareForecast compares the production of every complete day of
+// [from, to] in the last analysis with the forecast. Days without forecast
+// or without any forecast production are skipped.
+func (ea *EnergyAnalyzer) CompareForecast(forecast *weather.Forecast, from, to time.Time) (*ForecastReport, error) {
+	days, err := ea.DailyStats(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("calculating daily production: %w", err)
+	}
+	cfg := ea.config.Forecast
+	report := &ForecastReport{Provider: cfg.Provider, ShortfallPercent: cfg.Shortfall(), Days: []ForecastDay{}}
+	for _, stats := range days {
+		start := stats.Period.Start
+		if start.Before(from) || stats.Period.End.After(to) {
+			continue
+		}
+		expected, ok := forecast.Day(start)
+		if !ok || expected <= 0 {
+			continue
+		}
+		day := ForecastDay{Date: start, Forecast: expected, Actual: stats.Production / 1000}
+		day.Deviation = (day.Actual - day.Forecast) / day.Forecast * 100
+		day.Shortfall = day.Deviation < -report.ShortfallPercent
+		if day.Shortfall {
+			report.Shortfalls++
+		}
+		report.Forecast += day.Forecast
+		report.Actual += day.Actual
+		report.Days = append(report.Days, day)
+	}
+	return report, nil
+}
@@ -0,0 +1,28 @@
+package analyzer
+
+// GridMeterStats holds the exchange of a single grid connection point
+type GridMeterStats struct {
+	SensorID string  `json:"sensorId"`
+	Name     string  `json:"name"`
+	Import   float64 `json:"importWh"`
+	Export   float64 `json:"exportWh"`
+}
+
+// GridMeters returns the import and export of every grid meter in the last
+// analysis, or nil when there is only one (its figures are the totals)
+func (ea *EnergyAnalyzer) GridMeters() []GridMeterStats {
+	ids := ea.config.ZEV.GridMeters()
+	if len(ids) < 2 {
+		return nil
+	}
+	meters := make([]GridMeterStats, 0, len(ids))
+	for _, id := range ids {
+		exchange := ea.gridExchange[id]
+		stats := GridMeterStats{SensorID: id, Name: id, Import: exchange[0], Export: exchange[1]}
+		if sensor := ea.sensorMap[id]; sensor != nil && sensor.Tag.Name != "" {
+			stats.Name = sensor.Tag.Name
+		}
+		meters = append(meters, stats)
+	}
+	return meters
+}
@@ -0,0 +1,104 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"zevalizer/internal/config"
+	"zevalizer/internal/models"
+)
+
+// UngroupedName is the group collecting consumers not assigned to any group
+const UngroupedName = "Ungrouped"
+
+// SharedConsumerID is the consumer ID of the unmetered shared usage, in
+// the intervals and statistics as in groups, filters and common areas
+const SharedConsumerID = "shared"
+
+// validateGroups checks that groups have names and list reported
+// consumers, each in one group only
+func (ea *EnergyAnalyzer) validateGroups() error {
+	known := map[string]bool{SharedConsumerID: true}
+	for _, id := range ea.ConsumerIDs() {
+		known[id] = true
+	}
+	owner := make(map[string]string)
+	for _, group := range ea.config.ZEV.Groups {
+		if group.Name == "" {
+			return fmt.Errorf("%w: groups: every group needs a name", config.ErrInvalid)
+		}
+		for _, id := range group.Consumers {
+			if !known[id] {
+				return fmt.Errorf("%w: groups: %s in group %q is not a reported consumer", config.ErrInvalid, id, group.Name)
+			}
+			if other, taken := owner[id]; taken {
+				return fmt.Errorf("%w: groups: %s is in both %q and %q", config.ErrInvalid, id, other, group.Name)
+			}
+			owner[id] = group.Name
+		}
+	}
+	return nil
+}
+
+// validateBaseFees checks that base fees go to reported consumers and
+// configured groups
+func (ea *EnergyAnalyzer) validateBaseFees() error {
+	known := make(map[string]bool)
+	for _, id := range ea.ConsumerIDs() {
+		known[id] = true
+	}
+	groups := make(map[string]bool)
+	for _, group := range ea.config.ZEV.Groups {
+		groups[group.Name] = true
+	}
+	for _, fee := range ea.config.Prices.BaseFees {
+		for _, id := range fee.Consumers {
+			if !known[id] {
+				return fmt.Errorf("%w: baseFees: %s of %q is not a reported consumer", config.ErrInvalid, id, fee.Name)
+			}
+		}
+		for _, name := range fee.Groups {
+			if !groups[name] {
+				return fmt.Errorf("%w: baseFees: group %q of %q is not configured", config.ErrInvalid, name, fee.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Groups sums the consumers of the stats per configured group, in config
+// order. Consumers in no group are summed in a final UngroupedName group,
+// which is left out when it is empty. Returns nil without groups.
+func (stats *EnergyStats) Groups(groups []config.ConsumerGroup) []ConsumerStats {
+	if len(groups) == 0 {
+		return nil
+	}
+	index := make(map[string]int)
+	for i, group := range groups {
+		for _, id := range group.Consumers {
+			index[id] = i
+		}
+	}
+	result := make([]ConsumerStats, len(groups)+1)
+	for i, group := range groups {
+		result[i].Sensor = &models.Sensor{Tag: models.SensorTag{Name: group.Name}}
+	}
+	ungrouped := len(groups)
+	result[ungrouped].Sensor = &models.Sensor{Tag: models.SensorTag{Name: UngroupedName}}
+
+	for i := range stats.Consumers {
+		consumer := &stats.Consumers[i]
+		id := SharedConsumerID
+		if !synthetic(consumer) {
+			id = consumer.Sensor.ID
+		}
+		pos, ok := index[id]
+		if !ok {
+			pos = ungrouped
+		}
+		addConsumer(&result[pos], consumer)
+	}
+	if result[ungrouped].Total == 0 {
+		result = result[:ungrouped]
+	}
+	return result
+}
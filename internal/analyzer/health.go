@@ -0,0 +1,85 @@
+package analyzer
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrUnhealthySensors is returned by the health report when a sensor is
+// stale or dead
+var ErrUnhealthySensors = errors.New("sensors without recent readings")
+
+// Health states of a sensor, from good to bad
+const (
+	HealthOK    = "ok"
+	HealthGaps  = "gaps"  // recent readings, but too many intervals missing
+	HealthStale = "stale" // no reading for more than staleAfter
+	HealthDead  = "dead"  // no reading at all or for more than deadAfter
+)
+
+// Thresholds of the health states
+const (
+	staleAfter         = time.Hour
+	deadAfter          = 24 * time.Hour
+	minHealthyCoverage = 95.0 // percent of expected intervals
+)
+
+// SensorHealth combines what the Solar Manager reports about a sensor with
+// the freshness and gaps of its data in the last analysis
+type SensorHealth struct {
+	SensorID       string     `json:"sensorId"`
+	Name           string     `json:"name"`
+	Role           string     `json:"role"`
+	DeviceActivity int        `json:"deviceActivity"`
+	LastData       *time.Time `json:"lastData,omitempty"` // end of the last interval with a reading
+	Completeness   float64    `json:"completenessPercent"`
+	Gaps           int        `json:"gaps"`              // runs of missing intervals
+	LongestGap     int        `json:"longestGapMinutes"` // longest run of missing intervals
+	Status         string     `json:"status"`
+}
+
+// SensorHealth rates every configured sensor after an analysis. Freshness is
+// measured against now or the end of the analysis, whichever is earlier, so
+// a past period only reports sensors that stopped before its end.
+func (ea *EnergyAnalyzer) SensorHealth(now time.Time) []SensorHealth {
+	reference := now
+	if len(ea.intervals) > 0 {
+		if end := ea.intervals[len(ea.intervals)-1].End; end.Before(now) {
+			reference = end
+		}
+	}
+
+	health := make([]SensorHealth, 0, len(ea.completeness))
+	for _, sc := range ea.completeness {
+		sensor := SensorHealth{
+			SensorID:     sc.SensorID,
+			Name:         sc.Name,
+			Role:         sc.Role,
+			Completeness: sc.Percent(),
+			Gaps:         sc.gapRuns,
+			LongestGap:   sc.longestRun * IntervalSeconds / 60,
+		}
+		if sensor.Name == "" {
+			sensor.Name = sc.SensorID
+		}
+		if meta := ea.sensorMap[sc.SensorID]; meta != nil {
+			sensor.DeviceActivity = meta.DeviceActivity
+		}
+		if !sc.lastData.IsZero() {
+			lastData := sc.lastData
+			sensor.LastData = &lastData
+		}
+		switch {
+		case sensor.LastData == nil || reference.Sub(sc.lastData) > deadAfter:
+			sensor.Status = HealthDead
+		case reference.Sub(sc.lastData) > staleAfter:
+			sensor.Status = HealthStale
+		case sensor.Completeness < minHealthyCoverage:
+			sensor.Status = HealthGaps
+		default:
+			sensor.Status = HealthOK
+		}
+		health = append(health, sensor)
+	}
+	return health
+}
@@ -0,0 +1,41 @@
+package analyzer
+
+// Heatmap holds the average power per hour of day and day of week in kW.
+// The first index is the weekday starting with Monday, the second the hour.
+type Heatmap struct {
+	Consumption [7][24]float64 `json:"consumption"`
+	Production  [7][24]float64 `json:"production"`
+}
+
+// Heatmap averages the consumption of the consumers that keep accepts (by ID,
+// SharedConsumerID for the shared usage) and the production of the last
+// analysis per hour of the week
+func (ea *EnergyAnalyzer) Heatmap(keep func(id string) bool) *Heatmap {
+	var consumption, production [7][24]float64
+	var intervals [7][24]int
+	for _, interval := range ea.intervals {
+		day := (int(interval.Start.Weekday()) + 6) % 7
+		hour := interval.Start.Hour()
+		for id, usage := range interval.ConsumerUsage {
+			if keep(id) {
+				consumption[day][hour] += usage
+			}
+		}
+		production[day][hour] += interval.InverterGeneratedPower
+		intervals[day][hour]++
+	}
+
+	heatmap := &Heatmap{}
+	for day := range intervals {
+		for hour, n := range intervals[day] {
+			if n == 0 {
+				continue
+			}
+			// n intervals of 900s cover n/4 hours: Wh / h / 1000 = kW
+			hours := float64(n) * IntervalSeconds / 3600
+			heatmap.Consumption[day][hour] = consumption[day][hour] / hours / 1000
+			heatmap.Production[day][hour] = production[day][hour] / hours / 1000
+		}
+	}
+	return heatmap
+}
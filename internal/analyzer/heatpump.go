@@ -0,0 +1,106 @@
+package analyzer
+
+import (
+	"fmt"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+// Seasons reports energy per meteorological season
+type Seasons struct {
+	Winter float64 `json:"winterWh"` // December to February
+	Spring float64 `json:"springWh"` // March to May
+	Summer float64 `json:"summerWh"` // June to August
+	Autumn float64 `json:"autumnWh"` // September to November
+}
+
+// add books energy on the season of t
+func (s *Seasons) add(t time.Time, energy float64) {
+	switch t.Month() {
+	case time.December, time.January, time.February:
+		s.Winter += energy
+	case time.March, time.April, time.May:
+		s.Spring += energy
+	case time.June, time.July, time.August:
+		s.Summer += energy
+	default:
+		s.Autumn += energy
+	}
+}
+
+// HeatPumpStats holds the consumption of a heat pump by tariff and season
+// and, with a configured COP, the heat it produced
+type HeatPumpStats struct {
+	SensorID   string  `json:"sensorId"`
+	Name       string  `json:"name"`
+	Total      float64 `json:"totalWh"`
+	LowTariff  float64 `json:"lowTariffWh"`
+	HighTariff float64 `json:"highTariffWh"`
+	Seasons    Seasons `json:"seasons"`
+	COP        float64 `json:"cop,omitempty"`
+	HeatOutput float64 `json:"heatOutputWh,omitempty"` // Total * COP
+}
+
+// validateHeatPumps checks that the heat pumps are reported consumers with
+// a plausible COP
+func (ea *EnergyAnalyzer) validateHeatPumps() error {
+	known := make(map[string]bool)
+	for _, id := range ea.ConsumerIDs() {
+		known[id] = true
+	}
+	for id, heatPump := range ea.config.ZEV.HeatPumps {
+		if !known[id] {
+			return fmt.Errorf("%w: heat pump %s is not a reported consumer", config.ErrInvalid, id)
+		}
+		if heatPump.COP != 0 && heatPump.COP < 1 {
+			return fmt.Errorf("%w: COP %.2f of heat pump %s must be at least 1", config.ErrInvalid, heatPump.COP, id)
+		}
+	}
+	return nil
+}
+
+// collectHeatPumps adds the usage of the heat pumps in the current
+// intervals to their statistics
+func (ea *EnergyAnalyzer) collectHeatPumps() {
+	for id := range ea.config.ZEV.HeatPumps {
+		stats := ea.heatPumps[id]
+		if stats == nil {
+			stats = &HeatPumpStats{}
+			ea.heatPumps[id] = stats
+		}
+		for _, interval := range ea.intervals {
+			usage := interval.ConsumerUsage[id]
+			stats.Total += usage
+			if ea.IsLowTariff(interval.Start) {
+				stats.LowTariff += usage
+			} else {
+				stats.HighTariff += usage
+			}
+			stats.Seasons.add(interval.Start, usage)
+		}
+	}
+}
+
+// HeatPumps returns the statistics of every configured heat pump over the
+// analysis, in consumer order
+func (ea *EnergyAnalyzer) HeatPumps() []HeatPumpStats {
+	var heatPumps []HeatPumpStats
+	for _, id := range ea.ConsumerIDs() {
+		heatPump, ok := ea.config.ZEV.HeatPumps[id]
+		if !ok {
+			continue
+		}
+		stats := HeatPumpStats{SensorID: id, Name: id, COP: heatPump.COP}
+		if collected := ea.heatPumps[id]; collected != nil {
+			stats.Total, stats.LowTariff, stats.HighTariff = collected.Total, collected.LowTariff, collected.HighTariff
+			stats.Seasons = collected.Seasons
+		}
+		if sensor := ea.sensorMap[id]; sensor != nil && sensor.Tag.Name != "" {
+			stats.Name = sensor.Tag.Name
+		}
+		stats.HeatOutput = stats.Total * stats.COP
+		heatPumps = append(heatPumps, stats)
+	}
+	return heatPumps
+}
@@ -0,0 +1,94 @@
+package analyzer
+
+import "time"
+
+// IdleBattery is a run of complete days on which a battery system neither
+// charged nor discharged, e.g. because its breaker tripped
+type IdleBattery struct {
+	SensorID string    `json:"sensorId"`
+	Name     string    `json:"name"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Days     int       `json:"days"`
+	// The battery was still idle at the end of the analysis
+	Ongoing bool `json:"ongoing,omitempty"`
+}
+
+// trackBatteryActivity walks the complete days of the current intervals and
+// extends or closes the idle run of every battery. Runs continue across
+// the pieces of a stream (see AnalyzeStream).
+func (ea *EnergyAnalyzer) trackBatteryActivity(now time.Time) {
+	if len(ea.config.ZEV.BatterySystemIDs) == 0 {
+		return
+	}
+	first := 0
+	for index := 1; index <= len(ea.intervals); index++ {
+		if index < len(ea.intervals) && sameDay(ea.intervals[first].Start, ea.intervals[index].Start) {
+			continue
+		}
+		ea.closeBatteryDay(first, index, now)
+		first = index
+	}
+}
+
+// closeBatteryDay records the activity of every battery on the day formed
+// by the intervals [first, last). Days the analysis covers only partly, or
+// that are not over yet, are left out.
+func (ea *EnergyAnalyzer) closeBatteryDay(first, last int, now time.Time) {
+	start := ea.intervals[first].Start
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	// the last interval of a period ends just before midnight
+	end := day.AddDate(0, 0, 1)
+	lastStart := ea.intervals[last-1].Start
+	if !start.Equal(day) || !lastStart.Add(IntervalSeconds*time.Second).Equal(end) || end.After(now) {
+		return
+	}
+	for _, id := range ea.config.ZEV.BatterySystemIDs {
+		charge := ea.raw[rawKey{id, RoleBattery, FlowCharge}]
+		discharge := ea.raw[rawKey{id, RoleBattery, FlowDischarge}]
+		active := false
+		for index := first; index < last && !active; index++ {
+			active = charge != nil && charge[index] > 0 || discharge != nil && discharge[index] > 0
+		}
+
+		run := ea.idleRuns[id]
+		if !active {
+			if run.Days == 0 {
+				run = IdleBattery{SensorID: id, Start: day}
+			}
+			run.End = end
+			run.Days++
+			ea.idleRuns[id] = run
+			continue
+		}
+		if run.Days >= ea.config.ZEV.IdleDays() {
+			ea.idleBatteries = append(ea.idleBatteries, run)
+		}
+		delete(ea.idleRuns, id)
+	}
+}
+
+// IdleBatteries returns the runs of at least idleBatteryDays days without
+// any battery activity in the last analysis, in time order, including the
+// runs still going on at its end
+func (ea *EnergyAnalyzer) IdleBatteries() []IdleBattery {
+	idle := append([]IdleBattery{}, ea.idleBatteries...)
+	for _, id := range ea.config.ZEV.BatterySystemIDs {
+		if run, ok := ea.idleRuns[id]; ok && run.Days >= ea.config.ZEV.IdleDays() {
+			run.Ongoing = true
+			idle = append(idle, run)
+		}
+	}
+	for i, run := range idle {
+		idle[i].Name = run.SensorID
+		if sensor := ea.sensorMap[run.SensorID]; sensor != nil && sensor.Tag.Name != "" {
+			idle[i].Name = sensor.Tag.Name
+		}
+	}
+	return idle
+}
+
+// sameDay reports whether a and b fall on the same calendar day
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
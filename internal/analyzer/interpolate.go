@@ -0,0 +1,65 @@
+package analyzer
+
+import "time"
+
+// readingSpan returns how many intervals the time between two consecutive
+// counter readings covers, and whether their difference should be spread
+// across those intervals. Spreading is only done when gap interpolation is
+// configured and the gap does not exceed its maximum length; otherwise the
+// difference belongs to the interval of the later reading.
+func (ea *EnergyAnalyzer) readingSpan(previous, current time.Time) (intervals float64, interpolate bool) {
+	span := current.Sub(previous)
+	maxGap := time.Duration(ea.config.ZEV.MaxInterpolationMinutes) * time.Minute
+	if maxGap <= 0 || span <= IntervalSeconds*time.Second || span > maxGap {
+		return 1, false
+	}
+	return span.Seconds() / IntervalSeconds, true
+}
+
+// distribute calls add for every interval between two readings with the
+// fraction of the time between them that falls into the interval, i.e.
+// it interpolates the counters linearly across the gap. The part before
+// the first interval is dropped.
+func (ea *EnergyAnalyzer) distribute(previous, current time.Time, add func(interval *IntervalData, fraction float64)) {
+	total := current.Sub(previous).Seconds()
+	index := 0
+	if !previous.Before(ea.intervals[0].Start) {
+		index = ea.intervalIndex(previous)
+	}
+	for ; index >= 0 && index < len(ea.intervals); index++ {
+		interval := ea.intervals[index]
+		if !interval.Start.Before(current) {
+			break
+		}
+		start, end := interval.Start, interval.End
+		if previous.After(start) {
+			start = previous
+		}
+		if current.Before(end) {
+			end = current
+		}
+		if overlap := end.Sub(start).Seconds(); overlap > 0 {
+			add(interval, overlap/total)
+		}
+	}
+}
+
+// integrate calls add for every interval between two power samples with the
+// energy (Wh) under the straight line from previousW to currentW within the
+// interval. The part before the first interval is dropped.
+func (ea *EnergyAnalyzer) integrate(previous, current time.Time, previousW, currentW float64, add func(interval *IntervalData, energy float64)) {
+	total := current.Sub(previous).Seconds()
+	power := func(t time.Time) float64 {
+		return previousW + (currentW-previousW)*t.Sub(previous).Seconds()/total
+	}
+	ea.distribute(previous, current, func(interval *IntervalData, fraction float64) {
+		start, end := interval.Start, interval.End
+		if previous.After(start) {
+			start = previous
+		}
+		if current.Before(end) {
+			end = current
+		}
+		add(interval, (power(start)+power(end))/2*fraction*total/3600)
+	})
+}
@@ -0,0 +1,47 @@
+package analyzer
+
+// minInvertedAdvanceWh is how far the unread counter of a consumer must
+// advance while the read one stands still before the meter counts as
+// inverted
+const minInvertedAdvanceWh = 1000
+
+// InvertedMeter is a consumer meter whose counter used for its usage did
+// not advance in the last analysis while the other one did, so the
+// consumer shows no usage. Flipping InvertMeasurement of the sensor in the
+// smart-me portal makes the analysis read the other counter.
+type InvertedMeter struct {
+	SensorID          string  `json:"sensorId"`
+	Name              string  `json:"name"`
+	InvertMeasurement bool    `json:"invertMeasurement"` // current setting, the suggestion is the opposite
+	Advance           float64 `json:"otherCounterWh"`    // advance of the counter not read
+}
+
+// trackCounterAdvance adds how far the read and the other counter of a
+// consumer advanced between two readings
+func (ea *EnergyAnalyzer) trackCounterAdvance(consumerId string, read, other float64) {
+	advance := ea.counterAdvance[consumerId]
+	advance[0] += max(read, 0)
+	advance[1] += max(other, 0)
+	ea.counterAdvance[consumerId] = advance
+}
+
+// InvertedMeters returns the consumer meters of the last analysis that
+// look inverted, in configuration order
+func (ea *EnergyAnalyzer) InvertedMeters() []InvertedMeter {
+	var meters []InvertedMeter
+	for _, id := range ea.config.ZEV.ConsumerIDs {
+		advance, ok := ea.counterAdvance[id]
+		if !ok || advance[0] >= 1 || advance[1] < minInvertedAdvanceWh {
+			continue
+		}
+		meter := InvertedMeter{SensorID: id, Name: id, Advance: advance[1]}
+		if sensor := ea.sensorMap[id]; sensor != nil {
+			meter.InvertMeasurement = sensor.Data.InvertMeasurement
+			if sensor.Tag.Name != "" {
+				meter.Name = sensor.Tag.Name
+			}
+		}
+		meters = append(meters, meter)
+	}
+	return meters
+}
@@ -0,0 +1,57 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"zevalizer/internal/config"
+)
+
+// readingLimit returns the largest plausible energy per interval for a
+// sensor: its own override, else the configured limit of its role, else the
+// built-in default
+func (ea *EnergyAnalyzer) readingLimit(role, sensorID string) float64 {
+	if limit := ea.config.ZEV.SensorMaxReadingWh[sensorID]; limit > 0 {
+		return limit
+	}
+	limits := ea.config.ZEV.MaxReadingWh
+	switch role {
+	case RoleGrid:
+		if limits.Grid > 0 {
+			return limits.Grid
+		}
+		return MaxGridReadingDiffWh
+	case RoleProduction:
+		if limits.Production > 0 {
+			return limits.Production
+		}
+		return MaxProductionReadingWh
+	default:
+		if limits.Consumer > 0 {
+			return limits.Consumer
+		}
+		return MaxConsumerReadingWh
+	}
+}
+
+// validateReadingLimits rejects negative limits and overrides for sensors
+// that are not part of the configuration
+func (ea *EnergyAnalyzer) validateReadingLimits() error {
+	limits := ea.config.ZEV.MaxReadingWh
+	if limits.Grid < 0 || limits.Production < 0 || limits.Consumer < 0 {
+		return fmt.Errorf("%w: maxReadingWh limits must not be negative", config.ErrInvalid)
+	}
+	known := make(map[string]bool)
+	ids, _ := ea.configuredSensors()
+	for _, id := range ids {
+		known[id] = true
+	}
+	for id, limit := range ea.config.ZEV.SensorMaxReadingWh {
+		if limit <= 0 {
+			return fmt.Errorf("%w: sensorMaxReadingWh for sensor %s must be positive, got %g", config.ErrInvalid, id, limit)
+		}
+		if !known[id] {
+			return fmt.Errorf("%w: sensorMaxReadingWh names sensor %s which is not configured", config.ErrInvalid, id)
+		}
+	}
+	return nil
+}
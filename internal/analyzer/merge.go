@@ -0,0 +1,128 @@
+package analyzer
+
+import "zevalizer/internal/models"
+
+// consumerKey identifies a consumer across several EnergyStats. Synthetic
+// consumers (e.g. "Shared Usage") have no sensor ID and are keyed by name.
+func consumerKey(consumer *ConsumerStats) string {
+	if consumer.Sensor == nil {
+		return ""
+	}
+	if consumer.Sensor.ID != "" {
+		return consumer.Sensor.ID
+	}
+	return "name:" + consumer.Sensor.Tag.Name
+}
+
+// MergeStats sums several EnergyStats (e.g. both tariff periods, or a series
+// of days) into one. The period spans all merged stats.
+func MergeStats(stats ...*EnergyStats) *EnergyStats {
+	merged := &EnergyStats{}
+	index := make(map[string]int)
+
+	for _, s := range stats {
+		if s == nil {
+			continue
+		}
+		if merged.Period.Start.IsZero() || s.Period.Start.Before(merged.Period.Start) {
+			merged.Period.Start = s.Period.Start
+		}
+		if s.Period.End.After(merged.Period.End) {
+			merged.Period.End = s.Period.End
+		}
+		merged.GridImport += s.GridImport
+		merged.GridExport += s.GridExport
+		merged.Production += s.Production
+		merged.Consumption += s.Consumption
+		merged.BatteryCharge += s.BatteryCharge
+		merged.BatteryDischarge += s.BatteryDischarge
+		merged.BatteryChargeFromGrid += s.BatteryChargeFromGrid
+		if s.SharedStrategy != "" {
+			merged.SharedStrategy = s.SharedStrategy
+		}
+		if s.SharedAllocation != "" {
+			merged.SharedAllocation = s.SharedAllocation
+		}
+		merged.GridImportCost += s.GridImportCost
+		merged.GridExportRevenue += s.GridExportRevenue
+		merged.UnpricedIntervals += s.UnpricedIntervals
+		merged.FeedInRevenue += s.FeedInRevenue
+		merged.NetMetered = merged.NetMetered || s.NetMetered
+		merged.NetImport += s.NetImport
+		merged.NetExport += s.NetExport
+
+		for i := range s.Consumers {
+			consumer := &s.Consumers[i]
+			key := consumerKey(consumer)
+			pos, ok := index[key]
+			if !ok {
+				pos = len(merged.Consumers)
+				index[key] = pos
+				merged.Consumers = append(merged.Consumers, ConsumerStats{Sensor: consumer.Sensor})
+			}
+			addConsumer(&merged.Consumers[pos], consumer)
+		}
+	}
+	return merged
+}
+
+// OtherConsumerName is the name of the consumer that collects collapsed consumers
+const OtherConsumerName = "Other"
+
+// SmallConsumers returns the keys of all real consumers whose total across
+// all given stats is below minWh
+func SmallConsumers(minWh float64, stats ...*EnergyStats) map[string]bool {
+	small := make(map[string]bool)
+	merged := MergeStats(stats...)
+	for i := range merged.Consumers {
+		consumer := &merged.Consumers[i]
+		if !synthetic(consumer) && consumer.Total < minWh {
+			small[consumerKey(consumer)] = true
+		}
+	}
+	return small
+}
+
+// CollapseConsumers merges the consumers with the given keys into a single
+// synthetic "Other" consumer
+func (stats *EnergyStats) CollapseConsumers(keys map[string]bool) {
+	if len(keys) == 0 {
+		return
+	}
+	other := ConsumerStats{
+		Sensor: &models.Sensor{Tag: models.SensorTag{Name: OtherConsumerName}},
+	}
+	kept := stats.Consumers[:0]
+	for _, consumer := range stats.Consumers {
+		if !keys[consumerKey(&consumer)] {
+			kept = append(kept, consumer)
+			continue
+		}
+		addConsumer(&other, &consumer)
+	}
+	stats.Consumers = append(kept, other)
+}
+
+// FilterConsumers removes the consumers for which keep returns false. The
+// system totals are not changed.
+func (stats *EnergyStats) FilterConsumers(keep func(*ConsumerStats) bool) {
+	kept := stats.Consumers[:0]
+	for _, consumer := range stats.Consumers {
+		if keep(&consumer) {
+			kept = append(kept, consumer)
+		}
+	}
+	stats.Consumers = kept
+}
+
+// addConsumer adds the energy of consumer to target
+func addConsumer(target, consumer *ConsumerStats) {
+	target.Total += consumer.Total
+	target.Sources.FromInverter += consumer.Sources.FromInverter
+	target.Sources.FromBattery += consumer.Sources.FromBattery
+	target.Sources.FromBatterySolar += consumer.Sources.FromBatterySolar
+	target.Sources.FromBatteryGrid += consumer.Sources.FromBatteryGrid
+	target.Sources.FromGrid += consumer.Sources.FromGrid
+	target.GridCost += consumer.GridCost
+	target.SharedAllocated += consumer.SharedAllocated
+}
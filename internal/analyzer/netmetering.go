@@ -0,0 +1,27 @@
+package analyzer
+
+// applyNetMetering offsets the export of the stats against its import, as
+// utilities settling by net metering do per tariff. Only the remaining net
+// import is costed: the spot valuation of the import and the consumers' grid
+// cost are reduced in the same proportion, and only a net export earns
+// revenue, at spot prices as well as at the feed-in tariff. The energy
+// figures themselves stay gross.
+func (ea *EnergyAnalyzer) applyNetMetering(stats *EnergyStats) {
+	if !ea.config.Billing.NetMetering {
+		return
+	}
+	stats.NetMetered = true
+	stats.NetImport = max(stats.GridImport-stats.GridExport, 0)
+	stats.NetExport = max(stats.GridExport-stats.GridImport, 0)
+	if stats.GridImport > 0 {
+		factor := stats.NetImport / stats.GridImport
+		stats.GridImportCost *= factor
+		for i := range stats.Consumers {
+			stats.Consumers[i].GridCost *= factor
+		}
+	}
+	if stats.GridExport > 0 {
+		stats.GridExportRevenue *= stats.NetExport / stats.GridExport
+		stats.FeedInRevenue *= stats.NetExport / stats.GridExport
+	}
+}
@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+const (
+	// DefaultOutlierWindow is the number of readings the median is taken over (one day)
+	DefaultOutlierWindow = 96
+	// DefaultOutlierThreshold is the number of deviations above the median a reading may reach
+	DefaultOutlierThreshold = 10
+	// DefaultOutlierMinDeviationWh keeps small jumps of quiet meters from being rejected
+	DefaultOutlierMinDeviationWh = 1000
+
+	// madScale turns the median absolute deviation into an estimate of the
+	// standard deviation for normally distributed values
+	madScale = 1.4826
+)
+
+// Outlier records a counter jump rejected by the statistical outlier detection
+type Outlier struct {
+	SensorID string    `json:"sensorId"`
+	Counter  string    `json:"counter"` // "purchase" or "delivery"
+	Time     time.Time `json:"time"`
+	Energy   float64   `json:"energyWh"` // excluded energy
+	Median   float64   `json:"medianWh"` // median of the rolling window
+	Limit    float64   `json:"limitWh"`  // largest accepted value at the time
+}
+
+// Outliers returns the readings rejected as outliers in the last analysis
+func (ea *EnergyAnalyzer) Outliers() []Outlier {
+	return ea.outliers
+}
+
+// validateOutliers checks the outlier detection settings
+func (ea *EnergyAnalyzer) validateOutliers() error {
+	o := ea.config.ZEV.Outliers
+	if o.Window < 0 || o.Threshold < 0 || o.MinDeviationWh < 0 {
+		return fmt.Errorf("%w: outliers window, threshold and minDeviationWh must not be negative", config.ErrInvalid)
+	}
+	return nil
+}
+
+// outlierSettings returns the configured settings with defaults applied
+func (ea *EnergyAnalyzer) outlierSettings() (window int, threshold, minDeviation float64) {
+	o := ea.config.ZEV.Outliers
+	window, threshold, minDeviation = o.Window, o.Threshold, o.MinDeviationWh
+	if window == 0 {
+		window = DefaultOutlierWindow
+	}
+	if threshold == 0 {
+		threshold = DefaultOutlierThreshold
+	}
+	if minDeviation == 0 {
+		minDeviation = DefaultOutlierMinDeviationWh
+	}
+	return window, threshold, minDeviation
+}
+
+// rejectOutlier reports whether the energy of a reading spanning span
+// intervals is an implausible jump compared to the recent readings of the
+// same counter. Accepted readings join the rolling window, rejected ones are
+// recorded. Until the window has filled to a quarter there is too little
+// history to judge and every reading is accepted.
+func (ea *EnergyAnalyzer) rejectOutlier(sensorID, counter string, at time.Time, energy, span float64) bool {
+	if !ea.config.ZEV.Outliers.Enabled {
+		return false
+	}
+	window, threshold, minDeviation := ea.outlierSettings()
+	key := sensorID + "/" + counter
+	history := ea.outlierWindows[key]
+	perInterval := energy / span
+
+	if len(history) >= max(window/4, 1) {
+		median, mad := medianDeviation(history)
+		limit := median + max(threshold*mad*madScale, minDeviation)
+		if perInterval > limit {
+			ea.outliers = append(ea.outliers, Outlier{
+				SensorID: sensorID,
+				Counter:  counter,
+				Time:     at,
+				Energy:   energy,
+				Median:   median,
+				Limit:    limit * span,
+			})
+			ea.debugf("Rejecting outlier on %s %s at %s: %.1f Wh, median %.1f Wh",
+				sensorID, counter, at.Format("2006-01-02 15:04"), energy, median)
+			return true
+		}
+	}
+
+	history = append(history, perInterval)
+	if len(history) > window {
+		history = history[1:]
+	}
+	ea.outlierWindows[key] = history
+	return false
+}
+
+// medianDeviation returns the median of values and the median absolute
+// deviation from it
+func medianDeviation(values []float64) (median, mad float64) {
+	median = medianOf(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = v - median
+		if deviations[i] < 0 {
+			deviations[i] = -deviations[i]
+		}
+	}
+	return median, medianOf(deviations)
+}
+
+// medianOf returns the median of values without modifying them
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
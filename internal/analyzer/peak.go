@@ -0,0 +1,125 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"zevalizer/internal/models"
+)
+
+// Peak is the average grid import power of one 15-minute interval, or of
+// one reading of the finer resolution of a PeakReport
+type Peak struct {
+	Start time.Time `json:"start"`
+	Power float64   `json:"powerKw"`
+}
+
+// PeakReport lists the highest grid import intervals of the period and the
+// maximum of every calendar month, the basis of capacity-based grid tariffs
+type PeakReport struct {
+	// Seconds per peak when taken from power readings instead of the
+	// 15-minute intervals
+	Resolution int    `json:"resolutionSeconds,omitempty"`
+	Top        []Peak `json:"top"`
+	Monthly    []Peak `json:"monthly"`
+}
+
+// PowerFetcher is implemented by clients that can fetch sensor readings at
+// a finer resolution than the 15-minute intervals
+type PowerFetcher interface {
+	GetPowerData(ctx context.Context, smId string, sensorID string, from, to time.Time, seconds int) ([]models.SensorData, error)
+}
+
+// ErrNoPowerData is returned by PowerPeaks when the grid meters report no
+// instantaneous power
+var ErrNoPowerData = errors.New("grid meters report no power readings")
+
+// Peaks returns the n intervals with the highest grid import of the last
+// analysis, highest first, and the highest interval of each month
+func (ea *EnergyAnalyzer) Peaks(n int) *PeakReport {
+	var peaks []Peak
+	for _, interval := range ea.intervals {
+		if interval.GridImport <= 0 {
+			continue
+		}
+		peaks = append(peaks, Peak{
+			Start: interval.Start,
+			Power: interval.GridImport / 1000 * 3600 / IntervalSeconds,
+		})
+	}
+	return peakReport(peaks, n)
+}
+
+// PowerPeaks fetches the power of the grid meters over [from, to] every
+// seconds and reports its n highest import readings and the monthly maxima,
+// which catches short peaks like EV charging that the 15-minute averages
+// smooth away. The readings of several grid meters are added up per
+// period of seconds.
+func (ea *EnergyAnalyzer) PowerPeaks(ctx context.Context, smId string, from, to time.Time, seconds, n int) (*PeakReport, error) {
+	fetcher, ok := ea.client.(PowerFetcher)
+	if !ok {
+		return nil, fmt.Errorf("the data source cannot fetch power readings")
+	}
+	resolution := time.Duration(seconds) * time.Second
+	power := make(map[time.Time]float64)
+	for _, gridId := range ea.config.ZEV.GridMeters() {
+		readings, err := fetcher.GetPowerData(ctx, smId, gridId, from, to, seconds)
+		if err != nil {
+			return nil, fmt.Errorf("fetching power of %s: %w", gridId, err)
+		}
+		for _, reading := range readings {
+			if reading.Date.Before(from) || reading.Date.After(to) || reading.PowerW == 0 {
+				continue
+			}
+			power[reading.Date.Truncate(resolution)] += reading.PowerW
+		}
+	}
+	if len(power) == 0 {
+		return nil, ErrNoPowerData
+	}
+
+	var peaks []Peak
+	for start, watts := range power {
+		if watts > 0 {
+			peaks = append(peaks, Peak{Start: start, Power: watts / 1000})
+		}
+	}
+	sort.Slice(peaks, func(i, j int) bool { return peaks[i].Start.Before(peaks[j].Start) })
+	report := peakReport(peaks, n)
+	report.Resolution = seconds
+	return report, nil
+}
+
+// peakReport picks the n highest of the peaks, which are in time order, and
+// the highest of each month
+func peakReport(peaks []Peak, n int) *PeakReport {
+	report := &PeakReport{}
+	for _, peak := range peaks {
+		report.Top = append(report.Top, peak)
+
+		last := len(report.Monthly) - 1
+		if last >= 0 && sameMonth(report.Monthly[last].Start, peak.Start) {
+			if peak.Power > report.Monthly[last].Power {
+				report.Monthly[last] = peak
+			}
+			continue
+		}
+		report.Monthly = append(report.Monthly, peak)
+	}
+
+	sort.SliceStable(report.Top, func(i, j int) bool {
+		return report.Top[i].Power > report.Top[j].Power
+	})
+	if len(report.Top) > n {
+		report.Top = report.Top[:n]
+	}
+	return report
+}
+
+// sameMonth reports whether a and b fall into the same calendar month
+func sameMonth(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month()
+}
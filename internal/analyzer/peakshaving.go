@@ -0,0 +1,115 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ShavingMonth is what it takes to keep the grid import of one calendar
+// month at or below a peak level
+type ShavingMonth struct {
+	Start time.Time `json:"start"`
+	Peak  float64   `json:"peakKw"` // actual monthly maximum
+	// Battery discharge power and usable capacity needed to cover the
+	// import above the level; the battery recharges from the headroom
+	// below the level in between
+	Power    float64 `json:"powerKw"`
+	Capacity float64 `json:"capacityKwh"`
+	// Import above the level, to be shifted to other quarter hours
+	// instead of covered by a battery
+	Shifted float64 `json:"shiftedKwh"`
+	Savings float64 `json:"savings,omitempty"` // capacity tariff saved
+}
+
+// ShavingLevel holds the requirements for capping every monthly peak at
+// Level, the totals being the maximum power and capacity over all months
+type ShavingLevel struct {
+	Level    float64        `json:"levelKw"`
+	Months   []ShavingMonth `json:"months"`
+	Power    float64        `json:"powerKw"`
+	Capacity float64        `json:"capacityKwh"`
+	Shifted  float64        `json:"shiftedKwh"`
+	Savings  float64        `json:"savings,omitempty"`
+}
+
+// PeakShavingReport recommends battery sizes or load shifting for capping
+// the monthly grid import peaks at the requested levels
+type PeakShavingReport struct {
+	Currency string         `json:"currency,omitempty"`
+	Levels   []ShavingLevel `json:"levels"`
+}
+
+// ParsePeakLevels parses a comma separated list of peak levels in kW
+func ParsePeakLevels(spec string) ([]float64, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	var levels []float64
+	for _, item := range strings.Split(spec, ",") {
+		level, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+		if err != nil || level <= 0 {
+			return nil, fmt.Errorf("peak level %q must be a positive number of kW", item)
+		}
+		levels = append(levels, level)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(levels)))
+	return levels, nil
+}
+
+// PeakShaving calculates for every level of kW the battery power and
+// capacity needed to keep the grid import of every month of the last
+// analysis at or below it. The battery discharges whatever exceeds the
+// level and recharges from the grid within the headroom below it, so its
+// capacity is the deepest discharge over a month, divided by the inverter
+// efficiency. With a capacity tariff the report values the lower peaks.
+func (ea *EnergyAnalyzer) PeakShaving(levels []float64) *PeakShavingReport {
+	efficiency := ea.config.ZEV.InverterEfficiency
+	if efficiency == 0 {
+		efficiency = 0.93
+	}
+	report := &PeakShavingReport{Levels: []ShavingLevel{}}
+	if ea.config.Prices.Capacity > 0 {
+		report.Currency = ea.config.Prices.CurrencyLabel()
+	}
+	hours := float64(IntervalSeconds) / 3600
+
+	for _, level := range levels {
+		shaving := ShavingLevel{Level: level, Months: []ShavingMonth{}}
+		var month *ShavingMonth
+		var depth float64 // energy drawn from the battery, kWh AC
+		for _, interval := range ea.intervals {
+			if month == nil || !sameMonth(month.Start, interval.Start) {
+				shaving.Months = append(shaving.Months, ShavingMonth{
+					Start: time.Date(interval.Start.Year(), interval.Start.Month(), 1, 0, 0, 0, 0, interval.Start.Location()),
+				})
+				month = &shaving.Months[len(shaving.Months)-1]
+				depth = 0
+			}
+			power := interval.GridImport / 1000 / hours
+			month.Peak = max(month.Peak, power)
+			if power > level {
+				excess := power - level
+				month.Power = max(month.Power, excess)
+				month.Shifted += excess * hours
+				depth += excess * hours
+			} else {
+				depth = max(depth-(level-power)*hours, 0)
+			}
+			month.Capacity = max(month.Capacity, depth/efficiency)
+		}
+		for i := range shaving.Months {
+			month := &shaving.Months[i]
+			prices := ea.config.Prices.At(month.Start)
+			month.Savings = (month.Peak - min(month.Peak, level)) * prices.Capacity
+			shaving.Power = max(shaving.Power, month.Power)
+			shaving.Capacity = max(shaving.Capacity, month.Capacity)
+			shaving.Shifted += month.Shifted
+			shaving.Savings += month.Savings
+		}
+		report.Levels = append(report.Levels, shaving)
+	}
+	return report
+}
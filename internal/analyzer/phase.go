@@ -0,0 +1,128 @@
+package analyzer
+
+import (
+	"time"
+
+	"zevalizer/internal/models"
+)
+
+// nominalVoltage converts phase currents to power when a meter reports no
+// phase power
+const nominalVoltage = 230
+
+// PhaseStats holds the load per phase of a grid meter and how unevenly it
+// was spread. Power is positive for import and negative for export.
+type PhaseStats struct {
+	SensorID string     `json:"sensorId"`
+	Name     string     `json:"name"`
+	Readings int        `json:"readings"`
+	Average  [3]float64 `json:"averageW"` // L1, L2, L3
+	Max      [3]float64 `json:"maxW"`     // highest import per phase
+	// Difference between the most and the least loaded phase
+	AverageSpread float64   `json:"averageSpreadW"`
+	MaxSpread     float64   `json:"maxSpreadW"`
+	MaxSpreadAt   time.Time `json:"maxSpreadAt"`
+	// Readings with a spread above Limit
+	Unbalanced int     `json:"unbalancedReadings"`
+	Limit      float64 `json:"limitW"`
+	// The meter reported currents only, converted at 230 V
+	FromCurrent bool `json:"fromCurrent,omitempty"`
+}
+
+// UnbalancedPercent returns the share of readings above the spread limit
+func (ps *PhaseStats) UnbalancedPercent() float64 {
+	if ps.Readings == 0 {
+		return 0
+	}
+	return float64(ps.Unbalanced) / float64(ps.Readings) * 100
+}
+
+// phaseSums accumulates the phase readings of a grid meter across the
+// pieces of a stream
+type phaseSums struct {
+	stats  PhaseStats
+	sum    [3]float64
+	spread float64
+}
+
+// collectPhases adds the per-phase readings of the grid meters within the
+// analysis period. Readings without any phase value are skipped, so meters
+// that do not report phases end up without readings.
+func (ea *EnergyAnalyzer) collectPhases(sensorData map[string][]models.SensorData) {
+	if !ea.config.ZEV.Phases {
+		return
+	}
+	limit := ea.config.ZEV.PhaseLimit()
+	for _, gridId := range ea.config.ZEV.GridMeters() {
+		sums := ea.phases[gridId]
+		if sums == nil {
+			sums = &phaseSums{stats: PhaseStats{SensorID: gridId, Limit: limit}}
+			ea.phases[gridId] = sums
+		}
+		for _, reading := range sensorData[gridId] {
+			if ea.intervalIndex(reading.Date) < 0 {
+				continue
+			}
+			power, fromCurrent, ok := phasePower(reading)
+			if !ok {
+				continue
+			}
+			stats := &sums.stats
+			stats.FromCurrent = stats.FromCurrent || fromCurrent
+			stats.Readings++
+			for phase, p := range power {
+				sums.sum[phase] += p
+				stats.Max[phase] = max(stats.Max[phase], p)
+			}
+			spread := max(power[0], power[1], power[2]) - min(power[0], power[1], power[2])
+			sums.spread += spread
+			if spread > stats.MaxSpread {
+				stats.MaxSpread = spread
+				stats.MaxSpreadAt = reading.Date
+			}
+			if spread > limit {
+				stats.Unbalanced++
+			}
+		}
+	}
+}
+
+// phasePower returns the power per phase of a reading, from the phase
+// currents at nominal voltage if the meter reports no phase power
+func phasePower(reading models.SensorData) (power [3]float64, fromCurrent, ok bool) {
+	power = [3]float64{reading.PowerL1W, reading.PowerL2W, reading.PowerL3W}
+	if power != [3]float64{} {
+		return power, false, true
+	}
+	current := [3]float64{reading.CurrentL1A, reading.CurrentL2A, reading.CurrentL3A}
+	if current == [3]float64{} {
+		return power, false, false
+	}
+	for phase, i := range current {
+		power[phase] = i * nominalVoltage
+	}
+	return power, true, true
+}
+
+// Phases returns the phase load of every grid meter that reported phase
+// values in the last analysis, in configuration order
+func (ea *EnergyAnalyzer) Phases() []PhaseStats {
+	var phases []PhaseStats
+	for _, id := range ea.config.ZEV.GridMeters() {
+		sums := ea.phases[id]
+		if sums == nil || sums.stats.Readings == 0 {
+			continue
+		}
+		stats := sums.stats
+		for phase := range stats.Average {
+			stats.Average[phase] = sums.sum[phase] / float64(stats.Readings)
+		}
+		stats.AverageSpread = sums.spread / float64(stats.Readings)
+		stats.Name = id
+		if sensor := ea.sensorMap[id]; sensor != nil && sensor.Tag.Name != "" {
+			stats.Name = sensor.Tag.Name
+		}
+		phases = append(phases, stats)
+	}
+	return phases
+}
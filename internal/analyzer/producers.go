@@ -0,0 +1,82 @@
+package analyzer
+
+// ProducerStats holds the production of a single PV plant and its part of
+// the grid export
+type ProducerStats struct {
+	SensorID   string  `json:"sensorId"`
+	Name       string  `json:"name"`
+	Production float64 `json:"productionWh"` // intervals with positive net production
+	Export     float64 `json:"exportWh"`
+	// Export valued at spot prices, when configured
+	ExportRevenue float64 `json:"exportRevenue,omitempty"`
+	// Export valued at the feed-in tariff, when configured
+	FeedInRevenue float64 `json:"feedInRevenue,omitempty"`
+}
+
+// SelfConsumed returns the part of the production that stayed in the ZEV
+func (ps *ProducerStats) SelfConsumed() float64 {
+	return ps.Production - ps.Export
+}
+
+// attributeExport splits the grid export of every interval of the current
+// range onto the production meters in proportion to their net production
+// in that interval. Export in intervals without production (e.g. from the
+// battery at night) is left unattributed.
+func (ea *EnergyAnalyzer) attributeExport() {
+	ids := ea.config.ZEV.ProductionIDs
+	for index, interval := range ea.intervals {
+		var total float64
+		production := make([]float64, len(ids))
+		for i, id := range ids {
+			if series := ea.raw[rawKey{id, RoleProduction, FlowNet}]; series != nil && series[index] > 0 {
+				production[i] = series[index]
+				total += series[index]
+			}
+		}
+		if total <= 0 {
+			continue
+		}
+
+		var price float64
+		if ea.prices != nil {
+			price, _ = ea.prices.Price(interval.Start)
+		}
+		prices := ea.config.Prices.At(interval.Start)
+		feedIn := prices.FeedInPrice(ea.IsLowTariff(interval.Start))
+		for i, id := range ids {
+			stats := ea.producers[id]
+			if stats == nil {
+				stats = &ProducerStats{}
+				ea.producers[id] = stats
+			}
+			export := interval.GridExport * production[i] / total
+			stats.Production += production[i]
+			stats.Export += export
+			stats.ExportRevenue += export / 1000 * price
+			stats.FeedInRevenue += export / 1000 * feedIn
+		}
+	}
+}
+
+// Producers returns the production and export of every production meter in
+// the last analysis, or nil when there is only one (its figures are the
+// totals)
+func (ea *EnergyAnalyzer) Producers() []ProducerStats {
+	ids := ea.config.ZEV.ProductionIDs
+	if len(ids) < 2 {
+		return nil
+	}
+	producers := make([]ProducerStats, 0, len(ids))
+	for _, id := range ids {
+		stats := ProducerStats{SensorID: id, Name: id}
+		if collected := ea.producers[id]; collected != nil {
+			stats = *collected
+			stats.SensorID, stats.Name = id, id
+		}
+		if sensor := ea.sensorMap[id]; sensor != nil && sensor.Tag.Name != "" {
+			stats.Name = sensor.Tag.Name
+		}
+		producers = append(producers, stats)
+	}
+	return producers
+}
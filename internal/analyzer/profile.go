@@ -0,0 +1,67 @@
+package analyzer
+
+// ProfileSlots is the number of quarter hours in a typical day
+const ProfileSlots = 24 * 3600 / IntervalSeconds
+
+// LoadProfile is the typical day of the analysis period: the average power
+// in kW of every quarter hour, for the whole ZEV and per consumer
+type LoadProfile struct {
+	Production  [ProfileSlots]float64 `json:"productionKw"`
+	Consumption [ProfileSlots]float64 `json:"consumptionKw"`
+	Consumers   []ConsumerProfile     `json:"consumers"`
+}
+
+// ConsumerProfile is the typical day of a single consumer
+type ConsumerProfile struct {
+	ID    string                `json:"id,omitempty"`
+	Name  string                `json:"name"`
+	Power [ProfileSlots]float64 `json:"powerKw"`
+}
+
+// LoadProfile averages the intervals of the last analysis by time of day.
+// Consumers appear in config order followed by the shared usage.
+func (ea *EnergyAnalyzer) LoadProfile() *LoadProfile {
+	ids := append(ea.ConsumerIDs(), SharedConsumerID)
+	index := make(map[string]int, len(ids))
+	profile := &LoadProfile{Consumers: make([]ConsumerProfile, len(ids))}
+	for i, id := range ids {
+		index[id] = i
+		if id == SharedConsumerID {
+			profile.Consumers[i].Name = "Shared Usage"
+			continue
+		}
+		profile.Consumers[i].ID = id
+		profile.Consumers[i].Name = id
+		if sensor := ea.sensorMap[id]; sensor != nil && sensor.Tag.Name != "" {
+			profile.Consumers[i].Name = sensor.Tag.Name
+		}
+	}
+
+	var counts [ProfileSlots]int
+	for _, interval := range ea.intervals {
+		slot := (interval.Start.Hour()*3600 + interval.Start.Minute()*60) / IntervalSeconds
+		counts[slot]++
+		profile.Production[slot] += interval.InverterGeneratedPower
+		for id, usage := range interval.ConsumerUsage {
+			profile.Consumption[slot] += usage
+			if i, ok := index[id]; ok {
+				profile.Consumers[i].Power[slot] += usage
+			}
+		}
+	}
+
+	// Wh per interval -> kW: divide by the number of days and 0.25 h
+	const hours = float64(IntervalSeconds) / 3600
+	for slot, n := range counts {
+		if n == 0 {
+			continue
+		}
+		scale := 1 / (float64(n) * hours * 1000)
+		profile.Production[slot] *= scale
+		profile.Consumption[slot] *= scale
+		for i := range profile.Consumers {
+			profile.Consumers[i].Power[slot] *= scale
+		}
+	}
+	return profile
+}
@@ -0,0 +1,96 @@
+package analyzer
+
+import "zevalizer/internal/config"
+
+// ConsumerSavings compares the cost of a consumer's usage with the cost of
+// the same usage bought entirely from the grid
+type ConsumerSavings struct {
+	ID           string  `json:"id,omitempty"`
+	Name         string  `json:"name"`
+	GridOnlyCost float64 `json:"gridOnlyCost"`
+	GridCost     float64 `json:"gridCost"` // grid energy, including battery energy charged from the grid
+	Savings      float64 `json:"savings"`
+}
+
+// SavingsReport holds the savings by PV and battery per consumer and for
+// the whole ZEV at the configured grid tariffs
+type SavingsReport struct {
+	Currency     string            `json:"currency"`
+	Consumers    []ConsumerSavings `json:"consumers"`
+	GridOnlyCost float64           `json:"gridOnlyCost"`
+	GridCost     float64           `json:"gridCost"`
+	Savings      float64           `json:"savings"`
+}
+
+// Savings values the usage of the consumers at the low and high grid
+// tariff, once as if all of it came from the grid and once for the part
+// that actually did. The difference is the saving by PV and battery.
+func Savings(lowTariff, highTariff *EnergyStats, prices config.PriceConfig) *SavingsReport {
+	report := &SavingsReport{Currency: prices.CurrencyLabel(), Consumers: []ConsumerSavings{}}
+	index := make(map[string]int)
+	for _, tariff := range []struct {
+		stats *EnergyStats
+		price float64
+	}{{lowTariff, prices.GridLowPrice()}, {highTariff, prices.GridHigh}} {
+		if tariff.stats == nil {
+			continue
+		}
+		for i := range tariff.stats.Consumers {
+			consumer := &tariff.stats.Consumers[i]
+			key := consumerKey(consumer)
+			pos, ok := index[key]
+			if !ok {
+				pos = len(report.Consumers)
+				index[key] = pos
+				savings := ConsumerSavings{Name: consumer.Name()}
+				if !synthetic(consumer) {
+					savings.ID = consumer.Sensor.ID
+				}
+				report.Consumers = append(report.Consumers, savings)
+			}
+			savings := &report.Consumers[pos]
+			gridOnly := consumer.Total / 1000 * tariff.price
+			grid := (consumer.Sources.FromGrid + consumer.Sources.FromBatteryGrid) / 1000 * tariff.price
+			savings.GridOnlyCost += gridOnly
+			savings.GridCost += grid
+			savings.Savings += gridOnly - grid
+			report.GridOnlyCost += gridOnly
+			report.GridCost += grid
+			report.Savings += gridOnly - grid
+		}
+	}
+	return report
+}
+
+// PricedSavings values every part of the period at the prices in force
+// over it, see PriceParts, and sums the savings. The consumers keep the
+// order of the stats of the whole period. Without parts it is Savings.
+func PricedSavings(lowTariff, highTariff *EnergyStats, parts []PricePart, prices config.PriceConfig) *SavingsReport {
+	report := Savings(lowTariff, highTariff, prices)
+	if parts == nil {
+		return report
+	}
+	index := make(map[[2]string]int)
+	for i, consumer := range report.Consumers {
+		index[[2]string{consumer.ID, consumer.Name}] = i
+		report.Consumers[i] = ConsumerSavings{ID: consumer.ID, Name: consumer.Name}
+	}
+	report.GridOnlyCost, report.GridCost, report.Savings = 0, 0, 0
+	for _, part := range parts {
+		partReport := Savings(part.LowTariff, part.HighTariff, prices.At(part.Start))
+		for _, consumer := range partReport.Consumers {
+			pos, ok := index[[2]string{consumer.ID, consumer.Name}]
+			if !ok {
+				continue
+			}
+			savings := &report.Consumers[pos]
+			savings.GridOnlyCost += consumer.GridOnlyCost
+			savings.GridCost += consumer.GridCost
+			savings.Savings += consumer.Savings
+		}
+		report.GridOnlyCost += partReport.GridOnlyCost
+		report.GridCost += partReport.GridCost
+		report.Savings += partReport.Savings
+	}
+	return report
+}
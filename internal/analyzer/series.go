@@ -0,0 +1,90 @@
+package analyzer
+
+import (
+	"fmt"
+	"time"
+)
+
+// Aggregation levels for Series
+const (
+	AggregateDay   = "day"
+	AggregateMonth = "month"
+)
+
+// ValidateAggregation checks that agg is a supported aggregation level
+func ValidateAggregation(agg string) error {
+	switch agg {
+	case "", AggregateDay, AggregateMonth:
+		return nil
+	}
+	return fmt.Errorf("unknown aggregation %q (use %s or %s)", agg, AggregateDay, AggregateMonth)
+}
+
+// Series calculates one EnergyStats per day or per calendar month of
+// [from, to] from the intervals of the last analysis, across both tariffs.
+// The first and last entry only cover the part inside the period.
+func (ea *EnergyAnalyzer) Series(agg string, from, to time.Time) ([]*EnergyStats, error) {
+	switch agg {
+	case AggregateDay:
+		return ea.DailyStats(from, to)
+	case AggregateMonth:
+		return ea.MonthlyStats(from, to)
+	}
+	return nil, ValidateAggregation(agg)
+}
+
+// MonthlyStats calculates one EnergyStats per local calendar month of [from, to]
+func (ea *EnergyAnalyzer) MonthlyStats(from, to time.Time) ([]*EnergyStats, error) {
+	var months []*EnergyStats
+	start := from
+	for start.Before(to) {
+		next := time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, start.Location())
+		end := next
+		if end.After(to) {
+			end = to
+		}
+		stats, err := ea.StatsFor(start, end)
+		if err != nil {
+			return nil, fmt.Errorf("calculating stats for %s: %w", start.Format("2006-01"), err)
+		}
+		months = append(months, stats)
+		start = next
+	}
+	return months, nil
+}
+
+// YearOverYear arranges monthly statistics of several years by calendar month
+type YearOverYear struct {
+	Years  []int              `json:"years"`
+	Months [12][]*EnergyStats `json:"months"` // [month-1][index into Years], nil if not analyzed
+}
+
+// GroupByMonth arranges a monthly series (see MonthlyStats) by calendar month
+// so that the same month of different years can be compared
+func GroupByMonth(monthly []*EnergyStats) *YearOverYear {
+	yoy := &YearOverYear{}
+	index := make(map[int]int)
+	for _, stats := range monthly {
+		year := stats.Period.Start.Year()
+		if _, ok := index[year]; !ok {
+			index[year] = len(yoy.Years)
+			yoy.Years = append(yoy.Years, year)
+		}
+	}
+	for m := range yoy.Months {
+		yoy.Months[m] = make([]*EnergyStats, len(yoy.Years))
+	}
+	for _, stats := range monthly {
+		yoy.Months[stats.Period.Start.Month()-1][index[stats.Period.Start.Year()]] = stats
+	}
+	return yoy
+}
+
+// Year returns the statistics of all analyzed months of the year at index i
+func (yoy *YearOverYear) Year(i int) *EnergyStats {
+	var months []*EnergyStats
+	for m := range yoy.Months {
+		months = append(months, yoy.Months[m][i])
+	}
+	return MergeStats(months...)
+}
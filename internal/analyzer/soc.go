@@ -0,0 +1,50 @@
+package analyzer
+
+import "time"
+
+// SocPoint is a state of charge reading of a battery
+type SocPoint struct {
+	Time    time.Time `json:"time"`
+	Percent float64   `json:"percent"`
+}
+
+// SocSummary describes how a battery's state of charge moved over the
+// period. FullCycles counts equivalent full cycles: the sum of all rises in
+// state of charge divided by 100%.
+type SocSummary struct {
+	SensorID   string     `json:"sensorId"`
+	Name       string     `json:"name"`
+	Min        float64    `json:"minPercent"`
+	Max        float64    `json:"maxPercent"`
+	Average    float64    `json:"averagePercent"`
+	FullCycles float64    `json:"fullCycles"`
+	Timeline   []SocPoint `json:"timeline"`
+}
+
+// StateOfCharge returns the state of charge summary of every battery that
+// reported it during the last analysis
+func (ea *EnergyAnalyzer) StateOfCharge() []SocSummary {
+	var summaries []SocSummary
+	for _, id := range ea.config.ZEV.BatterySystemIDs {
+		points := ea.soc[id]
+		if len(points) == 0 {
+			continue
+		}
+		summary := SocSummary{SensorID: id, Name: id, Min: points[0].Percent, Max: points[0].Percent, Timeline: points}
+		if sensor := ea.sensorMap[id]; sensor != nil && sensor.Tag.Name != "" {
+			summary.Name = sensor.Tag.Name
+		}
+		var sum float64
+		for i, point := range points {
+			summary.Min = min(summary.Min, point.Percent)
+			summary.Max = max(summary.Max, point.Percent)
+			sum += point.Percent
+			if i > 0 && point.Percent > points[i-1].Percent {
+				summary.FullCycles += (point.Percent - points[i-1].Percent) / 100
+			}
+		}
+		summary.Average = sum / float64(len(points))
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Sort keys for SortConsumers
+const (
+	SortConfig = "config" // order of the consumerIds in the config
+	SortName   = "name"   // alphabetically by name
+	SortTotal  = "total"  // largest total consumption first
+	SortGrid   = "grid"   // largest grid share first
+)
+
+// SortConsumers orders the consumers by the given key. Synthetic consumers
+// without a sensor ID (e.g. "Shared Usage") always stay at the end, and ties
+// are broken by name so the order is stable between runs.
+func (stats *EnergyStats) SortConsumers(key string) error {
+	if err := ValidateSortKey(key); err != nil {
+		return err
+	}
+
+	sort.SliceStable(stats.Consumers, func(i, j int) bool {
+		a, b := &stats.Consumers[i], &stats.Consumers[j]
+		if synthetic(a) != synthetic(b) {
+			return !synthetic(a)
+		}
+		switch key {
+		case SortName:
+			return a.Name() < b.Name()
+		case SortTotal:
+			if a.Total != b.Total {
+				return a.Total > b.Total
+			}
+			return a.Name() < b.Name()
+		case SortGrid:
+			if a.GridShare() != b.GridShare() {
+				return a.GridShare() > b.GridShare()
+			}
+			return a.Name() < b.Name()
+		}
+		return false
+	})
+	return nil
+}
+
+// ValidateSortKey checks that key is a supported consumer sort key
+func ValidateSortKey(key string) error {
+	switch key {
+	case "", SortConfig, SortName, SortTotal, SortGrid:
+		return nil
+	}
+	return fmt.Errorf("invalid sort key %q: must be %s, %s, %s or %s",
+		key, SortConfig, SortName, SortTotal, SortGrid)
+}
+
+// GridShare returns the fraction of the consumer's energy that came from the grid
+func (cs *ConsumerStats) GridShare() float64 {
+	if cs.Total <= 0 {
+		return 0
+	}
+	return cs.Sources.FromGrid / cs.Total
+}
+
+// Name returns the display name of the consumer
+func (cs *ConsumerStats) Name() string {
+	if cs.Sensor == nil {
+		return ""
+	}
+	if cs.Sensor.Tag.Name != "" {
+		return cs.Sensor.Tag.Name
+	}
+	return cs.Sensor.ID
+}
+
+// synthetic reports whether the consumer is not backed by a real sensor
+func synthetic(cs *ConsumerStats) bool {
+	return cs.Sensor == nil || cs.Sensor.ID == ""
+}
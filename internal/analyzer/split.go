@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+
+	"zevalizer/internal/config"
+	"zevalizer/internal/models"
+)
+
+// splitID returns the ID of the virtual consumer of a split meter
+func splitID(sensorID, name string) string {
+	return sensorID + "/" + name
+}
+
+// splitShares returns the share of a split meter's usage per virtual
+// consumer, in configured order
+func splitShares(shares []config.SplitShare) []float64 {
+	var area float64
+	for _, share := range shares {
+		area += share.Area
+	}
+	result := make([]float64, len(shares))
+	for i, share := range shares {
+		if area > 0 {
+			result[i] = share.Area / area
+			continue
+		}
+		result[i] = share.Ratio
+	}
+	return result
+}
+
+// validateSplits checks that split meters are consumers and that their
+// shares are either all ratios adding up to 1 or all floor areas
+func (ea *EnergyAnalyzer) validateSplits() error {
+	consumers := make(map[string]bool)
+	for _, id := range ea.config.ZEV.ConsumerIDs {
+		consumers[id] = true
+	}
+	for _, members := range ea.config.ZEV.Combine {
+		for _, member := range members {
+			delete(consumers, member)
+		}
+	}
+	for id, shares := range ea.config.ZEV.Splits {
+		if !consumers[id] {
+			return fmt.Errorf("%w: splits: %s is not a consumer reported on its own", config.ErrInvalid, id)
+		}
+		if len(shares) < 2 {
+			return fmt.Errorf("%w: splits: %s needs at least two shares", config.ErrInvalid, id)
+		}
+		names := make(map[string]bool)
+		var ratios, areas float64
+		for _, share := range shares {
+			if share.Name == "" || names[share.Name] {
+				return fmt.Errorf("%w: splits: shares of %s need distinct names", config.ErrInvalid, id)
+			}
+			names[share.Name] = true
+			if share.Ratio < 0 || share.Area < 0 || (share.Ratio > 0) == (share.Area > 0) {
+				return fmt.Errorf("%w: splits: share %q of %s needs either a ratio or an area", config.ErrInvalid, share.Name, id)
+			}
+			ratios += share.Ratio
+			areas += share.Area
+		}
+		if ratios > 0 && areas > 0 {
+			return fmt.Errorf("%w: splits: shares of %s mix ratios and areas", config.ErrInvalid, id)
+		}
+		if ratios > 0 && math.Abs(ratios-1) > 0.001 {
+			return fmt.Errorf("%w: splits: ratios of %s add up to %g instead of 1", config.ErrInvalid, id, ratios)
+		}
+	}
+	return nil
+}
+
+// registerSplits adds a sensor record for every virtual consumer, named as
+// configured, so reports show it like a real consumer
+func (ea *EnergyAnalyzer) registerSplits() {
+	ea.splitMeter = make(map[string]string)
+	for id, shares := range ea.config.ZEV.Splits {
+		for _, share := range shares {
+			vid := splitID(id, share.Name)
+			ea.sensorMap[vid] = &models.Sensor{ID: vid, Tag: models.SensorTag{Name: share.Name}}
+			ea.splitMeter[vid] = id
+		}
+	}
+}
+
+// meterOf returns the sensor that measures a consumer: the split meter for
+// a virtual consumer, the consumer itself otherwise
+func (ea *EnergyAnalyzer) meterOf(id string) string {
+	if meter, ok := ea.splitMeter[id]; ok {
+		return meter
+	}
+	return id
+}
+
+// splitConsumers distributes the usage of every split meter to its virtual
+// consumers in every interval
+func (ea *EnergyAnalyzer) splitConsumers() {
+	for id, shares := range ea.config.ZEV.Splits {
+		ratios := splitShares(shares)
+		for _, interval := range ea.intervals {
+			usage, ok := interval.ConsumerUsage[id]
+			if !ok {
+				continue
+			}
+			delete(interval.ConsumerUsage, id)
+			for i, share := range shares {
+				interval.ConsumerUsage[splitID(id, share.Name)] += usage * ratios[i]
+			}
+		}
+	}
+}
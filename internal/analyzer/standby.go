@@ -0,0 +1,64 @@
+package analyzer
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// StandbyStartHour and StandbyEndHour bound the nightly hours in which
+	// the standby load is measured
+	StandbyStartHour = 0
+	StandbyEndHour   = 5
+	// StandbyPercentile is the low percentile of the nightly intervals taken
+	// as standby load, robust against single readings of zero
+	StandbyPercentile = 3
+)
+
+// StandbyLoad is the always-on baseline of a consumer
+type StandbyLoad struct {
+	ID        string  `json:"id,omitempty"`
+	Name      string  `json:"name"`
+	Power     float64 `json:"powerKw"`
+	AnnualKWh float64 `json:"annualKwh"` // the standby power running all year
+	Intervals int     `json:"intervals"` // nightly intervals with readings
+}
+
+// StandbyLoads derives the standby load of every configured consumer from
+// the nightly intervals of the last analysis in which its meter delivered a
+// reading. Consumers without such intervals are left out.
+func (ea *EnergyAnalyzer) StandbyLoads() []StandbyLoad {
+	var loads []StandbyLoad
+	for _, id := range ea.ConsumerIDs() {
+		covered := ea.coverage[ea.meterOf(id)]
+		var values []float64
+		for index, interval := range ea.intervals {
+			hour := interval.Start.Hour()
+			if hour < StandbyStartHour || hour >= StandbyEndHour || covered == nil || !covered[index] {
+				continue
+			}
+			values = append(values, interval.ConsumerUsage[id])
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		wh := percentile(values, StandbyPercentile)
+		power := wh / 1000 * 3600 / IntervalSeconds
+		load := StandbyLoad{ID: id, Name: id, Power: power, AnnualKWh: power * 24 * 365, Intervals: len(values)}
+		if sensor := ea.sensorMap[id]; sensor != nil && sensor.Tag.Name != "" {
+			load.Name = sensor.Tag.Name
+		}
+		loads = append(loads, load)
+	}
+	return loads
+}
+
+// percentile returns the p-th percentile of values by the nearest-rank
+// method without modifying them
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
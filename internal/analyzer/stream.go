@@ -0,0 +1,54 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AnalyzeStream analyzes [from, to] in pieces of at most days calendar days
+// and folds the statistics of each piece into running totals. Only the
+// intervals and raw data of one piece are held in memory at a time, so the
+// per-interval accessors (Intervals, StatsFor, DailyStats) only cover the
+// last piece afterwards. Pieces without any readings are skipped.
+func (ea *EnergyAnalyzer) AnalyzeStream(ctx context.Context, smId string, from, to time.Time, days int) (*EnergyStats, *EnergyStats, error) {
+	if days <= 0 {
+		return nil, nil, fmt.Errorf("stream piece size must be at least one day, got %d", days)
+	}
+	if err := ea.prepare(ctx, smId); err != nil {
+		return nil, nil, err
+	}
+
+	var low, high *EnergyStats
+	for start := from; start.Before(to); {
+		// Pieces served from the cache make no requests that would notice
+		// the cancellation
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		end := start.AddDate(0, 0, days)
+		if end.After(to) {
+			end = to
+		}
+		// Start fetching one interval early so the first counter difference
+		// of the piece is not lost at the boundary
+		fetchFrom := start.Add(-IntervalSeconds * time.Second)
+		pieceLow, pieceHigh, err := ea.analyzeRange(ctx, smId, start, end, fetchFrom)
+		switch {
+		case errors.Is(err, ErrNoData):
+			ea.debugf("No readings from %s to %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+		case err != nil:
+			return nil, nil, fmt.Errorf("analyzing %s to %s: %w", start.Format("2006-01-02"), end.Format("2006-01-02"), err)
+		default:
+			low = MergeStats(low, pieceLow)
+			high = MergeStats(high, pieceHigh)
+		}
+		start = end
+	}
+
+	if low == nil {
+		return nil, nil, ErrNoData
+	}
+	return low, high, nil
+}
@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"zevalizer/internal/config"
+)
+
+// validateTopology checks that every sub-meter and its parent are
+// configured consumers and that the hierarchy has no cycles
+func (ea *EnergyAnalyzer) validateTopology() error {
+	consumers := make(map[string]bool)
+	for _, id := range ea.config.ZEV.ConsumerIDs {
+		consumers[id] = true
+	}
+	parents := ea.config.ZEV.Parents
+	for child, parent := range parents {
+		if !consumers[child] || !consumers[parent] {
+			return fmt.Errorf("%w: parents: sub-meter %s and parent %s must both be consumers", config.ErrInvalid, child, parent)
+		}
+		seen := map[string]bool{child: true}
+		for id, ok := parent, true; ok; id, ok = parents[id] {
+			if seen[id] {
+				return fmt.Errorf("%w: parents: sub-meter %s is its own ancestor", config.ErrInvalid, child)
+			}
+			seen[id] = true
+		}
+	}
+	return nil
+}
+
+// subtractSubMeters removes the usage of every sub-meter from its parent so
+// energy measured by both is counted once. Each child is subtracted from its
+// direct parent only, using the measured values, so nested hierarchies work.
+func (ea *EnergyAnalyzer) subtractSubMeters() {
+	parents := ea.config.ZEV.Parents
+	if len(parents) == 0 {
+		return
+	}
+	measured := make(map[string]float64, len(parents))
+	for _, interval := range ea.intervals {
+		for child := range parents {
+			measured[child] = interval.ConsumerUsage[child]
+		}
+		for child, parent := range parents {
+			if measured[child] == 0 {
+				continue
+			}
+			net := interval.ConsumerUsage[parent] - measured[child]
+			if net < 0 {
+				ea.debugf("Sub-meter %s used %.1f Wh more than its parent %s at %s",
+					child, -net, parent, interval.Start.Format("2006-01-02 15:04"))
+				net = 0
+			}
+			interval.ConsumerUsage[parent] = net
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package analyzer
+
+import "zevalizer/internal/weather"
+
+// NormalizeByWeather sets the irradiation of every series entry and its
+// weather index: the production per kWh/m² of irradiation in percent of
+// the production per kWh/m² over the whole series. A dull month keeps an
+// index near 100%, an inverter failure or shading drops it. Entries without
+// irradiation for all their days are left alone.
+func NormalizeByWeather(series []*EnergyStats, irradiation *weather.Irradiation) {
+	var production, total float64
+	for _, stats := range series {
+		sum, ok := irradiation.Sum(stats.Period.Start, stats.Period.End)
+		if !ok || sum <= 0 {
+			continue
+		}
+		stats.Irradiation = sum
+		production += stats.Production
+		total += sum
+	}
+	if production <= 0 {
+		return
+	}
+	reference := production / total
+	for _, stats := range series {
+		if stats.Irradiation > 0 {
+			stats.WeatherIndex = stats.Production / stats.Irradiation / reference * 100
+		}
+	}
+}
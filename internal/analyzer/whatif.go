@@ -0,0 +1,169 @@
+package analyzer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BatteryScenario is a battery to simulate in place of the installed ones
+type BatteryScenario struct {
+	Capacity float64 `json:"capacityKwh"` // usable capacity
+	Power    float64 `json:"powerKw"`     // charge and discharge limit
+}
+
+// ScenarioResult holds the outcome of a battery scenario over the analyzed
+// period
+type ScenarioResult struct {
+	Label           string  `json:"label"`
+	Capacity        float64 `json:"capacityKwh"`
+	Power           float64 `json:"powerKw"`
+	GridImport      float64 `json:"gridImportWh"`
+	GridExport      float64 `json:"gridExportWh"`
+	SelfConsumption float64 `json:"selfConsumptionPercent"`
+	Autarchy        float64 `json:"autarchyPercent"`
+	FullCycles      float64 `json:"fullCycles,omitempty"`
+	// Grid cost saved compared to no battery, less the lost feed-in revenue,
+	// when prices are configured
+	AvoidedCost float64 `json:"avoidedCost,omitempty"`
+}
+
+// BatterySimulation compares the period without a battery, with the
+// installed batteries and with every simulated battery
+type BatterySimulation struct {
+	Currency  string           `json:"currency,omitempty"`
+	Scenarios []ScenarioResult `json:"scenarios"`
+}
+
+// Labels of the reference rows of a BatterySimulation
+const (
+	ScenarioNone      = "none"
+	ScenarioInstalled = "installed"
+)
+
+// ParseBatteryScenarios parses a comma separated list of battery sizes in
+// kWh, each optionally followed by ":" and the power limit in kW, e.g.
+// "10,20:5". Without a power limit the battery charges and discharges with
+// its capacity per hour (1C).
+func ParseBatteryScenarios(spec string) ([]BatteryScenario, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	var scenarios []BatteryScenario
+	for _, item := range strings.Split(spec, ",") {
+		capacityText, powerText, hasPower := strings.Cut(strings.TrimSpace(item), ":")
+		capacity, err := strconv.ParseFloat(capacityText, 64)
+		if err != nil || capacity <= 0 {
+			return nil, fmt.Errorf("battery size %q must be a positive number of kWh", item)
+		}
+		power := capacity
+		if hasPower {
+			power, err = strconv.ParseFloat(powerText, 64)
+			if err != nil || power <= 0 {
+				return nil, fmt.Errorf("battery power %q must be a positive number of kW", item)
+			}
+		}
+		scenarios = append(scenarios, BatteryScenario{Capacity: capacity, Power: power})
+	}
+	return scenarios, nil
+}
+
+// SimulateBatteries replays the intervals of the last analysis with every
+// scenario battery in place of the installed ones. The production and
+// consumption of each interval are reconstructed from the meters without
+// the installed batteries; the simulated battery starts empty, charges
+// from surplus production only and discharges to cover consumption, losing
+// the inverter efficiency on the way out.
+func (ea *EnergyAnalyzer) SimulateBatteries(scenarios []BatteryScenario) *BatterySimulation {
+	efficiency := ea.config.ZEV.InverterEfficiency
+	if efficiency == 0 {
+		efficiency = 0.93
+	}
+	simulation := &BatterySimulation{}
+	if ea.config.Prices.Enabled() || ea.config.Prices.FeedIn > 0 {
+		simulation.Currency = ea.config.Prices.CurrencyLabel()
+	}
+
+	var production, consumption, installedCost float64
+	installed := ScenarioResult{Label: ScenarioInstalled}
+	for _, interval := range ea.intervals {
+		production += plainProduction(interval, efficiency)
+		consumption += interval.GridImport + interval.InverterGeneratedPower - interval.GridExport
+		installed.GridImport += interval.GridImport
+		installed.GridExport += interval.GridExport
+		installedCost += ea.gridCost(interval, interval.GridImport, interval.GridExport)
+	}
+
+	none, noneCost := ea.simulateBattery(BatteryScenario{}, efficiency)
+	none.Label = ScenarioNone
+	installed.AvoidedCost = noneCost - installedCost
+	results := []ScenarioResult{none, installed}
+	for _, scenario := range scenarios {
+		result, cost := ea.simulateBattery(scenario, efficiency)
+		result.Label = fmt.Sprintf("%g kWh / %g kW", scenario.Capacity, scenario.Power)
+		result.AvoidedCost = noneCost - cost
+		results = append(results, result)
+	}
+	for i := range results {
+		result := &results[i]
+		if production > 0 {
+			result.SelfConsumption = (production - result.GridExport) / production * 100
+		}
+		if consumption > 0 {
+			result.Autarchy = (consumption - result.GridImport) / consumption * 100
+		}
+	}
+	simulation.Scenarios = results
+	return simulation
+}
+
+// simulateBattery runs one scenario over all intervals and returns its
+// result along with the grid cost less the feed-in revenue. A scenario
+// without capacity yields the grid exchange without any battery.
+func (ea *EnergyAnalyzer) simulateBattery(scenario BatteryScenario, efficiency float64) (ScenarioResult, float64) {
+	result := ScenarioResult{Capacity: scenario.Capacity, Power: scenario.Power}
+	capacity := scenario.Capacity * 1000
+	limit := scenario.Power * 1000 * IntervalSeconds / 3600 // Wh per interval
+	var stored, discharged, cost float64
+	for _, interval := range ea.intervals {
+		consumption := interval.GridImport + interval.InverterGeneratedPower - interval.GridExport
+		residual := consumption - plainProduction(interval, efficiency)
+		var gridImport, gridExport float64
+		if residual < 0 {
+			charge := min(-residual, limit, capacity-stored)
+			stored += charge
+			gridExport = -residual - charge
+		} else {
+			discharge := min(residual/efficiency, limit, stored)
+			stored -= discharge
+			discharged += discharge
+			gridImport = residual - discharge*efficiency
+		}
+		result.GridImport += gridImport
+		result.GridExport += gridExport
+		cost += ea.gridCost(interval, gridImport, gridExport)
+	}
+	if capacity > 0 {
+		result.FullCycles = discharged / capacity
+	}
+	return result, cost
+}
+
+// plainProduction returns the AC production of an interval as it would
+// have been without the installed batteries: the inverter output plus what
+// went into the batteries, less what came out of them after the inverter
+func plainProduction(interval *IntervalData, efficiency float64) float64 {
+	return max(interval.InverterGeneratedPower+interval.BatteryCharge-interval.BatteryDischarge*efficiency, 0)
+}
+
+// gridCost values the grid exchange of an interval at the configured
+// tariff prices: import at the grid price less export at the feed-in price
+func (ea *EnergyAnalyzer) gridCost(interval *IntervalData, gridImport, gridExport float64) float64 {
+	prices := ea.config.Prices.At(interval.Start)
+	lowTariff := ea.IsLowTariff(interval.Start)
+	price := prices.GridHigh
+	if lowTariff {
+		price = prices.GridLowPrice()
+	}
+	return gridImport/1000*price - gridExport/1000*prices.FeedInPrice(lowTariff)
+}
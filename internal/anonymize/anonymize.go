@@ -0,0 +1,136 @@
+// internal/anonymize/anonymize.go
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"zevalizer/internal/models"
+)
+
+// key is the secret of the installation the pseudonyms are derived from,
+// see LoadKey. Without it, anyone could hash candidate names and IDs and
+// match them against the pseudonyms of a report.
+var key []byte
+
+// KeyFilePath returns the path of the pseudonym key belonging to a config
+// file, next to it
+func KeyFilePath(configPath string) string {
+	ext := filepath.Ext(configPath)
+	return strings.TrimSuffix(configPath, ext) + ".anonymize-key"
+}
+
+// LoadKey reads the pseudonym key from path. On first use a random key is
+// generated and saved there, so the pseudonyms stay the same across runs
+// for as long as the file is kept. It must be called before any pseudonym
+// is derived.
+func LoadKey(path string) error {
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return createKey(path)
+	}
+	if err != nil {
+		return fmt.Errorf("reading pseudonym key: %w", err)
+	}
+	secret, err := hex.DecodeString(strings.TrimSpace(string(buf)))
+	if err != nil || len(secret) < 16 {
+		return fmt.Errorf("%s does not hold a pseudonym key of at least 16 bytes in hex", path)
+	}
+	key = secret
+	return nil
+}
+
+// createKey generates a new pseudonym key and saves it, readable by the
+// owner only. An existing file is never replaced, it would change all
+// pseudonyms.
+func createKey(path string) error {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("generating pseudonym key: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("creating pseudonym key: %w", err)
+	}
+	if _, err := fmt.Fprintln(f, hex.EncodeToString(secret)); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("writing pseudonym key: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("writing pseudonym key: %w", err)
+	}
+	key = secret
+	return nil
+}
+
+// ID returns a stable pseudonym for a sensor ID. The same ID always maps to
+// the same pseudonym under the same key, so anonymized reports from
+// different runs can still be compared with each other.
+func ID(id string) string {
+	if id == "" {
+		return ""
+	}
+	return "sensor-" + digest(id)
+}
+
+// Name returns a stable display name for the sensor with the given ID
+func Name(id string) string {
+	return "Consumer " + digest(id)
+}
+
+// Tenant returns a stable display name for a tenant
+func Tenant(name string) string {
+	return "Tenant " + digest(name)
+}
+
+// Group returns a stable display name for a consumer group. Group names
+// are free text and often name the building or the owner.
+func Group(name string) string {
+	return "Group " + digest("group:"+name)
+}
+
+// ConfigEntry anonymizes a suggested config entry of the form "id  # name"
+func ConfigEntry(entry string) string {
+	id, _, _ := strings.Cut(entry, "  # ")
+	if id == "" {
+		return ""
+	}
+	return ID(id) + "  # " + Name(id)
+}
+
+// Sensor returns an anonymized copy of the sensor. Network identifiers and
+// free text notes are dropped entirely. Synthetic sensors without an ID
+// (e.g. "Shared Usage") are returned unchanged, they do not identify a tenant.
+func Sensor(sensor *models.Sensor) *models.Sensor {
+	if sensor == nil || sensor.ID == "" {
+		return sensor
+	}
+	anon := *sensor
+	anon.ID = ID(sensor.ID)
+	anon.Tag.Name = Name(sensor.ID)
+	anon.Mac = ""
+	anon.IP = ""
+	anon.Data.DeviceID = ""
+	anon.Data.Notes = ""
+	return &anon
+}
+
+// digest returns the first bytes of the HMAC-SHA256 of id under the key
+func digest(id string) string {
+	if key == nil {
+		panic("anonymize: pseudonym key not loaded")
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id))
+	return fmt.Sprintf("%x", mac.Sum(nil)[:4])
+}
@@ -0,0 +1,61 @@
+package anonymize
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadKey(t *testing.T) {
+	dir := t.TempDir()
+	path := KeyFilePath(filepath.Join(dir, "config.yaml"))
+	if err := LoadKey(path); err != nil {
+		t.Fatal(err)
+	}
+	first := ID("abc123")
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("key file %s: %v, mode %v", path, err, info.Mode())
+	}
+
+	// a later run reads the same key back
+	key = nil
+	if err := LoadKey(path); err != nil {
+		t.Fatal(err)
+	}
+	if again := ID("abc123"); again != first {
+		t.Errorf("pseudonym changed from %s to %s with the saved key", first, again)
+	}
+
+	// another installation has other pseudonyms
+	if err := LoadKey(filepath.Join(dir, "other.anonymize-key")); err != nil {
+		t.Fatal(err)
+	}
+	if other := ID("abc123"); other == first {
+		t.Errorf("pseudonym %s is the same under another key", other)
+	}
+
+	if err := os.WriteFile(path, []byte("short\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadKey(path); err == nil {
+		t.Error("loaded a key that is no hex")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	if err := LoadKey(filepath.Join(t.TempDir(), "config.anonymize-key")); err != nil {
+		t.Fatal(err)
+	}
+	group := Group("Haus Müller")
+	if !strings.HasPrefix(group, "Group ") || strings.Contains(group, "Müller") {
+		t.Errorf("group pseudonym %q", group)
+	}
+	if Group("Haus Müller") != group || Group("Haus Meier") == group {
+		t.Error("group pseudonyms are not stable per name")
+	}
+	// a group named like a consumer ID gets another pseudonym
+	if strings.TrimPrefix(group, "Group ") == strings.TrimPrefix(Name("Haus Müller"), "Consumer ") {
+		t.Error("group and consumer pseudonyms of the same text match")
+	}
+}
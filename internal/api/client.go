@@ -1,228 +1,479 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 	"zevalizer/internal/config"
 	"zevalizer/internal/models"
+	"zevalizer/internal/progress"
 )
 
+// Error is returned by all Client methods, so callers can tell API failures
+// (network problems, unexpected responses) from other errors
+type Error struct {
+	StatusCode int // HTTP status code of an unexpected response, 0 otherwise
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func apiErrorf(statusCode int, format string, args ...interface{}) error {
+	return &Error{StatusCode: statusCode, Err: fmt.Errorf(format, args...)}
+}
+
 type Client struct {
 	config    *config.Config
 	http      *http.Client
-	chunkDays int // maximum days per request
+	chunkDays atomic.Int32 // days per request, adapted to the responses
+	oauth     *tokenSource // nil without OAuth login
 }
 
 func (c *Client) debugf(format string, args ...interface{}) {
 	if c.config.Debug {
-		fmt.Printf("DEBUG: "+format+"\n", args...)
+		fmt.Fprintf(os.Stderr, "DEBUG: "+format+"\n", args...)
 	}
 }
 
-func NewClient(config *config.Config) *Client {
-	return &Client{
+// NewClient creates a client of the API. It fails when the TLS files of the
+// config cannot be loaded.
+func NewClient(config *config.Config) (*Client, error) {
+	tlsCfg, err := tlsConfig(&config.API.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	// Connections that cannot be established in time fail fast instead of
+	// using up the whole request timeout
+	connectTimeout := config.API.ConnectTimeout()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	if tlsCfg != nil {
+		transport.TLSClientConfig = tlsCfg
+	}
+
+	c := &Client{
 		config: config,
 		http: &http.Client{
-			Timeout: 30 * time.Second,
+			Transport: transport,
+			Timeout:   config.API.RequestTimeout(),
 		},
-		chunkDays: 30,
 	}
+	c.chunkDays.Store(int32(config.API.InitialChunkDays()))
+	if config.API.OAuth.Enabled() {
+		c.oauth = &tokenSource{api: &config.API, http: c.http, debugf: c.debugf}
+	}
+	return c, nil
 }
 
-func (c *Client) createRequest(method, path string) (*http.Request, error) {
-	req, err := http.NewRequest(method, c.config.API.BaseURL+path, nil)
+// ChunkDays returns the number of days currently fetched per request
+func (c *Client) ChunkDays() int {
+	return int(c.chunkDays.Load())
+}
+
+// resizeChunks changes the days per request from days to size, unless a
+// concurrent fetch changed it already
+func (c *Client) resizeChunks(days, size int) {
+	size = min(max(size, 1), c.config.API.ChunkDaysLimit())
+	if size != days && c.chunkDays.CompareAndSwap(int32(days), int32(size)) {
+		c.debugf("Chunk size changed from %d to %d days", days, size)
+	}
+}
+
+func (c *Client) createRequest(ctx context.Context, method, path string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.config.API.BaseURL+path, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	// Add basic auth
-	auth := base64.StdEncoding.EncodeToString([]byte(c.config.API.Username + ":" + c.config.API.Password))
-	req.Header.Add("Authorization", "Basic "+auth)
+	// Authenticate with the bearer token or else basic auth; the access
+	// token of the OAuth login is added per attempt
+	api := &c.config.API
+	switch {
+	case api.OAuth.Enabled():
+	case api.Token != "":
+		req.Header.Add("Authorization", "Bearer "+api.Token)
+	case api.Username != "" || api.Password != "":
+		auth := base64.StdEncoding.EncodeToString([]byte(api.Username + ":" + api.Password))
+		req.Header.Add("Authorization", "Basic "+auth)
+	}
+	if api.APIKey != "" {
+		req.Header.Add(api.KeyHeader(), api.APIKey)
+	}
 
 	return req, nil
 }
 
-// timeChunk represents a chunk of time for date range requests
-type timeChunk struct {
-	Start time.Time
-	End   time.Time
+// progressBar returns a bar counting the days of fetches spanning several
+// chunks, or nil for single requests and when progress output is unwanted
+// (quiet or debug mode)
+func (c *Client) progressBar(label string, days int) *progress.Bar {
+	if days <= c.ChunkDays() || c.config.Quiet || c.config.Debug {
+		return nil
+	}
+	return progress.New(os.Stderr, label, days)
 }
 
-// calculateChunks splits a date range into smaller chunks of up to chunkDays days each
-func (c *Client) calculateChunks(from, to time.Time) []timeChunk {
-	totalDays := int(to.Sub(from).Hours()/24) + 1
-	numChunks := (totalDays + c.chunkDays - 1) / c.chunkDays
-
-	chunks := make([]timeChunk, 0, numChunks)
-	chunkStart := from
+// fetchRange fetches [from, to] in chunks of ChunkDays days and hands every
+// response to decode, which returns the time of its last reading (zero if
+// there is none). Chunks end at local midnight, also across daylight saving
+// time switches. The chunk size adapts to the API:
+//   - a chunk of several days timing out is fetched again in halves at
+//     once instead of being retried; other retryable errors such as 429
+//     and 5xx are retried with backoff first, a server error persisting
+//     through the retries halves the chunks as well
+//   - a response whose readings end more than a day early may have been
+//     truncated, so the rest of the chunk is fetched; readings in there
+//     confirm the truncation and halve the chunks
+//   - a full chunk answered within a quarter of the request timeout grows
+//     the chunks by half, up to the configured maximum
+func (c *Client) fetchRange(ctx context.Context, label string, from, to time.Time,
+	path func(start, end time.Time) string, decode func(body []byte) (time.Time, error)) error {
+	totalDays := int(math.Ceil(to.Sub(from).Hours() / 24))
+	bar := c.progressBar(label, totalDays)
+	defer bar.Finish()
+
+	var (
+		doneDays  int
+		resumeEnd time.Time // end of a chunk whose response ended early
+	)
+	for start := from; start.Before(to); {
+		days := c.ChunkDays()
+		full := startOfDay(start).AddDate(0, 0, days)
+		end := resumeEnd
+		if end.IsZero() {
+			end = full
+			if end.After(to) {
+				end = to
+			}
+		}
+		multiDay := end.After(start.AddDate(0, 0, 1))
+
+		// a chunk of several days timing out is not retried but split
+		began := time.Now()
+		body, err := c.fetch(ctx, path(start, end), c.config.API.MaxRetries(), multiDay)
+		if err != nil && multiDay && (timedOut(ctx, err) || serverError(ctx, err)) {
+			c.debugf("Fetching %s to %s failed, halving the chunks: %v",
+				start.Format("2006-01-02"), end.Format("2006-01-02"), err)
+			c.resizeChunks(days, days/2)
+			resumeEnd = time.Time{}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		last, err := decode(body)
+		if err != nil {
+			return err
+		}
 
-	for i := 0; i < numChunks; i++ {
-		chunkEnd := chunkStart.Add(time.Duration(c.chunkDays) * 24 * time.Hour)
-		if chunkEnd.After(to) {
-			chunkEnd = to
+		truncated := !resumeEnd.IsZero() && !last.IsZero()
+		resumeEnd = time.Time{}
+		switch {
+		case truncated:
+			c.debugf("Response up to %s was truncated", start.Format("2006-01-02 15:04"))
+			c.resizeChunks(days, days/2)
+		case end.Equal(full) && time.Since(began) < c.config.API.RequestTimeout()/4:
+			c.resizeChunks(days, days+max(days/2, 1))
+		}
+		if early := earlier(end, time.Now()).AddDate(0, 0, -1); multiDay && !last.IsZero() && last.Before(early) {
+			resumeEnd = end
+			end = last.Add(time.Second)
 		}
-		chunks = append(chunks, timeChunk{Start: chunkStart, End: chunkEnd})
-		chunkStart = chunkEnd
+		bar.Add(int(end.Sub(from).Hours()/24)-doneDays, start.Format("2006-01-02"))
+		doneDays = int(end.Sub(from).Hours() / 24)
+		start = end
 	}
+	return nil
+}
 
-	return chunks
+// startOfDay returns local midnight of the day of t
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// earlier returns the earlier of a and b
+func earlier(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
 }
 
+// maxRetryDelay caps the backoff between retries, also when the server asks
+// for a longer wait
+const maxRetryDelay = time.Minute
+
 // fetchChunkedData performs an HTTP GET request and returns the response body.
 // The caller is responsible for unmarshaling the JSON response.
-func (c *Client) fetchChunkedData(path string) ([]byte, error) {
-	req, err := c.createRequest("GET", path)
+// Network errors and server errors (5xx, 408, 429) are retried up to the
+// configured number of times with exponential backoff and jitter; other
+// errors are permanent and returned at once.
+func (c *Client) fetchChunkedData(ctx context.Context, path string) ([]byte, error) {
+	return c.fetch(ctx, path, c.config.API.MaxRetries(), false)
+}
+
+// fetch performs the GET request of fetchChunkedData with up to retries
+// retries. A request that can be split gives up on a timeout, so the
+// caller can fetch its parts instead.
+func (c *Client) fetch(ctx context.Context, path string, retries int, split bool) ([]byte, error) {
+	req, err := c.createRequest(ctx, "GET", path)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %v", err)
+		return nil, apiErrorf(0, "creating request: %v", err)
+	}
+
+	var reauthorized bool
+	for attempt := 0; ; attempt++ {
+		body, retryAfter, err := c.do(ctx, req)
+		var apiErr *Error
+		if c.oauth != nil && !reauthorized && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+			// the access token was revoked before it expired: renew it once
+			c.debugf("Access token rejected, renewing it")
+			c.oauth.invalidate(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+			reauthorized = true
+			attempt--
+			continue
+		}
+		if err == nil || attempt == retries || !retryable(ctx, err) || (split && timedOut(ctx, err)) {
+			return body, err
+		}
+		delay := c.backoff(attempt, retryAfter)
+		c.debugf("Retrying %s in %s (%d/%d): %v", path, delay.Round(time.Millisecond), attempt+1, retries, err)
+		select {
+		case <-ctx.Done():
+			return nil, apiErrorf(0, "waiting to retry: %w", ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// do performs a single request. retryAfter is the wait the server asked for
+// with the Retry-After header, 0 if none.
+func (c *Client) do(ctx context.Context, req *http.Request) (body []byte, retryAfter time.Duration, err error) {
+	if c.oauth != nil {
+		token, err := c.oauth.accessToken(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
 	}
 
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("making request: %v", err)
+		return nil, 0, apiErrorf(0, "making request: %w", err)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err = io.ReadAll(resp.Body)
 	resp.Body.Close()
 	if err != nil {
-		return nil, fmt.Errorf("reading response: %v", err)
+		return nil, 0, apiErrorf(0, "reading response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return nil, retryAfter, apiErrorf(resp.StatusCode, "unexpected status code %d: %s", resp.StatusCode, string(body))
 	}
 
-	return body, nil
+	return body, 0, nil
 }
 
-func (c *Client) TestConnection() error {
-	req, err := c.createRequest("GET", "/v1/overview")
-	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+// retryable reports whether a failed request may succeed when repeated: a
+// network error or a server error, unless ctx is done. A server certificate
+// failing verification will fail again.
+func retryable(ctx context.Context, err error) bool {
+	var certErr *tls.CertificateVerificationError
+	if ctx.Err() != nil || errors.As(err, &certErr) {
+		return false
 	}
-
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to make request: %v", err)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return false
 	}
-	defer resp.Body.Close()
+	switch code := apiErr.StatusCode; {
+	case code == 0: // network error
+		return true
+	case code >= 500, code == http.StatusRequestTimeout, code == http.StatusTooManyRequests:
+		return true
+	}
+	return false
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+// timedOut reports whether a request took too long for the client or a
+// gateway, as large ranges do; a smaller one may get through
+func timedOut(ctx context.Context, err error) bool {
+	var apiErr *Error
+	if ctx.Err() != nil || !errors.As(err, &apiErr) {
+		return false
 	}
+	if code := apiErr.StatusCode; code != 0 {
+		return code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
 
-	return nil
+// serverError reports whether the server failed on a request, status 5xx
+func serverError(ctx context.Context, err error) bool {
+	var apiErr *Error
+	return ctx.Err() == nil && errors.As(err, &apiErr) && apiErr.StatusCode >= 500
 }
 
-func (c *Client) GetUsers() ([]models.User, error) {
-	req, err := c.createRequest("GET", "/v1/users")
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %v", err)
+// backoff returns the delay before retry attempt+1: the configured delay
+// doubled for every attempt so far, randomized by up to half of it so that
+// parallel fetches do not retry in lockstep. A longer retryAfter of the
+// server wins.
+func (c *Client) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := c.config.API.RetryDelay()
+	for i := 0; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
 	}
+	delay = min(delay, maxRetryDelay)
+	delay -= time.Duration(rand.Float64() * float64(delay) / 2)
+	return min(max(delay, retryAfter), maxRetryDelay)
+}
 
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("making request: %v", err)
-	}
-	defer resp.Body.Close()
+func (c *Client) TestConnection(ctx context.Context) error {
+	_, err := c.fetchChunkedData(ctx, "/v1/overview")
+	return err
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+func (c *Client) GetUsers(ctx context.Context) ([]models.User, error) {
+	body, err := c.fetchChunkedData(ctx, "/v1/users")
+	if err != nil {
+		return nil, err
 	}
 
 	var users []models.User
-	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
-		return nil, fmt.Errorf("decoding response: %v", err)
+	if err := json.Unmarshal(body, &users); err != nil {
+		return nil, apiErrorf(0, "decoding response: %v", err)
 	}
 
 	return users, nil
 }
 
-func (c *Client) GetSensors(smID string) ([]models.Sensor, error) {
+func (c *Client) GetSensors(ctx context.Context, smID string) ([]models.Sensor, error) {
 	path := fmt.Sprintf("/v1/info/sensors/%s", smID)
 	c.debugf("Fetching sensors from: %s", path)
 
-	req, err := c.createRequest("GET", path)
+	body, err := c.fetchChunkedData(ctx, path)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %v", err)
-	}
-
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("making request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading response: %v", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+		return nil, err
 	}
 
 	var sensors []models.Sensor
 	if err := json.Unmarshal(body, &sensors); err != nil {
-		return nil, fmt.Errorf("decoding response: %v\nResponse body: %s", err, string(body))
+		return nil, apiErrorf(0, "decoding response: %v\nResponse body: %s", err, string(body))
 	}
 
 	return sensors, nil
 }
 
-func (c *Client) GetSensorData(smId string, sensorID string, from, to time.Time) ([]models.SensorData, error) {
-	var allData []models.SensorData
-	chunks := c.calculateChunks(from, to)
-
-	for _, chunk := range chunks {
-		fromStr := chunk.Start.UTC().Format("2006-01-02T15:04:05.000Z")
-		toStr := chunk.End.UTC().Format("2006-01-02T15:04:05.000Z")
-		path := fmt.Sprintf("/v1/data/sensor/%s/range?from=%s&to=%s&interval=900", sensorID, fromStr, toStr)
-		c.debugf("Fetching sensor data from: %s", path)
+func (c *Client) GetSensorData(ctx context.Context, smId string, sensorID string, from, to time.Time) ([]models.SensorData, error) {
+	return c.getSensorRange(ctx, sensorID, from, to, 900)
+}
 
-		body, err := c.fetchChunkedData(path)
-		if err != nil {
-			return nil, err
-		}
+// GetPowerData fetches the sensor data of [from, to] at a resolution of
+// seconds, for the instantaneous power (pW) between the 15-minute readings
+func (c *Client) GetPowerData(ctx context.Context, smId string, sensorID string, from, to time.Time, seconds int) ([]models.SensorData, error) {
+	return c.getSensorRange(ctx, sensorID, from, to, seconds)
+}
 
+// getSensorRange fetches the readings of a sensor every seconds in chunks
+func (c *Client) getSensorRange(ctx context.Context, sensorID string, from, to time.Time, seconds int) ([]models.SensorData, error) {
+	var allData []models.SensorData
+	err := c.fetchRange(ctx, "Sensor "+sensorID, from, to, func(start, end time.Time) string {
+		fromStr := start.UTC().Format("2006-01-02T15:04:05.000Z")
+		toStr := end.UTC().Format("2006-01-02T15:04:05.000Z")
+		path := fmt.Sprintf("/v1/data/sensor/%s/range?from=%s&to=%s&interval=%d", sensorID, fromStr, toStr, seconds)
+		c.debugf("Fetching sensor data from: %s", path)
+		return path
+	}, func(body []byte) (time.Time, error) {
 		var chunkData []models.SensorData
 		if err := json.Unmarshal(body, &chunkData); err != nil {
-			return nil, fmt.Errorf("decoding response: %v\nFull response: %s", err, string(body))
+			return time.Time{}, apiErrorf(0, "decoding response: %v\nFull response: %s", err, string(body))
 		}
-
 		allData = append(allData, chunkData...)
-	}
 
+		var last time.Time
+		for _, reading := range chunkData {
+			if reading.Date.After(last) {
+				last = reading.Date
+			}
+		}
+		return last, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return allData, nil
 }
 
-func (c *Client) GetZevData(smId string, from, to time.Time) ([]models.ZevData, error) {
-	var allData []models.ZevData
-	chunks := c.calculateChunks(from, to)
-	c.debugf("Total days: %d, numChunks: %d", len(chunks)*c.chunkDays, len(chunks))
+// GetLiveData fetches the current power values of the gateway and its devices
+func (c *Client) GetLiveData(ctx context.Context, smId string) (*models.GatewayStream, error) {
+	path := fmt.Sprintf("/v1/stream/gateway/%s", smId)
+	c.debugf("Fetching live data from: %s", path)
 
-	for _, chunk := range chunks {
-		fromStr := chunk.Start.UTC().Format("2006-01-02T15:04:05.000Z")
-		toStr := chunk.End.UTC().Format("2006-01-02T15:04:05.000Z")
-		path := fmt.Sprintf("/v1/data/zev/%s?from=%s&to=%s", smId, fromStr, toStr)
-		c.debugf("Fetching zev data from: %s", path)
+	body, err := c.fetchChunkedData(ctx, path)
+	if err != nil {
+		return nil, err
+	}
 
-		body, err := c.fetchChunkedData(path)
-		if err != nil {
-			return nil, err
-		}
+	var stream models.GatewayStream
+	if err := json.Unmarshal(body, &stream); err != nil {
+		return nil, apiErrorf(0, "decoding response: %v\nResponse body: %s", err, string(body))
+	}
+	return &stream, nil
+}
 
+func (c *Client) GetZevData(ctx context.Context, smId string, from, to time.Time) ([]models.ZevData, error) {
+	var allData []models.ZevData
+	err := c.fetchRange(ctx, "ZEV data", from, to, func(start, end time.Time) string {
+		fromStr := start.UTC().Format("2006-01-02T15:04:05.000Z")
+		toStr := end.UTC().Format("2006-01-02T15:04:05.000Z")
+		path := fmt.Sprintf("/v1/data/zev/%s?from=%s&to=%s", smId, fromStr, toStr)
+		c.debugf("Fetching zev data from: %s", path)
+		return path
+	}, func(body []byte) (time.Time, error) {
 		var chunkData []models.ZevData
 		if err := json.Unmarshal(body, &chunkData); err != nil {
-			return nil, fmt.Errorf("decoding response: %v", err)
+			return time.Time{}, apiErrorf(0, "decoding response: %v", err)
 		}
-
 		allData = append(allData, chunkData...)
-	}
 
+		var last time.Time
+		for _, sensor := range chunkData {
+			for _, point := range sensor.Data {
+				if point.CreatedAt.After(last) {
+					last = point.CreatedAt
+				}
+			}
+		}
+		return last, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return allData, nil
 }
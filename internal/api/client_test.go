@@ -0,0 +1,422 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"zevalizer/internal/config"
+	"zevalizer/internal/models"
+)
+
+// testClient returns a quiet client of server that retries retries times
+// after a millisecond
+func testClient(t *testing.T, server *httptest.Server, retries int, api config.APIConfig) *Client {
+	t.Helper()
+	cfg := &config.Config{API: api, Quiet: true}
+	cfg.API.BaseURL = server.URL
+	cfg.API.Retries = &retries
+	cfg.API.RetryDelaySeconds = 0.001
+	c, err := NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// rangeOf returns the range of a sensor data request
+func rangeOf(query url.Values) (from, to time.Time) {
+	from, _ = time.Parse(time.RFC3339, query.Get("from"))
+	to, _ = time.Parse(time.RFC3339, query.Get("to"))
+	return from, to
+}
+
+// hourly answers with the readings of the full hours in [from, to), of the
+// first limit days only if limit is not 0
+func hourly(w http.ResponseWriter, from, to time.Time, limit int) {
+	if limit > 0 && to.After(from.AddDate(0, 0, limit)) {
+		to = from.AddDate(0, 0, limit)
+	}
+	data := []models.SensorData{}
+	first := from.Truncate(time.Hour)
+	if first.Before(from) {
+		first = first.Add(time.Hour)
+	}
+	for t := first; t.Before(to); t = t.Add(time.Hour) {
+		data = append(data, models.SensorData{Date: t})
+	}
+	json.NewEncoder(w).Encode(data)
+}
+
+func TestFetchRange(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 0, 60)
+	tests := []struct {
+		name    string
+		api     config.APIConfig
+		retries int
+		// answers the attempt-th request of a range of days, false leaves
+		// the answer to hourly
+		handle func(w http.ResponseWriter, r *http.Request, days float64, attempt int) bool
+		// chunk size after the fetch, at least and at most
+		minChunk, maxChunk int
+		minTime            time.Duration
+		// requests expected of a range of days, nil for any
+		attempts func(days float64) int
+	}{
+		{
+			name:    "fast responses grow the chunks",
+			api:     config.APIConfig{ChunkDays: 10, MaxChunkDays: 40},
+			retries: 2,
+			handle: func(w http.ResponseWriter, r *http.Request, days float64, attempt int) bool {
+				return false
+			},
+			minChunk: 15, maxChunk: 40,
+			attempts: func(days float64) int { return 1 },
+		},
+		{
+			name:    "persisting 5xx halves after the retries",
+			api:     config.APIConfig{ChunkDays: 8},
+			retries: 2,
+			handle: func(w http.ResponseWriter, r *http.Request, days float64, attempt int) bool {
+				if days > 2 {
+					http.Error(w, "overloaded", http.StatusInternalServerError)
+					return true
+				}
+				return false
+			},
+			// fast single days grow them again
+			minChunk: 1, maxChunk: 3,
+			attempts: func(days float64) int {
+				if days > 2 {
+					return 3
+				}
+				return 1
+			},
+		},
+		{
+			name:    "transient 503 is retried",
+			api:     config.APIConfig{ChunkDays: 10, MaxChunkDays: 10},
+			retries: 2,
+			handle: func(w http.ResponseWriter, r *http.Request, days float64, attempt int) bool {
+				if attempt == 0 {
+					http.Error(w, "unavailable", http.StatusServiceUnavailable)
+					return true
+				}
+				return false
+			},
+			minChunk: 10, maxChunk: 10,
+			attempts: func(days float64) int { return 2 },
+		},
+		{
+			name:    "429 waits for Retry-After",
+			api:     config.APIConfig{ChunkDays: 30, MaxChunkDays: 30},
+			retries: 1,
+			handle: func(w http.ResponseWriter, r *http.Request, days float64, attempt int) bool {
+				if attempt == 0 {
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, "slow down", http.StatusTooManyRequests)
+					return true
+				}
+				return false
+			},
+			minChunk: 30, maxChunk: 30,
+			// both chunks wait a second
+			minTime:  2 * time.Second,
+			attempts: func(days float64) int { return 2 },
+		},
+		{
+			name:    "timeouts halve at once",
+			api:     config.APIConfig{ChunkDays: 8, MaxChunkDays: 8, TimeoutSeconds: 0.1},
+			retries: 3,
+			handle: func(w http.ResponseWriter, r *http.Request, days float64, attempt int) bool {
+				if days > 2 {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(2 * time.Second):
+					}
+					return true
+				}
+				return false
+			},
+			// fast single days grow them again
+			minChunk: 1, maxChunk: 3,
+			attempts: func(days float64) int { return 1 },
+		},
+		{
+			name:    "truncated responses halve",
+			api:     config.APIConfig{ChunkDays: 20, MaxChunkDays: 20},
+			retries: 0,
+			handle: func(w http.ResponseWriter, r *http.Request, days float64, attempt int) bool {
+				from, to := rangeOf(r.URL.Query())
+				hourly(w, from, to, 5)
+				return true
+			},
+			// full chunks of 5 days grow to 7 before they are cut again
+			minChunk: 1, maxChunk: 7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			requests := make(map[string]int)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				from, to := rangeOf(r.URL.Query())
+				mu.Lock()
+				attempt := requests[r.URL.RawQuery]
+				requests[r.URL.RawQuery]++
+				mu.Unlock()
+				if !tt.handle(w, r, to.Sub(from).Hours()/24, attempt) {
+					hourly(w, from, to, 0)
+				}
+			}))
+			defer server.Close()
+			c := testClient(t, server, tt.retries, tt.api)
+
+			began := time.Now()
+			data, err := c.GetSensorData(context.Background(), "sm", "sensor", from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(began); elapsed < tt.minTime {
+				t.Errorf("fetched in %s, want at least %s", elapsed, tt.minTime)
+			}
+
+			// every hour exactly once, in order
+			if want := int(to.Sub(from).Hours()); len(data) != want {
+				t.Errorf("got %d readings, want %d", len(data), want)
+			}
+			for i := 1; i < len(data); i++ {
+				if !data[i].Date.Equal(data[i-1].Date.Add(time.Hour)) {
+					t.Fatalf("reading %d at %s follows %s", i, data[i].Date, data[i-1].Date)
+				}
+			}
+			if days := c.ChunkDays(); days < tt.minChunk || days > tt.maxChunk {
+				t.Errorf("chunks of %d days, want %d to %d", days, tt.minChunk, tt.maxChunk)
+			}
+			if tt.attempts == nil {
+				return
+			}
+			for rawQuery, n := range requests {
+				query, _ := url.ParseQuery(rawQuery)
+				from, to := rangeOf(query)
+				if want := tt.attempts(to.Sub(from).Hours() / 24); n != want {
+					t.Errorf("%s to %s requested %d times, want %d", from, to, n, want)
+				}
+			}
+		})
+	}
+}
+
+// TestFetchRangeConcurrent fetches several sensors at once from a server
+// that fails on long ranges; the chunk size is shared and must be halved
+// once per failure seen at its size, not once per fetch
+func TestFetchRangeConcurrent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from, to := rangeOf(r.URL.Query())
+		if to.Sub(from) > 4*24*time.Hour {
+			http.Error(w, "overloaded", http.StatusBadGateway)
+			return
+		}
+		hourly(w, from, to, 0)
+	}))
+	defer server.Close()
+	c := testClient(t, server, 0, config.APIConfig{ChunkDays: 32, MaxChunkDays: 32})
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 0, 64)
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	counts := make([]int, len(errs))
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := c.GetSensorData(context.Background(), "sm", fmt.Sprint("sensor", i), from, to)
+			errs[i], counts[i] = err, len(data)
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("sensor %d: %v", i, err)
+		}
+		if want := int(to.Sub(from).Hours()); counts[i] != want {
+			t.Errorf("sensor %d: got %d readings, want %d", i, counts[i], want)
+		}
+	}
+	// 32 days halve to 4, the fast responses may grow them again, but
+	// concurrent halving must not have shrunk them below
+	if days := c.ChunkDays(); days < 4 {
+		t.Errorf("chunks of %d days, want at least 4", days)
+	}
+}
+
+func TestResizeChunks(t *testing.T) {
+	c := &Client{config: &config.Config{API: config.APIConfig{ChunkDays: 30, MaxChunkDays: 60}}}
+	c.chunkDays.Store(30)
+
+	// fetches that all saw 30 days fail halve once
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.resizeChunks(30, 15)
+		}()
+	}
+	wg.Wait()
+	if days := c.ChunkDays(); days != 15 {
+		t.Fatalf("chunks of %d days after concurrent halving, want 15", days)
+	}
+
+	// a stale size changes nothing, growth stops at the limit and
+	// halving at a day
+	c.resizeChunks(30, 45)
+	if days := c.ChunkDays(); days != 15 {
+		t.Errorf("stale resize changed the chunks to %d days", days)
+	}
+	c.resizeChunks(15, 100)
+	if days := c.ChunkDays(); days != 60 {
+		t.Errorf("chunks grew to %d days, want the limit of 60", days)
+	}
+	c.chunkDays.Store(1)
+	c.resizeChunks(1, 0)
+	if days := c.ChunkDays(); days != 1 {
+		t.Errorf("chunks halved to %d days, want 1", days)
+	}
+}
+
+// timeoutError is a network error that timed out
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryable(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		err       error
+		retryable bool
+		timedOut  bool
+	}{
+		{"network error", context.Background(), apiErrorf(0, "making request: %w", errors.New("connection refused")), true, false},
+		{"network timeout", context.Background(), apiErrorf(0, "making request: %w", timeoutError{}), true, true},
+		{"500", context.Background(), apiErrorf(500, "unexpected status code 500"), true, false},
+		{"503", context.Background(), apiErrorf(503, "unexpected status code 503"), true, false},
+		{"504", context.Background(), apiErrorf(504, "unexpected status code 504"), true, true},
+		{"408", context.Background(), apiErrorf(408, "unexpected status code 408"), true, true},
+		{"429", context.Background(), apiErrorf(429, "unexpected status code 429"), true, false},
+		{"401", context.Background(), apiErrorf(401, "unexpected status code 401"), false, false},
+		{"404", context.Background(), apiErrorf(404, "unexpected status code 404"), false, false},
+		{"certificate", context.Background(), apiErrorf(0, "making request: %w", &tls.CertificateVerificationError{Err: errors.New("unknown authority")}), false, false},
+		{"canceled", canceled, apiErrorf(0, "making request: %w", timeoutError{}), false, false},
+		{"decoding", context.Background(), errors.New("decoding response"), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.ctx, tt.err); got != tt.retryable {
+				t.Errorf("retryable = %v, want %v", got, tt.retryable)
+			}
+			if got := timedOut(tt.ctx, tt.err); got != tt.timedOut {
+				t.Errorf("timedOut = %v, want %v", got, tt.timedOut)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := &Client{config: &config.Config{API: config.APIConfig{RetryDelaySeconds: 1}}}
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{0, 0, 500 * time.Millisecond, time.Second},
+		{1, 0, time.Second, 2 * time.Second},
+		{3, 0, 4 * time.Second, 8 * time.Second},
+		{20, 0, maxRetryDelay / 2, maxRetryDelay},
+		{0, 5 * time.Second, 5 * time.Second, 5 * time.Second},
+		{3, 5 * time.Second, 5 * time.Second, 8 * time.Second},
+		{0, time.Hour, maxRetryDelay, maxRetryDelay},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			if delay := c.backoff(tt.attempt, tt.retryAfter); delay < tt.min || delay > tt.max {
+				t.Errorf("backoff(%d, %s) = %s, want %s to %s", tt.attempt, tt.retryAfter, delay, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+// TestFetchRenewsToken checks that an access token revoked before it
+// expires is renewed once and the request repeated
+func TestFetchRenewsToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		accepted string // token the API accepts
+		logins   int
+		requests int
+		status   int // of the error, 0 for success
+	}{
+		{"renewed token accepted", "token-2", 2, 2, 0},
+		{"renewed token rejected", "none", 2, 2, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var logins, requests int
+			tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				logins++
+				n := logins
+				mu.Unlock()
+				json.NewEncoder(w).Encode(map[string]any{
+					"access_token": fmt.Sprint("token-", n), "token_type": "Bearer",
+					"refresh_token": "refresh", "expires_in": 3600,
+				})
+			}))
+			defer tokens.Close()
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				requests++
+				mu.Unlock()
+				if r.Header.Get("Authorization") != "Bearer "+tt.accepted {
+					http.Error(w, "token revoked", http.StatusUnauthorized)
+					return
+				}
+				w.Write([]byte("[]"))
+			}))
+			defer server.Close()
+			c := testClient(t, server, 3, config.APIConfig{
+				Username: "user", Password: "secret",
+				OAuth: config.OAuthConfig{TokenURL: tokens.URL},
+			})
+
+			_, err := c.fetchChunkedData(context.Background(), "/v1/users")
+			var apiErr *Error
+			switch {
+			case tt.status == 0 && err != nil:
+				t.Fatal(err)
+			case tt.status != 0 && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.status):
+				t.Fatalf("got error %v, want status %d", err, tt.status)
+			}
+			if logins != tt.logins || requests != tt.requests {
+				t.Errorf("%d logins and %d requests, want %d and %d", logins, requests, tt.logins, tt.requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+// expiryMargin renews access tokens this long before they expire, so they
+// do not run out while a request is under way
+const expiryMargin = 30 * time.Second
+
+// oauthToken is a token of the OAuth2 token endpoint, also the content of
+// the token file
+type oauthToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry"` // zero if the endpoint did not tell
+}
+
+// valid reports whether the access token can still be used at now
+func (t *oauthToken) valid(now time.Time) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || now.Add(expiryMargin).Before(t.Expiry))
+}
+
+// tokenSource hands out the access token of the OAuth2 login. It logs in
+// with the password grant and renews the token with the refresh token, so
+// username and password are only sent to the token endpoint, and only when
+// there is no usable refresh token. It is safe for concurrent use.
+type tokenSource struct {
+	api    *config.APIConfig
+	http   *http.Client
+	debugf func(format string, args ...interface{})
+
+	mu     sync.Mutex
+	token  *oauthToken
+	loaded bool // the token file was read
+}
+
+// accessToken returns a valid access token, logging in or renewing the
+// token when needed
+func (ts *tokenSource) accessToken(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	oauth := &ts.api.OAuth
+	if !ts.loaded && oauth.TokenFile != "" {
+		token, err := loadToken(oauth.TokenFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring the OAuth token file: %v\n", err)
+		}
+		ts.token = token
+	}
+	ts.loaded = true
+	if ts.token.valid(time.Now()) {
+		return ts.token.AccessToken, nil
+	}
+
+	var (
+		token *oauthToken
+		err   error
+	)
+	if ts.token != nil && ts.token.RefreshToken != "" {
+		ts.debugf("Renewing the OAuth access token")
+		token, err = ts.request(ctx, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {ts.token.RefreshToken}})
+		if token != nil && token.RefreshToken == "" {
+			// the endpoint keeps the refresh token
+			token.RefreshToken = ts.token.RefreshToken
+		}
+	}
+	if token == nil && ts.api.Username != "" && ctx.Err() == nil {
+		if err != nil {
+			ts.debugf("Renewing the OAuth access token failed, logging in again: %v", err)
+		}
+		ts.debugf("Logging in at %s", oauth.TokenURL)
+		token, err = ts.request(ctx, url.Values{"grant_type": {"password"},
+			"username": {ts.api.Username}, "password": {ts.api.Password}})
+	}
+	if token == nil {
+		if err == nil {
+			err = apiErrorf(http.StatusUnauthorized, "oauth: the session in %s expired and there is no username to log in again", oauth.TokenFile)
+		}
+		return "", err
+	}
+
+	ts.token = token
+	if oauth.TokenFile != "" {
+		if err := saveToken(oauth.TokenFile, token); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save the OAuth token: %v\n", err)
+		}
+	}
+	return token.AccessToken, nil
+}
+
+// invalidate drops the access token after the API rejected it, so the next
+// request renews it. A token renewed meanwhile is kept.
+func (ts *tokenSource) invalidate(rejected string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token != nil && ts.token.AccessToken == rejected {
+		ts.token.AccessToken = ""
+	}
+}
+
+// request asks the token endpoint for a token with the grant in form
+func (ts *tokenSource) request(ctx context.Context, form url.Values) (*oauthToken, error) {
+	oauth := &ts.api.OAuth
+	if oauth.ClientID != "" {
+		form.Set("client_id", oauth.ClientID)
+	}
+	if oauth.ClientSecret != "" {
+		form.Set("client_secret", oauth.ClientSecret)
+	}
+	if len(oauth.Scopes) > 0 {
+		form.Set("scope", strings.Join(oauth.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", oauth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("oauth: creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	issued := time.Now()
+	resp, err := ts.http.Do(req)
+	if err != nil {
+		return nil, apiErrorf(0, "oauth: making request: %w", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, apiErrorf(0, "oauth: reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiErrorf(resp.StatusCode, "oauth: unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	var response struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, apiErrorf(resp.StatusCode, "oauth: decoding response: %v", err)
+	}
+	if response.AccessToken == "" || (response.TokenType != "" && !strings.EqualFold(response.TokenType, "bearer")) {
+		return nil, apiErrorf(resp.StatusCode, "oauth: response has no bearer token")
+	}
+
+	token := &oauthToken{AccessToken: response.AccessToken, RefreshToken: response.RefreshToken}
+	if response.ExpiresIn > 0 {
+		token.Expiry = issued.Add(time.Duration(response.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// loadToken reads the token file, nil if it does not exist yet
+func loadToken(path string) (*oauthToken, error) {
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var token oauthToken
+	if err := json.Unmarshal(buf, &token); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &token, nil
+}
+
+// saveToken writes the token file atomically (write to temp, then rename),
+// readable by the owner only
+func saveToken(path string, token *oauthToken) error {
+	buf, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding token: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(buf, '\n'), 0o600); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("writing token: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("renaming token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"zevalizer/internal/config"
+)
+
+// tlsConfig builds the TLS configuration of the API connections, nil for
+// the defaults. Unreadable files are configuration errors.
+func tlsConfig(cfg *config.TLSConfig) (*tls.Config, error) {
+	if *cfg == (config.TLSConfig{}) {
+		return nil, nil
+	}
+	tlsCfg := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: api tls caFile: %v", config.ErrInvalid, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: api tls caFile %s has no PEM certificates", config.ErrInvalid, cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: api tls client certificate: %v", config.ErrInvalid, err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.InsecureSkipVerify {
+		fmt.Fprintf(os.Stderr, "Warning: the certificate of the API server is not verified (insecureSkipVerify)\n")
+	}
+	return tlsCfg, nil
+}
@@ -0,0 +1,290 @@
+// internal/audit/audit.go
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"zevalizer/internal/config"
+	"zevalizer/internal/version"
+)
+
+// Bundle captures everything needed to reproduce a run: the requested data
+// range, the readings the analysis was computed from with a SHA-256 per
+// sensor, the configuration, the software version and the resulting
+// figures. Digest is a SHA-256 over the bundle without Digest and
+// Signature, Signature is the ed25519 signature of the digest. Anyone can
+// recompute a plain digest after editing the bundle, only the signature
+// shows that the holder of the private key wrote it.
+type Bundle struct {
+	CreatedAt  time.Time     `json:"createdAt"`
+	Version    string        `json:"version"`
+	SmID       string        `json:"smId"`
+	From       time.Time     `json:"from"`
+	To         time.Time     `json:"to"`
+	AnalyzedAt time.Time     `json:"analyzedAt"`           // the current time of the analysis, see EnergyAnalyzer.SetNow
+	StreamDays int           `json:"streamDays,omitempty"` // analyzed in pieces of this many days
+	Config     config.Config `json:"config"`
+	Readings   *Readings     `json:"readings"`
+	// SHA-256 of the readings of every sensor, see Readings.Hashes
+	ReadingsSHA256 map[string]string          `json:"readingsSha256"`
+	Results        map[string]json.RawMessage `json:"results"`
+	Digest         string                     `json:"digest"`
+	Signature      string                     `json:"signature"` // base64
+}
+
+// NewBundle starts an audit bundle for a run over [from, to] that read
+// readings. Credentials are removed from the config snapshot.
+func NewBundle(cfg *config.Config, smID string, from, to time.Time, readings *Readings) (*Bundle, error) {
+	hashes, err := readings.Hashes()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := *cfg
+	snapshot.API.Password = ""
+	snapshot.API.Token = ""
+	snapshot.API.APIKey = ""
+	snapshot.API.OAuth.ClientSecret = ""
+	return &Bundle{
+		CreatedAt:      time.Now(),
+		Version:        version.Get().String(),
+		SmID:           smID,
+		From:           from,
+		To:             to,
+		Config:         snapshot,
+		Readings:       readings,
+		ReadingsSHA256: hashes,
+		Results:        make(map[string]json.RawMessage),
+	}, nil
+}
+
+// AddResult stores a named result figure set in the bundle. The result is
+// encoded right away, later changes to it are not recorded.
+func (b *Bundle) AddResult(name string, result any) error {
+	buf, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("encoding result %s: %w", name, err)
+	}
+	b.Results[name] = buf
+	return nil
+}
+
+// Compare checks that result matches the named result of the bundle.
+// Numbers may differ by rounding errors in the last digits, as sums over
+// maps are not added up in a fixed order.
+func (b *Bundle) Compare(name string, result any) error {
+	recorded, ok := b.Results[name]
+	if !ok {
+		return fmt.Errorf("audit bundle has no result %s", name)
+	}
+	buf, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("encoding result %s: %w", name, err)
+	}
+	var want, got any
+	if err := json.Unmarshal(recorded, &want); err != nil {
+		return fmt.Errorf("decoding result %s: %w", name, err)
+	}
+	if err := json.Unmarshal(buf, &got); err != nil {
+		return fmt.Errorf("decoding result %s: %w", name, err)
+	}
+	return compare(name, want, got)
+}
+
+// compare walks two decoded JSON values and reports the first difference
+func compare(path string, want, got any) error {
+	switch want := want.(type) {
+	case map[string]any:
+		got, ok := got.(map[string]any)
+		if !ok || len(got) != len(want) {
+			return fmt.Errorf("%s differs: recorded %v, reproduced %v", path, want, got)
+		}
+		keys := make([]string, 0, len(want))
+		for key := range want {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := compare(path+"."+key, want[key], got[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		got, ok := got.([]any)
+		if !ok || len(got) != len(want) {
+			return fmt.Errorf("%s differs: recorded %d entries, reproduced %v", path, len(want), got)
+		}
+		for i := range want {
+			if err := compare(fmt.Sprintf("%s[%d]", path, i), want[i], got[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case float64:
+		got, ok := got.(float64)
+		if !ok || math.Abs(got-want) > 1e-9*math.Max(1, math.Abs(want)) {
+			return fmt.Errorf("%s differs: recorded %v, reproduced %v", path, want, got)
+		}
+		return nil
+	default:
+		if want != got {
+			return fmt.Errorf("%s differs: recorded %v, reproduced %v", path, want, got)
+		}
+		return nil
+	}
+}
+
+// Write seals and signs the bundle with key and stores it as a JSON file in
+// dir. Returns the path of the written file.
+func (b *Bundle) Write(dir string, key ed25519.PrivateKey) (string, error) {
+	digest, err := b.digest()
+	if err != nil {
+		return "", err
+	}
+	b.Digest = digest
+	b.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(digest)))
+
+	buf, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding audit bundle: %w", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating audit directory: %w", err)
+	}
+	name := fmt.Sprintf("audit-%s-%s.json", b.CreatedAt.Format("20060102-150405"), digest[:8])
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf, 0o644); err != nil {
+		return "", fmt.Errorf("writing audit bundle: %w", err)
+	}
+	return path, nil
+}
+
+// Verify reads a bundle from disk and checks that its digest still matches
+// and that it is signed by the private key belonging to pub
+func Verify(path string, pub ed25519.PublicKey) (*Bundle, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading audit bundle: %w", err)
+	}
+
+	var b Bundle
+	if err := json.Unmarshal(buf, &b); err != nil {
+		return nil, fmt.Errorf("decoding audit bundle: %w", err)
+	}
+
+	digest, err := b.digest()
+	if err != nil {
+		return nil, err
+	}
+	if digest != b.Digest {
+		return nil, fmt.Errorf("audit bundle digest mismatch: got %s, expected %s", digest, b.Digest)
+	}
+	signature, err := base64.StdEncoding.DecodeString(b.Signature)
+	if err != nil || b.Signature == "" {
+		return nil, errors.New("audit bundle is not signed")
+	}
+	if !ed25519.Verify(pub, []byte(digest), signature) {
+		return nil, errors.New("audit bundle signature does not match the public key")
+	}
+	if b.Readings == nil {
+		return nil, errors.New("audit bundle has no readings")
+	}
+	hashes, err := b.Readings.Hashes()
+	if err != nil {
+		return nil, err
+	}
+	for id, sum := range b.ReadingsSHA256 {
+		if hashes[id] != sum {
+			return nil, fmt.Errorf("readings of sensor %s do not match their SHA-256", id)
+		}
+	}
+	if len(hashes) != len(b.ReadingsSHA256) {
+		return nil, errors.New("audit bundle has readings without a SHA-256")
+	}
+	return &b, nil
+}
+
+// LoadSigningKey reads an ed25519 private key from a PEM file in PKCS #8
+// form, as written by "openssl genpkey -algorithm ed25519"
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 private key", path)
+	}
+	return private, nil
+}
+
+// LoadPublicKey reads an ed25519 public key from a PEM file, as written by
+// "openssl pkey -pubout"
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 public key", path)
+	}
+	return public, nil
+}
+
+// readPEM reads the first PEM block of a file
+func readPEM(path string) (*pem.Block, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key: %w", err)
+	}
+	block, _ := pem.Decode(buf)
+	if block == nil {
+		return nil, fmt.Errorf("%s contains no PEM data", path)
+	}
+	return block, nil
+}
+
+// digest computes the SHA-256 over the bundle with empty Digest and
+// Signature fields.
+// The bundle is first brought into a canonical JSON form (generic values with
+// sorted object keys), so a bundle read back from disk hashes identically.
+func (b *Bundle) digest() (string, error) {
+	unsealed := *b
+	unsealed.Digest, unsealed.Signature = "", ""
+	buf, err := json.Marshal(unsealed)
+	if err != nil {
+		return "", fmt.Errorf("encoding audit bundle: %w", err)
+	}
+	var generic any
+	if err := json.Unmarshal(buf, &generic); err != nil {
+		return "", fmt.Errorf("canonicalizing audit bundle: %w", err)
+	}
+	if buf, err = json.Marshal(generic); err != nil {
+		return "", fmt.Errorf("canonicalizing audit bundle: %w", err)
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,138 @@
+package audit_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/audit"
+	"zevalizer/internal/config"
+	"zevalizer/internal/models"
+)
+
+// meters serves counter readings every 15 minutes: consumer a draws 200 Wh
+// and b 150 Wh per interval, the PV covers 100 Wh of it during the day and
+// the grid the rest
+type meters struct{}
+
+func (meters) GetZevData(ctx context.Context, smId string, from, to time.Time) ([]models.ZevData, error) {
+	counters := map[string]*[]models.ZevSensorData{}
+	data := []models.ZevData{{SensorID: "grid"}, {SensorID: "pv"}, {SensorID: "a"}, {SensorID: "b"}}
+	for i := range data {
+		counters[data[i].SensorID] = &data[i].Data
+	}
+	var grid, pv, a, b float64
+	for t := from; !t.After(to); t = t.Add(analyzer.IntervalSeconds * time.Second) {
+		solar := 0.0
+		if hour := t.Hour(); hour >= 8 && hour < 16 {
+			solar = 100
+		}
+		grid, pv, a, b = grid+350-solar, pv+solar, a+200, b+150
+		*counters["grid"] = append(*counters["grid"], models.ZevSensorData{CreatedAt: t, CurrentEnergyPurchaseTariff1: grid})
+		*counters["pv"] = append(*counters["pv"], models.ZevSensorData{CreatedAt: t, CurrentEnergyDeliveryTariff1: pv})
+		*counters["a"] = append(*counters["a"], models.ZevSensorData{CreatedAt: t, CurrentEnergyPurchaseTariff1: a})
+		*counters["b"] = append(*counters["b"], models.ZevSensorData{CreatedAt: t, CurrentEnergyPurchaseTariff1: b})
+	}
+	return data, nil
+}
+
+func (meters) GetSensorData(ctx context.Context, smId string, sensorID string, from, to time.Time) ([]models.SensorData, error) {
+	return []models.SensorData{}, nil
+}
+
+func (meters) GetSensors(ctx context.Context, smID string) ([]models.Sensor, error) {
+	var sensors []models.Sensor
+	for _, id := range []string{"grid", "pv", "a", "b"} {
+		sensors = append(sensors, models.Sensor{ID: id, Tag: models.SensorTag{Name: "Meter " + id}})
+	}
+	return sensors, nil
+}
+
+func TestBundleReproduce(t *testing.T) {
+	cfg := &config.Config{
+		ZEV:       config.ZEVConfig{GridMeterID: "grid", ProductionIDs: []string{"pv"}, ConsumerIDs: []string{"a", "b"}},
+		LowTariff: config.LowTariffConfig{StartHour: 22, EndHour: 6},
+		Prices:    config.PriceConfig{GridHigh: 0.30, GridLow: 0.20, Solar: 0.18},
+	}
+	cfg.API.Password = "secret"
+	from := time.Date(2025, 3, 3, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 0, 2)
+	analyzedAt := time.Date(2025, 4, 1, 12, 0, 0, 0, time.Local)
+
+	recorder := audit.NewRecorder(meters{})
+	ea := analyzer.NewEnergyAnalyzer(recorder, cfg)
+	ea.SetNow(analyzedAt)
+	statsLT, statsHT, err := ea.Analyze(context.Background(), "sm", from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bill, err := ea.ConsumerBills(statsLT, statsHT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := audit.NewBundle(cfg, "sm", from, to, recorder.Readings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle.AnalyzedAt = analyzedAt
+	for name, result := range map[string]any{"lowTariff": statsLT, "highTariff": statsHT, "bill": bill} {
+		if err := bundle.AddResult(name, result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(bundle.ReadingsSHA256) != 4 {
+		t.Errorf("readings hashed for %v, want grid, pv, a and b", bundle.ReadingsSHA256)
+	}
+
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path, err := bundle.Write(dir, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(buf), "secret") {
+		t.Error("the bundle contains the API password")
+	}
+
+	verified, err := audit.Verify(path, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verified.Reproduce(context.Background()); err != nil {
+		t.Errorf("reproducing the bundle: %v", err)
+	}
+
+	// the readings can not be changed without breaking their hash, nor the
+	// results without the reproduction noticing
+	tamper := func(name string, edit func(b *audit.Bundle)) {
+		var b audit.Bundle
+		if err := json.Unmarshal(buf, &b); err != nil {
+			t.Fatal(err)
+		}
+		edit(&b)
+		if err := b.Reproduce(context.Background()); err == nil {
+			t.Errorf("%s: reproduced", name)
+		}
+	}
+	tamper("changed reading", func(b *audit.Bundle) {
+		b.Readings.Fetches[0].Zev[2].Data[40].CurrentEnergyPurchaseTariff1 += 1000
+		if hashes, _ := b.Readings.Hashes(); hashes["a"] == b.ReadingsSHA256["a"] {
+			t.Error("changed reading: hash of a unchanged")
+		}
+	})
+	tamper("changed result", func(b *audit.Bundle) {
+		b.Results["bill"] = []byte(strings.Replace(string(b.Results["bill"]), `"total":`, `"total":1`, 1))
+	})
+}
@@ -0,0 +1,206 @@
+// internal/audit/readings.go
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"zevalizer/internal/models"
+)
+
+// Fetcher is the part of the API client an analysis reads its data from
+type Fetcher interface {
+	GetZevData(ctx context.Context, smId string, from, to time.Time) ([]models.ZevData, error)
+	GetSensorData(ctx context.Context, smId string, sensorID string, from, to time.Time) ([]models.SensorData, error)
+	GetSensors(ctx context.Context, smID string) ([]models.Sensor, error)
+}
+
+// Readings are the sensors and meter readings an analysis was computed
+// from, exactly as they were fetched. They serve them again as a Fetcher,
+// so the analysis can be repeated from the bundle.
+type Readings struct {
+	Sensors []models.Sensor `json:"sensors"`
+	Fetches []Fetch         `json:"fetches"`
+}
+
+// Fetch is the response to one request of the analysis
+type Fetch struct {
+	SensorID string              `json:"sensorId,omitempty"` // empty for the ZEV data
+	From     time.Time           `json:"from"`
+	To       time.Time           `json:"to"`
+	Zev      []models.ZevData    `json:"zev,omitempty"`
+	Data     []models.SensorData `json:"data,omitempty"`
+}
+
+// Recorder passes the requests of an analysis on to a Fetcher and keeps a
+// copy of every response. It is safe for concurrent use.
+type Recorder struct {
+	fetcher Fetcher
+
+	mu       sync.Mutex
+	readings Readings
+}
+
+// NewRecorder records the responses of fetcher
+func NewRecorder(fetcher Fetcher) *Recorder {
+	return &Recorder{fetcher: fetcher, readings: Readings{Sensors: []models.Sensor{}, Fetches: []Fetch{}}}
+}
+
+// Readings returns the responses recorded so far, ordered by their start
+// and sensor rather than by when the concurrent requests completed
+func (r *Recorder) Readings() *Readings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	readings := Readings{Sensors: r.readings.Sensors, Fetches: slices.Clone(r.readings.Fetches)}
+	sort.SliceStable(readings.Fetches, func(i, j int) bool {
+		a, b := readings.Fetches[i], readings.Fetches[j]
+		if !a.From.Equal(b.From) {
+			return a.From.Before(b.From)
+		}
+		return a.SensorID < b.SensorID
+	})
+	return &readings
+}
+
+// GetZevData fetches and records the ZEV data of [from, to)
+func (r *Recorder) GetZevData(ctx context.Context, smId string, from, to time.Time) ([]models.ZevData, error) {
+	data, err := r.fetcher.GetZevData(ctx, smId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	fetch := Fetch{From: from, To: to}
+	if err := deepCopy(&fetch.Zev, data); err != nil {
+		return nil, err
+	}
+	r.add(fetch)
+	return data, nil
+}
+
+// GetSensorData fetches and records the data of a sensor over [from, to)
+func (r *Recorder) GetSensorData(ctx context.Context, smId string, sensorID string, from, to time.Time) ([]models.SensorData, error) {
+	data, err := r.fetcher.GetSensorData(ctx, smId, sensorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	fetch := Fetch{SensorID: sensorID, From: from, To: to}
+	if err := deepCopy(&fetch.Data, data); err != nil {
+		return nil, err
+	}
+	r.add(fetch)
+	return data, nil
+}
+
+// GetSensors fetches and records the sensors
+func (r *Recorder) GetSensors(ctx context.Context, smID string) ([]models.Sensor, error) {
+	sensors, err := r.fetcher.GetSensors(ctx, smID)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := deepCopy(&r.readings.Sensors, sensors); err != nil {
+		return nil, err
+	}
+	return sensors, nil
+}
+
+func (r *Recorder) add(fetch Fetch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.readings.Fetches = append(r.readings.Fetches, fetch)
+}
+
+// deepCopy copies src into dst through JSON, the form the bundle stores
+// them in, so the analysis cannot change the recorded readings
+func deepCopy(dst, src any) error {
+	buf, err := json.Marshal(src)
+	if err != nil {
+		return fmt.Errorf("recording readings: %w", err)
+	}
+	if err := json.Unmarshal(buf, dst); err != nil {
+		return fmt.Errorf("recording readings: %w", err)
+	}
+	return nil
+}
+
+// GetZevData returns the recorded ZEV data of [from, to)
+func (r *Readings) GetZevData(ctx context.Context, smId string, from, to time.Time) ([]models.ZevData, error) {
+	fetch, err := r.find("", from, to)
+	if err != nil {
+		return nil, err
+	}
+	return fetch.Zev, nil
+}
+
+// GetSensorData returns the recorded data of a sensor over [from, to)
+func (r *Readings) GetSensorData(ctx context.Context, smId string, sensorID string, from, to time.Time) ([]models.SensorData, error) {
+	fetch, err := r.find(sensorID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return fetch.Data, nil
+}
+
+// GetSensors returns the recorded sensors
+func (r *Readings) GetSensors(ctx context.Context, smID string) ([]models.Sensor, error) {
+	return r.Sensors, nil
+}
+
+func (r *Readings) find(sensorID string, from, to time.Time) (*Fetch, error) {
+	for i := range r.Fetches {
+		fetch := &r.Fetches[i]
+		if fetch.SensorID == sensorID && fetch.From.Equal(from) && fetch.To.Equal(to) {
+			return fetch, nil
+		}
+	}
+	what := "ZEV data"
+	if sensorID != "" {
+		what = "data of sensor " + sensorID
+	}
+	return nil, fmt.Errorf("audit bundle has no %s from %s to %s", what, from.Format(time.RFC3339), to.Format(time.RFC3339))
+}
+
+// Hashes returns the SHA-256 over the readings of every sensor, in the
+// order they were fetched, keyed by sensor ID. They identify the data of
+// a sensor independent of the cache or the other sensors, e.g. to compare
+// it with the data the API returns later.
+func (r *Readings) Hashes() (map[string]string, error) {
+	hashes := make(map[string]hash.Hash)
+	write := func(id string, v any) error {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("hashing readings: %w", err)
+		}
+		if hashes[id] == nil {
+			hashes[id] = sha256.New()
+		}
+		hashes[id].Write(buf)
+		return nil
+	}
+	for _, fetch := range r.Fetches {
+		if fetch.SensorID != "" {
+			if err := write(fetch.SensorID, fetch.Data); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, zev := range fetch.Zev {
+			if err := write(zev.SensorID, zev.Data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sums := make(map[string]string, len(hashes))
+	for id, h := range hashes {
+		sums[id] = hex.EncodeToString(h.Sum(nil))
+	}
+	return sums, nil
+}
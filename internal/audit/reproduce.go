@@ -0,0 +1,72 @@
+// internal/audit/reproduce.go
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/billing"
+)
+
+// Reproduce repeats the analysis of the bundle from its readings and
+// checks that it arrives at the recorded results. The intervals and tariff
+// times follow the local time zone, which has to be the one of the run.
+func (b *Bundle) Reproduce(ctx context.Context) error {
+	from, to := b.From.In(time.Local), b.To.In(time.Local)
+	for _, t := range []struct{ recorded, local time.Time }{{b.From, from}, {b.To, to}} {
+		_, recorded := t.recorded.Zone()
+		if _, local := t.local.Zone(); local != recorded {
+			return fmt.Errorf("the bundle was written in another time zone (%s), set TZ to the zone of the run",
+				t.recorded.Format("-07:00"))
+		}
+	}
+
+	cfg := b.Config
+	ea := analyzer.NewEnergyAnalyzer(b.Readings, &cfg)
+	ea.SetNow(b.AnalyzedAt)
+	var statsLT, statsHT *analyzer.EnergyStats
+	var err error
+	if b.StreamDays > 0 {
+		statsLT, statsHT, err = ea.AnalyzeStream(ctx, b.SmID, from, to, b.StreamDays)
+	} else {
+		statsLT, statsHT, err = ea.Analyze(ctx, b.SmID, from, to)
+	}
+	if err != nil {
+		return fmt.Errorf("analyzing the recorded readings: %w", err)
+	}
+	if err := b.Compare("lowTariff", statsLT); err != nil {
+		return err
+	}
+	if err := b.Compare("highTariff", statsHT); err != nil {
+		return err
+	}
+
+	recorded, ok := b.Results["bill"]
+	if !ok {
+		return nil
+	}
+	var shown billing.Bill
+	if err := json.Unmarshal(recorded, &shown); err != nil {
+		return fmt.Errorf("decoding result bill: %w", err)
+	}
+	bill, err := ea.ConsumerBills(statsLT, statsHT)
+	if err != nil {
+		return fmt.Errorf("billing consumers: %w", err)
+	}
+	// the bundle holds the bills that were shown, with their invoice
+	// numbers; the shared usage has no ID and goes by name
+	type key struct{ id, name string }
+	booked := make(map[key]billing.Consumer, len(shown.Consumers))
+	for _, consumer := range shown.Consumers {
+		booked[key{consumer.ID, consumer.Name}] = consumer
+	}
+	bill.Keep(func(consumer *billing.Consumer) bool {
+		recorded, ok := booked[key{consumer.ID, consumer.Name}]
+		consumer.Invoice, consumer.Corrects = recorded.Invoice, recorded.Corrects
+		return ok
+	})
+	return b.Compare("bill", bill)
+}
@@ -0,0 +1,492 @@
+// internal/billing/billing.go
+package billing
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+// Items of a consumer bill
+const (
+	ItemSolar        = "solar"
+	ItemBattery      = "battery"
+	ItemGridHigh     = "grid-high"
+	ItemGridLow      = "grid-low"
+	ItemSurcharge    = "surcharge"
+	ItemBaseFee      = "base-fee"
+	ItemAmortization = "amortization"
+	ItemCommonCost   = "common-cost"
+	ItemCommonArea   = "common-area" // share of the bill of a common area
+	ItemPassedOn     = "passed-on"   // the common area bill passed on to the others
+	ItemGuidelineCap = "guideline-cap"
+)
+
+// Items lists the items in the order they appear on a bill
+var Items = []string{ItemSolar, ItemBattery, ItemGridHigh, ItemGridLow, ItemGuidelineCap,
+	ItemSurcharge, ItemBaseFee, ItemAmortization, ItemCommonCost, ItemCommonArea, ItemPassedOn}
+
+// Sources is the energy a consumer drew in one tariff, in kWh
+type Sources struct {
+	Solar float64
+	// Battery energy split by the origin of the stored energy
+	BatterySolar float64
+	BatteryGrid  float64
+	Grid         float64
+}
+
+// total returns the energy drawn from all sources
+func (s Sources) total() float64 {
+	return s.Solar + s.BatterySolar + s.BatteryGrid + s.Grid
+}
+
+// Usage is the energy of one consumer in both tariffs, over the period of
+// the bill or, for a unit with tenants, over [From, To). An empty ID marks
+// a synthetic consumer such as the shared usage.
+type Usage struct {
+	ID   string
+	Name string
+	// ID the common areas refer to a synthetic consumer by
+	AreaID string
+	Group  string // configured group of the consumer, if any
+	Tenant string // tenant of the unit over [From, To), if any
+	From   time.Time
+	To     time.Time
+	Low    Sources
+	High   Sources
+	// The usage split at the price changes within its time, if any; Low
+	// and High remain the sums
+	Parts []Part
+}
+
+// Part is the usage of a consumer from From until the next part, under
+// the prices in force at From
+type Part struct {
+	From time.Time
+	Low  Sources
+	High Sources
+}
+
+// Line is one item of a consumer bill
+type Line struct {
+	Item string `json:"item"`
+	// Name of a surcharge, base fee or shared cost, or consumer of a common
+	// area, empty for the shared usage
+	ID     string  `json:"id,omitempty"`
+	Name   string  `json:"name,omitempty"`
+	Energy float64 `json:"kwh,omitempty"`
+	// Start of the prices of an energy item, when they change within the bill
+	From   *time.Time `json:"from,omitempty"`
+	Months float64    `json:"months,omitempty"`       // of a base fee
+	Share  float64    `json:"sharePercent,omitempty"` // of a shared cost or common area
+	// Per kWh, the average for battery energy, per month for a base fee, or
+	// the shared cost of the whole period or net amount of the common area
+	Price  float64 `json:"price"`
+	Amount float64 `json:"amount"` // rounded to cents
+}
+
+// Consumer is the bill of one consumer, or of one tenant of its unit, for
+// [From, To)
+type Consumer struct {
+	ID     string    `json:"id,omitempty"`
+	Name   string    `json:"name"`
+	Tenant string    `json:"tenant,omitempty"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Lines  []Line    `json:"lines"`
+	Energy float64   `json:"kwh"`
+	Net    float64   `json:"net"` // sum of the items
+	VAT    float64   `json:"vat,omitempty"`
+	// Difference of the total to net and VAT from rounding to the step of
+	// the totals
+	Rounding float64 `json:"rounding,omitempty"`
+	Total    float64 `json:"total"`
+	// Number of the invoice in the ledger and of the invoice it corrects,
+	// when booked
+	Invoice  string `json:"invoice,omitempty"`
+	Corrects string `json:"corrects,omitempty"`
+
+	kwhStep float64 // step the billed energy is rounded to, if any
+}
+
+// Bill holds the bills of all consumers and their sum
+type Bill struct {
+	From       time.Time  `json:"from"`
+	To         time.Time  `json:"to"`
+	Currency   string     `json:"currency"`
+	Guideline  string     `json:"guideline,omitempty"`
+	VATPercent float64    `json:"vatPercent,omitempty"`
+	Consumers  []Consumer `json:"consumers"`
+	Energy     float64    `json:"kwh"`
+	Net        float64    `json:"net"`
+	VAT        float64    `json:"vat,omitempty"`
+	Rounding   float64    `json:"rounding,omitempty"`
+	Total      float64    `json:"total"`
+}
+
+// Calculate prices the usage of every consumer. Solar energy costs the
+// solar tariff and grid energy the grid tariff it was drawn in. Battery
+// energy costs what the stored energy cost, the solar tariff or the grid
+// tariff it was charged in, plus the battery surcharge. Every surcharge
+// adds an item over all energy, or over the grid energy including battery
+// energy charged from the grid. Items without energy are left out. Base
+// fees are charged for the months of the period [from, to), or of the
+// usage's own period, see Months; synthetic consumers pay none. The yearly
+// amortization and common costs for the months of the period are shared
+// among the consumers by their allocation keys, see shares. Finally the
+// net amount of every common area is passed on to the consumers by its
+// key, and the common area's bill is balanced by an item of its own.
+//
+// When the prices change within the time of a usage, it comes in parts
+// and each part is priced with the prices in force at its start, in
+// energy items of its own.
+//
+// Under the VSE guideline the solar and battery energy of a consumer must
+// not cost more than the cap of what the same energy would have cost at
+// the grid tariffs; an item reduces the bill by any excess.
+//
+// Like on an invoice every item is rounded to cents and the VAT is taken
+// on the sum of the rounded items. The energy of the items is rounded to
+// the configured step before it is priced. Shared costs and common areas
+// are apportioned in cents so the parts add up to the whole. Finally the
+// total of the period is rounded to the step of the totals and apportioned
+// onto the bills, so the rounded bills add up to it exactly.
+func Calculate(from, to time.Time, usage []Usage, prices config.PriceConfig) *Bill {
+	bill := &Bill{From: from, To: to, Currency: prices.CurrencyLabel(), Guideline: prices.Guideline,
+		VATPercent: prices.VATPercent, Consumers: []Consumer{}}
+	months := Months(from, to)
+	// index of the common area of every usage, -1 for the others
+	common := make([]int, len(usage))
+	payers := make([]bool, len(usage))
+	for i, u := range usage {
+		common[i] = slices.IndexFunc(prices.CommonAreas, func(area config.CommonAreaConfig) bool {
+			return area.Consumer == cmp.Or(u.ID, u.AreaID)
+		})
+		payers[i] = u.ID != "" && common[i] < 0
+	}
+
+	consumers := make([]Consumer, len(usage))
+	for i, u := range usage {
+		consumer := Consumer{ID: u.ID, Name: u.Name, Tenant: u.Tenant, From: from, To: to,
+			Lines: []Line{}, kwhStep: prices.Rounding.KWh}
+		consumer.Energy = consumer.quantity(u.Low.total() + u.High.total())
+		if !u.From.IsZero() {
+			consumer.From, consumer.To = u.From, u.To
+		}
+		if u.Parts == nil {
+			consumer.addEnergy(Part{From: consumer.From, Low: u.Low, High: u.High}, prices.At(consumer.From), nil)
+		}
+		for _, part := range u.Parts {
+			consumer.addEnergy(part, prices.At(part.From), &part.From)
+		}
+		grid := u.Low.Grid + u.Low.BatteryGrid + u.High.Grid + u.High.BatteryGrid
+		for _, surcharge := range prices.Surcharges {
+			energy := consumer.Energy
+			if surcharge.GridOnly {
+				energy = grid
+			}
+			consumer.add(Line{Item: ItemSurcharge, Name: surcharge.Name}, energy, surcharge.PerKWh)
+		}
+		for _, fee := range prices.BaseFees {
+			if u.ID != "" && charges(fee, u) {
+				consumer.addFee(Line{Item: ItemBaseFee, Name: fee.Name}, Months(consumer.From, consumer.To), fee.PerMonth)
+			}
+		}
+		consumers[i] = consumer
+	}
+
+	for _, costs := range []struct {
+		item    string
+		entries []config.SharedCostConfig
+	}{{ItemAmortization, prices.Amortization}, {ItemCommonCost, prices.CommonCosts}} {
+		for _, cost := range costs.entries {
+			amount := cost.PerYear * months / 12
+			parts := shares(from, to, usage, payers, prices.Allocation(cost.Key, cost.Weights))
+			amounts := apportion(amount, parts, 0.01)
+			for i := range consumers {
+				consumers[i].addShare(Line{Item: costs.item, Name: cost.Name}, amount, parts[i], amounts[i])
+			}
+		}
+	}
+
+	for i := range consumers {
+		if common[i] < 0 || consumers[i].Net == 0 {
+			continue
+		}
+		area := prices.CommonAreas[common[i]]
+		net := consumers[i].Net
+		parts := shares(from, to, usage, payers, prices.Allocation(area.Key, area.Weights))
+		amounts := apportion(net, parts, 0.01)
+		var passed bool
+		for j := range consumers {
+			if parts[j] > 0 {
+				consumers[j].addShare(Line{Item: ItemCommonArea, ID: usage[i].ID, Name: usage[i].Name}, net, parts[j], amounts[j])
+				passed = true
+			}
+		}
+		if passed {
+			consumers[i].Lines = append(consumers[i].Lines, Line{Item: ItemPassedOn, Amount: -net})
+			consumers[i].Net = 0
+		}
+	}
+
+	exact := make([]float64, len(consumers))
+	var sum float64
+	for i := range consumers {
+		consumer := &consumers[i]
+		consumer.VAT = round(consumer.Net * prices.VATPercent / 100)
+		exact[i] = round(consumer.Net + consumer.VAT)
+		sum = round(sum + exact[i])
+	}
+	totals := apportion(sum, exact, prices.Rounding.TotalStep())
+	for i, consumer := range consumers {
+		consumer.Total = totals[i]
+		consumer.Rounding = round(consumer.Total - exact[i])
+		bill.add(consumer)
+	}
+	return bill
+}
+
+// add appends the bill of consumer and adds it to the sums
+func (b *Bill) add(consumer Consumer) {
+	b.Energy += consumer.Energy
+	b.Net = round(b.Net + consumer.Net)
+	b.VAT = round(b.VAT + consumer.VAT)
+	b.Rounding = round(b.Rounding + consumer.Rounding)
+	b.Total = round(b.Total + consumer.Total)
+	b.Consumers = append(b.Consumers, consumer)
+}
+
+// Keep drops the bills of the consumers keep rejects and sums up the
+// others. The kept bills are unchanged, so shared costs stay split among
+// all consumers of the period.
+func (b *Bill) Keep(keep func(consumer *Consumer) bool) {
+	consumers := b.Consumers
+	b.Consumers = []Consumer{}
+	b.Energy, b.Net, b.VAT, b.Rounding, b.Total = 0, 0, 0, 0, 0
+	for i := range consumers {
+		if keep(&consumers[i]) {
+			b.add(consumers[i])
+		}
+	}
+}
+
+// addEnergy appends the energy items of part, priced with the prices in
+// force over it, dated from when given
+func (c *Consumer) addEnergy(part Part, prices config.PriceConfig, from *time.Time) {
+	low, high := part.Low, part.High
+	solar := low.Solar + high.Solar
+	c.add(Line{Item: ItemSolar, From: from}, solar, prices.Solar)
+	battery := low.BatterySolar + low.BatteryGrid + high.BatterySolar + high.BatteryGrid
+	var batteryPrice float64
+	if battery > 0 {
+		cost := (low.BatterySolar+high.BatterySolar)*prices.Solar +
+			low.BatteryGrid*prices.GridLowPrice() + high.BatteryGrid*prices.GridHigh
+		batteryPrice = cost/battery + prices.BatterySurcharge
+		c.add(Line{Item: ItemBattery, From: from}, battery, batteryPrice)
+	}
+	if prices.Guideline == config.GuidelineVSE {
+		internal := solar + battery
+		limit := (low.Solar+low.BatterySolar+low.BatteryGrid)*prices.GridLowPrice() +
+			(high.Solar+high.BatterySolar+high.BatteryGrid)*prices.GridHigh
+		if excess := solar*prices.Solar + battery*batteryPrice - limit*prices.SolarCap()/100; excess > 0 {
+			c.add(Line{Item: ItemGuidelineCap, From: from}, internal, -excess/internal)
+		}
+	}
+	c.add(Line{Item: ItemGridHigh, From: from}, high.Grid, prices.GridHigh)
+	c.add(Line{Item: ItemGridLow, From: from}, low.Grid, prices.GridLowPrice())
+}
+
+// quantity rounds energy to the billed step
+func (c *Consumer) quantity(energy float64) float64 {
+	if c.kwhStep <= 0 {
+		return energy
+	}
+	// dividing by the inverse avoids residue like 3.3000000000000003
+	return math.Round(energy/c.kwhStep) / (1 / c.kwhStep)
+}
+
+// add completes line with energy kWh at price and appends it, unless it is
+// empty
+func (c *Consumer) add(line Line, energy, price float64) {
+	energy = c.quantity(energy)
+	if energy <= 0 {
+		return
+	}
+	line.Energy = energy
+	line.Price = price
+	line.Amount = round(energy * price)
+	c.Lines = append(c.Lines, line)
+	c.Net = round(c.Net + line.Amount)
+}
+
+// addFee appends a base fee line for months at price per month
+func (c *Consumer) addFee(line Line, months, price float64) {
+	if months <= 0 {
+		return
+	}
+	line.Months = months
+	line.Price = price
+	line.Amount = round(months * price)
+	c.Lines = append(c.Lines, line)
+	c.Net = round(c.Net + line.Amount)
+}
+
+// addShare appends a line for share percent of amount, apportioned to
+// part, unless the share is empty
+func (c *Consumer) addShare(line Line, amount, share, part float64) {
+	if share <= 0 || amount <= 0 {
+		return
+	}
+	line.Share = share
+	line.Price = amount
+	line.Amount = part
+	c.Lines = append(c.Lines, line)
+	c.Net = round(c.Net + line.Amount)
+}
+
+// Violations lists the prices that can exceed the cap of the VSE guideline
+// on the internal energy, before and after every price change, without
+// the guideline none
+func Violations(prices config.PriceConfig) []string {
+	if prices.Guideline != config.GuidelineVSE {
+		return nil
+	}
+	found := capViolations(prices, "")
+	for _, change := range prices.Changes {
+		found = append(found, capViolations(prices.At(change.Start(time.Local)), "from "+change.From+" ")...)
+	}
+	return found
+}
+
+// capViolations lists the prices that can exceed the cap, each message
+// starting with prefix
+func capViolations(prices config.PriceConfig, prefix string) []string {
+	var violations []string
+	for _, tariff := range []struct {
+		name  string
+		price float64
+	}{{"high", prices.GridHigh}, {"low", prices.GridLowPrice()}} {
+		limit := tariff.price * prices.SolarCap() / 100
+		if prices.Solar > limit {
+			violations = append(violations, fmt.Sprintf(prefix+"solarTariff %.4f exceeds %g%% of the %s tariff grid price %.4f",
+				prices.Solar, prices.SolarCap(), tariff.name, tariff.price))
+		} else if prices.Solar+prices.BatterySurcharge > limit {
+			violations = append(violations, fmt.Sprintf(prefix+"solarTariff with batterySurcharge %.4f exceeds %g%% of the %s tariff grid price %.4f",
+				prices.Solar+prices.BatterySurcharge, prices.SolarCap(), tariff.name, tariff.price))
+		}
+		if prices.GridLowPrice() == prices.GridHigh {
+			break
+		}
+	}
+	return violations
+}
+
+// shares returns the share of every usage in percent by the allocation
+// key, among the usages that pay. With the consumption key the shares
+// follow the energy of the usage. With the other keys a part of a unit
+// with tenants gets the weight of the unit in proportion to the length of
+// the part.
+func shares(from, to time.Time, usage []Usage, payers []bool, alloc config.SharedAllocationConfig) []float64 {
+	months := Months(from, to)
+	parts := make([]float64, len(usage))
+	var sum float64
+	for i, u := range usage {
+		if !payers[i] {
+			continue
+		}
+		var weight float64
+		switch alloc.Key {
+		case config.AllocationConsumption:
+			weight = u.Low.total() + u.High.total()
+		case config.AllocationEqual:
+			weight = 1
+		default:
+			weight = alloc.Weights[u.ID]
+		}
+		if !u.From.IsZero() && alloc.Key != config.AllocationConsumption && months > 0 {
+			weight *= Months(u.From, u.To) / months
+		}
+		parts[i] = weight
+		sum += weight
+	}
+	for i := range parts {
+		if sum > 0 {
+			parts[i] = parts[i] / sum * 100
+		}
+	}
+	return parts
+}
+
+// charges reports whether the base fee applies to the consumer
+func charges(fee config.BaseFeeConfig, u Usage) bool {
+	if len(fee.Consumers) == 0 && len(fee.Groups) == 0 {
+		return true
+	}
+	return slices.Contains(fee.Consumers, u.ID) || (u.Group != "" && slices.Contains(fee.Groups, u.Group))
+}
+
+// Months returns the length of [from, to) in months, counting the days of
+// a month only partly in the range in proportion to its length. Ranges
+// ending on the last millisecond of a day include that day.
+func Months(from, to time.Time) float64 {
+	var months float64
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for day.Before(to) {
+		next := time.Date(day.Year(), day.Month()+1, 1, 0, 0, 0, 0, day.Location())
+		var days int
+		for ; day.Before(to) && day.Before(next); day = day.AddDate(0, 0, 1) {
+			days++
+		}
+		months += float64(days) / float64(next.AddDate(0, 0, -1).Day())
+	}
+	return months
+}
+
+// apportion splits amount rounded to step into parts by weight, in whole
+// steps: every part gets the steps of its exact part rounded down and the
+// steps left go to the parts with the largest remainders, earlier parts
+// first among equals
+func apportion(amount float64, weights []float64, step float64) []float64 {
+	parts := make([]float64, len(weights))
+	var sum float64
+	for _, weight := range weights {
+		sum += weight
+	}
+	if sum == 0 {
+		return parts
+	}
+	steps := math.Round(amount / step)
+	remainders := make([]float64, len(weights))
+	left := steps
+	for i, weight := range weights {
+		// round away the floating point residue before cutting off
+		exact := math.Round(steps*weight/sum*1e6) / 1e6
+		parts[i] = math.Floor(exact)
+		remainders[i] = exact - parts[i]
+		left -= parts[i]
+	}
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for _, i := range order[:min(int(left), len(order))] {
+		parts[i]++
+	}
+	for i := range parts {
+		parts[i] = round(parts[i] * step)
+	}
+	return parts
+}
+
+// round rounds an amount to cents, also to keep sums of cents free of
+// floating point residue
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
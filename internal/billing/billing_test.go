@@ -0,0 +1,315 @@
+package billing
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+func TestApportion(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  float64
+		weights []float64
+		step    float64
+		want    []float64
+	}{
+		{"even split", 9, []float64{1, 1, 1}, 0.01, []float64{3, 3, 3}},
+		{"tie goes to the earlier parts", 10, []float64{1, 1, 1}, 0.01, []float64{3.34, 3.33, 3.33}},
+		{"tie in steps of 0.05", 1, []float64{1, 1, 1, 1, 1, 1, 1}, 0.05, []float64{0.15, 0.15, 0.15, 0.15, 0.15, 0.15, 0.10}},
+		{"largest remainder first", 0.1, []float64{1, 2}, 0.01, []float64{0.03, 0.07}},
+		{"largest remainder before earlier part", 0.1, []float64{2, 1, 4}, 0.01, []float64{0.03, 0.01, 0.06}},
+		{"amount rounded to the step", 10.03, []float64{1, 1}, 0.05, []float64{5.05, 5}},
+		{"zero weight gets nothing", 1, []float64{0, 1, 1}, 0.01, []float64{0, 0.5, 0.5}},
+		{"all weights zero", 1, []float64{0, 0, 0}, 0.01, []float64{0, 0, 0}},
+		{"no weights", 1, nil, 0.01, []float64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := apportion(tt.amount, tt.weights, tt.step)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("apportion(%g, %v, %g) = %v, want %v", tt.amount, tt.weights, tt.step, got, tt.want)
+			}
+			var sum, weights float64
+			for i, part := range got {
+				sum = round(sum + part)
+				weights += tt.weights[i]
+			}
+			if total := round(math.Round(tt.amount/tt.step) * tt.step); weights > 0 && sum != total {
+				t.Errorf("parts add up to %g, want %g", sum, total)
+			}
+		})
+	}
+}
+
+func TestCalculateTotalRounding(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	usage := []Usage{
+		{ID: "a", Name: "A", High: Sources{Solar: 10.3, Grid: 20.7}},
+		{ID: "b", Name: "B", High: Sources{Solar: 3.1, Grid: 4.9}},
+		{ID: "c", Name: "C", Low: Sources{Grid: 1.8}, High: Sources{Solar: 0.6}},
+	}
+	tests := []struct {
+		name   string
+		total  float64 // rounding step of the totals, 0 for the default
+		want   float64 // bill total
+		totals []float64
+	}{
+		// net and VAT of the consumers add up to 8.94, 2.26 and 0.52
+		{"cents", 0, 11.72, []float64{8.94, 2.26, 0.52}},
+		{"five cents", 0.05, 11.70, []float64{8.95, 2.25, 0.50}},
+		{"francs", 1, 12, []float64{9, 2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prices := config.PriceConfig{GridHigh: 0.30, GridLow: 0.20, Solar: 0.20, VATPercent: 8.1,
+				Rounding: config.RoundingConfig{Total: tt.total}}
+			bill := Calculate(from, to, usage, prices)
+			if bill.Total != tt.want {
+				t.Errorf("bill total = %g, want %g", bill.Total, tt.want)
+			}
+			step := prices.Rounding.TotalStep()
+			var totals, roundings float64
+			for i, consumer := range bill.Consumers {
+				if consumer.Total != tt.totals[i] {
+					t.Errorf("%s: total = %g, want %g", consumer.ID, consumer.Total, tt.totals[i])
+				}
+				if steps := consumer.Total / step; math.Abs(steps-math.Round(steps)) > 1e-9 {
+					t.Errorf("%s: total %g is not a multiple of %g", consumer.ID, consumer.Total, step)
+				}
+				if got := round(consumer.Net + consumer.VAT + consumer.Rounding); got != consumer.Total {
+					t.Errorf("%s: net %g + vat %g + rounding %g = %g, want total %g",
+						consumer.ID, consumer.Net, consumer.VAT, consumer.Rounding, got, consumer.Total)
+				}
+				if math.Abs(consumer.Rounding) >= step {
+					t.Errorf("%s: rounding %g is not below the step %g", consumer.ID, consumer.Rounding, step)
+				}
+				totals = round(totals + consumer.Total)
+				roundings = round(roundings + consumer.Rounding)
+			}
+			if totals != bill.Total {
+				t.Errorf("consumer totals add up to %g, want the bill total %g", totals, bill.Total)
+			}
+			if roundings != bill.Rounding {
+				t.Errorf("consumer roundings add up to %g, want the bill rounding %g", roundings, bill.Rounding)
+			}
+		})
+	}
+}
+
+func TestQuantity(t *testing.T) {
+	tests := []struct {
+		name   string
+		step   float64
+		energy float64
+		want   float64
+	}{
+		{"unrounded", 0, 3.33333, 3.33333},
+		{"tenth down", 0.1, 3.34, 3.3},
+		{"tenth up", 0.1, 3.36, 3.4},
+		{"tenth without residue", 0.1, 3.3, 3.3},
+		{"whole kWh down", 1, 2.4, 2},
+		{"whole kWh half up", 1, 2.5, 3},
+		{"quarter kWh", 0.25, 1.13, 1.25},
+		{"below half a step", 1, 0.4, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumer := Consumer{kwhStep: tt.step}
+			if got := consumer.quantity(tt.energy); got != tt.want {
+				t.Errorf("quantity(%g) with step %g = %v, want %v", tt.energy, tt.step, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateKWhStep(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	usage := []Usage{{ID: "a", Name: "A", Low: Sources{Grid: 0.4}, High: Sources{Solar: 10.26, Grid: 5.64}}}
+	prices := config.PriceConfig{GridHigh: 0.30, GridLow: 0.20, Solar: 0.20, Rounding: config.RoundingConfig{KWh: 0.1}}
+	consumer := Calculate(from, from.AddDate(0, 1, 0), usage, prices).Consumers[0]
+
+	if consumer.Energy != 16.3 {
+		t.Errorf("energy = %v, want 16.3", consumer.Energy)
+	}
+	want := []Line{
+		{Item: ItemSolar, Energy: 10.3, Price: 0.20, Amount: 2.06},
+		{Item: ItemGridHigh, Energy: 5.6, Price: 0.30, Amount: 1.68},
+		{Item: ItemGridLow, Energy: 0.4, Price: 0.20, Amount: 0.08},
+	}
+	if len(consumer.Lines) != len(want) {
+		t.Fatalf("lines = %+v, want %+v", consumer.Lines, want)
+	}
+	for i, line := range consumer.Lines {
+		if line.Item != want[i].Item || line.Energy != want[i].Energy || line.Price != want[i].Price || line.Amount != want[i].Amount {
+			t.Errorf("line %d = %+v, want %+v", i, line, want[i])
+		}
+	}
+}
+
+func TestCalculatePriceChange(t *testing.T) {
+	jan := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	mid := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	gridHigh, solar := 0.35, 0.22
+	prices := config.PriceConfig{GridHigh: 0.30, Solar: 0.20,
+		BaseFees: []config.BaseFeeConfig{{Name: "Metering", PerMonth: 5}},
+		Changes:  []config.PriceChangeConfig{{From: "2025-02-01", GridHigh: &gridHigh, Solar: &solar}}}
+
+	tests := []struct {
+		name   string
+		usage  Usage
+		from   time.Time // of the consumer bill
+		months float64   // of the base fee
+		want   []Line
+	}{
+		{
+			name: "without parts the lines are not dated",
+			usage: Usage{ID: "a", Name: "A",
+				High: Sources{Solar: 15, Grid: 30}},
+			from:   jan,
+			months: 2,
+			want: []Line{
+				{Item: ItemSolar, Energy: 15, Price: 0.20, Amount: 3},
+				{Item: ItemGridHigh, Energy: 30, Price: 0.30, Amount: 9},
+			},
+		},
+		{
+			name: "change inside the bill",
+			usage: Usage{ID: "a", Name: "A",
+				High: Sources{Solar: 15, Grid: 30},
+				Parts: []Part{
+					{From: jan, High: Sources{Solar: 10, Grid: 20}},
+					{From: feb, High: Sources{Solar: 5, Grid: 10}},
+				}},
+			from:   jan,
+			months: 2,
+			want: []Line{
+				{Item: ItemSolar, From: &jan, Energy: 10, Price: 0.20, Amount: 2},
+				{Item: ItemGridHigh, From: &jan, Energy: 20, Price: 0.30, Amount: 6},
+				{Item: ItemSolar, From: &feb, Energy: 5, Price: 0.22, Amount: 1.10},
+				{Item: ItemGridHigh, From: &feb, Energy: 10, Price: 0.35, Amount: 3.50},
+			},
+		},
+		{
+			name: "change inside a tenant part",
+			usage: Usage{ID: "a", Name: "A", Tenant: "Meier", From: mid, To: mar,
+				High: Sources{Solar: 9, Grid: 14},
+				Parts: []Part{
+					{From: mid, High: Sources{Solar: 4, Grid: 6}},
+					{From: feb, High: Sources{Solar: 5, Grid: 8}},
+				}},
+			from:   mid,
+			months: 17.0/31 + 1,
+			want: []Line{
+				{Item: ItemSolar, From: &mid, Energy: 4, Price: 0.20, Amount: 0.80},
+				{Item: ItemGridHigh, From: &mid, Energy: 6, Price: 0.30, Amount: 1.80},
+				{Item: ItemSolar, From: &feb, Energy: 5, Price: 0.22, Amount: 1.10},
+				{Item: ItemGridHigh, From: &feb, Energy: 8, Price: 0.35, Amount: 2.80},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumer := Calculate(jan, mar, []Usage{tt.usage}, prices).Consumers[0]
+			if !consumer.From.Equal(tt.from) || !consumer.To.Equal(mar) {
+				t.Errorf("bill covers %s to %s, want %s to %s", consumer.From, consumer.To, tt.from, mar)
+			}
+			fee := consumer.Lines[len(consumer.Lines)-1]
+			if fee.Item != ItemBaseFee || math.Abs(fee.Months-tt.months) > 1e-9 || fee.Amount != round(tt.months*5) {
+				t.Errorf("base fee = %+v, want %g months", fee, tt.months)
+			}
+			lines := consumer.Lines[:len(consumer.Lines)-1]
+			if len(lines) != len(tt.want) {
+				t.Fatalf("energy lines = %+v, want %+v", lines, tt.want)
+			}
+			for i, line := range lines {
+				want := tt.want[i]
+				dated := line.From != nil && want.From != nil && line.From.Equal(*want.From) || line.From == nil && want.From == nil
+				if line.Item != want.Item || !dated || line.Energy != want.Energy || line.Price != want.Price || line.Amount != want.Amount {
+					t.Errorf("line %d = %+v from %v, want %+v from %v", i, line, line.From, want, want.From)
+				}
+			}
+		})
+	}
+}
+
+func TestViolations(t *testing.T) {
+	solar, gridLow := 0.32, 0.26
+	surcharge := 0.10
+	tests := []struct {
+		name    string
+		prices  config.PriceConfig
+		changes []config.PriceChangeConfig
+		want    []string
+	}{
+		{
+			name:   "without the guideline",
+			prices: config.PriceConfig{GridHigh: 0.30, GridLow: 0.20, Solar: 0.25},
+		},
+		{
+			name:   "within the cap",
+			prices: config.PriceConfig{GridHigh: 0.30, GridLow: 0.20, Solar: 0.18, Guideline: config.GuidelineVSE},
+		},
+		{
+			name: "every change is checked with the prices in force",
+			prices: config.PriceConfig{GridHigh: 0.30, GridLow: 0.20, Solar: 0.25, Guideline: config.GuidelineVSE,
+				Changes: []config.PriceChangeConfig{
+					{From: "2025-02-01", GridLow: &gridLow},
+					{From: "2025-03-01", Solar: &solar},
+				}},
+			want: []string{
+				"solarTariff 0.2500 exceeds 100% of the low tariff grid price 0.2000",
+				"from 2025-03-01 solarTariff 0.3200 exceeds 100% of the high tariff grid price 0.3000",
+				"from 2025-03-01 solarTariff 0.3200 exceeds 100% of the low tariff grid price 0.2600",
+			},
+		},
+		{
+			name: "battery surcharge from a change on",
+			prices: config.PriceConfig{GridHigh: 0.30, Solar: 0.25, Guideline: config.GuidelineVSE,
+				Changes: []config.PriceChangeConfig{{From: "2025-07-01", BatterySurcharge: &surcharge}}},
+			want: []string{
+				"from 2025-07-01 solarTariff with batterySurcharge 0.3500 exceeds 100% of the high tariff grid price 0.3000",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Violations(tt.prices); !slices.Equal(got, tt.want) {
+				t.Errorf("Violations() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonths(t *testing.T) {
+	day := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     float64
+	}{
+		{"whole month", day(2025, 1, 1), day(2025, 2, 1), 1},
+		{"across the year end", day(2024, 12, 1), day(2025, 2, 1), 2},
+		{"second half of january", day(2025, 1, 15), day(2025, 2, 1), 17.0 / 31},
+		{"part of a leap february", day(2024, 2, 10), day(2024, 3, 1), 20.0 / 29},
+		{"partial months at both ends", day(2025, 1, 15), day(2025, 3, 10), 17.0/31 + 1 + 9.0/31},
+		{"within a month", day(2025, 4, 10), day(2025, 4, 20), 10.0 / 30},
+		{"ending on the last millisecond", day(2025, 1, 1), day(2025, 2, 1).Add(-time.Millisecond), 1},
+		{"empty", day(2025, 1, 1), day(2025, 1, 1), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Months(tt.from, tt.to); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Months(%s, %s) = %g, want %g", tt.from.Format(time.DateOnly), tt.to.Format(time.DateOnly), got, tt.want)
+			}
+		})
+	}
+}
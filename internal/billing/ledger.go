@@ -0,0 +1,141 @@
+// internal/billing/ledger.go
+package billing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Statuses of an invoice in the ledger
+const (
+	StatusIssued     = "issued"
+	StatusSuperseded = "superseded" // replaced by a correction
+)
+
+// Invoice is a numbered consumer bill recorded in the ledger
+type Invoice struct {
+	Number     string    `json:"number"`
+	PeriodFrom time.Time `json:"periodFrom"`
+	PeriodTo   time.Time `json:"periodTo"`
+	ConsumerID string    `json:"consumerId,omitempty"`
+	Consumer   string    `json:"consumer"`
+	Tenant     string    `json:"tenant,omitempty"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Currency   string    `json:"currency"`
+	Total      float64   `json:"total"`
+	Status     string    `json:"status"`
+	// Number of the invoice this one corrects
+	Corrects string    `json:"corrects,omitempty"`
+	Issued   time.Time `json:"issued"`
+}
+
+// Ledger keeps the invoices issued for the consumer bills, so billing a
+// period again finds its invoices instead of numbering them anew
+type Ledger struct {
+	Next     int       `json:"next"` // sequence number of the next invoice
+	Invoices []Invoice `json:"invoices"`
+}
+
+// LoadLedger reads the ledger from path, an empty one if it does not exist
+// yet
+func LoadLedger(path string) (*Ledger, error) {
+	ledger := &Ledger{Next: 1, Invoices: []Invoice{}}
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading ledger: %w", err)
+	}
+	if err := json.Unmarshal(buf, ledger); err != nil {
+		return nil, fmt.Errorf("parsing ledger %s: %w", path, err)
+	}
+	if ledger.Next < 1 {
+		return nil, fmt.Errorf("parsing ledger %s: next must be positive", path)
+	}
+	return ledger, nil
+}
+
+// Save writes the ledger to path atomically (write to temp, then rename)
+func (l *Ledger) Save(path string) error {
+	buf, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding ledger: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(buf, '\n'), 0o644); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("writing ledger: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("renaming ledger: %w", err)
+	}
+	return nil
+}
+
+// Book numbers the consumer bills with the invoices of the ledger and
+// returns how many invoices it issued. A bill keeps the number of the
+// invoice issued for the same consumer, tenant and time as long as its
+// total stays the same. When the total changed, the old invoice is
+// superseded by a new one correcting it. Bills of nothing, like those of
+// common areas, get no invoice unless they correct one. Numbers are the
+// prefix and the sequence number with at least five digits.
+func (l *Ledger) Book(bill *Bill, prefix string, now time.Time) int {
+	var issued int
+	for i := range bill.Consumers {
+		consumer := &bill.Consumers[i]
+		original := l.find(bill, consumer)
+		if original != nil && original.Total == consumer.Total && original.Currency == bill.Currency {
+			consumer.Invoice = original.Number
+			continue
+		}
+		if original == nil && consumer.Total == 0 {
+			continue
+		}
+		invoice := Invoice{
+			Number:     fmt.Sprintf("%s%05d", prefix, l.Next),
+			PeriodFrom: bill.From,
+			PeriodTo:   bill.To,
+			ConsumerID: consumer.ID,
+			Consumer:   consumer.Name,
+			Tenant:     consumer.Tenant,
+			From:       consumer.From,
+			To:         consumer.To,
+			Currency:   bill.Currency,
+			Total:      consumer.Total,
+			Status:     StatusIssued,
+			Issued:     now,
+		}
+		if original != nil {
+			original.Status = StatusSuperseded
+			invoice.Corrects = original.Number
+		}
+		l.Next++
+		l.Invoices = append(l.Invoices, invoice)
+		consumer.Invoice, consumer.Corrects = invoice.Number, invoice.Corrects
+		issued++
+	}
+	return issued
+}
+
+// find returns the invoice in force for the bill of consumer, or nil.
+// Consumers without an ID, like the shared usage, go by name.
+func (l *Ledger) find(bill *Bill, consumer *Consumer) *Invoice {
+	for i := len(l.Invoices) - 1; i >= 0; i-- {
+		invoice := &l.Invoices[i]
+		if invoice.Status != StatusSuperseded &&
+			invoice.ConsumerID == consumer.ID && (consumer.ID != "" || invoice.Consumer == consumer.Name) &&
+			invoice.Tenant == consumer.Tenant &&
+			invoice.PeriodFrom.Equal(bill.From) && invoice.PeriodTo.Equal(bill.To) &&
+			invoice.From.Equal(consumer.From) && invoice.To.Equal(consumer.To) {
+			return invoice
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package billing
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testBill returns a bill of January 2025 with a consumer per total, a
+// and b metered, the shared usage without an ID
+func testBill(a, b, shared float64) *Bill {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 1, 0)
+	return &Bill{From: from, To: to, Currency: "CHF", Consumers: []Consumer{
+		{ID: "a", Name: "Flat A", From: from, To: to, Total: a},
+		{ID: "b", Name: "Flat B", From: from, To: to, Total: b},
+		{Name: "Shared Usage", From: from, To: to, Total: shared},
+	}}
+}
+
+// booked is the invoice and the corrected invoice of every consumer
+type booked struct{ invoice, corrects string }
+
+func bookings(bill *Bill) []booked {
+	var result []booked
+	for _, consumer := range bill.Consumers {
+		result = append(result, booked{consumer.Invoice, consumer.Corrects})
+	}
+	return result
+}
+
+func TestLedgerBook(t *testing.T) {
+	now := time.Date(2025, 2, 3, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		first  *Bill
+		second *Bill
+		issued int      // invoices issued by the second run
+		want   []booked // numbers of the second run
+		status []string // of all invoices in the ledger
+	}{
+		{
+			name:   "rerun reuses the numbers",
+			first:  testBill(120.50, 80.25, 0),
+			second: testBill(120.50, 80.25, 0),
+			want:   []booked{{"ZEV-00001", ""}, {"ZEV-00002", ""}, {"", ""}},
+			status: []string{StatusIssued, StatusIssued},
+		},
+		{
+			name:   "changed total supersedes",
+			first:  testBill(120.50, 80.25, 0),
+			second: testBill(121.00, 80.25, 0),
+			issued: 1,
+			want:   []booked{{"ZEV-00003", "ZEV-00001"}, {"ZEV-00002", ""}, {"", ""}},
+			status: []string{StatusSuperseded, StatusIssued, StatusIssued},
+		},
+		{
+			name:   "drop to zero issues a correction",
+			first:  testBill(120.50, 80.25, 0),
+			second: testBill(120.50, 0, 0),
+			issued: 1,
+			want:   []booked{{"ZEV-00001", ""}, {"ZEV-00003", "ZEV-00002"}, {"", ""}},
+			status: []string{StatusIssued, StatusSuperseded, StatusIssued},
+		},
+		{
+			name:   "shared usage goes by name",
+			first:  testBill(120.50, 80.25, 10),
+			second: testBill(120.50, 80.25, 12),
+			issued: 1,
+			want:   []booked{{"ZEV-00001", ""}, {"ZEV-00002", ""}, {"ZEV-00004", "ZEV-00003"}},
+			status: []string{StatusIssued, StatusIssued, StatusSuperseded, StatusIssued},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := &Ledger{Next: 1, Invoices: []Invoice{}}
+			ledger.Book(tt.first, "ZEV-", now)
+
+			// the second run reads the ledger back, as a later -book does
+			path := filepath.Join(t.TempDir(), "ledger.json")
+			if err := ledger.Save(path); err != nil {
+				t.Fatal(err)
+			}
+			ledger, err := LoadLedger(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if issued := ledger.Book(tt.second, "ZEV-", now.Add(time.Hour)); issued != tt.issued {
+				t.Errorf("issued %d invoices, want %d", issued, tt.issued)
+			}
+			got := bookings(tt.second)
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("consumer %d booked as %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+			if len(ledger.Invoices) != len(tt.status) {
+				t.Fatalf("ledger has %d invoices, want %d", len(ledger.Invoices), len(tt.status))
+			}
+			for i, invoice := range ledger.Invoices {
+				if invoice.Status != tt.status[i] {
+					t.Errorf("invoice %s is %s, want %s", invoice.Number, invoice.Status, tt.status[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLedgerBookZero(t *testing.T) {
+	now := time.Date(2025, 2, 3, 10, 0, 0, 0, time.UTC)
+	ledger := &Ledger{Next: 1, Invoices: []Invoice{}}
+
+	// a bill of nothing gets no invoice
+	if issued := ledger.Book(testBill(0, 80.25, 0), "", now); issued != 1 {
+		t.Fatalf("issued %d invoices, want 1", issued)
+	}
+	bill := testBill(120.50, 80.25, 0)
+	ledger.Book(bill, "", now)
+	if got := bookings(bill)[0]; got != (booked{"00002", ""}) {
+		t.Fatalf("first invoice of a booked as %+v, want 00002", got)
+	}
+
+	// dropping to 0 corrects the invoice with an invoice of 0
+	bill = testBill(0, 80.25, 0)
+	if issued := ledger.Book(bill, "", now); issued != 1 {
+		t.Fatalf("issued %d invoices, want 1", issued)
+	}
+	correction := ledger.Invoices[len(ledger.Invoices)-1]
+	if correction.Number != "00003" || correction.Corrects != "00002" || correction.Total != 0 {
+		t.Errorf("correction = %+v, want 00003 of 0 correcting 00002", correction)
+	}
+	if ledger.Invoices[1].Status != StatusSuperseded {
+		t.Errorf("invoice 00002 is %s, want %s", ledger.Invoices[1].Status, StatusSuperseded)
+	}
+
+	// booking 0 again keeps the correction
+	bill = testBill(0, 80.25, 0)
+	if issued := ledger.Book(bill, "", now); issued != 0 {
+		t.Errorf("rebooking issued %d invoices, want 0", issued)
+	}
+	if got := bookings(bill)[0]; got != (booked{"00003", ""}) {
+		t.Errorf("rebooked a as %+v, want 00003", got)
+	}
+	if ledger.Next != 4 {
+		t.Errorf("next = %d, want 4", ledger.Next)
+	}
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"time"
+)
+
+// BackfillZevKey is the backfill key of the ZEV data set (sensor data uses the sensor ID)
+const BackfillZevKey = "zev"
+
+// RecordBackfill widens the backfill target of a data set to include [from, to].
+// Today is excluded, it is never cached.
+func (c *Cache) RecordBackfill(key string, from, to time.Time) {
+	from = NormalizeDate(from)
+	to = NormalizeDate(to)
+	today := Today()
+
+	if !to.Before(today) {
+		to = today.AddDate(0, 0, -1)
+	}
+	if from.After(to) {
+		return
+	}
+
+	if c.Backfills == nil {
+		c.Backfills = make(map[string]DateRange)
+	}
+
+	target, ok := c.Backfills[key]
+	if !ok {
+		c.Backfills[key] = DateRange{Start: from, End: to}
+		return
+	}
+	if from.Before(target.Start) {
+		target.Start = from
+	}
+	if to.After(target.End) {
+		target.End = to
+	}
+	c.Backfills[key] = target
+}
+
+// BackfillProgress returns how many days of the backfill target of a data set
+// are already cached, and the total number of days in the target
+func (c *Cache) BackfillProgress(key string) (cachedDays, totalDays int) {
+	target, ok := c.Backfills[key]
+	if !ok {
+		return 0, 0
+	}
+
+	var ranges []DateRange
+	if key == BackfillZevKey {
+		ranges = c.ZevData.CachedRanges
+	} else {
+		ranges = c.SensorData.CachedRanges[key]
+	}
+
+	totalDays = dayCount(target)
+	missing := 0
+	for _, gap := range subtractAll(target, ranges) {
+		missing += dayCount(gap)
+	}
+	return totalDays - missing, totalDays
+}
+
+// subtractAll removes all cached ranges from base
+func subtractAll(base DateRange, cached []DateRange) []DateRange {
+	gaps := []DateRange{base}
+	for _, c := range cached {
+		var newGaps []DateRange
+		for _, gap := range gaps {
+			newGaps = append(newGaps, subtractRange(gap, c)...)
+		}
+		gaps = newGaps
+	}
+	return gaps
+}
+
+// dayCount returns the number of days in an inclusive date range
+func dayCount(r DateRange) int {
+	return int(r.End.Sub(r.Start).Hours()/24+0.5) + 1
+}
+
+// cutRange cuts the first piece of at most days days off ranges and returns
+// it with the remaining ranges
+func cutRange(ranges []DateRange, days int) (DateRange, []DateRange) {
+	first := ranges[0]
+	end := first.Start.AddDate(0, 0, max(days, 1)-1)
+	if !end.Before(first.End) {
+		return first, ranges[1:]
+	}
+	rest := append([]DateRange{{Start: end.AddDate(0, 0, 1), End: first.End}}, ranges[1:]...)
+	return DateRange{Start: first.Start, End: end}, rest
+}
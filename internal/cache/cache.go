@@ -36,6 +36,7 @@ func NewCache(smID string) *Cache {
 			Data:         make(map[string]map[string][]models.SensorData),
 			CachedRanges: make(map[string][]DateRange),
 		},
+		Backfills: make(map[string]DateRange),
 	}
 }
 
@@ -72,6 +73,9 @@ func Load(path string, smID string) (*Cache, error) {
 	if cache.SensorData.CachedRanges == nil {
 		cache.SensorData.CachedRanges = make(map[string][]DateRange)
 	}
+	if cache.Backfills == nil {
+		cache.Backfills = make(map[string]DateRange)
+	}
 
 	return &cache, nil
 }
@@ -114,6 +118,7 @@ func (c *Cache) Clear() {
 	c.ZevData.CachedRanges = []DateRange{}
 	c.SensorData.Data = make(map[string]map[string][]models.SensorData)
 	c.SensorData.CachedRanges = make(map[string][]DateRange)
+	c.Backfills = make(map[string]DateRange)
 }
 
 // Delete removes the cache file from disk
@@ -1,26 +1,43 @@
 package cache
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"os"
 	"sort"
+	"sync"
 	"time"
 
 	"zevalizer/internal/api"
 	"zevalizer/internal/models"
+	"zevalizer/internal/progress"
 )
 
-// CachedClient wraps api.Client with caching capabilities
+// checkpointInterval is the least time between two saves of the cache
+// while backfilling. Every save rewrites the whole cache, which takes
+// longer than fetching a chunk once the cache has grown.
+const checkpointInterval = 30 * time.Second
+
+// CachedClient wraps api.Client with caching capabilities. It is safe for
+// concurrent use: mu guards the cache, API requests run without holding it.
 type CachedClient struct {
 	client    *api.Client
+	mu        sync.Mutex
 	cache     *Cache
 	cachePath string
 	enabled   bool
 	debug     bool
+	quiet     bool
+
+	// checkpoints, guarded by mu as well
+	dirty       bool      // chunks were stored since the last save
+	lastSave    time.Time // of the last checkpoint
+	backfilling int       // backfills in progress
 }
 
 // NewCachedClient creates a caching wrapper around the API client
-func NewCachedClient(client *api.Client, cachePath string, smID string, enabled bool, debug bool) (*CachedClient, error) {
+func NewCachedClient(client *api.Client, cachePath string, smID string, enabled bool, debug bool, quiet bool) (*CachedClient, error) {
 	var c *Cache
 	var err error
 
@@ -39,63 +56,87 @@ func NewCachedClient(client *api.Client, cachePath string, smID string, enabled
 		cachePath: cachePath,
 		enabled:   enabled,
 		debug:     debug,
+		quiet:     quiet,
 	}, nil
 }
 
 func (cc *CachedClient) debugf(format string, args ...interface{}) {
 	if cc.debug {
-		fmt.Printf("DEBUG [cache]: "+format+"\n", args...)
+		fmt.Fprintf(os.Stderr, "DEBUG [cache]: "+format+"\n", args...)
 	}
 }
 
 // GetSensors fetches sensor list (not cached, rarely changes)
-func (cc *CachedClient) GetSensors(smID string) ([]models.Sensor, error) {
-	return cc.client.GetSensors(smID)
+func (cc *CachedClient) GetSensors(ctx context.Context, smID string) ([]models.Sensor, error) {
+	return cc.client.GetSensors(ctx, smID)
+}
+
+// GetPowerData fetches sensor data at a finer resolution than the cached
+// 15-minute readings (not cached)
+func (cc *CachedClient) GetPowerData(ctx context.Context, smId string, sensorID string, from, to time.Time, seconds int) ([]models.SensorData, error) {
+	return cc.client.GetPowerData(ctx, smId, sensorID, from, to, seconds)
 }
 
 // GetZevData fetches ZEV data, using cache where possible
-func (cc *CachedClient) GetZevData(smId string, from, to time.Time) ([]models.ZevData, error) {
+func (cc *CachedClient) GetZevData(ctx context.Context, smId string, from, to time.Time) ([]models.ZevData, error) {
 	if !cc.enabled {
-		return cc.client.GetZevData(smId, from, to)
+		return cc.client.GetZevData(ctx, smId, from, to)
 	}
 
 	today := Today()
 	var allData []models.ZevData
-	cacheModified := false
 
 	// 1. Get gaps that need fetching (excludes today automatically)
+	cc.mu.Lock()
 	gaps := cc.cache.GetZevCacheGaps(from, to)
+	cc.mu.Unlock()
 
 	// 2. Check if request includes today
 	includestoday := !NormalizeDate(to).Before(today)
 
-	// 3. Fetch missing historical data
+	// 3. Fetch missing historical data chunk by chunk, checkpointing the
+	// cache as it goes so an interrupted backfill resumes here
+	var days int
 	for _, gap := range gaps {
-		cc.debugf("Fetching ZEV data gap: %s to %s",
-			gap.Start.Format("2006-01-02"),
-			gap.End.Format("2006-01-02"))
-
-		// Fetch ends at 23:59:59 of the last day
-		gapEnd := time.Date(gap.End.Year(), gap.End.Month(), gap.End.Day(),
-			23, 59, 59, 999999999, gap.End.Location())
-
-		data, err := cc.client.GetZevData(smId, gap.Start, gapEnd)
+		days += dayCount(gap)
+	}
+	if days > 0 {
+		cc.mu.Lock()
+		cc.cache.RecordBackfill(BackfillZevKey, from, to)
+		cc.backfilling++
+		cc.mu.Unlock()
+	}
+	// The chunks are cut one at a time, as the client adapts its chunk size
+	bar := cc.progressBar("ZEV data", days)
+	for len(gaps) > 0 {
+		var chunk DateRange
+		chunk, gaps = cutRange(gaps, cc.client.ChunkDays())
+		cc.debugf("Fetching ZEV data chunk: %s to %s",
+			chunk.Start.Format("2006-01-02"),
+			chunk.End.Format("2006-01-02"))
+
+		data, err := cc.client.GetZevData(ctx, smId, chunk.Start, endOfDay(chunk.End))
 		if err != nil {
-			return nil, err
+			bar.Finish()
+			return nil, cc.interrupted(BackfillZevKey, "ZEV data", chunk, err)
 		}
 
-		// Store in cache
+		cc.mu.Lock()
 		cc.cache.StoreZevData(data)
-		cc.cache.UpdateZevCachedRanges(gap.Start, gap.End)
-		cacheModified = true
+		cc.cache.UpdateZevCachedRanges(chunk.Start, chunk.End)
+		status := cc.checkpoint(BackfillZevKey)
+		cc.mu.Unlock()
+		bar.Add(dayCount(chunk), status)
+	}
+	bar.Finish()
+	if days > 0 {
+		cc.backfilled()
 	}
 
 	// 4. Fetch today's data fresh (never cached)
 	if includestoday {
 		cc.debugf("Fetching today's ZEV data (not cached)")
-		todayEnd := time.Date(today.Year(), today.Month(), today.Day(),
-			23, 59, 59, 999999999, today.Location())
-		todayData, err := cc.client.GetZevData(smId, today, todayEnd)
+		todayData, err := cc.client.GetZevData(ctx, smId, today, endOfDay(today))
 		if err != nil {
 			return nil, err
 		}
@@ -108,7 +149,9 @@ func (cc *CachedClient) GetZevData(smId string, from, to time.Time) ([]models.Ze
 		historicalEnd = today.AddDate(0, 0, -1)
 	}
 	if !historicalEnd.Before(NormalizeDate(from)) {
+		cc.mu.Lock()
 		cachedData := cc.cache.GetZevData(from, historicalEnd)
+		cc.mu.Unlock()
 		cc.debugf("Retrieved %d sensors from cache for %s to %s",
 			len(cachedData),
 			from.Format("2006-01-02"),
@@ -116,57 +159,68 @@ func (cc *CachedClient) GetZevData(smId string, from, to time.Time) ([]models.Ze
 		allData = append(allData, cachedData...)
 	}
 
-	// 6. Save updated cache
-	if cacheModified {
-		if err := cc.cache.Save(cc.cachePath); err != nil {
-			cc.debugf("Warning: failed to save cache: %v", err)
-		}
-	}
-
-	// 7. Merge data by sensor (combine cached + fresh)
+	// 6. Merge data by sensor (combine cached + fresh)
 	return mergeZevData(allData), nil
 }
 
 // GetSensorData fetches sensor data with caching (for batteries)
-func (cc *CachedClient) GetSensorData(smId string, sensorID string, from, to time.Time) ([]models.SensorData, error) {
+func (cc *CachedClient) GetSensorData(ctx context.Context, smId string, sensorID string, from, to time.Time) ([]models.SensorData, error) {
 	if !cc.enabled {
-		return cc.client.GetSensorData(smId, sensorID, from, to)
+		return cc.client.GetSensorData(ctx, smId, sensorID, from, to)
 	}
 
 	today := Today()
 	var allData []models.SensorData
-	cacheModified := false
 
 	// Get gaps for this specific sensor
+	cc.mu.Lock()
 	gaps := cc.cache.GetSensorCacheGaps(sensorID, from, to)
+	cc.mu.Unlock()
 	includestoday := !NormalizeDate(to).Before(today)
 
-	// Fetch missing historical data
+	// Fetch missing historical data, checkpointing as it goes
+	var days int
 	for _, gap := range gaps {
-		cc.debugf("Fetching sensor %s data gap: %s to %s",
+		days += dayCount(gap)
+	}
+	if days > 0 {
+		cc.mu.Lock()
+		cc.cache.RecordBackfill(sensorID, from, to)
+		cc.backfilling++
+		cc.mu.Unlock()
+	}
+	// The chunks are cut one at a time, as the client adapts its chunk size
+	bar := cc.progressBar("Sensor "+sensorID, days)
+	for len(gaps) > 0 {
+		var chunk DateRange
+		chunk, gaps = cutRange(gaps, cc.client.ChunkDays())
+		cc.debugf("Fetching sensor %s data chunk: %s to %s",
 			sensorID,
-			gap.Start.Format("2006-01-02"),
-			gap.End.Format("2006-01-02"))
+			chunk.Start.Format("2006-01-02"),
+			chunk.End.Format("2006-01-02"))
 
-		gapEnd := time.Date(gap.End.Year(), gap.End.Month(), gap.End.Day(),
-			23, 59, 59, 999999999, gap.End.Location())
-
-		data, err := cc.client.GetSensorData(smId, sensorID, gap.Start, gapEnd)
+		data, err := cc.client.GetSensorData(ctx, smId, sensorID, chunk.Start, endOfDay(chunk.End))
 		if err != nil {
-			return nil, err
+			bar.Finish()
+			return nil, cc.interrupted(sensorID, "sensor "+sensorID, chunk, err)
 		}
 
+		cc.mu.Lock()
 		cc.cache.StoreSensorData(sensorID, data)
-		cc.cache.UpdateSensorCachedRanges(sensorID, gap.Start, gap.End)
-		cacheModified = true
+		cc.cache.UpdateSensorCachedRanges(sensorID, chunk.Start, chunk.End)
+		status := cc.checkpoint(sensorID)
+		cc.mu.Unlock()
+		bar.Add(dayCount(chunk), status)
+	}
+	bar.Finish()
+	if days > 0 {
+		cc.backfilled()
 	}
 
 	// Fetch today fresh
 	if includestoday {
 		cc.debugf("Fetching today's sensor %s data (not cached)", sensorID)
-		todayEnd := time.Date(today.Year(), today.Month(), today.Day(),
-			23, 59, 59, 999999999, today.Location())
-		todayData, err := cc.client.GetSensorData(smId, sensorID, today, todayEnd)
+		todayData, err := cc.client.GetSensorData(ctx, smId, sensorID, today, endOfDay(today))
 		if err != nil {
 			return nil, err
 		}
@@ -179,22 +233,89 @@ func (cc *CachedClient) GetSensorData(smId string, sensorID string, from, to tim
 		historicalEnd = today.AddDate(0, 0, -1)
 	}
 	if !historicalEnd.Before(NormalizeDate(from)) {
+		cc.mu.Lock()
 		cachedData := cc.cache.GetSensorData(sensorID, from, historicalEnd)
+		cc.mu.Unlock()
 		allData = append(allData, cachedData...)
 	}
 
-	// Save updated cache
-	if cacheModified {
-		if err := cc.cache.Save(cc.cachePath); err != nil {
-			cc.debugf("Warning: failed to save cache: %v", err)
-		}
+	return mergeSensorData(allData), nil
+}
+
+// interrupted saves the chunks fetched before a failed one and adds the
+// backfill progress to its error, so the next run resumes with the failed
+// chunk
+func (cc *CachedClient) interrupted(key, label string, chunk DateRange, err error) error {
+	cc.mu.Lock()
+	cc.backfilling--
+	cc.save()
+	cached, total := cc.cache.BackfillProgress(key)
+	cc.mu.Unlock()
+	return fmt.Errorf("fetching %s from %s (%d of %d days cached, the next run resumes here): %w",
+		label, chunk.Start.Format("2006-01-02"), cached, total, err)
+}
+
+// backfilled ends a backfill; the last one of concurrent backfills saves
+// the chunks not saved yet
+func (cc *CachedClient) backfilled() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.backfilling--
+	if cc.backfilling == 0 {
+		cc.save()
 	}
+}
 
-	return mergeSensorData(allData), nil
+// checkpoint records a completed chunk, saves the cache at most every
+// checkpointInterval and returns the overall backfill progress of the data
+// set, which spans previous invocations. The caller must hold cc.mu.
+func (cc *CachedClient) checkpoint(key string) string {
+	cc.dirty = true
+	if time.Since(cc.lastSave) >= checkpointInterval {
+		cc.save()
+	}
+	cached, total := cc.cache.BackfillProgress(key)
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("backfill %d/%d days (%.0f%%)",
+		cached, total, float64(cached)/float64(total)*100)
+}
+
+// save writes the cache if chunks were stored since the last save. The
+// caller must hold cc.mu.
+func (cc *CachedClient) save() {
+	if !cc.dirty {
+		return
+	}
+	if err := cc.cache.Save(cc.cachePath); err != nil {
+		// without the checkpoint, an interrupted run starts over
+		fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+		return
+	}
+	cc.dirty = false
+	cc.lastSave = time.Now()
+}
+
+// progressBar returns a bar counting the days of chunked fetches, or nil
+// when there is nothing to fetch or progress output is unwanted (quiet or
+// debug mode)
+func (cc *CachedClient) progressBar(label string, days int) *progress.Bar {
+	if days == 0 || cc.quiet || cc.debug {
+		return nil
+	}
+	return progress.New(os.Stderr, label, days)
+}
+
+// endOfDay returns the last instant of the given day
+func endOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 59, 999999999, t.Location())
 }
 
 // ClearCache removes all cached data
 func (cc *CachedClient) ClearCache() error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
 	cc.cache.Clear()
 	return cc.cache.Save(cc.cachePath)
 }
@@ -205,8 +326,10 @@ func (cc *CachedClient) DeleteCache() error {
 }
 
 // DumpCache writes cache contents to the given writer
-func (cc *CachedClient) DumpCache(w io.Writer) {
-	cc.cache.Dump(w)
+func (cc *CachedClient) DumpCache(w io.Writer, anonymized bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.cache.Dump(w, anonymized)
 }
 
 // mergeZevData combines data from multiple ZevData slices by sensor
@@ -4,16 +4,26 @@ import (
 	"fmt"
 	"io"
 	"sort"
+
+	"zevalizer/internal/anonymize"
 )
 
-// Dump writes a human-readable representation of the cache
-func (c *Cache) Dump(w io.Writer) {
+// Dump writes a human-readable representation of the cache.
+// With anonymized set, SmID and sensor IDs are replaced by stable pseudonyms.
+func (c *Cache) Dump(w io.Writer, anonymized bool) {
+	name := func(id string) string {
+		if anonymized {
+			return anonymize.ID(id)
+		}
+		return id
+	}
+
 	fmt.Fprintf(w, "=== Cache Dump ===\n\n")
 
 	// Metadata
 	fmt.Fprintf(w, "Metadata:\n")
 	fmt.Fprintf(w, "  Version:      %d\n", c.Metadata.Version)
-	fmt.Fprintf(w, "  SmID:         %s\n", c.Metadata.SmID)
+	fmt.Fprintf(w, "  SmID:         %s\n", name(c.Metadata.SmID))
 	fmt.Fprintf(w, "  Created:      %s\n", c.Metadata.CreatedAt.Format("2006-01-02 15:04:05"))
 	fmt.Fprintf(w, "  Last Updated: %s\n\n", c.Metadata.LastUpdated.Format("2006-01-02 15:04:05"))
 
@@ -24,7 +34,7 @@ func (c *Cache) Dump(w io.Writer) {
 		fmt.Fprintf(w, "    (none)\n")
 	}
 	for _, r := range c.ZevData.CachedRanges {
-		days := int(r.End.Sub(r.Start).Hours()/24) + 1
+		days := dayCount(r)
 		fmt.Fprintf(w, "    %s to %s (%d days)\n",
 			r.Start.Format("2006-01-02"),
 			r.End.Format("2006-01-02"),
@@ -49,7 +59,7 @@ func (c *Cache) Dump(w io.Writer) {
 		}
 		sort.Strings(sensorIDs)
 		for _, id := range sensorIDs {
-			fmt.Fprintf(w, "    %s: %d points\n", id, sensorCounts[id])
+			fmt.Fprintf(w, "    %s: %d points\n", name(id), sensorCounts[id])
 		}
 	}
 
@@ -67,10 +77,10 @@ func (c *Cache) Dump(w io.Writer) {
 
 	for _, sensorID := range batteryIDs {
 		ranges := c.SensorData.CachedRanges[sensorID]
-		fmt.Fprintf(w, "  Sensor %s:\n", sensorID)
+		fmt.Fprintf(w, "  Sensor %s:\n", name(sensorID))
 		fmt.Fprintf(w, "    Cached Ranges:\n")
 		for _, r := range ranges {
-			days := int(r.End.Sub(r.Start).Hours()/24) + 1
+			days := dayCount(r)
 			fmt.Fprintf(w, "      %s to %s (%d days)\n",
 				r.Start.Format("2006-01-02"),
 				r.End.Format("2006-01-02"),
@@ -85,5 +95,29 @@ func (c *Cache) Dump(w io.Writer) {
 		}
 	}
 
+	// Backfill progress across invocations
+	fmt.Fprintf(w, "\nBackfill Progress:\n")
+	if len(c.Backfills) == 0 {
+		fmt.Fprintf(w, "  (none)\n")
+	}
+	var backfillKeys []string
+	for key := range c.Backfills {
+		backfillKeys = append(backfillKeys, key)
+	}
+	sort.Strings(backfillKeys)
+	for _, key := range backfillKeys {
+		target := c.Backfills[key]
+		cached, total := c.BackfillProgress(key)
+		label := key
+		if key != BackfillZevKey {
+			label = name(key)
+		}
+		fmt.Fprintf(w, "  %s: %s to %s, %d/%d days cached\n",
+			label,
+			target.Start.Format("2006-01-02"),
+			target.End.Format("2006-01-02"),
+			cached, total)
+	}
+
 	fmt.Fprintf(w, "\n=== End Cache Dump ===\n")
 }
@@ -85,20 +85,8 @@ func FindGaps(cached []DateRange, from, to time.Time) []DateRange {
 		return nil // Entire range is today or future
 	}
 
-	// Start with the full range as a gap
-	gaps := []DateRange{{Start: from, End: to}}
-
-	// Subtract each cached range
-	for _, c := range cached {
-		var newGaps []DateRange
-		for _, gap := range gaps {
-			subtracted := subtractRange(gap, c)
-			newGaps = append(newGaps, subtracted...)
-		}
-		gaps = newGaps
-	}
-
-	return gaps
+	// Subtract each cached range from the full range
+	return subtractAll(DateRange{Start: from, End: to}, cached)
 }
 
 // subtractRange removes the 'subtract' range from 'base', returning remaining pieces
@@ -43,4 +43,9 @@ type Cache struct {
 	Metadata   CacheMetadata
 	ZevData    ZevDataCache
 	SensorData SensorDataCache
+
+	// Backfills maps a data set key (BackfillZevKey or a sensor ID) to the
+	// widest historical range ever requested, so backfill progress can be
+	// reported across invocations
+	Backfills map[string]DateRange
 }
@@ -1,35 +1,826 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/goccy/go-yaml"
 )
 
+// ErrInvalid is wrapped by all errors caused by invalid configuration values
+var ErrInvalid = errors.New("invalid configuration")
+
 type APIConfig struct {
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
 	BaseURL  string `yaml:"baseUrl"`
+	// Token is sent as bearer token instead of username and password
+	Token string `yaml:"token,omitempty"`
+	// APIKey is sent in the APIKeyHeader header besides the credentials,
+	// e.g. for a gateway in front of the API
+	APIKey       string `yaml:"apiKey,omitempty"`
+	APIKeyHeader string `yaml:"apiKeyHeader,omitempty"` // default DefaultAPIKeyHeader
+	// OAuth logs in with username and password instead of sending them with
+	// every request
+	OAuth OAuthConfig `yaml:"oauth,omitempty"`
+	TLS   TLSConfig   `yaml:"tls,omitempty"`
+	// Retries of a request failing with a network error or a server error,
+	// default DefaultRetries; 0 disables them
+	Retries *int `yaml:"retries,omitempty"`
+	// Delay before the first retry, doubled for every further one, default
+	// DefaultRetryDelay
+	RetryDelaySeconds float64 `yaml:"retryDelaySeconds,omitempty"`
+	// Limit of establishing the connection including the TLS handshake,
+	// default DefaultConnectTimeout
+	ConnectTimeoutSeconds float64 `yaml:"connectTimeoutSeconds,omitempty"`
+	// Limit of a whole request including reading the response, default
+	// DefaultRequestTimeout
+	TimeoutSeconds float64 `yaml:"timeoutSeconds,omitempty"`
+	// Days fetched per request at first, default DefaultChunkDays. The
+	// client halves the chunks when requests fail or come back truncated and
+	// lets them grow up to MaxChunkDays while responses are fast.
+	ChunkDays    int `yaml:"chunkDays,omitempty"`
+	MaxChunkDays int `yaml:"maxChunkDays,omitempty"` // default DefaultMaxChunkDays, at least ChunkDays
+}
+
+// OAuthConfig is the OAuth2 token endpoint of the API. The client logs in
+// with the password grant and renews the access token with the refresh
+// token when it expires.
+type OAuthConfig struct {
+	TokenURL     string   `yaml:"tokenUrl"`
+	ClientID     string   `yaml:"clientId,omitempty"`
+	ClientSecret string   `yaml:"clientSecret,omitempty"`
+	Scopes       []string `yaml:"scopes,omitempty"`
+	// File keeping the tokens between runs, so username and password are
+	// only needed when the refresh token expired
+	TokenFile string `yaml:"tokenFile,omitempty"`
+}
+
+// Enabled reports whether a token endpoint is configured
+func (o *OAuthConfig) Enabled() bool {
+	return o.TokenURL != ""
+}
+
+// TLSConfig customizes the TLS of the API connections, e.g. behind a proxy
+// intercepting TLS with a corporate CA
+type TLSConfig struct {
+	CAFile   string `yaml:"caFile,omitempty"`   // PEM certificates trusted besides the system roots
+	CertFile string `yaml:"certFile,omitempty"` // PEM client certificate, together with KeyFile
+	KeyFile  string `yaml:"keyFile,omitempty"`
+	// Accept any server certificate, only for test servers
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify,omitempty"`
+}
+
+// Defaults of the API authentication, request retries and timeouts
+const (
+	DefaultAPIKeyHeader   = "X-API-Key"
+	DefaultRetries        = 3
+	DefaultRetryDelay     = time.Second
+	DefaultConnectTimeout = 10 * time.Second
+	DefaultRequestTimeout = 30 * time.Second
+	DefaultChunkDays      = 30
+	DefaultMaxChunkDays   = 90
+)
+
+// KeyHeader returns the name of the header carrying the API key
+func (a *APIConfig) KeyHeader() string {
+	if a.APIKeyHeader == "" {
+		return DefaultAPIKeyHeader
+	}
+	return a.APIKeyHeader
+}
+
+// MaxRetries returns how often a failed request is retried
+func (a *APIConfig) MaxRetries() int {
+	if a.Retries == nil {
+		return DefaultRetries
+	}
+	return *a.Retries
+}
+
+// RetryDelay returns the delay before the first retry
+func (a *APIConfig) RetryDelay() time.Duration {
+	if a.RetryDelaySeconds == 0 {
+		return DefaultRetryDelay
+	}
+	return time.Duration(a.RetryDelaySeconds * float64(time.Second))
+}
+
+// ConnectTimeout returns the time limit of establishing a connection
+func (a *APIConfig) ConnectTimeout() time.Duration {
+	if a.ConnectTimeoutSeconds == 0 {
+		return DefaultConnectTimeout
+	}
+	return time.Duration(a.ConnectTimeoutSeconds * float64(time.Second))
+}
+
+// RequestTimeout returns the time limit of a single request
+func (a *APIConfig) RequestTimeout() time.Duration {
+	if a.TimeoutSeconds == 0 {
+		return DefaultRequestTimeout
+	}
+	return time.Duration(a.TimeoutSeconds * float64(time.Second))
+}
+
+// InitialChunkDays returns the days fetched per request before any
+// adaption
+func (a *APIConfig) InitialChunkDays() int {
+	if a.ChunkDays == 0 {
+		return DefaultChunkDays
+	}
+	return a.ChunkDays
+}
+
+// ChunkDaysLimit returns the most days the chunks may grow to
+func (a *APIConfig) ChunkDaysLimit() int {
+	if a.MaxChunkDays == 0 {
+		return max(DefaultMaxChunkDays, a.InitialChunkDays())
+	}
+	return a.MaxChunkDays
 }
 
 type LowTariffConfig struct {
-	StartHour int `yaml:"startHour"`
-	EndHour   int `yaml:"endHour"`
+	StartHour     int            `yaml:"startHour"`
+	EndHour       int            `yaml:"endHour"`
+	Windows       []TariffWindow `yaml:"windows,omitempty"`       // Replaces startHour/endHour when set
+	Weekends      bool           `yaml:"weekends,omitempty"`      // Saturday and Sunday are low tariff all day
+	Holidays      bool           `yaml:"holidays,omitempty"`      // Swiss public holidays are low tariff all day
+	ExtraHolidays []string       `yaml:"extraHolidays,omitempty"` // Additional all-day low tariff dates (YYYY-MM-DD)
+	// Counter of dual-tariff grid meters that runs in the low tariff, see
+	// the MeterCounters constants; default MeterCountersTariff2
+	MeterCounters string `yaml:"meterCounters,omitempty"`
+}
+
+// Ways to take the tariff from dual-tariff grid meters
+const (
+	MeterCountersTariff2 = "tariff2" // Tariff 2 is the low tariff
+	MeterCountersTariff1 = "tariff1" // Tariff 1 is the low tariff
+	MeterCountersOff     = "off"     // always use the configured schedule
+)
+
+// TariffWindow is a low tariff time window on the given weekdays (mon..sun,
+// all days if empty). Windows crossing midnight belong to their start day.
+type TariffWindow struct {
+	Days      []string `yaml:"days,omitempty"`
+	StartHour int      `yaml:"startHour"`
+	EndHour   int      `yaml:"endHour"`
 }
 
 type ZEVConfig struct {
-	GridMeterID         string   `yaml:"gridMeterId"`
-	ProductionIDs       []string `yaml:"productionIds"`
-	ConsumerIDs         []string `yaml:"consumerIds"`
-	BatterySystemIDs    []string `yaml:"batterySystemId"`    // IDs of the battery smart meter
-	InverterEfficiency  float64  `yaml:"inverterEfficiency"` // Battery-to-AC efficiency (0.0-1.0), default 0.93
+	GridMeterID        string            `yaml:"gridMeterId"`
+	GridMeterIDs       []string          `yaml:"gridMeterIds,omitempty"` // Further grid connection points
+	ProductionIDs      []string          `yaml:"productionIds"`
+	ConsumerIDs        []string          `yaml:"consumerIds"`
+	BatterySystemIDs   []string          `yaml:"batterySystemId"`    // IDs of the battery smart meter
+	InverterEfficiency float64           `yaml:"inverterEfficiency"` // Battery-to-AC efficiency (0.0-1.0), default 0.93
+	SensorModes        map[string]string `yaml:"sensorModes"`        // Per-sensor data mode: "counter" (default) or "power"
+
+	// Spread counter differences linearly over gaps of up to this many
+	// minutes instead of booking them on the interval after the gap (0 = off)
+	MaxInterpolationMinutes int `yaml:"maxInterpolationMinutes,omitempty"`
+
+	// Largest plausible energy per 15-minute interval; readings above it
+	// are skipped as anomalies. Per-sensor values override the limit of the
+	// sensor's role.
+	MaxReadingWh       ReadingLimits      `yaml:"maxReadingWh,omitempty"`
+	SensorMaxReadingWh map[string]float64 `yaml:"sensorMaxReadingWh,omitempty"`
+
+	Outliers OutlierConfig `yaml:"outliers,omitempty"`
+
+	// Former IDs of replaced meters (old ID -> current ID); their data is
+	// joined with that of the current meter
+	Aliases map[string]string `yaml:"aliases,omitempty"`
+
+	// Meters summed into one logical consumer (consumer ID -> further meter
+	// IDs); the members are consumers that are not reported on their own
+	Combine map[string][]string `yaml:"combine,omitempty"`
+
+	// Meters serving several units, split into virtual consumers before
+	// source attribution (meter ID -> shares)
+	Splits map[string][]SplitShare `yaml:"splits,omitempty"`
+
+	// How unmetered common usage is attributed in each interval: "residual"
+	// (default) reports it as the Shared Usage consumer, "proportional" adds
+	// it to the consumers in proportion to their usage in the interval
+	SharedStrategy string `yaml:"sharedStrategy,omitempty"`
+
+	// How the solar, battery and grid energy of an interval is split onto
+	// the consumers
+	Distribution DistributionConfig `yaml:"distribution,omitempty"`
+
+	// Distribute the shared usage onto the consumers (see SharedAllocationConfig)
+	SharedAllocation SharedAllocationConfig `yaml:"sharedAllocation,omitempty"`
+
+	// Report subtotals per group of consumers
+	Groups []ConsumerGroup `yaml:"groups,omitempty"`
+
+	// Tenants of the consumers' units; the consumer bills split a unit's
+	// usage at the move-in and move-out dates
+	Tenants []TenantConfig `yaml:"tenants,omitempty"`
+
+	// Consumers measured behind another consumer's meter (sub-meter ID ->
+	// parent ID); their usage is subtracted from the parent
+	Parents map[string]string `yaml:"parents,omitempty"`
+
+	// Consumers that are EV chargers, detected by name if empty, and the
+	// power above which a charger counts as charging (default 1000 W)
+	EVChargerIDs []string `yaml:"evChargerIds,omitempty"`
+	EVMinPowerW  float64  `yaml:"evMinPowerW,omitempty"`
+
+	// Heat pumps, reported by tariff and season (consumer ID -> settings)
+	HeatPumps map[string]HeatPumpConfig `yaml:"heatPumps,omitempty"`
+
+	// Usable capacity per battery system, to count full cycles of batteries
+	// that do not report their state of charge
+	BatteryCapacityWh map[string]float64 `yaml:"batteryCapacityWh,omitempty"`
+
+	// Days without any charge or discharge after which a battery is
+	// reported as idle (default 2)
+	IdleBatteryDays int `yaml:"idleBatteryDays,omitempty"`
+
+	// Fetch the sensor data of the grid meters for their per-phase values
+	// and report the phase imbalance; PhaseLimitW is the difference between
+	// the phases above which a reading counts as unbalanced (default 3680 W,
+	// 16 A at 230 V)
+	Phases      bool    `yaml:"phases,omitempty"`
+	PhaseLimitW float64 `yaml:"phaseLimitW,omitempty"`
+
+	// Installed PV peak power per production meter, for the specific yield
+	PeakPowerKW map[string]float64 `yaml:"peakPowerKw,omitempty"`
+}
+
+// Strategies splitting the sources of an interval onto the consumers
+const (
+	DistributionProportional = "proportional" // same source mix for every consumer
+	DistributionPriority     = "priority"     // listed consumers get solar and battery first
+	DistributionContracted   = "contracted"   // guaranteed share of the solar energy
+	DistributionFirstCome    = "firstcome"    // EV chargers in the order their sessions started
+)
+
+// DistributionConfig selects the source distribution strategy. Priority
+// lists consumer IDs in the order they are served (strategy priority),
+// SolarShares the contracted part of the solar energy per consumer ID
+// (strategy contracted). BatteryPriority lists consumers that receive the
+// battery discharge first, with any strategy.
+type DistributionConfig struct {
+	Strategy        string             `yaml:"strategy,omitempty"`
+	Priority        []string           `yaml:"priority,omitempty"`
+	SolarShares     map[string]float64 `yaml:"solarShares,omitempty"`
+	BatteryPriority []string           `yaml:"batteryPriority,omitempty"`
+}
+
+// Strategies for the shared usage of an interval
+const (
+	SharedStrategyResidual     = "residual"
+	SharedStrategyProportional = "proportional"
+)
+
+// Allocation keys for the shared usage
+const (
+	AllocationEqual       = "equal"       // same part for every consumer
+	AllocationQuota       = "quota"       // ownership quota per consumer (weights)
+	AllocationConsumption = "consumption" // in proportion to the consumer's own usage
+	AllocationArea        = "area"        // floor area per consumer (weights)
+)
+
+// SharedAllocationConfig distributes the shared usage onto the consumers
+// for billing. Weights hold the quota or area per consumer ID.
+type SharedAllocationConfig struct {
+	Key     string             `yaml:"key"`
+	Weights map[string]float64 `yaml:"weights,omitempty"`
+}
+
+// HeatPumpConfig describes a heat pump consumer. With a coefficient of
+// performance, the report includes the heat output (electricity * COP).
+type HeatPumpConfig struct {
+	COP float64 `yaml:"cop,omitempty"`
+}
+
+// ConsumerGroup is a category of consumers with a subtotal in the report.
+// Consumers are referenced by ID, virtual consumers of split meters as
+// "<meter-id>/<name>" and the shared usage as "shared".
+type ConsumerGroup struct {
+	Name      string   `yaml:"name"`
+	Consumers []string `yaml:"consumers"`
+}
+
+// TenantConfig is a tenant of the unit metered by a consumer. The tenancy
+// runs from the move-in to the move-out day, both included; an empty date
+// leaves it open on that side.
+type TenantConfig struct {
+	Name     string `yaml:"name"`
+	Consumer string `yaml:"consumer"`
+	MoveIn   string `yaml:"moveIn,omitempty"`  // YYYY-MM-DD
+	MoveOut  string `yaml:"moveOut,omitempty"` // YYYY-MM-DD
+}
+
+// SplitShare is one virtual consumer of a split meter. Its share is either
+// a fixed ratio (all ratios of a meter add up to 1) or a floor area (the
+// usage is split in proportion to the areas).
+type SplitShare struct {
+	Name  string  `yaml:"name"`
+	Ratio float64 `yaml:"ratio,omitempty"`
+	Area  float64 `yaml:"area,omitempty"`
+}
+
+// OutlierConfig enables the statistical detection of implausible counter
+// jumps: a reading is rejected when it exceeds the median of the sensor's
+// recent readings by more than Threshold median absolute deviations
+type OutlierConfig struct {
+	Enabled        bool    `yaml:"enabled"`
+	Window         int     `yaml:"window,omitempty"`         // Readings in the rolling window, default 96 (one day)
+	Threshold      float64 `yaml:"threshold,omitempty"`      // Deviations above the median, default 10
+	MinDeviationWh float64 `yaml:"minDeviationWh,omitempty"` // Jumps closer to the median are never rejected, default 1000
+}
+
+// ReadingLimits holds the anomaly limit per sensor role in Wh per interval,
+// 0 keeps the built-in default
+type ReadingLimits struct {
+	Grid       float64 `yaml:"grid,omitempty"`
+	Production float64 `yaml:"production,omitempty"`
+	Consumer   float64 `yaml:"consumer,omitempty"`
+}
+
+const (
+	// SensorModeCounter derives energy from the sensor's energy counters
+	SensorModeCounter = "counter"
+	// SensorModePower integrates instantaneous power samples into energy
+	SensorModePower = "power"
+)
+
+// GridMeters returns the IDs of all grid meters: gridMeterId followed by
+// gridMeterIds, without duplicates
+func (z *ZEVConfig) GridMeters() []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range append([]string{z.GridMeterID}, z.GridMeterIDs...) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// IdleDays returns the number of days without activity after which a
+// battery counts as idle
+func (z *ZEVConfig) IdleDays() int {
+	if z.IdleBatteryDays == 0 {
+		return 2
+	}
+	return z.IdleBatteryDays
+}
+
+// PhaseLimit returns the phase spread in W above which a reading counts as
+// unbalanced
+func (z *ZEVConfig) PhaseLimit() float64 {
+	if z.PhaseLimitW == 0 {
+		return 3680
+	}
+	return z.PhaseLimitW
+}
+
+// SensorMode returns the configured data mode for a sensor, defaulting to counter
+func (z *ZEVConfig) SensorMode(sensorID string) string {
+	if mode, ok := z.SensorModes[sensorID]; ok && mode != "" {
+		return mode
+	}
+	return SensorModeCounter
+}
+
+// SpotPriceConfig points to a CSV file with dynamic (spot) energy prices.
+// Columns are zero based.
+type SpotPriceConfig struct {
+	File        string `yaml:"file"`
+	Unit        string `yaml:"unit,omitempty"`        // Price per "MWh" (default) or "kWh"
+	Currency    string `yaml:"currency,omitempty"`    // Label for reports, default CHF
+	TimeColumn  int    `yaml:"timeColumn,omitempty"`  // Column with the period start, default 0
+	PriceColumn int    `yaml:"priceColumn,omitempty"` // Column with the price, default 1
+	Delimiter   string `yaml:"delimiter,omitempty"`   // Field separator, default ","
+}
+
+// CurrencyLabel returns the configured currency, defaulting to CHF
+func (s *SpotPriceConfig) CurrencyLabel() string {
+	if s.Currency == "" {
+		return "CHF"
+	}
+	return s.Currency
+}
+
+// BillingConfig describes the utility's billing cycle
+type BillingConfig struct {
+	StartDay int `yaml:"startDay"` // Day of month a billing period starts (1-28), default 1
+	// Offset export against import per tariff before costing
+	NetMetering bool `yaml:"netMetering,omitempty"`
+}
+
+// PeriodStartDay returns the configured billing period start day, defaulting to 1
+func (b *BillingConfig) PeriodStartDay() int {
+	if b.StartDay == 0 {
+		return 1
+	}
+	return b.StartDay
+}
+
+// BillExportConfig lays out the CSV export of the consumer bills
+// (-bill-csv) for the import into property management or accounting
+// software
+type BillExportConfig struct {
+	Delimiter  string `yaml:"delimiter,omitempty"`  // Field separator, default ","
+	Decimal    string `yaml:"decimal,omitempty"`    // Decimal separator, default "."
+	DateFormat string `yaml:"dateFormat,omitempty"` // Go time layout, default 2006-01-02
+	// Columns in order, default every field under its own name
+	Columns []BillColumnConfig `yaml:"columns,omitempty"`
+}
+
+// BillColumnConfig maps a field of the consumer bills, or a fixed value,
+// to a column of the bill export
+type BillColumnConfig struct {
+	Header string `yaml:"header"`
+	Field  string `yaml:"field,omitempty"`
+	Value  string `yaml:"value,omitempty"` // fixed text instead of a field
+}
+
+// DateLayout returns the configured date format, defaulting to ISO dates
+func (b *BillExportConfig) DateLayout() string {
+	if b.DateFormat == "" {
+		return "2006-01-02"
+	}
+	return b.DateFormat
+}
+
+// LedgerConfig names the file that records the invoices of the consumer
+// bills (-book) and the prefix of their numbers
+type LedgerConfig struct {
+	File   string `yaml:"file"`
+	Prefix string `yaml:"prefix,omitempty"` // e.g. "ZEV-", default none
+}
+
+// AuditConfig names the key that signs the audit bundles (-audit)
+type AuditConfig struct {
+	SigningKey string `yaml:"signingKey"` // PEM file with an ed25519 private key
+}
+
+// SDATConfig sets up the export of load profiles for the grid operator
+// (-sdat)
+type SDATConfig struct {
+	Sender   string `yaml:"sender"`   // EIC or GLN of the ZEV
+	Receiver string `yaml:"receiver"` // EIC or GLN of the grid operator
+	// Metering point ID per consumer sensor ID or per grid-import,
+	// grid-export and production of the whole ZEV
+	MeteringPoints map[string]string `yaml:"meteringPoints"`
+}
+
+// ValidationConfig sets the tolerance of the per interval energy balance
+// check (-validate). An interval violates the balance when its outputs
+// exceed its inputs by more than the larger of both tolerances.
+type ValidationConfig struct {
+	ToleranceWh      float64 `yaml:"toleranceWh,omitempty"`      // default 50
+	TolerancePercent float64 `yaml:"tolerancePercent,omitempty"` // of the interval's energy, default 5
+	// Also flag inputs exceeding outputs, i.e. unmetered "shared" usage
+	CheckSurplus bool `yaml:"checkSurplus,omitempty"`
+}
+
+// Tolerance returns the allowed imbalance in Wh for an interval moving the
+// given amount of energy
+func (v *ValidationConfig) Tolerance(energyWh float64) float64 {
+	absolute, relative := v.ToleranceWh, v.TolerancePercent
+	if absolute == 0 {
+		absolute = 50
+	}
+	if relative == 0 {
+		relative = 5
+	}
+	return max(absolute, energyWh*relative/100)
+}
+
+// PriceConfig holds the energy prices per kWh used to value the analysis
+type PriceConfig struct {
+	Currency  string  `yaml:"currency,omitempty"`        // Label for reports, default CHF
+	GridHigh  float64 `yaml:"gridHighTariff"`            // Grid energy in the high tariff
+	GridLow   float64 `yaml:"gridLowTariff,omitempty"`   // Grid energy in the low tariff, default gridHighTariff
+	FeedIn    float64 `yaml:"feedIn,omitempty"`          // Remuneration of exported energy
+	FeedInLow float64 `yaml:"feedInLowTariff,omitempty"` // Remuneration in the low tariff, default feedIn
+	Capacity  float64 `yaml:"capacityTariff,omitempty"`  // Per kW of the monthly grid import peak
+	// Internal ZEV prices of the consumer bills
+	Solar            float64 `yaml:"solarTariff,omitempty"`      // PV energy sold to the consumers
+	BatterySurcharge float64 `yaml:"batterySurcharge,omitempty"` // Added to every kWh from the batteries
+	// Levies billed per kWh on top of the energy, e.g. grid fees
+	Surcharges []SurchargeConfig `yaml:"surcharges,omitempty"`
+	VATPercent float64           `yaml:"vatPercent,omitempty"` // Value added tax on the consumer bills
+	// Fixed fees per month, e.g. for metering and administration
+	BaseFees []BaseFeeConfig `yaml:"baseFees,omitempty"`
+	// Yearly amortization of the PV plant and the batteries
+	Amortization []SharedCostConfig `yaml:"amortization,omitempty"`
+	// Other yearly costs of the ZEV, e.g. maintenance or insurance
+	CommonCosts []SharedCostConfig `yaml:"commonCosts,omitempty"`
+	// Consumers whose bills the other consumers pay, e.g. the staircase
+	// lighting or the shared usage
+	CommonAreas []CommonAreaConfig `yaml:"commonAreas,omitempty"`
+	// Allocation keys the shared costs and common areas refer to by name
+	AllocationKeys []AllocationKeyConfig `yaml:"allocationKeys,omitempty"`
+	// Rounding of the consumer bills
+	Rounding RoundingConfig `yaml:"rounding,omitempty"`
+	// Billing guideline the consumer bills follow, GuidelineVSE or none
+	Guideline string `yaml:"guideline,omitempty"`
+	// Limit of the internal energy price under the guideline, in percent
+	// of the grid tariff, default 100
+	SolarCapPercent float64 `yaml:"solarCapPercent,omitempty"`
+	// New tariffs from a day on, in time order
+	Changes []PriceChangeConfig `yaml:"changes,omitempty"`
+}
+
+// PriceChangeConfig sets new tariffs from the start of a day on. Prices
+// left out stay as they were.
+type PriceChangeConfig struct {
+	From             string   `yaml:"from"` // YYYY-MM-DD
+	GridHigh         *float64 `yaml:"gridHighTariff,omitempty"`
+	GridLow          *float64 `yaml:"gridLowTariff,omitempty"`
+	FeedIn           *float64 `yaml:"feedIn,omitempty"`
+	FeedInLow        *float64 `yaml:"feedInLowTariff,omitempty"`
+	Capacity         *float64 `yaml:"capacityTariff,omitempty"`
+	Solar            *float64 `yaml:"solarTariff,omitempty"`
+	BatterySurcharge *float64 `yaml:"batterySurcharge,omitempty"`
+}
+
+// Start returns the first moment the change applies, in location. The
+// date is checked by Load.
+func (c *PriceChangeConfig) Start(location *time.Location) time.Time {
+	start, _ := time.ParseInLocation("2006-01-02", c.From, location)
+	return start
+}
+
+// At returns the prices in force at t, with the changes up to t applied
+func (p *PriceConfig) At(t time.Time) PriceConfig {
+	prices := *p
+	for _, change := range p.Changes {
+		if change.Start(t.Location()).After(t) {
+			break
+		}
+		for _, price := range []struct {
+			value  *float64
+			target *float64
+		}{
+			{change.GridHigh, &prices.GridHigh},
+			{change.GridLow, &prices.GridLow},
+			{change.FeedIn, &prices.FeedIn},
+			{change.FeedInLow, &prices.FeedInLow},
+			{change.Capacity, &prices.Capacity},
+			{change.Solar, &prices.Solar},
+			{change.BatterySurcharge, &prices.BatterySurcharge},
+		} {
+			if price.value != nil {
+				*price.target = *price.value
+			}
+		}
+	}
+	return prices
+}
+
+// ChangesWithin returns the starts of the price changes within (from, to),
+// in time order
+func (p *PriceConfig) ChangesWithin(from, to time.Time) []time.Time {
+	var starts []time.Time
+	for _, change := range p.Changes {
+		if start := change.Start(from.Location()); start.After(from) && start.Before(to) {
+			starts = append(starts, start)
+		}
+	}
+	return starts
+}
+
+// RoundingConfig sets the steps the consumer bills round to
+type RoundingConfig struct {
+	Total float64 `yaml:"total,omitempty"` // Bill totals, e.g. 0.05, default 0.01
+	KWh   float64 `yaml:"kwh,omitempty"`   // Billed energy, e.g. 0.1 or 1, default unrounded
+}
+
+// TotalStep returns the step of the bill totals, defaulting to cents
+func (r *RoundingConfig) TotalStep() float64 {
+	if r.Total == 0 {
+		return 0.01
+	}
+	return r.Total
+}
+
+// GuidelineVSE follows the recommendation of the Swiss electricity
+// industry association (VSE/AES) for the internal billing of a ZEV
+const GuidelineVSE = "vse"
+
+// SolarCap returns the limit of the internal energy price in percent of
+// the grid tariff, defaulting to 100
+func (p *PriceConfig) SolarCap() float64 {
+	if p.SolarCapPercent == 0 {
+		return 100
+	}
+	return p.SolarCapPercent
+}
+
+// SharedCostConfig spreads a yearly cost, e.g. the amortization of the PV
+// plant, onto the consumer bills as an item of its own. The key is the
+// name of one of the allocationKeys or a key that works with the weights
+// as for the shared usage (see SharedAllocationConfig); the default key is
+// consumption.
+type SharedCostConfig struct {
+	Name    string             `yaml:"name"`
+	PerYear float64            `yaml:"perYear"`
+	Key     string             `yaml:"key,omitempty"`
+	Weights map[string]float64 `yaml:"weights,omitempty"`
+}
+
+// CommonAreaConfig passes the bill of a consumer on to the other consumers
+// by an allocation key as for SharedCostConfig. The consumer is referenced
+// by ID, the shared usage as "shared".
+type CommonAreaConfig struct {
+	Consumer string             `yaml:"consumer"`
+	Key      string             `yaml:"key,omitempty"`
+	Weights  map[string]float64 `yaml:"weights,omitempty"`
+}
+
+// AllocationKeyConfig is a named allocation key of the consumer bills
+type AllocationKeyConfig struct {
+	Name    string             `yaml:"name"`
+	Key     string             `yaml:"key"`
+	Weights map[string]float64 `yaml:"weights,omitempty"`
+}
+
+// Allocation resolves the key of a shared cost or common area: the named
+// allocation key, or the key itself with the given weights, defaulting to
+// consumption
+func (p *PriceConfig) Allocation(key string, weights map[string]float64) SharedAllocationConfig {
+	for _, named := range p.AllocationKeys {
+		if named.Name == key {
+			return SharedAllocationConfig{Key: named.Key, Weights: named.Weights}
+		}
+	}
+	if key == "" {
+		key = AllocationConsumption
+	}
+	return SharedAllocationConfig{Key: key, Weights: weights}
+}
+
+// BaseFeeConfig is a fixed monthly fee the consumer bills charge as an item
+// of its own. It applies to the listed consumers and the consumers of the
+// listed groups, or to every consumer when both lists are empty.
+type BaseFeeConfig struct {
+	Name      string   `yaml:"name"`
+	PerMonth  float64  `yaml:"perMonth"`
+	Consumers []string `yaml:"consumers,omitempty"`
+	Groups    []string `yaml:"groups,omitempty"`
+}
+
+// SurchargeConfig is a levy the consumer bills charge per kWh as an item of
+// its own
+type SurchargeConfig struct {
+	Name     string  `yaml:"name"`
+	PerKWh   float64 `yaml:"perKwh"`
+	GridOnly bool    `yaml:"gridOnly,omitempty"` // Only on energy drawn from the grid, default all energy
+}
+
+// Enabled reports whether grid prices are configured
+func (p *PriceConfig) Enabled() bool {
+	return p.GridHigh > 0
+}
+
+// GridLowPrice returns the low tariff grid price, defaulting to the high
+// tariff price for utilities without a low tariff
+func (p *PriceConfig) GridLowPrice() float64 {
+	if p.GridLow == 0 {
+		return p.GridHigh
+	}
+	return p.GridLow
+}
+
+// FeedInPrice returns the feed-in remuneration in the given tariff
+func (p *PriceConfig) FeedInPrice(lowTariff bool) float64 {
+	if lowTariff && p.FeedInLow != 0 {
+		return p.FeedInLow
+	}
+	return p.FeedIn
+}
+
+// Billing reports whether the consumer bills are enabled, which takes a
+// solar tariff
+func (p *PriceConfig) Billing() bool {
+	return p.Solar > 0
+}
+
+// CurrencyLabel returns the configured currency, defaulting to CHF
+func (p *PriceConfig) CurrencyLabel() string {
+	if p.Currency == "" {
+		return "CHF"
+	}
+	return p.Currency
+}
+
+// DefaultWeatherURL is the historical weather API of Open-Meteo
+const DefaultWeatherURL = "https://archive-api.open-meteo.com/v1/archive"
+
+// WeatherConfig locates the PV plant for fetching the daily solar
+// irradiation that normalizes the production in the trend report
+type WeatherConfig struct {
+	Latitude  float64 `yaml:"latitude"`
+	Longitude float64 `yaml:"longitude"`
+	URL       string  `yaml:"url,omitempty"` // Open-Meteo compatible archive API, default DefaultWeatherURL
 }
+
+// Enabled reports whether a location is configured
+func (w *WeatherConfig) Enabled() bool {
+	return w.Latitude != 0 || w.Longitude != 0
+}
+
+// ArchiveURL returns the configured API URL, defaulting to Open-Meteo
+func (w *WeatherConfig) ArchiveURL() string {
+	if w.URL == "" {
+		return DefaultWeatherURL
+	}
+	return w.URL
+}
+
+// Providers of the PV forecast compared with the measured production
+const (
+	ForecastFile        = "file"        // CSV with the forecast kWh per day
+	ForecastIrradiation = "irradiation" // measured irradiation times peak power and performance ratio
+)
+
+// ForecastConfig selects the PV forecast for the forecast comparison
+type ForecastConfig struct {
+	Provider  string `yaml:"provider"`
+	File      string `yaml:"file,omitempty"`      // file provider: date and kWh per row
+	Delimiter string `yaml:"delimiter,omitempty"` // file provider: field separator, default ","
+	// irradiation provider: share of the irradiation on the peak power
+	// that reaches the meter, default DefaultPerformanceRatio
+	PerformanceRatio float64 `yaml:"performanceRatio,omitempty"`
+	// Days producing this many percent below the forecast are flagged,
+	// default DefaultShortfallPercent
+	ShortfallPercent float64 `yaml:"shortfallPercent,omitempty"`
+}
+
+// Defaults of the forecast comparison
+const (
+	DefaultPerformanceRatio = 0.8
+	DefaultShortfallPercent = 30
+)
+
+// Ratio returns the configured performance ratio or its default
+func (f *ForecastConfig) Ratio() float64 {
+	if f.PerformanceRatio == 0 {
+		return DefaultPerformanceRatio
+	}
+	return f.PerformanceRatio
+}
+
+// Shortfall returns the configured shortfall threshold or its default
+func (f *ForecastConfig) Shortfall() float64 {
+	if f.ShortfallPercent == 0 {
+		return DefaultShortfallPercent
+	}
+	return f.ShortfallPercent
+}
+
+// EmissionFactors are the CO₂ emissions per source in g/kWh. Battery is
+// added to all energy delivered by the battery, for its storage losses.
+type EmissionFactors struct {
+	Grid    float64 `yaml:"gridGPerKwh"`
+	PV      float64 `yaml:"pvGPerKwh"`
+	Battery float64 `yaml:"batteryGPerKwh,omitempty"`
+}
+
+// Enabled reports whether any emission factor is configured
+func (e *EmissionFactors) Enabled() bool {
+	return e.Grid > 0 || e.PV > 0 || e.Battery > 0
+}
+
+// PluginConfig describes an external report generator. The command receives
+// the analysis result as JSON on stdin.
+type PluginConfig struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+}
+
 type Config struct {
-	API       APIConfig       `yaml:"api"`
-	LowTariff LowTariffConfig `yaml:"lowTariff"`
-	ZEV       ZEVConfig       `yaml:"zev,omitempty"`
-	Debug     bool
+	API        APIConfig               `yaml:"api"`
+	LowTariff  LowTariffConfig         `yaml:"lowTariff"`
+	ZEV        ZEVConfig               `yaml:"zev,omitempty"`
+	Billing    BillingConfig           `yaml:"billing,omitempty"`
+	Spot       SpotPriceConfig         `yaml:"spotPrices,omitempty"`
+	Validation ValidationConfig        `yaml:"validation,omitempty"`
+	Emissions  EmissionFactors         `yaml:"emissions,omitempty"`
+	Prices     PriceConfig             `yaml:"prices,omitempty"`
+	BillExport BillExportConfig        `yaml:"billExport,omitempty"`
+	SDAT       SDATConfig              `yaml:"sdat,omitempty"`
+	Ledger     LedgerConfig            `yaml:"ledger,omitempty"`
+	Audit      AuditConfig             `yaml:"audit,omitempty"`
+	Weather    WeatherConfig           `yaml:"weather,omitempty"`
+	Forecast   ForecastConfig          `yaml:"forecast,omitempty"`
+	Plugins    map[string]PluginConfig `yaml:"plugins,omitempty"`
+	Debug      bool
+	Quiet      bool // suppress informational messages
 }
 
 func Load(filename string) (*Config, error) {
@@ -44,5 +835,157 @@ func Load(filename string) (*Config, error) {
 		return nil, fmt.Errorf("parsing yaml: %v", err)
 	}
 
+	if c.API.Token != "" && (c.API.Username != "" || c.API.Password != "") {
+		return nil, fmt.Errorf("%w: api token replaces username and password, set only one of them", ErrInvalid)
+	}
+	if c.API.OAuth.Enabled() {
+		if tokenURL, err := url.Parse(c.API.OAuth.TokenURL); err != nil || (tokenURL.Scheme != "https" && tokenURL.Scheme != "http") || tokenURL.Host == "" {
+			return nil, fmt.Errorf("%w: api oauth tokenUrl %q must be an http(s) URL", ErrInvalid, c.API.OAuth.TokenURL)
+		}
+		if c.API.Token != "" {
+			return nil, fmt.Errorf("%w: api token and oauth exclude each other", ErrInvalid)
+		}
+		if c.API.Username == "" && c.API.OAuth.TokenFile == "" {
+			return nil, fmt.Errorf("%w: api oauth needs username and password or a tokenFile", ErrInvalid)
+		}
+	}
+	if (c.API.TLS.CertFile == "") != (c.API.TLS.KeyFile == "") {
+		return nil, fmt.Errorf("%w: api tls certFile and keyFile go together", ErrInvalid)
+	}
+	if c.API.APIKeyHeader != "" && (c.API.APIKey == "" || strings.ContainsAny(c.API.APIKeyHeader, " \t:")) {
+		return nil, fmt.Errorf("%w: api apiKeyHeader %q needs an apiKey and must be a header name", ErrInvalid, c.API.APIKeyHeader)
+	}
+	if c.API.MaxRetries() < 0 || c.API.RetryDelaySeconds < 0 {
+		return nil, fmt.Errorf("%w: api retries and retryDelaySeconds must not be negative", ErrInvalid)
+	}
+	if c.API.ConnectTimeoutSeconds < 0 || c.API.TimeoutSeconds < 0 {
+		return nil, fmt.Errorf("%w: api connectTimeoutSeconds and timeoutSeconds must not be negative", ErrInvalid)
+	}
+	if c.API.ChunkDays < 0 || c.API.MaxChunkDays < 0 {
+		return nil, fmt.Errorf("%w: api chunkDays and maxChunkDays must not be negative", ErrInvalid)
+	}
+	if c.API.ChunkDaysLimit() < c.API.InitialChunkDays() {
+		return nil, fmt.Errorf("%w: api maxChunkDays %d must not be below chunkDays %d", ErrInvalid, c.API.MaxChunkDays, c.API.InitialChunkDays())
+	}
+	if c.Validation.ToleranceWh < 0 || c.Validation.TolerancePercent < 0 {
+		return nil, fmt.Errorf("%w: validation tolerances must not be negative", ErrInvalid)
+	}
+	if c.Prices.GridHigh < 0 || c.Prices.GridLow < 0 || c.Prices.FeedIn < 0 || c.Prices.FeedInLow < 0 || c.Prices.Capacity < 0 ||
+		c.Prices.Solar < 0 || c.Prices.BatterySurcharge < 0 {
+		return nil, fmt.Errorf("%w: prices must not be negative", ErrInvalid)
+	}
+	var previous time.Time
+	for _, change := range c.Prices.Changes {
+		start, err := time.ParseInLocation("2006-01-02", change.From, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%w: prices changes: from %q must be YYYY-MM-DD", ErrInvalid, change.From)
+		}
+		if !start.After(previous) {
+			return nil, fmt.Errorf("%w: prices changes: %s must come after the change before it", ErrInvalid, change.From)
+		}
+		previous = start
+		for _, price := range []*float64{change.GridHigh, change.GridLow, change.FeedIn, change.FeedInLow, change.Capacity, change.Solar, change.BatterySurcharge} {
+			if price != nil && *price < 0 {
+				return nil, fmt.Errorf("%w: prices changes: prices from %s must not be negative", ErrInvalid, change.From)
+			}
+		}
+	}
+	if c.Prices.VATPercent < 0 || c.Prices.VATPercent > 100 {
+		return nil, fmt.Errorf("%w: vatPercent %.2f must be between 0 and 100", ErrInvalid, c.Prices.VATPercent)
+	}
+	for _, surcharge := range c.Prices.Surcharges {
+		if surcharge.Name == "" {
+			return nil, fmt.Errorf("%w: surcharges need a name", ErrInvalid)
+		}
+		if surcharge.PerKWh < 0 {
+			return nil, fmt.Errorf("%w: surcharge %s must not be negative", ErrInvalid, surcharge.Name)
+		}
+	}
+	for _, fee := range c.Prices.BaseFees {
+		if fee.Name == "" {
+			return nil, fmt.Errorf("%w: baseFees need a name", ErrInvalid)
+		}
+		if fee.PerMonth < 0 {
+			return nil, fmt.Errorf("%w: base fee %s must not be negative", ErrInvalid, fee.Name)
+		}
+	}
+	for _, cost := range append(append([]SharedCostConfig{}, c.Prices.Amortization...), c.Prices.CommonCosts...) {
+		if cost.Name == "" {
+			return nil, fmt.Errorf("%w: amortization and commonCosts entries need a name", ErrInvalid)
+		}
+		if cost.PerYear < 0 {
+			return nil, fmt.Errorf("%w: cost %s must not be negative", ErrInvalid, cost.Name)
+		}
+	}
+	if total := c.Prices.Rounding.Total; total != 0 && (total < 0.01 || math.Abs(total*100-math.Round(total*100)) > 1e-9) {
+		return nil, fmt.Errorf("%w: rounding total %g must be a multiple of 0.01", ErrInvalid, total)
+	}
+	if c.Prices.Rounding.KWh < 0 {
+		return nil, fmt.Errorf("%w: rounding kwh must not be negative", ErrInvalid)
+	}
+	if c.Prices.Guideline != "" && c.Prices.Guideline != GuidelineVSE {
+		return nil, fmt.Errorf("%w: guideline %q must be %s or empty", ErrInvalid, c.Prices.Guideline, GuidelineVSE)
+	}
+	if c.Prices.SolarCapPercent < 0 || c.Prices.SolarCapPercent > 100 {
+		return nil, fmt.Errorf("%w: solarCapPercent %.2f must be between 0 and 100", ErrInvalid, c.Prices.SolarCapPercent)
+	}
+	keys := make(map[string]bool)
+	for _, key := range c.Prices.AllocationKeys {
+		if key.Name == "" || keys[key.Name] {
+			return nil, fmt.Errorf("%w: allocationKeys need distinct names", ErrInvalid)
+		}
+		keys[key.Name] = true
+	}
+	if c.ZEV.PhaseLimitW < 0 {
+		return nil, fmt.Errorf("%w: phaseLimitW must not be negative", ErrInvalid)
+	}
+	if c.ZEV.IdleBatteryDays < 0 {
+		return nil, fmt.Errorf("%w: idleBatteryDays must not be negative", ErrInvalid)
+	}
+	if c.Emissions.Grid < 0 || c.Emissions.PV < 0 || c.Emissions.Battery < 0 {
+		return nil, fmt.Errorf("%w: emission factors must not be negative", ErrInvalid)
+	}
+	if c.Weather.Latitude < -90 || c.Weather.Latitude > 90 || c.Weather.Longitude < -180 || c.Weather.Longitude > 180 {
+		return nil, fmt.Errorf("%w: weather location %.4f, %.4f is not a valid latitude and longitude", ErrInvalid, c.Weather.Latitude, c.Weather.Longitude)
+	}
+	switch c.Forecast.Provider {
+	case "":
+	case ForecastFile:
+		if c.Forecast.File == "" {
+			return nil, fmt.Errorf("%w: forecast provider %q needs a file", ErrInvalid, ForecastFile)
+		}
+	case ForecastIrradiation:
+		if !c.Weather.Enabled() || len(c.ZEV.PeakPowerKW) == 0 {
+			return nil, fmt.Errorf("%w: forecast provider %q needs a weather location and the peakPowerKw of the plants", ErrInvalid, ForecastIrradiation)
+		}
+	default:
+		return nil, fmt.Errorf("%w: forecast provider %q must be %q or %q", ErrInvalid, c.Forecast.Provider, ForecastFile, ForecastIrradiation)
+	}
+	if c.Forecast.PerformanceRatio < 0 || c.Forecast.PerformanceRatio > 1 {
+		return nil, fmt.Errorf("%w: forecast performanceRatio %.2f must be between 0 and 1", ErrInvalid, c.Forecast.PerformanceRatio)
+	}
+	if c.Forecast.ShortfallPercent < 0 || c.Forecast.ShortfallPercent > 100 {
+		return nil, fmt.Errorf("%w: forecast shortfallPercent %.1f must be between 0 and 100", ErrInvalid, c.Forecast.ShortfallPercent)
+	}
+	export := c.BillExport
+	if len([]rune(export.Delimiter)) > 1 || len([]rune(export.Decimal)) > 1 {
+		return nil, fmt.Errorf("%w: billExport delimiter and decimal must be single characters", ErrInvalid)
+	}
+	if delimiter := export.Delimiter; export.Decimal != "" && (export.Decimal == delimiter || delimiter == "" && export.Decimal == ",") {
+		return nil, fmt.Errorf("%w: billExport delimiter and decimal must differ", ErrInvalid)
+	}
+	for _, column := range export.Columns {
+		if column.Header == "" {
+			return nil, fmt.Errorf("%w: billExport: every column needs a header", ErrInvalid)
+		}
+		if column.Field != "" && column.Value != "" {
+			return nil, fmt.Errorf("%w: billExport: column %q has both a field and a value", ErrInvalid, column.Header)
+		}
+	}
+	// Day 29 and later do not exist in every month
+	if c.Billing.StartDay < 0 || c.Billing.StartDay > 28 {
+		return nil, fmt.Errorf("%w: billing startDay must be between 1 and 28, got %d", ErrInvalid, c.Billing.StartDay)
+	}
+
 	return c, nil
 }
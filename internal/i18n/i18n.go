@@ -0,0 +1,678 @@
+// internal/i18n/i18n.go
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// English is the default language; its messages are the catalog keys
+const English = "en"
+
+var current = English
+
+// catalogs maps language -> English message -> translation
+var catalogs = map[string]map[string]string{
+	"de": {
+		"Energy Analysis for period: %s to %s": "Energieanalyse für den Zeitraum: %s bis %s",
+		"Energy Analysis":                      "Energieanalyse",
+		"High Tariff Energy":                   "Energie Hochtarif",
+		"Low Tariff Energy":                    "Energie Niedertarif",
+		"Total Energy":                         "Energie total",
+		"System Overview":                      "Systemübersicht",
+		"Grid Import":                          "Netzbezug",
+		"Grid Export":                          "Netzeinspeisung",
+		"Production":                           "Produktion",
+		"Consumption":                          "Verbrauch",
+		"Battery Charge":                       "Batterieladung",
+		"Battery Discharge":                    "Batterieentladung",
+		"Self Consumption":                     "Eigenverbrauch",
+		"Autarchy":                             "Autarkie",
+		"Energy Balance":                       "Energiebilanz",
+		"Total Input":                          "Total Zufluss",
+		"Total Output":                         "Total Abfluss",
+		"Difference":                           "Differenz",
+		"Consumer Details":                     "Verbraucher",
+		"Name":                                 "Name",
+		"Total":                                "Total",
+		"Inverter":                             "Solarstrom",
+		"Battery":                              "Batterie",
+		"Grid":                                 "Netz",
+		"Share":                                "Anteil",
+		"Solar":                                "Solar",
+		"Batt.":                                "Batt.",
+		"Shared Usage":                         "Allgemeinstrom",
+		"Other":                                "Übrige",
+		"Overview":                             "Übersicht",
+		"High Tariff":                          "Hochtarif",
+		"Low Tariff":                           "Niedertarif",
+		"Consumer":                             "Verbraucher",
+		"Date":                                 "Datum",
+		"weekends":                             "Wochenende",
+		"holidays":                             "Feiertage",
+		"outside low tariff":                   "ausserhalb Niedertarif",
+		"Mon":                                  "Mo",
+		"Tue":                                  "Di",
+		"Wed":                                  "Mi",
+		"Thu":                                  "Do",
+		"Fri":                                  "Fr",
+		"Sat":                                  "Sa",
+		"Sun":                                  "So",
+		"Spot Price Valuation":                 "Bewertung zu Spotpreisen",
+		"Grid Import Cost":                     "Kosten Netzbezug",
+		"Grid Export Revenue":                  "Erlös Einspeisung",
+		"Average Import Price":                 "Mittlerer Bezugspreis",
+		"Warning: %d intervals without spot price were not valued": "Warnung: %d Intervalle ohne Spotpreis wurden nicht bewertet",
+		"Trend":                                "Verlauf",
+		"Consumers":                            "Verbraucher",
+		"Period":                               "Periode",
+		"Self":                                 "Eigen",
+		"Comparison of %s to %s with %s to %s": "Vergleich %s bis %s mit %s bis %s",
+		"Current":                              "Aktuell",
+		"Baseline":                             "Basis",
+		"Change":                               "Änderung",
+		"Year over Year":                       "Jahresvergleich",
+		"Month":                                "Monat",
+		"Data Completeness":                    "Datenvollständigkeit",
+		"grid":                                 "Netz",
+		"production":                           "Produktion",
+		"battery":                              "Batterie",
+		"consumer":                             "Verbraucher",
+		"... %d more days with gaps":           "... %d weitere Tage mit Lücken",
+		"%d intervals missing":                 "%d Intervalle fehlen",
+		"Rejected Outliers":                    "Verworfene Ausreisser",
+		"%d readings, %.1f kWh excluded":       "%d Messwerte, %.1f kWh ausgeschlossen",
+		"purchase":                             "Bezug",
+		"delivery":                             "Lieferung",
+		"Energy Balance Validation":            "Prüfung der Energiebilanz",
+		"%d of %d intervals outside tolerance": "%d von %d Intervallen ausserhalb der Toleranz",
+		"... %d more intervals":                "... %d weitere Intervalle",
+		"Input":                                "Eingang",
+		"Output":                               "Ausgang",
+		"Solar Coverage per Consumer":          "Solare Deckung pro Verbraucher",
+		"Production Share":                     "Anteil Produktion",
+		"Grid Import Peaks":                    "Bezugsspitzen",
+		"Monthly Maximum":                      "Monatsmaximum",
+		"Daily Load Profile (kW)":              "Tageslastprofil (kW)",
+		"Hour":                                 "Stunde",
+		"Standby Load":                         "Grundlast",
+		"kWh per year":                         "kWh pro Jahr",
+		"  from Grid":                          "  aus dem Netz",
+		"Battery Energy by Origin":             "Batterieenergie nach Herkunft",
+		"Battery State of Charge":              "Ladezustand der Batterie",
+		"Minimum":                              "Minimum",
+		"Maximum":                              "Maximum",
+		"Average":                              "Durchschnitt",
+		"Full Cycles":                          "Vollzyklen",
+		"Battery Systems":                      "Batteriesysteme",
+		"Charge":                               "Ladung",
+		"Discharge":                            "Entladung",
+		"Efficiency":                           "Wirkungsgrad",
+		"Cycles":                               "Zyklen",
+		"Grid Meters":                          "Netzzähler",
+		"Consumer Groups":                      "Verbrauchergruppen",
+		"Ungrouped":                            "Ohne Gruppe",
+		"Shared usage allocated by":            "Gemeinschaftsverbrauch verteilt nach",
+		"equal":                                "gleichmässig",
+		"quota":                                "Wertquote",
+		"consumption":                          "Verbrauch",
+		"area":                                 "Fläche",
+		"Shared usage attributed per interval in proportion to consumption": "Gemeinschaftsverbrauch pro Intervall im Verhältnis zum Verbrauch zugeteilt",
+		"EV Charging Sessions":           "Ladevorgänge Elektrofahrzeuge",
+		"sessions":                       "Ladevorgänge",
+		"solar":                          "Solar",
+		"Heat Pumps":                     "Wärmepumpen",
+		"Winter":                         "Winter",
+		"Spring":                         "Frühling",
+		"Summer":                         "Sommer",
+		"Autumn":                         "Herbst",
+		"Heat Output":                    "Wärmeabgabe",
+		"Daily Detail":                   "Tagesdetail",
+		"LT":                             "NT",
+		"HT":                             "HT",
+		"Peak":                           "Spitze",
+		"Unaccounted Energy":             "Nicht zugeordnete Energie",
+		"All intervals within tolerance": "Alle Intervalle innerhalb der Toleranz",
+		"import":                         "Bezug",
+		"export":                         "Einspeisung",
+		"net":                            "netto",
+		"charge":                         "Ladung",
+		"discharge":                      "Entladung",
+		"usage":                          "Verbrauch",
+		"Producers":                      "Produzenten",
+		"Export":                         "Einspeisung",
+		"Self-used":                      "Eigenverbrauch",
+		"Revenue":                        "Ertrag",
+		"Net Import":                     "Nettobezug",
+		"Net Export":                     "Nettoeinspeisung",
+		"CO₂ Emissions":                  "CO₂-Emissionen",
+		"Grid only":                      "Nur Netzbezug",
+		"Savings vs. Grid Only":          "Einsparung gegenüber reinem Netzbezug",
+		"Savings":                        "Einsparung",
+		"Feed-in Revenue":                "Einspeisevergütung",
+		"Feed-in":                        "Vergütung",
+		"Battery Simulation":             "Batteriesimulation",
+		"Scenario":                       "Szenario",
+		"No battery":                     "Ohne Batterie",
+		"Installed":                      "Installiert",
+		"Avoided":                        "Vermieden",
+		"Peak Shaving to":                "Lastspitzenkappung auf",
+		"Power":                          "Leistung",
+		"Capacity":                       "Kapazität",
+		"Shift":                          "Verschieben",
+		"PV Degradation":                 "PV-Degradation",
+		"Year":                           "Jahr",
+		"Specific Yield":                 "Spez. Ertrag",
+		"Months":                         "Monate",
+		"Index":                          "Index",
+		"per year":                       "pro Jahr",
+		"Irradiation":                    "Einstrahlung",
+		"Weather":                        "Wetterber.",
+		"Forecast Comparison":            "Prognosevergleich",
+		"Forecast":                       "Prognose",
+		"Actual":                         "Ist",
+		"Deviation":                      "Abweichung",
+		"%d of %d days more than %.0f%% below forecast": "%d von %d Tagen mehr als %.0f%% unter der Prognose",
+		"Anomalies":                          "Auffälligkeiten",
+		"No anomalies found":                 "Keine Auffälligkeiten gefunden",
+		"info":                               "Info",
+		"warning":                            "Warnung",
+		"critical":                           "Kritisch",
+		"Consumers used more than came in":   "Verbraucher bezogen mehr als zufloss",
+		"Meter stuck at zero":                "Zähler steht auf null",
+		"Several readings for the same time": "Mehrere Messwerte für denselben Zeitpunkt",
+		"Battery charged from the grid while exporting": "Batterie lud aus dem Netz während der Einspeisung",
+		"intervals":                  "Intervalle",
+		"readings":                   "Messwerte",
+		"Sensor Health":              "Sensorzustand",
+		"Sensor":                     "Sensor",
+		"Role":                       "Rolle",
+		"Activity":                   "Aktivität",
+		"Last Data":                  "Letzte Daten",
+		"Complete":                   "Vollständig",
+		"Gaps":                       "Lücken",
+		"Longest":                    "Längste",
+		"Status":                     "Status",
+		"ok":                         "ok",
+		"gaps":                       "Lücken",
+		"stale":                      "veraltet",
+		"dead":                       "tot",
+		"grid meter tariff counters": "Tarifzählwerke des Netzzählers",
+		"Phase Imbalance":            "Phasenasymmetrie",
+		"Average Spread":             "Mittlere Differenz",
+		"Maximum Spread":             "Grösste Differenz",
+		"Unbalanced":                 "Asymmetrisch",
+		"Power estimated from the phase currents at 230 V": "Leistung aus den Phasenströmen bei 230 V geschätzt",
+		"Live Power":               "Aktuelle Leistung",
+		"Resolution: %d seconds":   "Auflösung: %d Sekunden",
+		"Grid Bill":                "Netzrechnung",
+		"Energy":                   "Energie",
+		"Consumer Bills":           "Rechnungen der Bezüger",
+		"Solar energy":             "Solarstrom",
+		"Battery energy":           "Batteriestrom",
+		"Grid energy, high tariff": "Netzstrom, Hochtarif",
+		"Grid energy, low tariff":  "Netzstrom, Niedertarif",
+		"VAT":                      "MWST",
+		"Net":                      "Netto",
+		"mo.":                      "Mt.",
+		"Passed on":                "Weiterverrechnet",
+		"VSE guideline cap":        "Begrenzung nach VSE",
+		"Rounding":                 "Rundung",
+		"Invoice %s":               "Rechnung %s",
+		"Invoice %s, corrects %s":  "Rechnung %s, korrigiert %s",
+		"Prices from %s":           "Preise ab %s",
+	},
+	"fr": {
+		"Energy Analysis for period: %s to %s": "Analyse énergétique pour la période : %s à %s",
+		"Energy Analysis":                      "Analyse énergétique",
+		"High Tariff Energy":                   "Énergie tarif haut",
+		"Low Tariff Energy":                    "Énergie tarif bas",
+		"Total Energy":                         "Énergie totale",
+		"System Overview":                      "Vue d'ensemble du système",
+		"Grid Import":                          "Soutirage réseau",
+		"Grid Export":                          "Injection réseau",
+		"Production":                           "Production",
+		"Consumption":                          "Consommation",
+		"Battery Charge":                       "Charge batterie",
+		"Battery Discharge":                    "Décharge batterie",
+		"Self Consumption":                     "Autoconsommation",
+		"Autarchy":                             "Autarcie",
+		"Energy Balance":                       "Bilan énergétique",
+		"Total Input":                          "Total entrées",
+		"Total Output":                         "Total sorties",
+		"Difference":                           "Différence",
+		"Consumer Details":                     "Détail des consommateurs",
+		"Name":                                 "Nom",
+		"Total":                                "Total",
+		"Inverter":                             "Onduleur",
+		"Battery":                              "Batterie",
+		"Grid":                                 "Réseau",
+		"Share":                                "Part",
+		"Solar":                                "Solaire",
+		"Batt.":                                "Batt.",
+		"Shared Usage":                         "Consommation commune",
+		"Other":                                "Autres",
+		"Overview":                             "Vue d'ensemble",
+		"High Tariff":                          "Tarif haut",
+		"Low Tariff":                           "Tarif bas",
+		"Consumer":                             "Consommateur",
+		"Date":                                 "Date",
+		"weekends":                             "week-ends",
+		"holidays":                             "jours fériés",
+		"outside low tariff":                   "hors tarif bas",
+		"Mon":                                  "lun",
+		"Tue":                                  "mar",
+		"Wed":                                  "mer",
+		"Thu":                                  "jeu",
+		"Fri":                                  "ven",
+		"Sat":                                  "sam",
+		"Sun":                                  "dim",
+		"Spot Price Valuation":                 "Valorisation au prix spot",
+		"Grid Import Cost":                     "Coût soutirage",
+		"Grid Export Revenue":                  "Revenu injection",
+		"Average Import Price":                 "Prix moyen soutirage",
+		"Warning: %d intervals without spot price were not valued": "Attention : %d intervalles sans prix spot n'ont pas été valorisés",
+		"Trend":                                "Évolution",
+		"Consumers":                            "Consommateurs",
+		"Period":                               "Période",
+		"Self":                                 "Autocons.",
+		"Comparison of %s to %s with %s to %s": "Comparaison du %s au %s avec le %s au %s",
+		"Current":                              "Actuel",
+		"Baseline":                             "Référence",
+		"Change":                               "Variation",
+		"Year over Year":                       "Comparaison annuelle",
+		"Month":                                "Mois",
+		"Data Completeness":                    "Complétude des données",
+		"grid":                                 "réseau",
+		"production":                           "production",
+		"battery":                              "batterie",
+		"consumer":                             "consommateur",
+		"... %d more days with gaps":           "... %d autres jours avec lacunes",
+		"%d intervals missing":                 "%d intervalles manquants",
+		"Rejected Outliers":                    "Valeurs aberrantes rejetées",
+		"%d readings, %.1f kWh excluded":       "%d relevés, %.1f kWh exclus",
+		"purchase":                             "soutirage",
+		"delivery":                             "injection",
+		"Energy Balance Validation":            "Validation du bilan énergétique",
+		"%d of %d intervals outside tolerance": "%d sur %d intervalles hors tolérance",
+		"... %d more intervals":                "... %d autres intervalles",
+		"Input":                                "Entrée",
+		"Output":                               "Sortie",
+		"Solar Coverage per Consumer":          "Couverture solaire par consommateur",
+		"Production Share":                     "Part de production",
+		"Grid Import Peaks":                    "Pointes de soutirage",
+		"Monthly Maximum":                      "Maximum mensuel",
+		"Daily Load Profile (kW)":              "Profil de charge journalier (kW)",
+		"Hour":                                 "Heure",
+		"Standby Load":                         "Charge de veille",
+		"kWh per year":                         "kWh par an",
+		"  from Grid":                          "  depuis le réseau",
+		"Battery Energy by Origin":             "Énergie de batterie par origine",
+		"Battery State of Charge":              "État de charge de la batterie",
+		"Minimum":                              "Minimum",
+		"Maximum":                              "Maximum",
+		"Average":                              "Moyenne",
+		"Full Cycles":                          "Cycles complets",
+		"Battery Systems":                      "Systèmes de batterie",
+		"Charge":                               "Charge",
+		"Discharge":                            "Décharge",
+		"Efficiency":                           "Rendement",
+		"Cycles":                               "Cycles",
+		"Grid Meters":                          "Compteurs réseau",
+		"Consumer Groups":                      "Groupes de consommateurs",
+		"Ungrouped":                            "Sans groupe",
+		"Shared usage allocated by":            "Consommation commune répartie selon",
+		"equal":                                "parts égales",
+		"quota":                                "quote-part",
+		"consumption":                          "consommation",
+		"area":                                 "surface",
+		"Shared usage attributed per interval in proportion to consumption": "Consommation commune attribuée par intervalle au prorata de la consommation",
+		"EV Charging Sessions":           "Sessions de recharge VE",
+		"sessions":                       "sessions",
+		"solar":                          "solaire",
+		"Heat Pumps":                     "Pompes à chaleur",
+		"Winter":                         "Hiver",
+		"Spring":                         "Printemps",
+		"Summer":                         "Été",
+		"Autumn":                         "Automne",
+		"Heat Output":                    "Chaleur produite",
+		"Daily Detail":                   "Détail journalier",
+		"LT":                             "TB",
+		"HT":                             "TH",
+		"Peak":                           "Pointe",
+		"Unaccounted Energy":             "Énergie non attribuée",
+		"All intervals within tolerance": "Tous les intervalles dans la tolérance",
+		"import":                         "soutirage",
+		"export":                         "injection",
+		"net":                            "net",
+		"charge":                         "charge",
+		"discharge":                      "décharge",
+		"usage":                          "consommation",
+		"Producers":                      "Producteurs",
+		"Export":                         "Injection",
+		"Self-used":                      "Autoconsommé",
+		"Revenue":                        "Revenu",
+		"Net Import":                     "Soutirage net",
+		"Net Export":                     "Injection nette",
+		"CO₂ Emissions":                  "Émissions de CO₂",
+		"Grid only":                      "Réseau uniquement",
+		"Savings vs. Grid Only":          "Économies par rapport au réseau seul",
+		"Savings":                        "Économies",
+		"Feed-in Revenue":                "Rétribution de l'injection",
+		"Feed-in":                        "Rétribution",
+		"Battery Simulation":             "Simulation de batterie",
+		"Scenario":                       "Scénario",
+		"No battery":                     "Sans batterie",
+		"Installed":                      "Installée",
+		"Avoided":                        "Évité",
+		"Peak Shaving to":                "Écrêtement des pointes à",
+		"Power":                          "Puissance",
+		"Capacity":                       "Capacité",
+		"Shift":                          "Décaler",
+		"PV Degradation":                 "Dégradation PV",
+		"Year":                           "Année",
+		"Specific Yield":                 "Rendement spéc.",
+		"Months":                         "Mois",
+		"Index":                          "Indice",
+		"per year":                       "par an",
+		"Irradiation":                    "Irradiation",
+		"Weather":                        "Corr. météo",
+		"Forecast Comparison":            "Comparaison avec la prévision",
+		"Forecast":                       "Prévision",
+		"Actual":                         "Réel",
+		"Deviation":                      "Écart",
+		"%d of %d days more than %.0f%% below forecast": "%d sur %d jours à plus de %.0f%% sous la prévision",
+		"Anomalies":                          "Anomalies",
+		"No anomalies found":                 "Aucune anomalie trouvée",
+		"info":                               "Info",
+		"warning":                            "Avertissement",
+		"critical":                           "Critique",
+		"Consumers used more than came in":   "Les consommateurs ont utilisé plus que l'apport",
+		"Meter stuck at zero":                "Compteur bloqué à zéro",
+		"Several readings for the same time": "Plusieurs relevés pour le même instant",
+		"Battery charged from the grid while exporting": "Batterie chargée depuis le réseau pendant l'injection",
+		"intervals":                  "intervalles",
+		"readings":                   "relevés",
+		"Sensor Health":              "État des capteurs",
+		"Sensor":                     "Capteur",
+		"Role":                       "Rôle",
+		"Activity":                   "Activité",
+		"Last Data":                  "Dernières données",
+		"Complete":                   "Complet",
+		"Gaps":                       "Lacunes",
+		"Longest":                    "Plus longue",
+		"Status":                     "État",
+		"ok":                         "ok",
+		"gaps":                       "lacunes",
+		"stale":                      "périmé",
+		"dead":                       "muet",
+		"grid meter tariff counters": "registres tarifaires du compteur réseau",
+		"Phase Imbalance":            "Déséquilibre des phases",
+		"Average Spread":             "Écart moyen",
+		"Maximum Spread":             "Écart maximal",
+		"Unbalanced":                 "Déséquilibré",
+		"Power estimated from the phase currents at 230 V": "Puissance estimée à partir des courants de phase à 230 V",
+		"Live Power":               "Puissance actuelle",
+		"Resolution: %d seconds":   "Résolution : %d secondes",
+		"Grid Bill":                "Facture réseau",
+		"Energy":                   "Énergie",
+		"Consumer Bills":           "Factures des consommateurs",
+		"Solar energy":             "Électricité solaire",
+		"Battery energy":           "Électricité de la batterie",
+		"Grid energy, high tariff": "Électricité du réseau, tarif haut",
+		"Grid energy, low tariff":  "Électricité du réseau, tarif bas",
+		"VAT":                      "TVA",
+		"Net":                      "Net",
+		"mo.":                      "m.",
+		"Passed on":                "Refacturé",
+		"VSE guideline cap":        "Plafond selon l’AES",
+		"Rounding":                 "Arrondi",
+		"Invoice %s":               "Facture %s",
+		"Invoice %s, corrects %s":  "Facture %s, corrige %s",
+		"Prices from %s":           "Prix dès le %s",
+	},
+	"it": {
+		"Energy Analysis for period: %s to %s": "Analisi energetica per il periodo: %s - %s",
+		"Energy Analysis":                      "Analisi energetica",
+		"High Tariff Energy":                   "Energia tariffa alta",
+		"Low Tariff Energy":                    "Energia tariffa bassa",
+		"Total Energy":                         "Energia totale",
+		"System Overview":                      "Panoramica del sistema",
+		"Grid Import":                          "Prelievo dalla rete",
+		"Grid Export":                          "Immissione in rete",
+		"Production":                           "Produzione",
+		"Consumption":                          "Consumo",
+		"Battery Charge":                       "Carica batteria",
+		"Battery Discharge":                    "Scarica batteria",
+		"Self Consumption":                     "Autoconsumo",
+		"Autarchy":                             "Autarchia",
+		"Energy Balance":                       "Bilancio energetico",
+		"Total Input":                          "Totale entrate",
+		"Total Output":                         "Totale uscite",
+		"Difference":                           "Differenza",
+		"Consumer Details":                     "Dettagli consumatori",
+		"Name":                                 "Nome",
+		"Total":                                "Totale",
+		"Inverter":                             "Inverter",
+		"Battery":                              "Batteria",
+		"Grid":                                 "Rete",
+		"Share":                                "Quota",
+		"Solar":                                "Solare",
+		"Batt.":                                "Batt.",
+		"Shared Usage":                         "Consumo comune",
+		"Other":                                "Altri",
+		"Overview":                             "Panoramica",
+		"High Tariff":                          "Tariffa alta",
+		"Low Tariff":                           "Tariffa bassa",
+		"Consumer":                             "Consumatore",
+		"Date":                                 "Data",
+		"weekends":                             "fine settimana",
+		"holidays":                             "giorni festivi",
+		"outside low tariff":                   "fuori tariffa bassa",
+		"Mon":                                  "lun",
+		"Tue":                                  "mar",
+		"Wed":                                  "mer",
+		"Thu":                                  "gio",
+		"Fri":                                  "ven",
+		"Sat":                                  "sab",
+		"Sun":                                  "dom",
+		"Spot Price Valuation":                 "Valutazione a prezzi spot",
+		"Grid Import Cost":                     "Costo prelievo",
+		"Grid Export Revenue":                  "Ricavo immissione",
+		"Average Import Price":                 "Prezzo medio prelievo",
+		"Warning: %d intervals without spot price were not valued": "Attenzione: %d intervalli senza prezzo spot non sono stati valutati",
+		"Trend":                                "Andamento",
+		"Consumers":                            "Utenze",
+		"Period":                               "Periodo",
+		"Self":                                 "Autocons.",
+		"Comparison of %s to %s with %s to %s": "Confronto dal %s al %s con il %s al %s",
+		"Current":                              "Attuale",
+		"Baseline":                             "Riferimento",
+		"Change":                               "Variazione",
+		"Year over Year":                       "Confronto annuale",
+		"Month":                                "Mese",
+		"Data Completeness":                    "Completezza dei dati",
+		"grid":                                 "rete",
+		"production":                           "produzione",
+		"battery":                              "batteria",
+		"consumer":                             "utenza",
+		"... %d more days with gaps":           "... altri %d giorni con lacune",
+		"%d intervals missing":                 "%d intervalli mancanti",
+		"Rejected Outliers":                    "Valori anomali scartati",
+		"%d readings, %.1f kWh excluded":       "%d letture, %.1f kWh esclusi",
+		"purchase":                             "prelievo",
+		"delivery":                             "immissione",
+		"Energy Balance Validation":            "Verifica del bilancio energetico",
+		"%d of %d intervals outside tolerance": "%d di %d intervalli fuori tolleranza",
+		"... %d more intervals":                "... altri %d intervalli",
+		"Input":                                "Ingresso",
+		"Output":                               "Uscita",
+		"Solar Coverage per Consumer":          "Copertura solare per utenza",
+		"Production Share":                     "Quota di produzione",
+		"Grid Import Peaks":                    "Picchi di prelievo",
+		"Monthly Maximum":                      "Massimo mensile",
+		"Daily Load Profile (kW)":              "Profilo di carico giornaliero (kW)",
+		"Hour":                                 "Ora",
+		"Standby Load":                         "Carico di base",
+		"kWh per year":                         "kWh all'anno",
+		"  from Grid":                          "  dalla rete",
+		"Battery Energy by Origin":             "Energia della batteria per origine",
+		"Battery State of Charge":              "Stato di carica della batteria",
+		"Minimum":                              "Minimo",
+		"Maximum":                              "Massimo",
+		"Average":                              "Media",
+		"Full Cycles":                          "Cicli completi",
+		"Battery Systems":                      "Sistemi di batteria",
+		"Charge":                               "Carica",
+		"Discharge":                            "Scarica",
+		"Efficiency":                           "Rendimento",
+		"Cycles":                               "Cicli",
+		"Grid Meters":                          "Contatori di rete",
+		"Consumer Groups":                      "Gruppi di utenze",
+		"Ungrouped":                            "Senza gruppo",
+		"Shared usage allocated by":            "Consumo comune ripartito secondo",
+		"equal":                                "parti uguali",
+		"quota":                                "quota di proprietà",
+		"consumption":                          "consumo",
+		"area":                                 "superficie",
+		"Shared usage attributed per interval in proportion to consumption": "Consumo comune attribuito per intervallo in proporzione al consumo",
+		"EV Charging Sessions":           "Sessioni di ricarica VE",
+		"sessions":                       "sessioni",
+		"solar":                          "solare",
+		"Heat Pumps":                     "Pompe di calore",
+		"Winter":                         "Inverno",
+		"Spring":                         "Primavera",
+		"Summer":                         "Estate",
+		"Autumn":                         "Autunno",
+		"Heat Output":                    "Calore prodotto",
+		"Daily Detail":                   "Dettaglio giornaliero",
+		"LT":                             "TB",
+		"HT":                             "TA",
+		"Peak":                           "Picco",
+		"Unaccounted Energy":             "Energia non attribuita",
+		"All intervals within tolerance": "Tutti gli intervalli entro la tolleranza",
+		"import":                         "prelievo",
+		"export":                         "immissione",
+		"net":                            "netto",
+		"charge":                         "carica",
+		"discharge":                      "scarica",
+		"usage":                          "consumo",
+		"Producers":                      "Produttori",
+		"Export":                         "Immissione",
+		"Self-used":                      "Autoconsumato",
+		"Revenue":                        "Ricavo",
+		"Net Import":                     "Prelievo netto",
+		"Net Export":                     "Immissione netta",
+		"CO₂ Emissions":                  "Emissioni di CO₂",
+		"Grid only":                      "Solo rete",
+		"Savings vs. Grid Only":          "Risparmio rispetto alla sola rete",
+		"Savings":                        "Risparmio",
+		"Feed-in Revenue":                "Rimunerazione immissione",
+		"Feed-in":                        "Rimunerazione",
+		"Battery Simulation":             "Simulazione batteria",
+		"Scenario":                       "Scenario",
+		"No battery":                     "Senza batteria",
+		"Installed":                      "Installata",
+		"Avoided":                        "Evitato",
+		"Peak Shaving to":                "Riduzione dei picchi a",
+		"Power":                          "Potenza",
+		"Capacity":                       "Capacità",
+		"Shift":                          "Spostare",
+		"PV Degradation":                 "Degrado FV",
+		"Year":                           "Anno",
+		"Specific Yield":                 "Resa specifica",
+		"Months":                         "Mesi",
+		"Index":                          "Indice",
+		"per year":                       "all'anno",
+		"Irradiation":                    "Irraggiamento",
+		"Weather":                        "Corr. meteo",
+		"Forecast Comparison":            "Confronto con la previsione",
+		"Forecast":                       "Previsione",
+		"Actual":                         "Effettivo",
+		"Deviation":                      "Scostamento",
+		"%d of %d days more than %.0f%% below forecast": "%d giorni su %d oltre il %.0f%% sotto la previsione",
+		"Anomalies":                          "Anomalie",
+		"No anomalies found":                 "Nessuna anomalia trovata",
+		"info":                               "Info",
+		"warning":                            "Avviso",
+		"critical":                           "Critico",
+		"Consumers used more than came in":   "I consumatori hanno usato più dell'apporto",
+		"Meter stuck at zero":                "Contatore fermo a zero",
+		"Several readings for the same time": "Più letture per lo stesso istante",
+		"Battery charged from the grid while exporting": "Batteria caricata dalla rete durante l'immissione",
+		"intervals":                  "intervalli",
+		"readings":                   "letture",
+		"Sensor Health":              "Stato dei sensori",
+		"Sensor":                     "Sensore",
+		"Role":                       "Ruolo",
+		"Activity":                   "Attività",
+		"Last Data":                  "Ultimi dati",
+		"Complete":                   "Completo",
+		"Gaps":                       "Lacune",
+		"Longest":                    "Più lunga",
+		"Status":                     "Stato",
+		"ok":                         "ok",
+		"gaps":                       "lacune",
+		"stale":                      "obsoleto",
+		"dead":                       "muto",
+		"grid meter tariff counters": "registri tariffari del contatore di rete",
+		"Phase Imbalance":            "Squilibrio delle fasi",
+		"Average Spread":             "Differenza media",
+		"Maximum Spread":             "Differenza massima",
+		"Unbalanced":                 "Squilibrato",
+		"Power estimated from the phase currents at 230 V": "Potenza stimata dalle correnti di fase a 230 V",
+		"Live Power":               "Potenza attuale",
+		"Resolution: %d seconds":   "Risoluzione: %d secondi",
+		"Grid Bill":                "Bolletta di rete",
+		"Energy":                   "Energia",
+		"Consumer Bills":           "Fatture dei consumatori",
+		"Solar energy":             "Energia solare",
+		"Battery energy":           "Energia della batteria",
+		"Grid energy, high tariff": "Energia di rete, tariffa alta",
+		"Grid energy, low tariff":  "Energia di rete, tariffa bassa",
+		"VAT":                      "IVA",
+		"Net":                      "Netto",
+		"mo.":                      "m.",
+		"Passed on":                "Riaddebitato",
+		"VSE guideline cap":        "Limite secondo l’AES",
+		"Rounding":                 "Arrotondamento",
+		"Invoice %s":               "Fattura %s",
+		"Invoice %s, corrects %s":  "Fattura %s, corregge %s",
+		"Prices from %s":           "Prezzi dal %s",
+	},
+}
+
+// Languages returns all supported language codes
+func Languages() []string {
+	langs := []string{English}
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs[1:])
+	return langs
+}
+
+// SetLanguage selects the language used by T
+func SetLanguage(lang string) error {
+	lang = strings.ToLower(lang)
+	if lang == "" || lang == English {
+		current = English
+		return nil
+	}
+	if _, ok := catalogs[lang]; !ok {
+		return fmt.Errorf("unsupported language %q: must be one of %s",
+			lang, strings.Join(Languages(), ", "))
+	}
+	current = lang
+	return nil
+}
+
+// T translates an English message into the selected language. Messages
+// without a translation are returned unchanged.
+func T(msg string) string {
+	if translated, ok := catalogs[current][msg]; ok {
+		return translated
+	}
+	return msg
+}
@@ -45,8 +45,35 @@ type SensorData struct {
 	Date               time.Time `json:"date"`
 	PurchaseCounter    int       `json:"CurrentEnergyPurchaseTariff1"`
 	DeliveryCounter    int       `json:"CurrentEnergyDeliveryTariff1"`
+	PurchaseCounter2   int       `json:"CurrentEnergyPurchaseTariff2,omitempty"` // dual-tariff meters only
+	DeliveryCounter2   int       `json:"CurrentEnergyDeliveryTariff2,omitempty"`
 	BatteryDischargeWh float64   `json:"bdWh"`
 	BatteryChargeWh    float64   `json:"bcWh"`
+	PowerW             float64   `json:"pW"`            // Instantaneous power, for sensors without energy counters
+	SOC                *float64  `json:"soc,omitempty"` // Battery state of charge in percent, if reported
+	// Per-phase power and current of smart meters, if reported
+	PowerL1W   float64 `json:"pL1,omitempty"`
+	PowerL2W   float64 `json:"pL2,omitempty"`
+	PowerL3W   float64 `json:"pL3,omitempty"`
+	CurrentL1A float64 `json:"iL1,omitempty"`
+	CurrentL2A float64 `json:"iL2,omitempty"`
+	CurrentL3A float64 `json:"iL3,omitempty"`
+}
+
+// GatewayStream holds the live power values of the gateway in W
+type GatewayStream struct {
+	TimeStamp        time.Time      `json:"TimeStamp"`
+	PowerConsumption float64        `json:"currentPowerConsumption"`
+	PvGeneration     float64        `json:"currentPvGeneration"`
+	BatteryPower     float64        `json:"currentBatteryChargeDischarge"` // positive while charging
+	BatterySOC       float64        `json:"soc,omitempty"`
+	Devices          []DeviceStream `json:"devices"`
+}
+
+// DeviceStream is the live power of one device of the gateway
+type DeviceStream struct {
+	ID    string  `json:"_id"`
+	Power float64 `json:"power"`
 }
 
 type ZevData struct {
@@ -60,4 +87,22 @@ type ZevSensorData struct {
 	CreatedAt                    time.Time `json:"createdAt"`
 	CurrentEnergyPurchaseTariff1 float64   `json:"CurrentEnergyPurchaseTariff1"`
 	CurrentEnergyDeliveryTariff1 float64   `json:"CurrentEnergyDeliveryTariff1,omitempty"`
+	// Dual-tariff meters count the energy of the second tariff separately
+	CurrentEnergyPurchaseTariff2 float64 `json:"CurrentEnergyPurchaseTariff2,omitempty"`
+	CurrentEnergyDeliveryTariff2 float64 `json:"CurrentEnergyDeliveryTariff2,omitempty"`
+}
+
+// Purchase returns the purchase counter over both tariffs
+func (d ZevSensorData) Purchase() float64 {
+	return d.CurrentEnergyPurchaseTariff1 + d.CurrentEnergyPurchaseTariff2
+}
+
+// Delivery returns the delivery counter over both tariffs
+func (d ZevSensorData) Delivery() float64 {
+	return d.CurrentEnergyDeliveryTariff1 + d.CurrentEnergyDeliveryTariff2
+}
+
+// DualTariff reports whether the reading carries Tariff 2 counters
+func (d ZevSensorData) DualTariff() bool {
+	return d.CurrentEnergyPurchaseTariff2 != 0 || d.CurrentEnergyDeliveryTariff2 != 0
 }
@@ -0,0 +1,63 @@
+// internal/plugin/plugin.go
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"zevalizer/internal/config"
+)
+
+// ProtocolVersion is incremented whenever the document sent to plugins
+// changes in an incompatible way
+const ProtocolVersion = 1
+
+// Document is written as JSON to the standard input of a report plugin
+type Document struct {
+	ProtocolVersion int    `json:"protocolVersion"`
+	Kind            string `json:"kind"` // type of the result, e.g. "energy"
+	Result          any    `json:"result"`
+}
+
+// Run executes a report plugin as a subprocess. The result is passed as a
+// JSON Document on stdin; whatever the plugin writes to stdout and stderr is
+// passed through. A non-zero exit status is reported as error.
+func Run(name string, cfg config.PluginConfig, kind string, result any, stdout, stderr io.Writer) error {
+	if cfg.Command == "" {
+		return fmt.Errorf("plugin %s: no command configured", name)
+	}
+
+	payload, err := json.Marshal(Document{
+		ProtocolVersion: ProtocolVersion,
+		Kind:            kind,
+		Result:          result,
+	})
+	if err != nil {
+		return fmt.Errorf("plugin %s: encoding result: %w", name, err)
+	}
+
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("plugin %s: %w", name, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("plugin %s: starting %s: %w", name, cfg.Command, err)
+	}
+
+	_, writeErr := stdin.Write(payload)
+	stdin.Close()
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("plugin %s: %w", name, err)
+	}
+	if writeErr != nil {
+		return fmt.Errorf("plugin %s: writing result: %w", name, writeErr)
+	}
+	return nil
+}
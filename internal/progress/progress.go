@@ -0,0 +1,125 @@
+// internal/progress/progress.go
+package progress
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mu serializes the output of bars updated from several goroutines and
+// guards active, the bars drawn one per line at the bottom of the
+// terminal. Bars shown at the same time are expected to share a writer.
+var (
+	mu     sync.Mutex
+	active []*Bar
+)
+
+const barWidth = 24
+
+// ANSI sequences to move the cursor up n lines and to clear the rest of a
+// line
+const (
+	cursorUp  = "\033[%dA"
+	clearLine = "\033[K"
+)
+
+// Bar renders a progress bar on a line of its own, redrawn in place. Bars
+// running in parallel are stacked below each other; a finished bar moves
+// above the running ones and stays. A nil *Bar is valid and does nothing,
+// so callers can disable progress output by simply not creating one.
+type Bar struct {
+	w      io.Writer
+	label  string
+	total  int
+	done   int
+	status string
+	start  time.Time
+}
+
+// New creates a progress bar for total steps and draws it below the
+// running ones
+func New(w io.Writer, label string, total int) *Bar {
+	b := &Bar{w: w, label: label, total: total, start: time.Now()}
+	mu.Lock()
+	defer mu.Unlock()
+	active = append(active, b)
+	redraw(w, len(active)-1, nil)
+	return b
+}
+
+// Increment marks one more step as done. The optional status is shown
+// after the counters (e.g. the date range just fetched).
+func (b *Bar) Increment(status string) {
+	b.Add(1, status)
+}
+
+// Add marks n more steps as done, like Increment
+func (b *Bar) Add(n int, status string) {
+	if b == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	b.done += n
+	b.status = status
+	if slices.Contains(active, b) {
+		redraw(b.w, len(active), nil)
+	}
+}
+
+// Finish ends the bar. Its line stays above the running bars, the line is
+// ended once the last bar has finished.
+func (b *Bar) Finish() {
+	if b == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	i := slices.Index(active, b)
+	if i < 0 {
+		return
+	}
+	drawn := len(active)
+	active = slices.Delete(active, i, i+1)
+	redraw(b.w, drawn, b)
+}
+
+// redraw replaces the drawn lines of running bars by the finished bar, if
+// any, followed by the running ones. The cursor is left at the end of the
+// last running bar, or at the start of a new line when none is left. The
+// caller must hold mu.
+func redraw(w io.Writer, drawn int, finished *Bar) {
+	if drawn > 1 {
+		fmt.Fprintf(w, cursorUp, drawn-1)
+	}
+	if finished != nil {
+		fmt.Fprintf(w, "\r%s%s\n", finished.line(), clearLine)
+	}
+	for i, b := range active {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "\r%s%s", b.line(), clearLine)
+	}
+}
+
+func (b *Bar) line() string {
+	filled := 0
+	if b.total > 0 {
+		filled = min(b.done*barWidth/b.total, barWidth)
+	}
+	elapsed := time.Since(b.start).Round(time.Second)
+	line := fmt.Sprintf("%-20s [%s%s] %d/%d %s",
+		b.label,
+		strings.Repeat("#", filled),
+		strings.Repeat("-", barWidth-filled),
+		b.done, b.total, elapsed)
+	if b.status != "" {
+		line += " " + b.status
+	}
+	return line
+}
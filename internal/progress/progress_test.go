@@ -0,0 +1,49 @@
+package progress
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// elapsed matches the elapsed time, which may tick between drawing a bar
+// and checking it
+var elapsed = regexp.MustCompile(` [0-9hms.]+s\b`)
+
+// drawn returns what was written with the elapsed times blanked
+func drawn(out *bytes.Buffer) string {
+	return elapsed.ReplaceAllString(out.String(), " -")
+}
+
+// line returns the line of b as drawn
+func line(b *Bar) string {
+	return elapsed.ReplaceAllString(b.line(), " -")
+}
+
+func TestParallelBars(t *testing.T) {
+	var out bytes.Buffer
+	a := New(&out, "Sensor a", 2)
+	b := New(&out, "Sensor b", 3)
+	a.Add(2, "")
+	b.Add(1, "")
+	if !strings.Contains(drawn(&out), "\r"+line(a)+clearLine+"\n\r"+line(b)) {
+		t.Errorf("bars are not drawn on a line each: %q", drawn(&out))
+	}
+
+	a.Finish()
+	if strings.HasSuffix(drawn(&out), "\n") {
+		t.Error("the line is ended while sensor b is still running")
+	}
+	if !strings.HasSuffix(drawn(&out), "\x1b[1A\r"+line(a)+clearLine+"\n\r"+line(b)+clearLine) {
+		t.Errorf("finished bar a does not move above b: %q", drawn(&out))
+	}
+
+	b.Finish()
+	if !strings.HasSuffix(drawn(&out), "\r"+line(b)+clearLine+"\n") {
+		t.Errorf("the line is not ended after the last bar: %q", drawn(&out))
+	}
+	if len(active) != 0 {
+		t.Errorf("%d bars left running", len(active))
+	}
+}
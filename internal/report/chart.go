@@ -0,0 +1,139 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	chartWidth   = 1000
+	chartHeight  = 420
+	chartLeft    = 70
+	chartRight   = 20
+	chartTop     = 50
+	chartBottom  = 60
+	chartXTicks  = 6
+	chartYTicks  = 5
+	chartLegendX = chartLeft + 10
+)
+
+// Series is one line of a chart
+type Series struct {
+	Name   string
+	Color  string
+	Values []float64 // one value per entry of LineChart.Times
+}
+
+// LineChart is a time series chart rendered as SVG
+type LineChart struct {
+	Title  string
+	YLabel string
+	Times  []time.Time
+	Series []Series
+}
+
+// WriteSVG renders the chart
+func (c *LineChart) WriteSVG(w io.Writer) error {
+	if len(c.Times) < 2 {
+		return fmt.Errorf("chart %q: need at least two points", c.Title)
+	}
+
+	minY, maxY := 0.0, 0.0
+	for _, s := range c.Series {
+		for _, v := range s.Values {
+			minY = math.Min(minY, v)
+			maxY = math.Max(maxY, v)
+		}
+	}
+	step := niceStep((maxY - minY) / chartYTicks)
+	minY = math.Floor(minY/step) * step
+	maxY = math.Ceil(maxY/step) * step
+	if maxY == minY {
+		maxY = minY + step
+	}
+
+	plotW := float64(chartWidth - chartLeft - chartRight)
+	plotH := float64(chartHeight - chartTop - chartBottom)
+	start := c.Times[0]
+	span := c.Times[len(c.Times)-1].Sub(start).Seconds()
+	x := func(t time.Time) float64 {
+		return chartLeft + t.Sub(start).Seconds()/span*plotW
+	}
+	y := func(v float64) float64 {
+		return chartTop + (maxY-v)/(maxY-minY)*plotH
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n",
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<text x="%d" y="24" text-anchor="middle" font-size="15">%s</text>`+"\n", chartWidth/2, escape(c.Title))
+
+	// Y grid and labels
+	for v := minY; v <= maxY+step/2; v += step {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`+"\n",
+			chartLeft, y(v), chartWidth-chartRight, y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`+"\n",
+			chartLeft-6, y(v), formatTick(v))
+	}
+	fmt.Fprintf(&b, `<text transform="translate(16,%d) rotate(-90)" text-anchor="middle">%s</text>`+"\n",
+		chartTop+int(plotH)/2, escape(c.YLabel))
+
+	// X labels
+	layout := "02.01. 15:04"
+	if span > 3*24*3600 {
+		layout = "02.01.2006"
+	}
+	for i := 0; i <= chartXTicks; i++ {
+		t := start.Add(time.Duration(float64(i) / chartXTicks * span * float64(time.Second)))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`+"\n",
+			x(t), chartHeight-chartBottom+18, t.Format(layout))
+	}
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.0f" height="%.0f" fill="none" stroke="#999"/>`+"\n",
+		chartLeft, chartTop, plotW, plotH)
+
+	// Series
+	for i, s := range c.Series {
+		var points strings.Builder
+		for j, v := range s.Values {
+			if j >= len(c.Times) {
+				break
+			}
+			fmt.Fprintf(&points, "%.1f,%.1f ", x(c.Times[j]), y(v))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1" points="%s"/>`+"\n",
+			s.Color, strings.TrimSpace(points.String()))
+
+		legendX := chartLegendX + i*160
+		legendY := chartHeight - 18
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="14" height="4" fill="%s"/>`+"\n", legendX, legendY-2, s.Color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d" dominant-baseline="middle">%s</text>`+"\n", legendX+20, legendY, escape(s.Name))
+	}
+
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// niceStep rounds a raw tick distance to 1, 2 or 5 times a power of ten
+func niceStep(raw float64) float64 {
+	if raw <= 0 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if raw <= m*exp {
+			return m * exp
+		}
+	}
+	return 10 * exp
+}
+
+func formatTick(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
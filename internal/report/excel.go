@@ -0,0 +1,93 @@
+package report
+
+import (
+	"io"
+
+	"zevalizer/internal/analyzer"
+	"zevalizer/internal/i18n"
+)
+
+// WriteExcel writes the analysis as xlsx workbook: an overview sheet with the
+// system figures per tariff and one sheet per consumer with daily values
+func WriteExcel(w io.Writer, result *analyzer.Result, daily []*analyzer.EnergyStats) error {
+	wb := NewWorkbook()
+	total := analyzer.MergeStats(result.HighTariff, result.LowTariff)
+
+	var kwhColumns [4]string
+	for i, label := range []string{"Total", "Inverter", "Battery", "Grid"} {
+		kwhColumns[i] = i18n.T(label) + " [kWh]"
+	}
+
+	overview := wb.AddSheet(i18n.T("Overview"))
+	overview.AddRow(i18n.T("Energy Analysis"), result.From, result.To)
+	overview.AddRow()
+	overview.AddRow("", i18n.T("High Tariff"), i18n.T("Low Tariff"), i18n.T("Total"))
+	overviewRow := func(label string, value func(*analyzer.EnergyStats) float64) {
+		overview.AddRow(label, value(result.HighTariff), value(result.LowTariff), value(total))
+	}
+	overviewRow(i18n.T("Grid Import")+" [kWh]", func(s *analyzer.EnergyStats) float64 { return s.GridImport / 1000 })
+	overviewRow(i18n.T("Grid Export")+" [kWh]", func(s *analyzer.EnergyStats) float64 { return s.GridExport / 1000 })
+	overviewRow(i18n.T("Production")+" [kWh]", func(s *analyzer.EnergyStats) float64 { return s.Production / 1000 })
+	overviewRow(i18n.T("Consumption")+" [kWh]", func(s *analyzer.EnergyStats) float64 { return s.Consumption / 1000 })
+	overviewRow(i18n.T("Battery Charge")+" [kWh]", func(s *analyzer.EnergyStats) float64 { return s.BatteryCharge / 1000 })
+	overviewRow(i18n.T("Battery Discharge")+" [kWh]", func(s *analyzer.EnergyStats) float64 { return s.BatteryDischarge / 1000 })
+	overviewRow(i18n.T("Self Consumption")+" [%]", (*analyzer.EnergyStats).SelfConsumptionRate)
+	overviewRow(i18n.T("Autarchy")+" [%]", (*analyzer.EnergyStats).AutarchyRate)
+
+	overview.AddRow()
+	overview.AddRow(i18n.T("Consumer"), kwhColumns[0], kwhColumns[1], kwhColumns[2], kwhColumns[3])
+	consumers := total.Consumers
+	for i := range consumers {
+		consumer := &consumers[i]
+		overview.AddRow(displayName(consumer),
+			consumer.Total/1000,
+			consumer.Sources.FromInverter/1000,
+			consumer.Sources.FromBattery/1000,
+			consumer.Sources.FromGrid/1000)
+	}
+
+	for i := range consumers {
+		consumer := &consumers[i]
+		sheet := wb.AddSheet(displayName(consumer))
+		sheet.AddRow(i18n.T("Date"), kwhColumns[0], kwhColumns[1], kwhColumns[2], kwhColumns[3])
+		for _, day := range daily {
+			var dayTotal, inverter, battery, grid float64
+			if c := findConsumer(day, consumer); c != nil {
+				dayTotal = c.Total / 1000
+				inverter = c.Sources.FromInverter / 1000
+				battery = c.Sources.FromBattery / 1000
+				grid = c.Sources.FromGrid / 1000
+			}
+			sheet.AddRow(day.Period.Start, dayTotal, inverter, battery, grid)
+		}
+		sheet.AddRow(i18n.T("Total"),
+			consumer.Total/1000,
+			consumer.Sources.FromInverter/1000,
+			consumer.Sources.FromBattery/1000,
+			consumer.Sources.FromGrid/1000)
+	}
+
+	return wb.Write(w)
+}
+
+// findConsumer returns the stats of the same consumer within other stats
+func findConsumer(stats *analyzer.EnergyStats, consumer *analyzer.ConsumerStats) *analyzer.ConsumerStats {
+	for i := range stats.Consumers {
+		c := &stats.Consumers[i]
+		if c.Sensor == nil || consumer.Sensor == nil {
+			continue
+		}
+		if c.Sensor.ID == consumer.Sensor.ID && c.Sensor.Tag.Name == consumer.Sensor.Tag.Name {
+			return c
+		}
+	}
+	return nil
+}
+
+// displayName returns the consumer name, translating synthetic consumers
+func displayName(consumer *analyzer.ConsumerStats) string {
+	if consumer.Sensor == nil || consumer.Sensor.ID == "" {
+		return i18n.T(consumer.Name())
+	}
+	return consumer.Name()
+}
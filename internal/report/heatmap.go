@@ -0,0 +1,90 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+
+	"zevalizer/internal/analyzer"
+)
+
+// weekdays labels the rows of a heatmap, Monday first as in analyzer.Heatmap
+var weekdays = [7]string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// WriteHeatmapCSV writes one row per series and weekday with the average kW
+// of each hour of the day
+func WriteHeatmapCSV(w io.Writer, heatmap *analyzer.Heatmap) error {
+	cw := csv.NewWriter(w)
+	header := []string{"series", "weekday"}
+	for hour := 0; hour < 24; hour++ {
+		header = append(header, fmt.Sprintf("%02d", hour))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, series := range heatmapSeries(heatmap) {
+		for day, hours := range series.values {
+			row := []string{series.name, weekdays[day]}
+			for _, kw := range hours {
+				row = append(row, strconv.FormatFloat(kw, 'f', 3, 64))
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteHeatmapHTML writes a self-contained HTML page with one colored table
+// per series; the darker a cell, the higher the average power
+func WriteHeatmapHTML(w io.Writer, heatmap *analyzer.Heatmap, title string) error {
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprintf(w, "<style>body{font-family:sans-serif}table{border-collapse:collapse;margin-bottom:2em}"+
+		"td,th{padding:4px 6px;text-align:right;font-size:12px}</style>\n</head>\n<body>\n")
+	fmt.Fprintf(w, "<h1>%s</h1>\n", html.EscapeString(title))
+	for _, series := range heatmapSeries(heatmap) {
+		var peak float64
+		for _, hours := range series.values {
+			for _, kw := range hours {
+				peak = max(peak, kw)
+			}
+		}
+		fmt.Fprintf(w, "<h2>%s (kW)</h2>\n<table>\n<tr><th></th>", series.name)
+		for hour := 0; hour < 24; hour++ {
+			fmt.Fprintf(w, "<th>%02d</th>", hour)
+		}
+		fmt.Fprintf(w, "</tr>\n")
+		for day, hours := range series.values {
+			fmt.Fprintf(w, "<tr><th>%s</th>", weekdays[day])
+			for _, kw := range hours {
+				alpha := 0.0
+				if peak > 0 {
+					alpha = kw / peak
+				}
+				fmt.Fprintf(w, "<td style=\"background:rgba(%s,%.2f)\">%.2f</td>", series.rgb, alpha, kw)
+			}
+			fmt.Fprintf(w, "</tr>\n")
+		}
+		fmt.Fprintf(w, "</table>\n")
+	}
+	_, err := fmt.Fprintf(w, "</body>\n</html>\n")
+	return err
+}
+
+type heatmapTable struct {
+	name   string
+	rgb    string
+	values [7][24]float64
+}
+
+// heatmapSeries lists the tables of a heatmap with their color
+func heatmapSeries(heatmap *analyzer.Heatmap) []heatmapTable {
+	return []heatmapTable{
+		{name: "Consumption", rgb: "91,127,166", values: heatmap.Consumption},
+		{name: "Production", rgb: "242,177,52", values: heatmap.Production},
+	}
+}
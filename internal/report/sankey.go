@@ -0,0 +1,153 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"zevalizer/internal/analyzer"
+)
+
+const (
+	sankeyWidth     = 900
+	sankeyNodeWidth = 18
+	sankeyLeftX     = 180
+	sankeyRightX    = sankeyWidth - 220
+	sankeyTop       = 60
+	sankeyGap       = 14
+	sankeyMaxHeight = 560
+)
+
+// sankeyFlow is a single ribbon between a source and a sink node
+type sankeyFlow struct {
+	source, sink int
+	wh           float64
+}
+
+type sankeyNode struct {
+	label string
+	color string
+	total float64
+	y     float64
+	used  float64 // height already taken by attached ribbons
+}
+
+// WriteSankey renders the energy flows of the stats as an SVG Sankey diagram.
+// Sources are grid import, PV and battery discharge; sinks are the consumers,
+// grid export and battery charge. The consumer flows come from the source
+// attribution; export and battery charge are drawn as coming from PV.
+func WriteSankey(w io.Writer, stats *analyzer.EnergyStats, title string) error {
+	sources := []*sankeyNode{
+		{label: "Grid", color: "#8c8c8c"},
+		{label: "PV", color: "#f2b134"},
+		{label: "Battery", color: "#4caf50"},
+	}
+	const grid, pv, battery = 0, 1, 2
+
+	var sinks []*sankeyNode
+	var flows []sankeyFlow
+	addSink := func(label string, parts [3]float64) {
+		index := len(sinks)
+		added := false
+		for source, wh := range parts {
+			if wh <= 0 {
+				continue
+			}
+			flows = append(flows, sankeyFlow{source: source, sink: index, wh: wh})
+			added = true
+		}
+		if added {
+			sinks = append(sinks, &sankeyNode{label: label, color: "#5b7fa6"})
+		}
+	}
+
+	for i := range stats.Consumers {
+		consumer := &stats.Consumers[i]
+		addSink(consumer.Name(), [3]float64{
+			grid:    consumer.Sources.FromGrid,
+			pv:      consumer.Sources.FromInverter,
+			battery: consumer.Sources.FromBattery,
+		})
+	}
+	addSink("Grid Export", [3]float64{pv: stats.GridExport})
+	// the battery is charged from the grid as well, e.g. in the low tariff
+	addSink("Battery Charge", [3]float64{
+		grid: stats.BatteryChargeFromGrid,
+		pv:   stats.BatteryCharge - stats.BatteryChargeFromGrid,
+	})
+
+	for _, f := range flows {
+		sources[f.source].total += f.wh
+		sinks[f.sink].total += f.wh
+	}
+
+	// Drop sources without any outgoing energy
+	var activeSources []*sankeyNode
+	for _, node := range sources {
+		if node.total > 0 {
+			activeSources = append(activeSources, node)
+		}
+	}
+
+	sum := 0.0
+	for _, node := range sinks {
+		sum += node.total
+	}
+	if sum <= 0 {
+		return fmt.Errorf("no energy flows to draw")
+	}
+
+	gaps := float64(max(len(activeSources), len(sinks))-1) * sankeyGap
+	scale := (sankeyMaxHeight - gaps) / sum
+
+	layout := func(nodes []*sankeyNode) {
+		y := float64(sankeyTop)
+		for _, node := range nodes {
+			node.y = y
+			y += node.total*scale + sankeyGap
+		}
+	}
+	layout(activeSources)
+	layout(sinks)
+
+	height := sankeyTop + sankeyMaxHeight + 40
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		sankeyWidth, height, sankeyWidth, height)
+	fmt.Fprintf(&b, `<text x="%d" y="30" text-anchor="middle" font-size="16">%s</text>`+"\n", sankeyWidth/2, escape(title))
+
+	mid := float64(sankeyLeftX+sankeyNodeWidth+sankeyRightX) / 2
+	for _, f := range flows {
+		src := sources[f.source]
+		dst := sinks[f.sink]
+		t := f.wh * scale
+		x0 := float64(sankeyLeftX + sankeyNodeWidth)
+		y0 := src.y + src.used
+		x1 := float64(sankeyRightX)
+		y1 := dst.y + dst.used
+		src.used += t
+		dst.used += t
+		fmt.Fprintf(&b, `<path d="M%.1f,%.1f C%.1f,%.1f %.1f,%.1f %.1f,%.1f L%.1f,%.1f C%.1f,%.1f %.1f,%.1f %.1f,%.1f Z" fill="%s" fill-opacity="0.45"><title>%s → %s: %.1f kWh</title></path>`+"\n",
+			x0, y0, mid, y0, mid, y1, x1, y1,
+			x1, y1+t, mid, y1+t, mid, y0+t, x0, y0+t,
+			src.color, escape(src.label), escape(dst.label), f.wh/1000)
+	}
+
+	drawNode := func(node *sankeyNode, x int, labelX int, anchor string) {
+		h := node.total * scale
+		fmt.Fprintf(&b, `<rect x="%d" y="%.1f" width="%d" height="%.1f" fill="%s"/>`+"\n",
+			x, node.y, sankeyNodeWidth, h, node.color)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="%s" dominant-baseline="middle">%s (%.1f kWh)</text>`+"\n",
+			labelX, node.y+h/2, anchor, escape(node.label), node.total/1000)
+	}
+	for _, node := range activeSources {
+		drawNode(node, sankeyLeftX, sankeyLeftX-8, "end")
+	}
+	for _, node := range sinks {
+		drawNode(node, sankeyRightX, sankeyRightX+sankeyNodeWidth+8, "start")
+	}
+
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
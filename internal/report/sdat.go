@@ -0,0 +1,111 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// SDAT product code of active energy (ebIX)
+const sdatActiveEnergy = "8716867000030"
+
+// SDATProfile is the load profile of one metering point: the energy of
+// consecutive 15-minute intervals from Start, in kWh
+type SDATProfile struct {
+	MeteringPoint string
+	Start         time.Time
+	Values        []float64
+}
+
+// SDATHeader identifies the parties and the document of an SDAT file
+type SDATHeader struct {
+	Sender     string // EIC or GLN of the sender
+	Receiver   string // EIC or GLN of the receiver
+	DocumentID string
+	Created    time.Time
+}
+
+type sdatParty struct {
+	ID string `xml:"rsm:ID>rsm:EICID"`
+}
+
+type sdatObservation struct {
+	Sequence int    `xml:"rsm:Position>rsm:Sequence"`
+	Volume   string `xml:"rsm:Volume"`
+}
+
+type sdatDocument struct {
+	XMLName xml.Name `xml:"rsm:ValidatedMeteredData_12"`
+	Rsm     string   `xml:"xmlns:rsm,attr"`
+	Header  struct {
+		Version  string    `xml:"rsm:HeaderVersion"`
+		Sender   sdatParty `xml:"rsm:Sender"`
+		Receiver sdatParty `xml:"rsm:Receiver"`
+		Instance struct {
+			Agency     string `xml:"rsm:DictionaryAgencyID"`
+			Version    string `xml:"rsm:VersionID"`
+			DocumentID string `xml:"rsm:DocumentID"`
+			Type       string `xml:"rsm:DocumentType>rsm:ECCode"`
+			Creation   string `xml:"rsm:Creation"`
+			Status     string `xml:"rsm:Status>rsm:ECCode"`
+		} `xml:"rsm:InstanceDocument"`
+	} `xml:"rsm:ValidatedMeteredData_HeaderInformation"`
+	Data struct {
+		DocumentID   string            `xml:"rsm:DocumentID"`
+		Start        string            `xml:"rsm:Interval>rsm:StartDateTime"`
+		End          string            `xml:"rsm:Interval>rsm:EndDateTime"`
+		Resolution   int               `xml:"rsm:Resolution>rsm:Resolution"`
+		Unit         string            `xml:"rsm:Resolution>rsm:Unit"`
+		Point        string            `xml:"rsm:MeteringPoint>rsm:VSENationalID"`
+		Product      string            `xml:"rsm:Product>rsm:ID"`
+		MeasureUnit  string            `xml:"rsm:Product>rsm:MeasureUnit"`
+		Observations []sdatObservation `xml:"rsm:Observation"`
+	} `xml:"rsm:MeteringData"`
+}
+
+// WriteSDAT writes the load profile as validated metered data (document
+// type E66) in the layout of the Swiss SDAT-CH exchange, with the times in
+// UTC and the energy in kWh
+func WriteSDAT(w io.Writer, header SDATHeader, profile SDATProfile) error {
+	const resolution = 15 * time.Minute
+	utc := func(t time.Time) string { return t.UTC().Format("2006-01-02T15:04:05Z") }
+
+	var doc sdatDocument
+	doc.Rsm = "http://www.strom.ch"
+	doc.Header.Version = "1.0"
+	doc.Header.Sender.ID = header.Sender
+	doc.Header.Receiver.ID = header.Receiver
+	doc.Header.Instance.Agency = "260"
+	doc.Header.Instance.Version = "1"
+	doc.Header.Instance.DocumentID = header.DocumentID
+	doc.Header.Instance.Type = "E66"
+	doc.Header.Instance.Creation = utc(header.Created)
+	doc.Header.Instance.Status = "23" // original
+	doc.Data.DocumentID = header.DocumentID
+	doc.Data.Start = utc(profile.Start)
+	doc.Data.End = utc(profile.Start.Add(time.Duration(len(profile.Values)) * resolution))
+	doc.Data.Resolution = int(resolution.Minutes())
+	doc.Data.Unit = "MIN"
+	doc.Data.Point = profile.MeteringPoint
+	doc.Data.Product = sdatActiveEnergy
+	doc.Data.MeasureUnit = "KWH"
+	for i, value := range profile.Values {
+		doc.Data.Observations = append(doc.Data.Observations, sdatObservation{
+			Sequence: i + 1,
+			Volume:   strconv.FormatFloat(value, 'f', 3, 64),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("encoding sdat: %v", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
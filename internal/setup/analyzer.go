@@ -2,6 +2,7 @@
 package setup
 
 import (
+	"context"
 	"fmt"
 	"zevalizer/internal/api"
 	"zevalizer/internal/config"
@@ -15,22 +16,25 @@ func NewAnalyzer(client *api.Client) *Analyzer {
 	return &Analyzer{client: client}
 }
 
-func (sa *Analyzer) AnalyzeSetup(smId string) (*config.ZEVConfig, error) {
+func (sa *Analyzer) AnalyzeSetup(ctx context.Context, smId string) (*config.ZEVConfig, error) {
 	// Get all sensors
-	sensors, err := sa.client.GetSensors(smId)
+	sensors, err := sa.client.GetSensors(ctx, smId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get sensors: %v", err)
+		return nil, fmt.Errorf("failed to get sensors: %w", err)
 	}
 
 	zevConfig := &config.ZEVConfig{}
 
-	// Find main grid meter
+	// Find grid meters; further grid connection points go to gridMeterIds
 	for _, sensor := range sensors {
 		if sensor.Type == "Smart Meter" &&
 			sensor.DeviceType == "sub-meter" &&
 			sensor.Data.SubMeterCostTypes == 1 {
-			zevConfig.GridMeterID = sensor.ID + "  # " + sensor.Tag.Name
-			break
+			if zevConfig.GridMeterID == "" {
+				zevConfig.GridMeterID = sensor.ID + "  # " + sensor.Tag.Name
+				continue
+			}
+			zevConfig.GridMeterIDs = append(zevConfig.GridMeterIDs, sensor.ID+"  # "+sensor.Tag.Name)
 		}
 	}
 
@@ -0,0 +1,45 @@
+// internal/tariff/holidays.go
+package tariff
+
+import "time"
+
+// SwissHolidays returns the public holidays observed in most Swiss cantons.
+// Cantonal holidays (e.g. Assumption, Corpus Christi) can be added with
+// lowTariff.extraHolidays.
+func SwissHolidays(year int) []time.Time {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+	}
+	easter := easterSunday(year)
+	return []time.Time{
+		date(time.January, 1),    // Neujahr
+		date(time.January, 2),    // Berchtoldstag
+		easter.AddDate(0, 0, -2), // Karfreitag
+		easter.AddDate(0, 0, 1),  // Ostermontag
+		easter.AddDate(0, 0, 39), // Auffahrt
+		easter.AddDate(0, 0, 50), // Pfingstmontag
+		date(time.August, 1),     // Bundesfeier
+		date(time.December, 25),  // Weihnachten
+		date(time.December, 26),  // Stephanstag
+	}
+}
+
+// easterSunday computes the date of Easter in the Gregorian calendar
+// (anonymous Gregorian algorithm)
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local)
+}
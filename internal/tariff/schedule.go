@@ -0,0 +1,192 @@
+// internal/tariff/schedule.go
+package tariff
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"zevalizer/internal/config"
+	"zevalizer/internal/i18n"
+)
+
+// window is a low tariff time window on a set of weekdays. Overnight
+// windows (start > end) belong to the day they start on.
+type window struct {
+	days  [7]bool // indexed by time.Weekday
+	start int
+	end   int
+}
+
+// Schedule decides which intervals are billed at the low tariff
+type Schedule struct {
+	windows  []window
+	weekends bool
+	holidays bool
+	extra    map[string]bool // additional holidays, YYYY-MM-DD
+	byYear   map[int]map[string]bool
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// New builds a schedule from the low tariff configuration. Without explicit
+// windows, startHour/endHour apply to every day.
+func New(cfg config.LowTariffConfig) (*Schedule, error) {
+	s := &Schedule{
+		weekends: cfg.Weekends,
+		holidays: cfg.Holidays,
+		extra:    make(map[string]bool),
+		byYear:   make(map[int]map[string]bool),
+	}
+
+	windows := cfg.Windows
+	if len(windows) == 0 {
+		windows = []config.TariffWindow{{StartHour: cfg.StartHour, EndHour: cfg.EndHour}}
+	}
+	for _, wc := range windows {
+		w, err := newWindow(wc)
+		if err != nil {
+			return nil, err
+		}
+		s.windows = append(s.windows, w)
+	}
+
+	for _, day := range cfg.ExtraHolidays {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			return nil, fmt.Errorf("%w: lowTariff extraHolidays entry %q must be YYYY-MM-DD", config.ErrInvalid, day)
+		}
+		s.extra[day] = true
+	}
+	return s, nil
+}
+
+func newWindow(wc config.TariffWindow) (window, error) {
+	w := window{start: wc.StartHour, end: wc.EndHour}
+	for _, hour := range []int{wc.StartHour, wc.EndHour} {
+		if hour < 0 || hour > 23 {
+			return w, fmt.Errorf("%w: lowTariff hour %d must be between 0 and 23", config.ErrInvalid, hour)
+		}
+	}
+	if len(wc.Days) == 0 {
+		for i := range w.days {
+			w.days[i] = true
+		}
+		return w, nil
+	}
+	for _, name := range wc.Days {
+		key := strings.ToLower(name)
+		if len(key) > 3 {
+			key = key[:3]
+		}
+		day, ok := weekdayNames[key]
+		if !ok {
+			return w, fmt.Errorf("%w: lowTariff day %q must be one of mon, tue, wed, thu, fri, sat, sun", config.ErrInvalid, name)
+		}
+		w.days[day] = true
+	}
+	return w, nil
+}
+
+// IsLow reports whether the interval starting at t is billed at the low tariff
+func (s *Schedule) IsLow(t time.Time) bool {
+	if s.weekends && (t.Weekday() == time.Saturday || t.Weekday() == time.Sunday) {
+		return true
+	}
+	if s.isHoliday(t) {
+		return true
+	}
+
+	hour := t.Hour()
+	for _, w := range s.windows {
+		if w.start > w.end {
+			// Overnight, e.g. 21:00 - 06:00: the evening part belongs to
+			// this day, the morning part to the previous one
+			if hour >= w.start && w.days[t.Weekday()] {
+				return true
+			}
+			if hour < w.end && w.days[t.AddDate(0, 0, -1).Weekday()] {
+				return true
+			}
+		} else if hour >= w.start && hour < w.end && w.days[t.Weekday()] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schedule) isHoliday(t time.Time) bool {
+	day := t.Format("2006-01-02")
+	if s.extra[day] {
+		return true
+	}
+	if !s.holidays {
+		return false
+	}
+	set, ok := s.byYear[t.Year()]
+	if !ok {
+		set = make(map[string]bool)
+		for _, h := range SwissHolidays(t.Year()) {
+			set[h.Format("2006-01-02")] = true
+		}
+		s.byYear[t.Year()] = set
+	}
+	return set[day]
+}
+
+// String describes the low tariff periods for report headings, e.g.
+// "Mon-Fri 21:00 - 6:00, weekends, holidays"
+func (s *Schedule) String() string {
+	var parts []string
+	for _, w := range s.windows {
+		desc := fmt.Sprintf("%d:00 - %d:00", w.start, w.end)
+		if days := w.dayList(); days != "" {
+			desc = days + " " + desc
+		}
+		parts = append(parts, desc)
+	}
+	if s.weekends {
+		parts = append(parts, i18n.T("weekends"))
+	}
+	if s.holidays || len(s.extra) > 0 {
+		parts = append(parts, i18n.T("holidays"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// HighTariffString describes the high tariff periods. Only a single daily
+// window has a simple complement; anything else is described relative to it.
+func (s *Schedule) HighTariffString() string {
+	if len(s.windows) == 1 && s.windows[0].dayList() == "" && !s.weekends && !s.holidays && len(s.extra) == 0 {
+		return fmt.Sprintf("%d:00 - %d:00", s.windows[0].end, s.windows[0].start)
+	}
+	return i18n.T("outside low tariff")
+}
+
+// dayList returns the window's weekdays as ranges, or "" for every day
+func (w window) dayList() string {
+	names := []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+	var ranges []string
+	for i := 0; i < 7; {
+		if !w.days[(i+1)%7] {
+			i++
+			continue
+		}
+		j := i
+		for j+1 < 7 && w.days[(j+2)%7] {
+			j++
+		}
+		if i == 0 && j == 6 {
+			return ""
+		}
+		if i == j {
+			ranges = append(ranges, i18n.T(names[i]))
+		} else {
+			ranges = append(ranges, i18n.T(names[i])+"-"+i18n.T(names[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, ",")
+}
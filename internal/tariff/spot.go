@@ -0,0 +1,165 @@
+// internal/tariff/spot.go
+package tariff
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+// SpotPrices holds dynamic energy prices per kWh, keyed by the Unix time
+// their validity starts. Both hourly and 15-minute resolutions are supported.
+type SpotPrices struct {
+	prices map[int64]float64
+}
+
+// timestamp layouts accepted in the time column, tried in order; only
+// RFC 3339 carries an offset, the others are local time
+var spotTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"02.01.2006 15:04:05",
+	"02.01.2006 15:04",
+}
+
+// LoadSpotPrices reads the price CSV configured in cfg, e.g. an ENTSO-E
+// day-ahead export ("01.01.2024 00:00 - 01.01.2024 01:00","62.5") or an
+// EPEX file with ISO timestamps. Rows without a parseable timestamp, such
+// as headers, are skipped.
+func LoadSpotPrices(cfg config.SpotPriceConfig) (*SpotPrices, error) {
+	f, err := os.Open(cfg.File)
+	if err != nil {
+		return nil, fmt.Errorf("opening spot prices: %w", err)
+	}
+	defer f.Close()
+	return ReadSpotPrices(f, cfg)
+}
+
+// ReadSpotPrices parses spot prices in CSV form, see LoadSpotPrices
+func ReadSpotPrices(r io.Reader, cfg config.SpotPriceConfig) (*SpotPrices, error) {
+	divisor := 1.0
+	switch strings.ToLower(cfg.Unit) {
+	case "", "mwh":
+		divisor = 1000
+	case "kwh":
+	default:
+		return nil, fmt.Errorf("%w: spotPrices unit %q must be MWh or kWh", config.ErrInvalid, cfg.Unit)
+	}
+	priceColumn := cfg.PriceColumn
+	if priceColumn == 0 {
+		priceColumn = 1
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if cfg.Delimiter != "" {
+		reader.Comma = []rune(cfg.Delimiter)[0]
+	}
+
+	sp := &SpotPrices{prices: make(map[int64]float64)}
+	var prev time.Time
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading spot prices: %w", err)
+		}
+		if len(record) <= max(cfg.TimeColumn, priceColumn) {
+			continue
+		}
+		start, zoned, ok := parseSpotTime(record[cfg.TimeColumn])
+		if !ok {
+			continue
+		}
+		if !zoned {
+			start = placeRepeated(start, prev)
+		}
+		prev = start
+		// Decimal commas are common in European exports
+		field := strings.ReplaceAll(strings.TrimSpace(record[priceColumn]), ",", ".")
+		price, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			// ENTSO-E marks missing values with "-" or "n/e"
+			continue
+		}
+		sp.prices[start.Unix()] = price / divisor
+	}
+
+	if len(sp.prices) == 0 {
+		return nil, fmt.Errorf("no spot prices found in column %d", priceColumn)
+	}
+	return sp, nil
+}
+
+// parseSpotTime parses the start of a price period. ENTSO-E ranges like
+// "01.01.2024 00:00 - 01.01.2024 01:00" are reduced to their start. zoned
+// reports whether the time is unambiguous: it carries an offset, or a
+// "(CET)" or "(CEST)" suffix tells which of a repeated hour it is.
+func parseSpotTime(s string) (t time.Time, zoned bool, ok bool) {
+	s = strings.TrimSpace(s)
+	if start, _, found := strings.Cut(s, " - "); found {
+		s = strings.TrimSpace(start)
+	}
+	loc := time.Local
+	// ENTSO-E's time zone suffix, e.g. "(CET/CEST)", names the offset only
+	// when it is one of them
+	if i := strings.Index(s, " ("); i > 0 {
+		switch s[i+1:] {
+		case "(CET)":
+			loc = time.FixedZone("CET", 3600)
+		case "(CEST)":
+			loc = time.FixedZone("CEST", 7200)
+		}
+		s = s[:i]
+	}
+	for _, layout := range spotTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, loc != time.Local || layout == time.RFC3339, true
+		}
+	}
+	return time.Time{}, false, false
+}
+
+// placeRepeated places a local time that occurs twice, in the hour
+// repeated when daylight saving time ends, after the previous row: the
+// rows run through the hour in summer time first and step back into it in
+// standard time.
+func placeRepeated(t, prev time.Time) time.Time {
+	first, second := t, t.Add(time.Hour)
+	if earlier := t.Add(-time.Hour); sameWallClock(earlier, t) {
+		first, second = earlier, t
+	} else if !sameWallClock(t, second) {
+		return t
+	}
+	if !prev.IsZero() && !first.After(prev) && second.After(prev) {
+		return second
+	}
+	return first
+}
+
+// sameWallClock reports whether a and b show the same local time
+func sameWallClock(a, b time.Time) bool {
+	const layout = "2006-01-02 15:04:05"
+	return a.In(time.Local).Format(layout) == b.In(time.Local).Format(layout)
+}
+
+// Price returns the price per kWh valid at t. A 15-minute price wins over
+// the price of the surrounding hour.
+func (sp *SpotPrices) Price(t time.Time) (float64, bool) {
+	if price, ok := sp.prices[t.Unix()]; ok {
+		return price, true
+	}
+	price, ok := sp.prices[t.Unix()-t.Unix()%3600]
+	return price, ok
+}
@@ -0,0 +1,59 @@
+package tariff
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+func TestReadSpotPricesDST(t *testing.T) {
+	zurich, err := time.LoadLocation("Europe/Zurich")
+	if err != nil {
+		t.Skip(err)
+	}
+	local := time.Local
+	time.Local = zurich
+	defer func() { time.Local = local }()
+
+	// the last Sunday in October 2024 runs through 02:00 twice, first in
+	// summer and then in standard time
+	csv := `"MTU (CET/CEST)","Day-ahead Price [EUR/MWh]"
+"27.10.2024 01:00 - 27.10.2024 02:00","10"
+"27.10.2024 02:00 - 27.10.2024 03:00","20"
+"27.10.2024 02:00 - 27.10.2024 03:00","30"
+"27.10.2024 03:00 - 27.10.2024 04:00","40"
+`
+	sp, err := ReadSpotPrices(strings.NewReader(csv), config.SpotPriceConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		utc  string
+		want float64
+	}{
+		{"2024-10-26T23:00:00Z", 0.010},
+		{"2024-10-27T00:00:00Z", 0.020}, // 02:00 CEST
+		{"2024-10-27T01:15:00Z", 0.030}, // 02:15 CET
+		{"2024-10-27T02:00:00Z", 0.040},
+	} {
+		at, _ := time.Parse(time.RFC3339, tt.utc)
+		if price, ok := sp.Price(at); !ok || price != tt.want {
+			t.Errorf("price at %s is %v (%v), want %v", tt.utc, price, ok, tt.want)
+		}
+	}
+
+	// a suffix names the offset whatever the order of the rows
+	csv = "27.10.2024 02:00 (CET),30\n27.10.2024 02:00 (CEST),20\n"
+	if sp, err = ReadSpotPrices(strings.NewReader(csv), config.SpotPriceConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	cest := time.Date(2024, 10, 27, 0, 0, 0, 0, time.UTC)
+	if price, _ := sp.Price(cest); price != 0.020 {
+		t.Errorf("price at 02:00 CEST is %v, want 0.02", price)
+	}
+	if price, _ := sp.Price(cest.Add(time.Hour)); price != 0.030 {
+		t.Errorf("price at 02:00 CET is %v, want 0.03", price)
+	}
+}
@@ -0,0 +1,82 @@
+// internal/version/version.go
+package version
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X zevalizer/internal/version.Tag=v1.2.0" ./cmd/zevalizer
+//
+// Empty values fall back to the build info embedded by the Go toolchain.
+var (
+	Tag    string
+	Commit string
+	Date   string
+)
+
+// Info describes the running binary
+type Info struct {
+	Tag       string `json:"tag"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the version information of the running binary
+func Get() Info {
+	info := Info{Tag: Tag, Commit: Commit, Date: Date}
+
+	build, ok := debug.ReadBuildInfo()
+	if ok {
+		info.GoVersion = build.GoVersion
+		// Pseudo versions just repeat the commit and date
+		if info.Tag == "" && build.Main.Version != "(devel)" && !strings.HasPrefix(build.Main.Version, "v0.0.0-") {
+			info.Tag = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if info.Tag == "" {
+		info.Tag = "devel"
+	}
+	return info
+}
+
+// String returns a one line summary like "v1.2.0 (abc1234, 2024-08-01T10:00:00Z)"
+func (i Info) String() string {
+	var details []string
+	if i.Commit != "" {
+		commit := i.Commit
+		if len(commit) > 12 {
+			commit = commit[:12]
+		}
+		if i.Modified {
+			commit += "-dirty"
+		}
+		details = append(details, commit)
+	}
+	if i.Date != "" {
+		details = append(details, i.Date)
+	}
+	if len(details) == 0 {
+		return i.Tag
+	}
+	return i.Tag + " (" + strings.Join(details, ", ") + ")"
+}
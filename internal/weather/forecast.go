@@ -0,0 +1,122 @@
+// internal/weather/forecast.go
+package weather
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+// Forecast holds the expected PV production per local date
+// (YYYY-MM-DD) in kWh
+type Forecast struct {
+	days map[string]float64
+}
+
+// forecastDateLayouts are the accepted formats of the date column
+var forecastDateLayouts = []string{"2006-01-02", "02.01.2006"}
+
+// NewForecast returns the forecast of the configured provider covering
+// [from, to]
+func NewForecast(cfg *config.Config, from, to time.Time) (*Forecast, error) {
+	switch cfg.Forecast.Provider {
+	case config.ForecastFile:
+		return LoadForecast(cfg.Forecast)
+	case config.ForecastIrradiation:
+		irradiation, err := Fetch(cfg.Weather, from, to)
+		if err != nil {
+			return nil, err
+		}
+		var peakPower float64
+		for _, power := range cfg.ZEV.PeakPowerKW {
+			peakPower += power
+		}
+		return ForecastFromIrradiation(irradiation, peakPower, cfg.Forecast.Ratio()), nil
+	}
+	return nil, fmt.Errorf("%w: no forecast provider configured", config.ErrInvalid)
+}
+
+// LoadForecast reads the forecast CSV of the file provider: the date in the
+// first column, the expected kWh in the second. Rows that do not parse,
+// such as headers, are skipped.
+func LoadForecast(cfg config.ForecastConfig) (*Forecast, error) {
+	f, err := os.Open(cfg.File)
+	if err != nil {
+		return nil, fmt.Errorf("opening forecast: %w", err)
+	}
+	defer f.Close()
+	return ReadForecast(f, cfg.Delimiter)
+}
+
+// ReadForecast parses a forecast in CSV form, see LoadForecast
+func ReadForecast(r io.Reader, delimiter string) (*Forecast, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	if delimiter != "" {
+		reader.Comma = []rune(delimiter)[0]
+	}
+	forecast := &Forecast{days: make(map[string]float64)}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading forecast: %w", err)
+		}
+		if len(record) < 2 {
+			continue
+		}
+		day, ok := parseForecastDate(strings.TrimSpace(record[0]))
+		if !ok {
+			continue
+		}
+		kWh, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			continue
+		}
+		forecast.days[day.Format("2006-01-02")] += kWh
+	}
+	if len(forecast.days) == 0 {
+		return nil, fmt.Errorf("%w: forecast contains no days", config.ErrInvalid)
+	}
+	return forecast, nil
+}
+
+// parseForecastDate parses the date column, also accepting a timestamp
+// whose date part is used
+func parseForecastDate(s string) (time.Time, bool) {
+	if len(s) > 10 {
+		s = s[:10]
+	}
+	for _, layout := range forecastDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ForecastFromIrradiation estimates the production of every day with
+// irradiation as irradiation (kWh/m²) times peak power (kW at 1 kW/m²)
+// times performance ratio. Horizontal irradiation ignores the orientation
+// of the modules; the performance ratio absorbs it.
+func ForecastFromIrradiation(irradiation *Irradiation, peakPowerKW, performanceRatio float64) *Forecast {
+	forecast := &Forecast{days: make(map[string]float64, len(irradiation.days))}
+	for day, value := range irradiation.days {
+		forecast.days[day] = value * peakPowerKW * performanceRatio
+	}
+	return forecast
+}
+
+// Day returns the forecast of the local day starting at t
+func (f *Forecast) Day(t time.Time) (float64, bool) {
+	kWh, ok := f.days[t.Format("2006-01-02")]
+	return kWh, ok
+}
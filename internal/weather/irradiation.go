@@ -0,0 +1,94 @@
+// internal/weather/irradiation.go
+package weather
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"zevalizer/internal/config"
+)
+
+// Irradiation holds the daily global horizontal irradiation in kWh/m²,
+// keyed by local date (YYYY-MM-DD)
+type Irradiation struct {
+	days map[string]float64
+}
+
+// archiveResponse is the part of an Open-Meteo archive response we use
+type archiveResponse struct {
+	Daily struct {
+		Time      []string   `json:"time"`
+		Radiation []*float64 `json:"shortwave_radiation_sum"` // MJ/m², null for missing days
+	} `json:"daily"`
+	Reason string `json:"reason"` // set on errors
+}
+
+// Fetch requests the daily irradiation of [from, to] at the configured
+// location from the Open-Meteo archive API (or a compatible one)
+func Fetch(cfg config.WeatherConfig, from, to time.Time) (*Irradiation, error) {
+	timezone := from.Location().String()
+	if timezone == "Local" || timezone == "" {
+		timezone = "auto"
+	}
+	query := url.Values{
+		"latitude":   {strconv.FormatFloat(cfg.Latitude, 'f', -1, 64)},
+		"longitude":  {strconv.FormatFloat(cfg.Longitude, 'f', -1, 64)},
+		"start_date": {from.Format("2006-01-02")},
+		"end_date":   {to.Add(-time.Nanosecond).Format("2006-01-02")},
+		"daily":      {"shortwave_radiation_sum"},
+		"timezone":   {timezone},
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(cfg.ArchiveURL() + "?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("fetching irradiation: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("fetching irradiation: status %d: %s", resp.StatusCode, body)
+	}
+	return ReadIrradiation(resp.Body)
+}
+
+// ReadIrradiation parses an Open-Meteo archive response with the daily
+// shortwave_radiation_sum, see Fetch
+func ReadIrradiation(r io.Reader) (*Irradiation, error) {
+	var response archiveResponse
+	if err := json.NewDecoder(r).Decode(&response); err != nil {
+		return nil, fmt.Errorf("parsing irradiation: %w", err)
+	}
+	if response.Reason != "" {
+		return nil, fmt.Errorf("irradiation: %s", response.Reason)
+	}
+	if len(response.Daily.Time) != len(response.Daily.Radiation) {
+		return nil, fmt.Errorf("parsing irradiation: %d days but %d values",
+			len(response.Daily.Time), len(response.Daily.Radiation))
+	}
+	irradiation := &Irradiation{days: make(map[string]float64)}
+	for i, day := range response.Daily.Time {
+		if value := response.Daily.Radiation[i]; value != nil {
+			irradiation.days[day] = *value / 3.6 // MJ/m² -> kWh/m²
+		}
+	}
+	return irradiation, nil
+}
+
+// Sum returns the irradiation of the local days in [from, to) in kWh/m²,
+// false when a day is missing
+func (ir *Irradiation) Sum(from, to time.Time) (float64, bool) {
+	var sum float64
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		value, ok := ir.days[day.Format("2006-01-02")]
+		if !ok {
+			return 0, false
+		}
+		sum += value
+	}
+	return sum, true
+}